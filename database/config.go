@@ -1,6 +1,8 @@
 package database
 
 import (
+	"fmt"
+
 	"github.com/asdine/genji/document"
 	"github.com/asdine/genji/document/encoding"
 	"github.com/asdine/genji/engine"
@@ -11,6 +13,8 @@ import (
 type TableConfig struct {
 	FieldConstraints []FieldConstraint
 
+	// LastKey is the last key generated for the table by the versions of Genji
+	// which didn't use sequences. The sequence of such a table starts after it.
 	LastKey int64
 }
 
@@ -34,8 +38,33 @@ type FieldConstraint struct {
 	IsNotNull    bool
 }
 
+// tableConfigStore manages the configuration of the tables.
+// The configurations read or written by the transaction are cached, so that they are
+// decoded at most once.
 type tableConfigStore struct {
-	st engine.Store
+	st    engine.Store
+	cache map[string]TableConfig
+}
+
+// cached returns a copy of the cached configuration of the table, if any.
+func (t *tableConfigStore) cached(tableName string) (*TableConfig, bool) {
+	cfg, ok := t.cache[tableName]
+	if !ok {
+		return nil, false
+	}
+
+	cfg.FieldConstraints = append([]FieldConstraint(nil), cfg.FieldConstraints...)
+	return &cfg, true
+}
+
+// setCache stores a copy of the configuration of the table in the cache.
+func (t *tableConfigStore) setCache(tableName string, cfg TableConfig) {
+	if t.cache == nil {
+		t.cache = make(map[string]TableConfig)
+	}
+
+	cfg.FieldConstraints = append([]FieldConstraint(nil), cfg.FieldConstraints...)
+	t.cache[tableName] = cfg
 }
 
 func (t *tableConfigStore) Insert(tableName string, cfg TableConfig) error {
@@ -58,7 +87,13 @@ func (t *tableConfigStore) Insert(tableName string, cfg TableConfig) error {
 		return err
 	}
 
-	return t.st.Put(key, v)
+	err = t.st.Put(key, v)
+	if err != nil {
+		return err
+	}
+
+	t.setCache(tableName, cfg)
+	return nil
 }
 
 func (t *tableConfigStore) Replace(tableName string, cfg *TableConfig) error {
@@ -80,10 +115,21 @@ func (t *tableConfigStore) Replace(tableName string, cfg *TableConfig) error {
 	if err != nil {
 		return err
 	}
-	return t.st.Put(key, v)
+
+	err = t.st.Put(key, v)
+	if err != nil {
+		return err
+	}
+
+	t.setCache(tableName, *cfg)
+	return nil
 }
 
 func (t *tableConfigStore) Get(tableName string) (*TableConfig, error) {
+	if cfg, ok := t.cached(tableName); ok {
+		return cfg, nil
+	}
+
 	key := []byte(tableName)
 	v, err := t.st.Get(key)
 	if err == engine.ErrKeyNotFound {
@@ -100,10 +146,13 @@ func (t *tableConfigStore) Get(tableName string) (*TableConfig, error) {
 		return nil, err
 	}
 
+	t.setCache(tableName, cfg)
 	return &cfg, nil
 }
 
 func (t *tableConfigStore) Delete(tableName string) error {
+	delete(t.cache, tableName)
+
 	key := []byte(tableName)
 	err := t.st.Delete(key)
 	if err == engine.ErrKeyNotFound {
@@ -121,6 +170,103 @@ type Index struct {
 	TableName string
 	Path      document.ValuePath
 	Unique    bool
+	// Fields indexed by a composite index, see IndexConfig.
+	Fields []IndexField
+
+	// Statistics about the content of the index, see IndexConfig.
+	Count       int64
+	Cardinality int64
+}
+
+func newIndex(tx engine.Transaction, opts IndexConfig) Index {
+	var idx index.Index
+
+	switch {
+	case opts.IsComposite():
+		desc := make([]bool, len(opts.Fields))
+		for i, f := range opts.Fields {
+			desc[i] = f.Desc
+		}
+		idx = index.NewCompositeIndex(tx, opts.IndexName, opts.Unique, desc)
+	case opts.Unique:
+		idx = index.NewUniqueIndex(tx, opts.IndexName)
+	default:
+		idx = index.NewListIndex(tx, opts.IndexName)
+	}
+
+	return Index{
+		Index:       idx,
+		IndexName:   opts.IndexName,
+		TableName:   opts.TableName,
+		Path:        opts.Path,
+		Unique:      opts.Unique,
+		Fields:      opts.Fields,
+		Count:       opts.Count,
+		Cardinality: opts.Cardinality,
+	}
+}
+
+func (i Index) config() IndexConfig {
+	return IndexConfig{
+		Unique:      i.Unique,
+		IndexName:   i.IndexName,
+		TableName:   i.TableName,
+		Path:        i.Path,
+		Fields:      i.Fields,
+		Count:       i.Count,
+		Cardinality: i.Cardinality,
+	}
+}
+
+// IsComposite returns true if the index is a composite index.
+func (i Index) IsComposite() bool {
+	return len(i.Fields) > 0
+}
+
+// value returns the value of the document stored in the index.
+// Missing fields are indexed as null values.
+// Composite indexes store an array containing the value of every indexed field.
+func (i Index) value(d document.Document) document.Value {
+	if !i.IsComposite() {
+		v, err := i.Path.GetValue(d)
+		if err != nil {
+			return document.NewNullValue()
+		}
+		return v
+	}
+
+	values := make(document.ValueBuffer, len(i.Fields))
+	for j, f := range i.Fields {
+		v, err := f.Path.GetValue(d)
+		if err != nil {
+			v = document.NewNullValue()
+		}
+
+		values[j] = v
+	}
+
+	return document.NewArrayValue(values)
+}
+
+// getKey returns the key of the document associated with the value in a unique index,
+// or nil if there is none.
+func (i Index) getKey(v document.Value) ([]byte, error) {
+	idx, ok := i.Index.(interface {
+		Get(val document.Value) ([]byte, error)
+	})
+	if !ok || !i.Unique {
+		return nil, fmt.Errorf("index %q is not unique", i.IndexName)
+	}
+
+	key, err := idx.Get(v)
+	if err == index.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), key...), nil
 }
 
 type indexStore struct {
@@ -150,6 +296,29 @@ func (t *indexStore) Insert(cfg IndexConfig) error {
 	return t.st.Put(key, v)
 }
 
+func (t *indexStore) Replace(cfg IndexConfig) error {
+	key := []byte(cfg.IndexName)
+	_, err := t.st.Get(key)
+	if err == engine.ErrKeyNotFound {
+		return ErrIndexNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	doc, err := document.NewFromStruct(&cfg)
+	if err != nil {
+		return err
+	}
+
+	v, err := encoding.EncodeDocument(doc)
+	if err != nil {
+		return err
+	}
+
+	return t.st.Put(key, v)
+}
+
 func (t *indexStore) Get(indexName string) (*IndexConfig, error) {
 	key := []byte(indexName)
 	v, err := t.st.Get(key)
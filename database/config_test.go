@@ -1,6 +1,7 @@
 package database
 
 import (
+	"context"
 	"testing"
 
 	"github.com/asdine/genji/document"
@@ -12,7 +13,7 @@ func TestTableConfigStore(t *testing.T) {
 	ng := memoryengine.NewEngine()
 	defer ng.Close()
 
-	tx, err := ng.Begin(true)
+	tx, err := ng.Begin(context.Background(), true)
 	require.NoError(t, err)
 	defer tx.Rollback()
 
@@ -21,7 +22,7 @@ func TestTableConfigStore(t *testing.T) {
 	st, err := tx.GetStore("foo")
 	require.NoError(t, err)
 
-	tcs := tableConfigStore{st}
+	tcs := tableConfigStore{st: st}
 
 	cfg := TableConfig{
 		FieldConstraints: []FieldConstraint{
@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+
+	"github.com/asdine/genji/engine"
+)
+
+// WithContext returns a copy of the transaction whose operations also fail with the context error
+// once ctx is canceled or its deadline is exceeded. It is used to bind a statement run within
+// the transaction to its own context, while the transaction remains bound to the context
+// it was started with. The copy must not be committed, rolled back or promoted.
+func (tx *Transaction) WithContext(ctx context.Context) *Transaction {
+	if ctx == nil {
+		panic("nil context")
+	}
+
+	if ctx == tx.ctx {
+		return tx
+	}
+
+	newTx := *tx
+	newTx.ctx = ctx
+	newTx.Tx = &contextTransaction{Transaction: tx.Tx, ctx: ctx}
+	return &newTx
+}
+
+// contextTransaction is an engine transaction whose stores fail once ctx is done.
+type contextTransaction struct {
+	engine.Transaction
+
+	ctx context.Context
+}
+
+func (t *contextTransaction) GetStore(name string) (engine.Store, error) {
+	if err := t.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	st, err := t.Transaction.GetStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contextStore{Store: st, ctx: t.ctx}, nil
+}
+
+func (t *contextTransaction) CreateStore(name string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+
+	return t.Transaction.CreateStore(name)
+}
+
+func (t *contextTransaction) DropStore(name string) error {
+	if err := t.ctx.Err(); err != nil {
+		return err
+	}
+
+	return t.Transaction.DropStore(name)
+}
+
+type contextStore struct {
+	engine.Store
+
+	ctx context.Context
+}
+
+func (s *contextStore) Get(k []byte) ([]byte, error) {
+	if err := s.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return s.Store.Get(k)
+}
+
+func (s *contextStore) Put(k, v []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Store.Put(k, v)
+}
+
+func (s *contextStore) Delete(k []byte) error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Store.Delete(k)
+}
+
+func (s *contextStore) Truncate() error {
+	if err := s.ctx.Err(); err != nil {
+		return err
+	}
+
+	return s.Store.Truncate()
+}
+
+func (s *contextStore) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) error {
+	return s.Store.AscendGreaterOrEqual(pivot, func(k, v []byte) error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		return fn(k, v)
+	})
+}
+
+func (s *contextStore) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) error {
+	return s.Store.DescendLessOrEqual(pivot, func(k, v []byte) error {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+
+		return fn(k, v)
+	})
+}
+
+func (s *contextStore) Iterator(opts *engine.IteratorOptions) engine.Iterator {
+	return &contextIterator{Iterator: s.Store.Iterator(opts), ctx: s.ctx}
+}
+
+// contextIterator is a store iterator which stops once ctx is done.
+type contextIterator struct {
+	engine.Iterator
+
+	ctx context.Context
+	err error
+}
+
+func (it *contextIterator) Valid() bool {
+	if it.err == nil {
+		it.err = it.ctx.Err()
+	}
+
+	return it.err == nil && it.Iterator.Valid()
+}
+
+func (it *contextIterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+
+	return it.Iterator.Err()
+}
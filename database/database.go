@@ -2,25 +2,53 @@
 package database
 
 import (
+	"context"
 	"sync"
 
 	"github.com/asdine/genji/engine"
 )
 
+// DefaultSortMemoryLimit is the default value of SortOptions.MemoryLimit.
+const DefaultSortMemoryLimit = 32 << 20
+
+// SortOptions controls how the documents are sorted by ORDER BY clauses,
+// when they cannot be read in order from an index.
+type SortOptions struct {
+	// MemoryLimit is the maximum number of bytes of documents kept in memory while sorting.
+	// Beyond that limit, documents are sorted by chunks, which are written to temporary files
+	// and merged. If zero, DefaultSortMemoryLimit is used.
+	MemoryLimit int
+	// TempDir is the directory in which the temporary files are created.
+	// If empty, the default directory for temporary files is used.
+	TempDir string
+}
+
 // A Database manages a list of tables in an engine.
 type Database struct {
 	ng engine.Engine
 
-	mu sync.Mutex
+	// Sort controls how the documents are sorted by the queries run on the database.
+	// It must not be modified while queries are running.
+	Sort SortOptions
+
+	// values of the sequences leased by committed transactions, see sequence.go.
+	sequences map[string]*sequence
+	seqMu     sync.Mutex
+
+	// changes made to the statistics of the indexes by committed transactions, see stats.go.
+	indexStats map[string]*indexStats
+	statsMu    sync.Mutex
 }
 
 // New initializes the DB using the given engine.
 func New(ng engine.Engine) (*Database, error) {
 	db := Database{
-		ng: ng,
+		ng:         ng,
+		sequences:  make(map[string]*sequence),
+		indexStats: make(map[string]*indexStats),
 	}
 
-	ntx, err := db.ng.Begin(true)
+	ntx, err := db.ng.Begin(context.Background(), true)
 	if err != nil {
 		return nil, err
 	}
@@ -42,6 +70,14 @@ func New(ng engine.Engine) (*Database, error) {
 		return nil, err
 	}
 
+	_, err = ntx.GetStore(sequenceStoreName)
+	if err == engine.ErrStoreNotFound {
+		err = ntx.CreateStore(sequenceStoreName)
+	}
+	if err != nil {
+		return nil, err
+	}
+
 	err = ntx.Commit()
 	if err != nil {
 		return nil, err
@@ -50,32 +86,56 @@ func New(ng engine.Engine) (*Database, error) {
 	return &db, nil
 }
 
-// Close the underlying engine.
+// Close the underlying engine, after writing the statistics of the indexes.
 func (db *Database) Close() error {
-	return db.ng.Close()
+	err := db.writeIndexStats()
+	if cerr := db.ng.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
 }
 
-// Begin starts a new transaction.
+// Begin starts a new transaction with a background context.
 // The returned transaction must be closed either by calling Rollback or Commit.
 func (db *Database) Begin(writable bool) (*Transaction, error) {
-	ntx, err := db.ng.Begin(writable)
+	return db.BeginTx(context.Background(), writable)
+}
+
+// BeginTx starts a new transaction bound to the given context.
+// Once the context is canceled or its deadline is exceeded, every operation of the transaction
+// returns the context error and Commit rolls the transaction back.
+// The returned transaction must be closed either by calling Rollback or Commit.
+func (db *Database) BeginTx(ctx context.Context, writable bool) (*Transaction, error) {
+	ntx, err := db.ng.Begin(ctx, writable)
 	if err != nil {
 		return nil, err
 	}
 
 	tx := Transaction{
-		db:       db,
-		Tx:       ntx,
-		writable: writable,
+		ctx:        ctx,
+		db:         db,
+		Tx:         ntx,
+		writable:   writable,
+		sequences:  make(map[string]*sequenceLease),
+		indexStats: make(map[string]*indexStats),
 	}
 
 	tx.tcfgStore, err = tx.getTableConfigStore()
 	if err != nil {
+		ntx.Rollback()
 		return nil, err
 	}
 
 	tx.indexStore, err = tx.getIndexStore()
 	if err != nil {
+		ntx.Rollback()
+		return nil, err
+	}
+
+	tx.seqStore, err = tx.getSequenceStore()
+	if err != nil {
+		ntx.Rollback()
 		return nil, err
 	}
 
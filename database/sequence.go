@@ -0,0 +1,179 @@
+package database
+
+import (
+	"github.com/asdine/genji/document/encoding"
+	"github.com/asdine/genji/engine"
+)
+
+var sequenceStoreName = "__genji.sequences"
+
+// sequenceLeaseSize is the number of values of a sequence allocated at once.
+const sequenceLeaseSize = 64
+
+// Sequences generate unique, increasing integers, such as the keys of the tables without a primary key.
+// Values are allocated by batches, called leases: the upper bound of the last lease of every sequence
+// is stored in the sequence store, and a transaction writes a new upper bound whenever it needs
+// more values. Once the transaction is committed, the values it didn't use are kept in memory
+// by the database, and returned to the next transactions without writing to the sequence store.
+// A value is never returned to two transactions which are committed, but the values returned to
+// a transaction which is rolled back, or kept in memory when the database is closed, are lost,
+// unless the lease itself was rolled back.
+//
+// A sequence holds the values of a sequence leased by committed transactions.
+type sequence struct {
+	// last value returned, and upper bound of the lease.
+	current, leased int64
+}
+
+// sequenceLease holds the values of a sequence leased by a transaction,
+// which can only be used by that transaction until it is committed.
+type sequenceLease struct {
+	sequence
+
+	// reset is true if the sequence was deleted by the transaction,
+	// in which case the values kept by the database are obsolete.
+	reset bool
+}
+
+// nextSequenceValue returns the next value of the sequence, which is created if it doesn't exist.
+// If it is not found in the sequence store, the sequence starts after the given value.
+func (tx *Transaction) nextSequenceValue(name string, start int64) (int64, error) {
+	l := tx.sequences[name]
+	if l != nil && l.current < l.leased {
+		l.current++
+		return l.current, nil
+	}
+
+	var leased int64
+	if l == nil || !l.reset {
+		v, ok := tx.db.nextSequenceValue(name)
+		if ok {
+			return v, nil
+		}
+		leased = v
+	}
+
+	v, err := tx.seqStore.Get(name)
+	if err == nil {
+		start = v
+	} else if err != engine.ErrKeyNotFound {
+		return 0, err
+	}
+	if start > leased {
+		leased = start
+	}
+
+	err = tx.seqStore.Put(name, leased+sequenceLeaseSize)
+	if err != nil {
+		return 0, err
+	}
+
+	if l == nil {
+		l = new(sequenceLease)
+		tx.sequences[name] = l
+	}
+	l.current = leased + 1
+	l.leased = leased + sequenceLeaseSize
+	return l.current, nil
+}
+
+// dropSequence deletes the sequence from the sequence store.
+func (tx *Transaction) dropSequence(name string) error {
+	err := tx.seqStore.Delete(name)
+	if err != nil && err != engine.ErrKeyNotFound {
+		return err
+	}
+
+	tx.sequences[name] = &sequenceLease{reset: true}
+	return nil
+}
+
+// renameSequence moves the sequence to a new name.
+// If it is not found in the sequence store, the sequence starts after the given value.
+func (tx *Transaction) renameSequence(oldName, newName string, start int64) error {
+	v, err := tx.seqStore.Get(oldName)
+	if err == nil {
+		start = v
+	} else if err != engine.ErrKeyNotFound {
+		return err
+	}
+
+	err = tx.dropSequence(oldName)
+	if err != nil {
+		return err
+	}
+
+	err = tx.dropSequence(newName)
+	if err != nil {
+		return err
+	}
+
+	return tx.seqStore.Put(newName, start)
+}
+
+// nextSequenceValue returns the next value leased by a committed transaction, if any.
+// Otherwise, it returns false and the upper bound of the last lease.
+func (db *Database) nextSequenceValue(name string) (int64, bool) {
+	db.seqMu.Lock()
+	defer db.seqMu.Unlock()
+
+	s, ok := db.sequences[name]
+	if !ok {
+		return 0, false
+	}
+
+	if s.current < s.leased {
+		s.current++
+		return s.current, true
+	}
+
+	return s.leased, false
+}
+
+// releaseSequences makes the values leased by a committed transaction available
+// to the other transactions.
+func (db *Database) releaseSequences(leases map[string]*sequenceLease) {
+	if len(leases) == 0 {
+		return
+	}
+
+	db.seqMu.Lock()
+	defer db.seqMu.Unlock()
+
+	for name, l := range leases {
+		s, ok := db.sequences[name]
+		switch {
+		case l.leased == 0:
+			delete(db.sequences, name)
+		case !ok || l.reset:
+			db.sequences[name] = &sequence{current: l.current, leased: l.leased}
+		case l.leased > s.leased:
+			// the values leased by the transaction follow the ones
+			// kept by the database, which were all returned.
+			*s = l.sequence
+		}
+	}
+}
+
+type sequenceStore struct {
+	st engine.Store
+}
+
+// Get returns the upper bound of the last lease of the sequence.
+// If the sequence doesn't exist, it returns engine.ErrKeyNotFound.
+func (s *sequenceStore) Get(name string) (int64, error) {
+	v, err := s.st.Get([]byte(name))
+	if err != nil {
+		return 0, err
+	}
+
+	return encoding.DecodeInt64(v)
+}
+
+func (s *sequenceStore) Put(name string, leased int64) error {
+	return s.st.Put([]byte(name), encoding.EncodeInt64(leased))
+}
+
+func (s *sequenceStore) Delete(name string) error {
+	return s.st.Delete([]byte(name))
+}
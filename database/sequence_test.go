@@ -0,0 +1,206 @@
+package database_test
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+	"github.com/asdine/genji/engine/memoryengine"
+	"github.com/stretchr/testify/require"
+)
+
+// insertDocuments inserts n documents in the table within a new transaction,
+// and returns their keys.
+func insertDocuments(t testing.TB, db *database.Database, tableName string, n int, commit bool) []int64 {
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	tb, err := tx.GetTable(tableName)
+	require.NoError(t, err)
+
+	keys := make([]int64, n)
+	for i := range keys {
+		k, err := tb.Insert(newDocument())
+		require.NoError(t, err)
+		keys[i], err = encoding.DecodeInt64(k)
+		require.NoError(t, err)
+	}
+
+	if commit {
+		require.NoError(t, tx.Commit())
+	}
+
+	return keys
+}
+
+func createTable(t testing.TB, db *database.Database, tableName string, cfg *database.TableConfig) {
+	tx, err := db.Begin(true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+
+	err = tx.CreateTable(tableName, cfg)
+	require.NoError(t, err)
+	require.NoError(t, tx.Commit())
+}
+
+func TestSequences(t *testing.T) {
+	t.Run("Should generate increasing keys", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+
+		var keys []int64
+		for i := 0; i < 10; i++ {
+			keys = append(keys, insertDocuments(t, db, "test", 30, true)...)
+		}
+
+		for i, k := range keys {
+			require.EqualValues(t, i+1, k)
+		}
+	})
+
+	t.Run("Should not reuse keys of committed transactions", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+
+		committed := insertDocuments(t, db, "test", 10, true)
+		insertDocuments(t, db, "test", 100, false)
+		keys := insertDocuments(t, db, "test", 100, true)
+		require.True(t, keys[0] > committed[len(committed)-1])
+		for i := 1; i < len(keys); i++ {
+			require.True(t, keys[i] > keys[i-1])
+		}
+	})
+
+	t.Run("Should not reuse keys after reopening the database", func(t *testing.T) {
+		ng := memoryengine.NewEngine()
+		db, err := database.New(ng)
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+
+		before := insertDocuments(t, db, "test", 10, true)
+
+		db, err = database.New(ng)
+		require.NoError(t, err)
+		after := insertDocuments(t, db, "test", 10, true)
+		require.True(t, after[0] > before[len(before)-1])
+	})
+
+	t.Run("Should start after the last key of tables created without sequences", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", &database.TableConfig{LastKey: 100})
+
+		keys := insertDocuments(t, db, "test", 1, true)
+		require.EqualValues(t, 101, keys[0])
+	})
+
+	t.Run("Should restart when the table is dropped", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+		insertDocuments(t, db, "test", 10, true)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		err = tx.DropTable("test")
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		createTable(t, db, "test", nil)
+		keys := insertDocuments(t, db, "test", 1, true)
+		require.EqualValues(t, 1, keys[0])
+	})
+
+	t.Run("Should keep the sequence when the table is renamed", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+		before := insertDocuments(t, db, "test", 10, true)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		err = tx.RenameTable("test", "foo")
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		after := insertDocuments(t, db, "foo", 10, true)
+		require.True(t, after[0] > before[len(before)-1])
+	})
+
+	t.Run("Should not rewrite the table configuration", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+		insertDocuments(t, db, "test", 10, true)
+
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+		cfg, err := tb.Config()
+		require.NoError(t, err)
+		require.Zero(t, cfg.LastKey)
+	})
+
+	t.Run("Should not rewrite the catalog for every document", func(t *testing.T) {
+		ng := countingEngine{Engine: memoryengine.NewEngine(), puts: make(map[string]int)}
+		db, err := database.New(&ng)
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+		err = tx.CreateIndex(database.IndexConfig{
+			IndexName: "idx_test",
+			TableName: "test",
+			Path:      document.NewValuePath("fielda"),
+		})
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		ng.reset()
+		insertDocuments(t, db, "test", 100, true)
+
+		// one lease every 64 keys, and the statistics of the index are kept in memory.
+		puts := ng.reset()
+		require.Equal(t, 2, puts["__genji.sequences"])
+		require.Zero(t, puts["__genji.indexes"])
+		require.Zero(t, puts["__genji.tables"])
+	})
+
+	t.Run("Should generate unique keys for concurrent writers", func(t *testing.T) {
+		db, err := database.New(memoryengine.NewEngine())
+		require.NoError(t, err)
+		createTable(t, db, "test", nil)
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		seen := make(map[int64]bool)
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				keys := insertDocuments(t, db, "test", 50, true)
+
+				mu.Lock()
+				defer mu.Unlock()
+				for _, k := range keys {
+					require.False(t, seen[k], "duplicate key %d", k)
+					seen[k] = true
+				}
+			}()
+		}
+		wg.Wait()
+
+		require.Len(t, seen, 400)
+	})
+}
@@ -0,0 +1,276 @@
+package database
+
+import (
+	"math"
+
+	"github.com/asdine/genji/document"
+)
+
+// indexStatsFlushSize is the number of values added to or removed from an index
+// after which its statistics are written to the index store.
+const indexStatsFlushSize = 1000
+
+// The statistics of the indexes are stored in their configuration, but they are not written
+// by every transaction modifying an index, which would make the configuration a key written
+// by all the writers. Instead, transactions only count the values they add to or remove from
+// the indexes. Once a transaction is committed, its changes are kept in memory by the database
+// and added to the stored statistics whenever an index is read. The changes are written
+// to the index store by the transaction which makes them reach indexStatsFlushSize,
+// and when the database is closed. The changes kept in memory are lost if the database
+// is not closed properly.
+//
+// The number of distinct values of unique indexes follows the number of values. For the
+// other indexes, it is assumed to grow or shrink in proportion to the number of values,
+// until it is computed again by ReIndex.
+//
+// indexStats holds the changes made to the statistics of an index.
+type indexStats struct {
+	// number of values added to the index, negative if values were removed.
+	count int64
+
+	// reset is true if the statistics were computed again or the index was dropped
+	// by the transaction, in which case the changes kept by the database are obsolete.
+	reset bool
+}
+
+// setIndexValue associates the value with the key in the index and updates its statistics.
+func (t *Table) setIndexValue(idx Index, v document.Value, key []byte) error {
+	err := idx.Set(v, key)
+	if err != nil {
+		return err
+	}
+
+	t.tx.updateIndexStats(idx.IndexName, 1)
+	return nil
+}
+
+// deleteIndexValue removes the association between the value and the key from the index
+// and updates its statistics.
+func (t *Table) deleteIndexValue(idx Index, v document.Value, key []byte) error {
+	err := idx.Delete(v, key)
+	if err != nil {
+		return err
+	}
+
+	t.tx.updateIndexStats(idx.IndexName, -1)
+	return nil
+}
+
+// updateIndexStats records that delta values were added to or removed from the index.
+func (tx *Transaction) updateIndexStats(indexName string, delta int64) {
+	s, ok := tx.indexStats[indexName]
+	if !ok {
+		s = new(indexStats)
+		tx.indexStats[indexName] = s
+	}
+
+	s.count += delta
+}
+
+// resetIndexStats discards the changes made to the statistics of the index,
+// by the transaction and by the committed transactions.
+func (tx *Transaction) resetIndexStats(indexName string) {
+	tx.indexStats[indexName] = &indexStats{reset: true}
+}
+
+// applyIndexStats adds the changes made by the committed transactions and by the transaction
+// to the statistics of the index.
+func (tx *Transaction) applyIndexStats(cfg *IndexConfig) {
+	var delta int64
+
+	s, ok := tx.indexStats[cfg.IndexName]
+	if ok {
+		delta = s.count
+	}
+	if !ok || !s.reset {
+		delta += tx.db.indexStatsDelta(cfg.IndexName)
+	}
+
+	addIndexStats(cfg, delta)
+}
+
+// writeIndexStats writes the statistics of the indexes whose changes reach indexStatsFlushSize
+// to the index store. The changes taken from the database are returned, so that they can
+// be given back if the transaction fails.
+func (tx *Transaction) writeIndexStats() (map[string]*indexStats, error) {
+	var taken map[string]*indexStats
+
+	for name, s := range tx.indexStats {
+		delta := s.count
+		if s.reset {
+			if abs(delta) < indexStatsFlushSize {
+				continue
+			}
+		} else {
+			pending, ok := tx.db.takeIndexStats(name, delta)
+			if !ok {
+				continue
+			}
+
+			if taken == nil {
+				taken = make(map[string]*indexStats)
+			}
+			taken[name] = &indexStats{count: pending}
+			delta += pending
+		}
+
+		err := tx.indexStore.addStats(name, delta)
+		if err != nil {
+			return taken, err
+		}
+
+		s.count = 0
+	}
+
+	return taken, nil
+}
+
+// indexStatsDelta returns the changes made to the statistics of the index by the committed transactions
+// and not written to the index store yet.
+func (db *Database) indexStatsDelta(indexName string) int64 {
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+
+	s, ok := db.indexStats[indexName]
+	if !ok {
+		return 0
+	}
+
+	return s.count
+}
+
+// takeIndexStats returns the changes made to the statistics of the index by the committed transactions,
+// and removes them from the database, if adding delta to them makes them reach indexStatsFlushSize.
+// Otherwise, it returns false.
+func (db *Database) takeIndexStats(indexName string, delta int64) (int64, bool) {
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+
+	var pending int64
+	if s, ok := db.indexStats[indexName]; ok {
+		pending = s.count
+	}
+
+	if abs(pending+delta) < indexStatsFlushSize {
+		return 0, false
+	}
+
+	delete(db.indexStats, indexName)
+	return pending, true
+}
+
+// releaseIndexStats keeps the changes made to the statistics of the indexes by a committed transaction,
+// until they are written to the index store.
+func (db *Database) releaseIndexStats(stats map[string]*indexStats) {
+	if len(stats) == 0 {
+		return
+	}
+
+	db.statsMu.Lock()
+	defer db.statsMu.Unlock()
+
+	for name, s := range stats {
+		cur, ok := db.indexStats[name]
+		switch {
+		case s.reset && s.count == 0:
+			delete(db.indexStats, name)
+		case s.reset:
+			db.indexStats[name] = &indexStats{count: s.count}
+		case s.count == 0:
+		case ok:
+			cur.count += s.count
+		default:
+			db.indexStats[name] = &indexStats{count: s.count}
+		}
+	}
+}
+
+// writeIndexStats writes the changes made to the statistics of the indexes by the committed transactions
+// to the index store.
+func (db *Database) writeIndexStats() error {
+	db.statsMu.Lock()
+	stats := db.indexStats
+	db.indexStats = make(map[string]*indexStats)
+	db.statsMu.Unlock()
+
+	if len(stats) == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin(true)
+	if err != nil {
+		db.releaseIndexStats(stats)
+		return err
+	}
+	defer tx.Rollback()
+
+	for name, s := range stats {
+		err = tx.indexStore.addStats(name, s.count)
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = tx.Tx.Commit()
+	}
+	if err != nil {
+		db.releaseIndexStats(stats)
+	}
+
+	return err
+}
+
+// addStats adds delta values to the statistics stored in the configuration of the index.
+// Indexes which don't exist anymore are ignored.
+func (t *indexStore) addStats(indexName string, delta int64) error {
+	if delta == 0 {
+		return nil
+	}
+
+	cfg, err := t.Get(indexName)
+	if err == ErrIndexNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	addIndexStats(cfg, delta)
+	return t.Replace(*cfg)
+}
+
+// addIndexStats adds delta values to the statistics of the index.
+func addIndexStats(cfg *IndexConfig, delta int64) {
+	if delta == 0 {
+		return
+	}
+
+	count := cfg.Count + delta
+	if count < 0 {
+		count = 0
+	}
+
+	switch {
+	case cfg.Unique:
+		cfg.Cardinality = count
+	case cfg.Count > 0 && cfg.Cardinality > 0:
+		cardinality := int64(math.Round(float64(cfg.Cardinality) * float64(count) / float64(cfg.Count)))
+		if cardinality == 0 && count > 0 {
+			cardinality = 1
+		}
+		cfg.Cardinality = cardinality
+	}
+
+	if cfg.Cardinality > count {
+		cfg.Cardinality = count
+	}
+	cfg.Count = count
+}
+
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
+	}
+
+	return n
+}
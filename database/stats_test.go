@@ -0,0 +1,214 @@
+package database_test
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/engine"
+	"github.com/asdine/genji/engine/memoryengine"
+	"github.com/stretchr/testify/require"
+)
+
+// countingEngine counts the number of values written to each store.
+type countingEngine struct {
+	engine.Engine
+
+	mu   sync.Mutex
+	puts map[string]int
+}
+
+func (e *countingEngine) Begin(ctx context.Context, writable bool) (engine.Transaction, error) {
+	tx, err := e.Engine.Begin(ctx, writable)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingTransaction{Transaction: tx, e: e}, nil
+}
+
+// reset returns the number of values written to each store and resets the counters.
+func (e *countingEngine) reset() map[string]int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	puts := e.puts
+	e.puts = make(map[string]int)
+	return puts
+}
+
+type countingTransaction struct {
+	engine.Transaction
+
+	e *countingEngine
+}
+
+func (tx *countingTransaction) GetStore(name string) (engine.Store, error) {
+	st, err := tx.Transaction.GetStore(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &countingStore{Store: st, name: name, e: tx.e}, nil
+}
+
+type countingStore struct {
+	engine.Store
+
+	name string
+	e    *countingEngine
+}
+
+func (s *countingStore) Put(k, v []byte) error {
+	s.e.mu.Lock()
+	s.e.puts[s.name]++
+	s.e.mu.Unlock()
+
+	return s.Store.Put(k, v)
+}
+
+func TestIndexStats(t *testing.T) {
+	setup := func(t *testing.T) (*database.Database, *countingEngine) {
+		ng := countingEngine{Engine: memoryengine.NewEngine(), puts: make(map[string]int)}
+		db, err := database.New(&ng)
+		require.NoError(t, err)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateTable("test", nil)
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{
+			Unique:    true,
+			IndexName: "idx_a",
+			TableName: "test",
+			Path:      document.NewValuePath("a"),
+		})
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{
+			IndexName: "idx_b",
+			TableName: "test",
+			Path:      document.NewValuePath("b"),
+		})
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		ng.reset()
+		return db, &ng
+	}
+
+	// insert documents whose field a goes from "from" to "to", excluded, and returns their keys.
+	insert := func(t *testing.T, db *database.Database, from, to int, commit bool) [][]byte {
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		var keys [][]byte
+		for i := from; i < to; i++ {
+			key, err := tb.Insert(document.NewFieldBuffer().
+				Add("a", document.NewIntValue(i)).
+				Add("b", document.NewIntValue(i%10)),
+			)
+			require.NoError(t, err)
+			keys = append(keys, key)
+		}
+
+		if commit {
+			require.NoError(t, tx.Commit())
+		}
+
+		return keys
+	}
+
+	requireStats := func(t *testing.T, db *database.Database, name string, count, cardinality int64) {
+		tx, err := db.Begin(false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		idx, err := tx.GetIndex(name)
+		require.NoError(t, err)
+		require.Equal(t, count, idx.Count)
+		require.Equal(t, cardinality, idx.Cardinality)
+	}
+
+	t.Run("Should keep the statistics in memory until they reach the flush size", func(t *testing.T) {
+		db, ng := setup(t)
+
+		insert(t, db, 0, 100, true)
+
+		puts := ng.reset()
+		require.Zero(t, puts["__genji.indexes"])
+		require.Zero(t, puts["__genji.tables"])
+		requireStats(t, db, "idx_a", 100, 100)
+		requireStats(t, db, "idx_b", 100, 0)
+
+		for i := 1; i < 10; i++ {
+			insert(t, db, i*100, i*100+100, true)
+		}
+
+		// the last transaction makes the changes of both indexes reach the flush size.
+		puts = ng.reset()
+		require.Equal(t, 2, puts["__genji.indexes"])
+		require.Zero(t, puts["__genji.tables"])
+		requireStats(t, db, "idx_a", 1000, 1000)
+		requireStats(t, db, "idx_b", 1000, 0)
+	})
+
+	t.Run("Should write the statistics when the database is closed", func(t *testing.T) {
+		db, ng := setup(t)
+
+		insert(t, db, 0, 10, true)
+		require.Zero(t, ng.reset()["__genji.indexes"])
+
+		require.NoError(t, db.Close())
+		require.Equal(t, 2, ng.reset()["__genji.indexes"])
+	})
+
+	t.Run("Should not keep the statistics of rolled back transactions", func(t *testing.T) {
+		db, _ := setup(t)
+
+		insert(t, db, 0, 10, true)
+		insert(t, db, 10, 20, false)
+
+		requireStats(t, db, "idx_a", 10, 10)
+		requireStats(t, db, "idx_b", 10, 0)
+	})
+
+	t.Run("Should scale the distinct values counted by ReIndex", func(t *testing.T) {
+		db, _ := setup(t)
+
+		keys := insert(t, db, 0, 20, true)
+
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.ReIndex("idx_b")
+		require.NoError(t, err)
+		require.NoError(t, tx.Commit())
+
+		requireStats(t, db, "idx_b", 20, 10)
+
+		tx, err = db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		for _, i := range []int{0, 10, 1} {
+			err = tb.Delete(keys[i])
+			require.NoError(t, err)
+		}
+		require.NoError(t, tx.Commit())
+
+		requireStats(t, db, "idx_a", 17, 17)
+		requireStats(t, db, "idx_b", 17, 9)
+	})
+}
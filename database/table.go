@@ -3,6 +3,7 @@ package database
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strconv"
 
 	"github.com/asdine/genji/document"
@@ -54,6 +55,63 @@ func (t *Table) Iterate(fn func(d document.Document) error) error {
 	})
 }
 
+// Cursor returns a cursor reading the documents of the table one at a time, in the order of their keys.
+func (t *Table) Cursor() document.Cursor {
+	return &tableCursor{st: t.Store}
+}
+
+// tableCursor reads the documents of a table using a store iterator,
+// which is created by the first call to Next.
+type tableCursor struct {
+	st   engine.Store
+	it   engine.Iterator
+	d    encodedDocumentWithKey
+	done bool
+	err  error
+}
+
+func (c *tableCursor) Next() bool {
+	if c.done || c.err != nil {
+		return false
+	}
+
+	if c.it == nil {
+		c.it = c.st.Iterator(nil)
+		c.it.Seek(nil)
+	} else {
+		c.it.Next()
+	}
+
+	if !c.it.Valid() {
+		c.done = true
+		return false
+	}
+
+	c.d.EncodedDocument, c.err = c.it.Value()
+	c.d.key = c.it.Key()
+	return c.err == nil
+}
+
+func (c *tableCursor) Document() document.Document {
+	return &c.d
+}
+
+func (c *tableCursor) Err() error {
+	if c.err != nil || c.it == nil {
+		return c.err
+	}
+
+	return c.it.Err()
+}
+
+func (c *tableCursor) Close() error {
+	if c.it == nil {
+		return nil
+	}
+
+	return c.it.Close()
+}
+
 // GetDocument returns one document by key.
 func (t *Table) GetDocument(key []byte) (document.Document, error) {
 	v, err := t.Store.Get(key)
@@ -76,7 +134,6 @@ func (t *Table) generateKey(d document.Document) ([]byte, error) {
 		return nil, err
 	}
 
-	var key []byte
 	if pk := cfg.GetPrimaryKey(); pk != nil {
 		v, err := pk.Path.GetValue(d)
 		if err == document.ErrFieldNotFound {
@@ -89,22 +146,12 @@ func (t *Table) generateKey(d document.Document) ([]byte, error) {
 		return encoding.EncodeValue(v)
 	}
 
-	t.tx.db.mu.Lock()
-	defer t.tx.db.mu.Unlock()
-
-	cfg, err = t.cfgStore.Get(t.name)
+	seq, err := t.tx.nextSequenceValue(t.name, cfg.LastKey)
 	if err != nil {
 		return nil, err
 	}
 
-	cfg.LastKey++
-	key = encoding.EncodeInt64(cfg.LastKey)
-	err = t.cfgStore.Replace(t.name, cfg)
-	if err != nil {
-		return nil, err
-	}
-
-	return key, nil
+	return encoding.EncodeInt64(seq), nil
 }
 
 func getParentValue(d document.Document, p document.ValuePath) (document.Value, error) {
@@ -261,6 +308,11 @@ func (t *Table) Insert(d document.Document) ([]byte, error) {
 		return nil, err
 	}
 
+	return t.insert(d)
+}
+
+// insert a document whose constraints have already been validated.
+func (t *Table) insert(d document.Document) ([]byte, error) {
 	key, err := t.generateKey(d)
 	if err != nil {
 		return nil, err
@@ -287,12 +339,7 @@ func (t *Table) Insert(d document.Document) ([]byte, error) {
 	}
 
 	for _, idx := range indexes {
-		v, err := idx.Path.GetValue(d)
-		if err != nil {
-			v = document.NewNullValue()
-		}
-
-		err = idx.Set(v, key)
+		err = t.setIndexValue(idx, idx.value(d), key)
 		if err != nil {
 			if err == index.ErrDuplicate {
 				return nil, ErrDuplicateDocument
@@ -305,6 +352,143 @@ func (t *Table) Insert(d document.Document) ([]byte, error) {
 	return key, nil
 }
 
+// Upsert inserts the document into the table, or replaces the documents it conflicts with.
+// A document conflicts with the document having the same primary key and with the documents
+// having the same values for the fields of a unique index.
+// The first conflicting document is replaced, keeping its key, and the other ones are deleted.
+// If the table has a primary key and no document has the same primary key, all the
+// conflicting documents are deleted and the document is inserted.
+// It returns the key of the document.
+func (t *Table) Upsert(d document.Document) ([]byte, error) {
+	d, err := t.validateConstraints(d)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := t.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	keys, err := t.conflicts(indexes, d)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keys) == 0 {
+		return t.insert(d)
+	}
+
+	cfg, err := t.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	key := keys[0]
+	if cfg.GetPrimaryKey() != nil {
+		pk, err := t.generateKey(d)
+		if err != nil {
+			return nil, err
+		}
+
+		if !bytes.Equal(pk, key) {
+			key = nil
+		}
+	}
+
+	for _, k := range keys {
+		if bytes.Equal(k, key) {
+			continue
+		}
+
+		err = t.Delete(k)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if key == nil {
+		return t.insert(d)
+	}
+
+	return key, t.replace(indexes, key, d)
+}
+
+// Conflicts returns the keys of the documents preventing d from being inserted in the table:
+// the document with the same primary key, if any, always comes first, followed by the documents
+// having the same values for the fields of a unique index.
+func (t *Table) Conflicts(d document.Document) ([][]byte, error) {
+	d, err := t.validateConstraints(d)
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := t.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	return t.conflicts(indexes, d)
+}
+
+// conflicts returns the keys of the documents conflicting with d,
+// whose constraints must have already been validated.
+func (t *Table) conflicts(indexes map[string]Index, d document.Document) ([][]byte, error) {
+	cfg, err := t.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	var keys [][]byte
+	add := func(key []byte) {
+		for _, k := range keys {
+			if bytes.Equal(k, key) {
+				return
+			}
+		}
+
+		keys = append(keys, key)
+	}
+
+	if cfg.GetPrimaryKey() != nil {
+		key, err := t.generateKey(d)
+		if err != nil {
+			return nil, err
+		}
+
+		_, err = t.Store.Get(key)
+		if err == nil {
+			add(key)
+		} else if err != engine.ErrKeyNotFound {
+			return nil, err
+		}
+	}
+
+	// sort the indexes by name so that conflicts are always returned in the same order
+	names := make([]string, 0, len(indexes))
+	for name, idx := range indexes {
+		if idx.Unique {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		idx := indexes[name]
+
+		key, err := idx.getKey(idx.value(d))
+		if err != nil {
+			return nil, err
+		}
+
+		if key != nil {
+			add(key)
+		}
+	}
+
+	return keys, nil
+}
+
 // Delete a document by key.
 // Indexes are automatically updated.
 func (t *Table) Delete(key []byte) error {
@@ -319,12 +503,7 @@ func (t *Table) Delete(key []byte) error {
 	}
 
 	for _, idx := range indexes {
-		v, err := idx.Path.GetValue(d)
-		if err != nil {
-			return err
-		}
-
-		err = idx.Delete(v, key)
+		err = t.deleteIndexValue(idx, idx.value(d), key)
 		if err != nil {
 			return err
 		}
@@ -354,12 +533,7 @@ func (t *Table) replace(indexes map[string]Index, key []byte, d document.Documen
 
 	// remove key from indexes
 	for _, idx := range indexes {
-		v, err := idx.Path.GetValue(old)
-		if err != nil {
-			return err
-		}
-
-		err = idx.Delete(v, key)
+		err = t.deleteIndexValue(idx, idx.value(old), key)
 		if err != nil {
 			return err
 		}
@@ -379,18 +553,139 @@ func (t *Table) replace(indexes map[string]Index, key []byte, d document.Documen
 
 	// update indexes
 	for _, idx := range indexes {
-		v, err := idx.Path.GetValue(d)
+		err = t.setIndexValue(idx, idx.value(d), key)
 		if err != nil {
-			continue
+			if err == index.ErrDuplicate {
+				return ErrDuplicateDocument
+			}
+
+			return err
 		}
+	}
 
-		err = idx.Set(v, key)
+	return err
+}
+
+// AddFieldConstraint adds a constraint to a field of the table.
+// All the documents of the table are validated against the new constraint and
+// fields are converted to the type of the constraint if necessary.
+// If one of the documents doesn't satisfy the constraint, an error is returned.
+// Primary keys can only be set during the creation of the table.
+func (t *Table) AddFieldConstraint(fc FieldConstraint) error {
+	if len(fc.Path) == 0 {
+		return errors.New("empty path")
+	}
+
+	if fc.IsPrimaryKey {
+		return errors.New("cannot add a primary key to an existing table")
+	}
+
+	cfg, err := t.Config()
+	if err != nil {
+		return err
+	}
+
+	for _, c := range cfg.FieldConstraints {
+		if c.Path.String() == fc.Path.String() {
+			return fmt.Errorf("field %q already has a constraint", fc.Path)
+		}
+	}
+
+	// validate every document and keep track of the ones
+	// which need to be converted.
+	var keys [][]byte
+	err = t.Iterate(func(d document.Document) error {
+		var fb document.FieldBuffer
+		err := fb.Copy(d)
 		if err != nil {
 			return err
 		}
+
+		err = validateConstraint(&fb, &fc)
+		if err != nil {
+			return err
+		}
+
+		if fc.Type == 0 {
+			return nil
+		}
+
+		v, err := fc.Path.GetValue(d)
+		if err == document.ErrFieldNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if v.Type != fc.Type {
+			keys = append(keys, append([]byte(nil), d.(document.Keyer).Key()...))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	return err
+	cfg.FieldConstraints = append(cfg.FieldConstraints, fc)
+	err = t.cfgStore.Replace(t.name, cfg)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return nil
+	}
+
+	indexes, err := t.Indexes()
+	if err != nil {
+		return err
+	}
+
+	for _, k := range keys {
+		d, err := t.GetDocument(k)
+		if err != nil {
+			return err
+		}
+
+		d, err = t.validateConstraints(d)
+		if err != nil {
+			return err
+		}
+
+		err = t.replace(indexes, k, d)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DropFieldConstraint removes the constraint associated with the given path.
+// Documents are not modified.
+// The primary key constraint cannot be removed.
+func (t *Table) DropFieldConstraint(path document.ValuePath) error {
+	cfg, err := t.Config()
+	if err != nil {
+		return err
+	}
+
+	for i, fc := range cfg.FieldConstraints {
+		if fc.Path.String() != path.String() {
+			continue
+		}
+
+		if fc.IsPrimaryKey {
+			return errors.New("cannot drop the primary key of a table")
+		}
+
+		cfg.FieldConstraints = append(cfg.FieldConstraints[:i], cfg.FieldConstraints[i+1:]...)
+		return t.cfgStore.Replace(t.name, cfg)
+	}
+
+	return fmt.Errorf("field %q has no constraint", path)
 }
 
 // Truncate deletes all the documents from the table.
@@ -403,7 +698,8 @@ func (t *Table) TableName() string {
 	return t.name
 }
 
-// Indexes returns a map of all the indexes of a table.
+// Indexes returns a map of all the indexes of a table, keyed by the path of the indexed field.
+// Composite indexes are keyed by the list of their fields, e.g. "a, b DESC".
 func (t *Table) Indexes() (map[string]Index, error) {
 	s, err := t.tx.Tx.GetStore(indexStoreName)
 	if err != nil {
@@ -440,20 +736,8 @@ func (t *Table) Indexes() (map[string]Index, error) {
 				return err
 			}
 
-			var idx index.Index
-			if opts.Unique {
-				idx = index.NewUniqueIndex(t.tx.Tx, opts.IndexName)
-			} else {
-				idx = index.NewListIndex(t.tx.Tx, opts.IndexName)
-			}
-
-			indexes[opts.Path.String()] = Index{
-				Index:     idx,
-				IndexName: opts.IndexName,
-				TableName: opts.TableName,
-				Path:      opts.Path,
-				Unique:    opts.Unique,
-			}
+			t.tx.applyIndexStats(&opts)
+			indexes[opts.String()] = newIndex(t.tx.Tx, opts)
 
 			return nil
 		})
@@ -1,6 +1,7 @@
 package database_test
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"testing"
@@ -449,6 +450,226 @@ func TestTableReplace(t *testing.T) {
 	})
 }
 
+// TestTableUpsert verifies Upsert and Conflicts behaviour.
+func TestTableUpsert(t *testing.T) {
+	newTable := func(t *testing.T, pk bool) (*database.Table, func()) {
+		tx, cleanup := newTestDB(t)
+
+		var cfg *database.TableConfig
+		if pk {
+			cfg = &database.TableConfig{
+				FieldConstraints: []database.FieldConstraint{
+					{Path: []string{"id"}, Type: document.Int64Value, IsPrimaryKey: true},
+				},
+			}
+		}
+
+		err := tx.CreateTable("test", cfg)
+		require.NoError(t, err)
+		err = tx.CreateIndex(database.IndexConfig{
+			IndexName: "idx_email", TableName: "test", Path: document.NewValuePath("email"), Unique: true,
+		})
+		require.NoError(t, err)
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		return tb, cleanup
+	}
+
+	doc := func(id int, email string) *document.FieldBuffer {
+		return document.NewFieldBuffer().
+			Add("id", document.NewIntValue(id)).
+			Add("email", document.NewTextValue(email))
+	}
+
+	all := func(t *testing.T, tb *database.Table) string {
+		var buf bytes.Buffer
+		err := document.IteratorToJSONArray(&buf, tb)
+		require.NoError(t, err)
+		return buf.String()
+	}
+
+	t.Run("Should insert if there is no conflict", func(t *testing.T) {
+		tb, cleanup := newTable(t, true)
+		defer cleanup()
+
+		keys, err := tb.Conflicts(doc(1, "a"))
+		require.NoError(t, err)
+		require.Empty(t, keys)
+
+		key, err := tb.Upsert(doc(1, "a"))
+		require.NoError(t, err)
+		require.Equal(t, encoding.EncodeInt64(1), key)
+		require.JSONEq(t, `[{"id": 1, "email": "a"}]`, all(t, tb))
+	})
+
+	t.Run("Should replace the document with the same primary key", func(t *testing.T) {
+		tb, cleanup := newTable(t, true)
+		defer cleanup()
+
+		_, err := tb.Insert(doc(1, "a"))
+		require.NoError(t, err)
+
+		keys, err := tb.Conflicts(doc(1, "b"))
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{encoding.EncodeInt64(1)}, keys)
+
+		key, err := tb.Upsert(doc(1, "b"))
+		require.NoError(t, err)
+		require.Equal(t, encoding.EncodeInt64(1), key)
+		require.JSONEq(t, `[{"id": 1, "email": "b"}]`, all(t, tb))
+
+		// the unique index must have been updated
+		_, err = tb.Insert(doc(2, "a"))
+		require.NoError(t, err)
+		_, err = tb.Insert(doc(3, "b"))
+		require.Equal(t, database.ErrDuplicateDocument, err)
+	})
+
+	t.Run("Should delete the documents conflicting on a unique index", func(t *testing.T) {
+		tb, cleanup := newTable(t, true)
+		defer cleanup()
+
+		_, err := tb.Insert(doc(1, "a"))
+		require.NoError(t, err)
+		_, err = tb.Insert(doc(2, "b"))
+		require.NoError(t, err)
+
+		keys, err := tb.Conflicts(doc(1, "b"))
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{encoding.EncodeInt64(1), encoding.EncodeInt64(2)}, keys)
+
+		_, err = tb.Upsert(doc(1, "b"))
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"id": 1, "email": "b"}]`, all(t, tb))
+
+		_, err = tb.Upsert(doc(3, "b"))
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"id": 3, "email": "b"}]`, all(t, tb))
+	})
+
+	t.Run("Should keep the key of the replaced document without primary key", func(t *testing.T) {
+		tb, cleanup := newTable(t, false)
+		defer cleanup()
+
+		key1, err := tb.Insert(doc(1, "a"))
+		require.NoError(t, err)
+
+		key2, err := tb.Upsert(doc(2, "a"))
+		require.NoError(t, err)
+		require.Equal(t, key1, key2)
+		require.JSONEq(t, `[{"id": 2, "email": "a"}]`, all(t, tb))
+	})
+
+	t.Run("Should validate the document", func(t *testing.T) {
+		tb, cleanup := newTable(t, true)
+		defer cleanup()
+
+		_, err := tb.Upsert(document.NewFieldBuffer().Add("email", document.NewTextValue("a")))
+		require.Error(t, err)
+	})
+}
+
+func TestTableAddFieldConstraint(t *testing.T) {
+	t.Run("Should convert existing documents", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		key, err := tb.Insert(document.NewFieldBuffer().Add("foo", document.NewIntValue(1)))
+		require.NoError(t, err)
+		_, err = tb.Insert(document.NewFieldBuffer().Add("bar", document.NewIntValue(2)))
+		require.NoError(t, err)
+
+		err = tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("foo"), Type: document.Float64Value})
+		require.NoError(t, err)
+
+		cfg, err := tb.Config()
+		require.NoError(t, err)
+		require.Len(t, cfg.FieldConstraints, 1)
+
+		d, err := tb.GetDocument(key)
+		require.NoError(t, err)
+		v, err := d.GetByField("foo")
+		require.NoError(t, err)
+		require.Equal(t, document.NewFloat64Value(1), v)
+
+		// new documents must satisfy the constraint
+		key, err = tb.Insert(document.NewFieldBuffer().Add("foo", document.NewIntValue(3)))
+		require.NoError(t, err)
+		d, err = tb.GetDocument(key)
+		require.NoError(t, err)
+		v, err = d.GetByField("foo")
+		require.NoError(t, err)
+		require.Equal(t, document.NewFloat64Value(3), v)
+	})
+
+	t.Run("Should fail if a document doesn't satisfy the constraint", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		_, err := tb.Insert(document.NewFieldBuffer().Add("foo", document.NewIntValue(1)))
+		require.NoError(t, err)
+		_, err = tb.Insert(document.NewFieldBuffer().Add("bar", document.NewIntValue(2)))
+		require.NoError(t, err)
+
+		err = tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("foo"), IsNotNull: true})
+		require.Error(t, err)
+
+		err = tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("bar"), Type: document.DocumentValue})
+		require.Error(t, err)
+
+		cfg, err := tb.Config()
+		require.NoError(t, err)
+		require.Empty(t, cfg.FieldConstraints)
+	})
+
+	t.Run("Should fail if the field already has a constraint", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		err := tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("foo"), Type: document.Int64Value})
+		require.NoError(t, err)
+
+		err = tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("foo"), IsNotNull: true})
+		require.Error(t, err)
+	})
+
+	t.Run("Should fail with a primary key", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		err := tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("foo"), IsPrimaryKey: true})
+		require.Error(t, err)
+	})
+}
+
+func TestTableDropFieldConstraint(t *testing.T) {
+	t.Run("Should remove the constraint", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		err := tb.AddFieldConstraint(database.FieldConstraint{Path: document.NewValuePath("foo"), IsNotNull: true})
+		require.NoError(t, err)
+
+		_, err = tb.Insert(document.NewFieldBuffer().Add("bar", document.NewIntValue(1)))
+		require.Error(t, err)
+
+		err = tb.DropFieldConstraint(document.NewValuePath("foo"))
+		require.NoError(t, err)
+
+		_, err = tb.Insert(document.NewFieldBuffer().Add("bar", document.NewIntValue(1)))
+		require.NoError(t, err)
+	})
+
+	t.Run("Should fail if there is no constraint", func(t *testing.T) {
+		tb, cleanup := newTestTable(t)
+		defer cleanup()
+
+		err := tb.DropFieldConstraint(document.NewValuePath("foo"))
+		require.Error(t, err)
+	})
+}
+
 // TestTableTruncate verifies Truncate behaviour.
 func TestTableTruncate(t *testing.T) {
 	t.Run("Should succeed if table empty", func(t *testing.T) {
@@ -539,6 +760,80 @@ func TestTableIndexes(t *testing.T) {
 	})
 }
 
+func TestTableIndexStats(t *testing.T) {
+	tx, cleanup := newTestDB(t)
+	defer cleanup()
+
+	err := tx.CreateTable("test", nil)
+	require.NoError(t, err)
+	tb, err := tx.GetTable("test")
+	require.NoError(t, err)
+
+	err = tx.CreateIndex(database.IndexConfig{
+		Unique:    true,
+		IndexName: "idx_a",
+		TableName: "test",
+		Path:      document.NewValuePath("a"),
+	})
+	require.NoError(t, err)
+	err = tx.CreateIndex(database.IndexConfig{
+		IndexName: "idx_b",
+		TableName: "test",
+		Path:      document.NewValuePath("b"),
+	})
+	require.NoError(t, err)
+
+	requireStats := func(t *testing.T, name string, count, cardinality int64) {
+		idx, err := tx.GetIndex(name)
+		require.NoError(t, err)
+		require.Equal(t, count, idx.Count)
+		require.Equal(t, cardinality, idx.Cardinality)
+	}
+
+	var keys [][]byte
+	for i := 0; i < 10; i++ {
+		key, err := tb.Insert(document.NewFieldBuffer().
+			Add("a", document.NewIntValue(i)).
+			Add("b", document.NewIntValue(i%3)),
+		)
+		require.NoError(t, err)
+		keys = append(keys, key)
+	}
+
+	t.Run("Insert", func(t *testing.T) {
+		requireStats(t, "idx_a", 10, 10)
+		// the distinct values of non unique indexes are only counted by ReIndex.
+		requireStats(t, "idx_b", 10, 0)
+	})
+
+	t.Run("ReIndex", func(t *testing.T) {
+		err := tx.ReIndex("idx_b")
+		require.NoError(t, err)
+		requireStats(t, "idx_b", 10, 3)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		for _, key := range keys[:8] {
+			err := tb.Delete(key)
+			require.NoError(t, err)
+		}
+
+		requireStats(t, "idx_a", 2, 2)
+		requireStats(t, "idx_b", 2, 1)
+	})
+
+	t.Run("Replace", func(t *testing.T) {
+		err := tb.Replace(keys[8], document.NewFieldBuffer().
+			Add("a", document.NewIntValue(8)).
+			Add("b", document.NewIntValue(0)),
+		)
+		require.NoError(t, err)
+
+		requireStats(t, "idx_a", 2, 2)
+		requireStats(t, "idx_b", 2, 1)
+	})
+}
+
 // BenchmarkTableInsert benchmarks the Insert method with 1, 10, 1000 and 10000 successive insertions.
 func BenchmarkTableInsert(b *testing.B) {
 	for size := 1; size <= 10000; size *= 10 {
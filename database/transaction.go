@@ -1,6 +1,8 @@
 package database
 
 import (
+	"bytes"
+	"context"
 	"strings"
 
 	"github.com/asdine/genji/document"
@@ -20,11 +22,17 @@ var (
 // Transaction is either read-only or read/write. Read-only can be used to read tables
 // and read/write can be used to read, create, delete and modify tables.
 type Transaction struct {
+	ctx        context.Context
 	db         *Database
 	Tx         engine.Transaction
 	writable   bool
 	tcfgStore  *tableConfigStore
 	indexStore *indexStore
+	seqStore   *sequenceStore
+	// values of the sequences leased by the transaction.
+	sequences map[string]*sequenceLease
+	// changes made to the statistics of the indexes, see stats.go.
+	indexStats map[string]*indexStats
 }
 
 // Rollback the transaction. Can be used safely after commit.
@@ -34,7 +42,32 @@ func (tx *Transaction) Rollback() error {
 
 // Commit the transaction.
 func (tx *Transaction) Commit() error {
-	return tx.Tx.Commit()
+	taken, err := tx.writeIndexStats()
+	if err == nil {
+		err = tx.Tx.Commit()
+	} else {
+		tx.Tx.Rollback()
+	}
+	if err != nil {
+		// give back the changes to the statistics which were not written.
+		tx.db.releaseIndexStats(taken)
+		return err
+	}
+
+	tx.db.releaseSequences(tx.sequences)
+	tx.db.releaseIndexStats(tx.indexStats)
+	return nil
+}
+
+// DB returns the database the transaction belongs to.
+func (tx *Transaction) DB() *Database {
+	return tx.db
+}
+
+// Context returns the context the transaction is bound to,
+// or the one of the statement for the copies returned by WithContext.
+func (tx *Transaction) Context() context.Context {
+	return tx.ctx
 }
 
 // Writable indicates if the transaction is writable or not.
@@ -43,6 +76,7 @@ func (tx *Transaction) Writable() bool {
 }
 
 // Promote rollsback a read-only transaction and begins a read-write transaction transparently.
+// The new transaction is bound to the same context.
 // It returns an error if the current transaction is already writable.
 func (tx *Transaction) Promote() error {
 	if tx.writable {
@@ -54,7 +88,7 @@ func (tx *Transaction) Promote() error {
 		return err
 	}
 
-	newTransaction, err := tx.db.Begin(true)
+	newTransaction, err := tx.db.BeginTx(tx.ctx, true)
 	if err != nil {
 		return err
 	}
@@ -122,9 +156,97 @@ func (tx Transaction) DropTable(name string) error {
 		return err
 	}
 
+	err = tx.dropSequence(name)
+	if err != nil {
+		return err
+	}
+
 	return tx.Tx.DropStore(name)
 }
 
+// RenameTable renames a table and updates the configuration of its indexes.
+// If the table doesn't exist, it returns ErrTableNotFound.
+// If a table with the new name already exists, it returns ErrTableAlreadyExists.
+func (tx Transaction) RenameTable(oldName, newName string) error {
+	cfg, err := tx.tcfgStore.Get(oldName)
+	if err != nil {
+		return err
+	}
+
+	err = tx.tcfgStore.Insert(newName, *cfg)
+	if err != nil {
+		return err
+	}
+
+	err = tx.tcfgStore.Delete(oldName)
+	if err != nil {
+		return err
+	}
+
+	err = tx.renameSequence(oldName, newName, cfg.LastKey)
+	if err != nil {
+		return err
+	}
+
+	// stores can't be renamed, the documents are copied
+	// to a new store before dropping the old one.
+	err = tx.Tx.CreateStore(newName)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create table %q", newName)
+	}
+
+	src, err := tx.Tx.GetStore(oldName)
+	if err != nil {
+		return err
+	}
+
+	dst, err := tx.Tx.GetStore(newName)
+	if err != nil {
+		return err
+	}
+
+	err = src.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		return dst.Put(append([]byte(nil), k...), append([]byte(nil), v...))
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tx.Tx.DropStore(oldName)
+	if err != nil {
+		return err
+	}
+
+	var indexes []IndexConfig
+	err = tx.indexStore.st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		var opts IndexConfig
+		err := document.StructScan(encoding.EncodedDocument(v), &opts)
+		if err != nil {
+			return err
+		}
+
+		if opts.TableName == oldName {
+			indexes = append(indexes, opts)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, opts := range indexes {
+		opts.TableName = newName
+
+		err = tx.indexStore.Replace(opts)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
 // ListTables lists all the tables.
 func (tx Transaction) ListTables() ([]string, error) {
 	stores, err := tx.Tx.ListStores("")
@@ -135,7 +257,7 @@ func (tx Transaction) ListTables() ([]string, error) {
 	tables := make([]string, 0, len(stores))
 
 	for _, st := range stores {
-		if st == indexStoreName || st == tableConfigStoreName {
+		if st == indexStoreName || st == tableConfigStoreName || st == sequenceStoreName {
 			continue
 		}
 		if strings.HasPrefix(st, index.StorePrefix) {
@@ -156,6 +278,53 @@ type IndexConfig struct {
 	IndexName string
 	TableName string
 	Path      document.ValuePath
+
+	// Fields indexed by a composite index, in order. Path is the path of the first one.
+	// Fields is empty for indexes on a single field sorted in ascending order.
+	Fields []IndexField
+
+	// Count is the number of values stored in the index and Cardinality
+	// the number of distinct values among them.
+	// They are used by the query planner to estimate how many documents
+	// are selected by a comparison on the indexed field.
+	// They are computed by ReIndex and kept up to date by the transactions
+	// writing to the index, whose changes are stored in batches, see stats.go.
+	Count       int64
+	Cardinality int64
+}
+
+// IndexField is a field indexed by a composite index.
+type IndexField struct {
+	Path document.ValuePath
+	// If set to true, the values of the field are sorted in descending order.
+	Desc bool
+}
+
+// IsComposite returns true if the index is a composite index.
+// Composite indexes are used to index multiple fields, or fields sorted in descending order.
+func (cfg IndexConfig) IsComposite() bool {
+	return len(cfg.Fields) > 0
+}
+
+// String returns the list of indexed fields,
+// as it would appear in a CREATE INDEX statement.
+func (cfg IndexConfig) String() string {
+	if !cfg.IsComposite() {
+		return cfg.Path.String()
+	}
+
+	var b strings.Builder
+	for i, f := range cfg.Fields {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		b.WriteString(f.Path.String())
+		if f.Desc {
+			b.WriteString(" DESC")
+		}
+	}
+
+	return b.String()
 }
 
 // CreateIndex creates an index with the given name.
@@ -176,20 +345,9 @@ func (tx Transaction) GetIndex(name string) (*Index, error) {
 		return nil, err
 	}
 
-	var idx index.Index
-	if opts.Unique {
-		idx = index.NewUniqueIndex(tx.Tx, opts.IndexName)
-	} else {
-		idx = index.NewListIndex(tx.Tx, opts.IndexName)
-	}
-
-	return &Index{
-		Index:     idx,
-		IndexName: opts.IndexName,
-		TableName: opts.TableName,
-		Path:      opts.Path,
-		Unique:    opts.Unique,
-	}, nil
+	tx.applyIndexStats(opts)
+	idx := newIndex(tx.Tx, *opts)
+	return &idx, nil
 }
 
 // DropIndex deletes an index from the database.
@@ -202,18 +360,13 @@ func (tx Transaction) DropIndex(name string) error {
 	if err != nil {
 		return err
 	}
+	tx.resetIndexStats(name)
 
-	var idx index.Index
-	if opts.Unique {
-		idx = index.NewUniqueIndex(tx.Tx, opts.IndexName)
-	} else {
-		idx = index.NewListIndex(tx.Tx, opts.IndexName)
-	}
-
-	return idx.Truncate()
+	return newIndex(tx.Tx, *opts).Truncate()
 }
 
 // ReIndex truncates and recreates selected index from scratch.
+// The statistics of the index are computed again.
 func (tx Transaction) ReIndex(indexName string) error {
 	idx, err := tx.GetIndex(indexName)
 	if err != nil {
@@ -230,14 +383,47 @@ func (tx Transaction) ReIndex(indexName string) error {
 		return err
 	}
 
-	return tb.Iterate(func(d document.Document) error {
-		v, err := idx.Path.GetValue(d)
+	err = tb.Iterate(func(d document.Document) error {
+		return idx.Set(idx.value(d), d.(document.Keyer).Key())
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.analyzeIndex(idx)
+}
+
+// analyzeIndex counts the values and the distinct values of the index
+// and stores them in the index configuration, replacing the changes
+// previously made to its statistics.
+func (tx Transaction) analyzeIndex(idx *Index) error {
+	tx.resetIndexStats(idx.IndexName)
+
+	cfg := idx.config()
+	cfg.Count = 0
+	cfg.Cardinality = 0
+
+	var prev []byte
+	err := idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+		v, err := index.EncodeFieldToIndexValue(val)
 		if err != nil {
 			return err
 		}
+		v = append([]byte{byte(index.NewTypeFromValueType(val.Type))}, v...)
 
-		return idx.Set(v, d.(document.Keyer).Key())
+		cfg.Count++
+		if prev == nil || !bytes.Equal(prev, v) {
+			cfg.Cardinality++
+			prev = v
+		}
+
+		return nil
 	})
+	if err != nil {
+		return err
+	}
+
+	return tx.indexStore.Replace(cfg)
 }
 
 // ReIndexAll truncates and recreates all indexes of the database from scratch.
@@ -272,6 +458,16 @@ func (tx *Transaction) getTableConfigStore() (*tableConfigStore, error) {
 	}, nil
 }
 
+func (tx *Transaction) getSequenceStore() (*sequenceStore, error) {
+	st, err := tx.Tx.GetStore(sequenceStoreName)
+	if err != nil {
+		return nil, err
+	}
+	return &sequenceStore{
+		st: st,
+	}, nil
+}
+
 func (tx *Transaction) getIndexStore() (*indexStore, error) {
 	st, err := tx.Tx.GetStore(indexStoreName)
 	if err != nil {
@@ -116,6 +116,83 @@ func TestTxDropTable(t *testing.T) {
 	})
 }
 
+func TestTxRenameTable(t *testing.T) {
+	t.Run("Should rename a table and update its indexes", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.CreateTable("test", &database.TableConfig{
+			FieldConstraints: []database.FieldConstraint{
+				{Path: document.NewValuePath("foo"), Type: document.Int64Value, IsPrimaryKey: true},
+			},
+		})
+		require.NoError(t, err)
+
+		err = tx.CreateIndex(database.IndexConfig{
+			IndexName: "idxBar", TableName: "test", Path: document.NewValuePath("bar"),
+		})
+		require.NoError(t, err)
+
+		tb, err := tx.GetTable("test")
+		require.NoError(t, err)
+
+		key, err := tb.Insert(document.NewFieldBuffer().Add("foo", document.NewIntValue(1)).Add("bar", document.NewTextValue("a")))
+		require.NoError(t, err)
+
+		err = tx.RenameTable("test", "foo")
+		require.NoError(t, err)
+
+		_, err = tx.GetTable("test")
+		require.Equal(t, database.ErrTableNotFound, err)
+
+		tb, err = tx.GetTable("foo")
+		require.NoError(t, err)
+
+		cfg, err := tb.Config()
+		require.NoError(t, err)
+		require.Equal(t, document.NewValuePath("foo"), cfg.GetPrimaryKey().Path)
+
+		d, err := tb.GetDocument(key)
+		require.NoError(t, err)
+		v, err := d.GetByField("bar")
+		require.NoError(t, err)
+		require.Equal(t, document.NewTextValue("a"), v)
+
+		idx, err := tx.GetIndex("idxBar")
+		require.NoError(t, err)
+		require.Equal(t, "foo", idx.TableName)
+
+		indexes, err := tb.Indexes()
+		require.NoError(t, err)
+		require.Len(t, indexes, 1)
+
+		tables, err := tx.ListTables()
+		require.NoError(t, err)
+		require.Equal(t, []string{"foo"}, tables)
+	})
+
+	t.Run("Should fail if it doesn't exist", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.RenameTable("foo", "bar")
+		require.Equal(t, database.ErrTableNotFound, err)
+	})
+
+	t.Run("Should fail if the new name is already used", func(t *testing.T) {
+		tx, cleanup := newTestDB(t)
+		defer cleanup()
+
+		err := tx.CreateTable("foo", nil)
+		require.NoError(t, err)
+		err = tx.CreateTable("bar", nil)
+		require.NoError(t, err)
+
+		err = tx.RenameTable("foo", "bar")
+		require.Equal(t, database.ErrTableAlreadyExists, err)
+	})
+}
+
 func TestTxDropIndex(t *testing.T) {
 	t.Run("Should drop an index", func(t *testing.T) {
 		tx, cleanup := newTestDB(t)
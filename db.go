@@ -1,6 +1,7 @@
 package genji
 
 import (
+	"context"
 	"database/sql"
 	"database/sql/driver"
 
@@ -14,6 +15,8 @@ import (
 // DB represents a collection of tables stored in the underlying engine.
 type DB struct {
 	DB *database.Database
+
+	functions *query.Functions
 }
 
 // New initializes the DB using the given engine.
@@ -24,7 +27,8 @@ func New(ng engine.Engine) (*DB, error) {
 	}
 
 	return &DB{
-		DB: db,
+		DB:        db,
+		functions: new(query.Functions),
 	}, nil
 }
 
@@ -36,13 +40,22 @@ func (db *DB) Close() error {
 // Begin starts a new transaction.
 // The returned transaction must be closed either by calling Rollback or Commit.
 func (db *DB) Begin(writable bool) (*Tx, error) {
-	tx, err := db.DB.Begin(writable)
+	return db.BeginTx(context.Background(), writable)
+}
+
+// BeginTx starts a new transaction bound to ctx.
+// Once ctx is canceled or its deadline is exceeded, every operation of the transaction
+// returns the context error, and Commit rolls the transaction back.
+// The returned transaction must be closed either by calling Rollback or Commit.
+func (db *DB) BeginTx(ctx context.Context, writable bool) (*Tx, error) {
+	tx, err := db.DB.BeginTx(ctx, writable)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Tx{
 		Transaction: tx,
+		functions:   db.functions,
 	}, nil
 }
 
@@ -75,7 +88,13 @@ func (db *DB) Update(fn func(tx *Tx) error) error {
 
 // Exec a query against the database without returning the result.
 func (db *DB) Exec(q string, args ...interface{}) error {
-	res, err := db.Query(q, args...)
+	return db.ExecContext(context.Background(), q, args...)
+}
+
+// ExecContext runs a query against the database without returning the result.
+// The query is bound to ctx, see QueryContext.
+func (db *DB) ExecContext(ctx context.Context, q string, args ...interface{}) error {
+	res, err := db.QueryContext(ctx, q, args...)
 	if err != nil {
 		return err
 	}
@@ -85,13 +104,23 @@ func (db *DB) Exec(q string, args ...interface{}) error {
 
 // Query the database and return the result.
 // The returned result must always be closed after usage.
+// A transaction started by a BEGIN statement must be committed or rolled back
+// by the same query, use NewSession to keep it across queries.
 func (db *DB) Query(q string, args ...interface{}) (*query.Result, error) {
-	pq, err := parser.ParseQuery(q)
+	return db.QueryContext(context.Background(), q, args...)
+}
+
+// QueryContext queries the database and returns the result, like Query.
+// The query is bound to ctx: once it is canceled or its deadline is exceeded,
+// the statements and the returned result fail with the context error,
+// and the writes of the query are rolled back.
+func (db *DB) QueryContext(ctx context.Context, q string, args ...interface{}) (*query.Result, error) {
+	pq, err := parser.ParseQueryWithFunctions(q, db.functions)
 	if err != nil {
 		return nil, err
 	}
 
-	return pq.Run(db.DB, argsToNamedValues(args))
+	return pq.Run(ctx, db.DB, argsToNamedValues(args))
 }
 
 // QueryDocument runs the query and returns the first document.
@@ -121,6 +150,19 @@ func (db *DB) QueryDocument(q string, args ...interface{}) (document.Document, e
 	return &fb, nil
 }
 
+// RegisterFunc makes fn callable from the queries run against the database, under the given name.
+// Function names are case insensitive and cannot replace builtin functions
+// or functions that were already registered.
+// Missing fields are passed to fn as null values.
+func (db *DB) RegisterFunc(name string, fn query.ScalarFunc) error {
+	return db.functions.Register(name, fn)
+}
+
+// Functions returns the functions registered using RegisterFunc.
+func (db *DB) Functions() *query.Functions {
+	return db.functions
+}
+
 // ViewTable starts a read only transaction, fetches the selected table, calls fn with that table
 // and automatically rolls back the transaction.
 func (db *DB) ViewTable(tableName string, fn func(*Tx, *database.Table) error) error {
@@ -154,17 +196,27 @@ func (db *DB) UpdateTable(tableName string, fn func(*Tx, *database.Table) error)
 // and read/write can be used to read, create, delete and modify tables.
 type Tx struct {
 	*database.Transaction
+
+	functions *query.Functions
 }
 
 // Query the database withing the transaction and returns the result.
 // Closing the returned result after usage is not mandatory.
 func (tx *Tx) Query(q string, args ...interface{}) (*query.Result, error) {
-	pq, err := parser.ParseQuery(q)
+	return tx.QueryContext(tx.Context(), q, args...)
+}
+
+// QueryContext queries the database within the transaction and returns the result, like Query.
+// The query is bound to ctx as well as to the context of the transaction: once one of them
+// is canceled or its deadline is exceeded, the statements and the returned result fail
+// with the context error.
+func (tx *Tx) QueryContext(ctx context.Context, q string, args ...interface{}) (*query.Result, error) {
+	pq, err := parser.ParseQueryWithFunctions(q, tx.functions)
 	if err != nil {
 		return nil, err
 	}
 
-	return pq.Exec(tx.Transaction, argsToNamedValues(args), false)
+	return pq.ExecContext(ctx, tx.Transaction, argsToNamedValues(args), false)
 }
 
 // QueryDocument runs the query and returns the first document.
@@ -189,7 +241,13 @@ func (tx *Tx) QueryDocument(q string, args ...interface{}) (document.Document, e
 
 // Exec a query against the database within tx and without returning the result.
 func (tx *Tx) Exec(q string, args ...interface{}) error {
-	res, err := tx.Query(q, args...)
+	return tx.ExecContext(tx.Context(), q, args...)
+}
+
+// ExecContext runs a query against the database within tx without returning the result.
+// The query is bound to ctx, see QueryContext.
+func (tx *Tx) ExecContext(ctx context.Context, q string, args ...interface{}) error {
+	res, err := tx.QueryContext(ctx, q, args...)
 	if err != nil {
 		return err
 	}
@@ -197,6 +255,76 @@ func (tx *Tx) Exec(q string, args ...interface{}) error {
 	return res.Close()
 }
 
+// NewSession returns a session running queries against the database.
+// Unlike with DB.Query, a transaction started by a BEGIN statement is kept by the session
+// and used by its subsequent queries until it is committed or rolled back.
+// A session must not be used by multiple goroutines concurrently, and must be closed after usage.
+func (db *DB) NewSession() *Session {
+	return &Session{
+		db: db,
+		s:  query.NewSession(db.DB),
+	}
+}
+
+// A Session runs queries against the database and keeps the transaction
+// started by a BEGIN statement across them.
+type Session struct {
+	db *DB
+	s  *query.Session
+}
+
+// Query the database within the session and return the result.
+// The returned result must always be closed after usage.
+func (s *Session) Query(q string, args ...interface{}) (*query.Result, error) {
+	return s.QueryContext(context.Background(), q, args...)
+}
+
+// QueryContext queries the database within the session and returns the result, like Query.
+// The query is bound to ctx, as well as the transaction started by a BEGIN statement of the query.
+func (s *Session) QueryContext(ctx context.Context, q string, args ...interface{}) (*query.Result, error) {
+	pq, err := parser.ParseQueryWithFunctions(q, s.db.functions)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.s.Run(ctx, pq, argsToNamedValues(args))
+}
+
+// Exec a query against the database within the session without returning the result.
+func (s *Session) Exec(q string, args ...interface{}) error {
+	return s.ExecContext(context.Background(), q, args...)
+}
+
+// ExecContext runs a query against the database within the session without returning the result.
+// The query is bound to ctx, see QueryContext.
+func (s *Session) ExecContext(ctx context.Context, q string, args ...interface{}) error {
+	res, err := s.QueryContext(ctx, q, args...)
+	if err != nil {
+		return err
+	}
+
+	return res.Close()
+}
+
+// Tx returns the transaction started by a BEGIN statement, or nil if there is none in progress.
+// It must be committed or rolled back using the COMMIT and ROLLBACK statements.
+func (s *Session) Tx() *Tx {
+	tx := s.s.Tx()
+	if tx == nil {
+		return nil
+	}
+
+	return &Tx{
+		Transaction: tx,
+		functions:   s.db.functions,
+	}
+}
+
+// Close the session. If a transaction started by a BEGIN statement is in progress, it is rolled back.
+func (s *Session) Close() error {
+	return s.s.Close()
+}
+
 func argsToNamedValues(args []interface{}) []driver.NamedValue {
 	nv := make([]driver.NamedValue, len(args))
 	for i := range args {
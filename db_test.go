@@ -1,9 +1,12 @@
 package genji_test
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"log"
 	"testing"
+	"time"
 
 	"github.com/asdine/genji"
 	"github.com/asdine/genji/database"
@@ -116,3 +119,159 @@ func TestQueryDocument(t *testing.T) {
 		require.Nil(t, r)
 	})
 }
+
+func TestQueryContext(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test; INSERT INTO test (a) VALUES (1), (2), (3)")
+	require.NoError(t, err)
+
+	t.Run("Should fail if the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err := db.ExecContext(ctx, "INSERT INTO test (a) VALUES (4)")
+		require.Equal(t, context.Canceled, err)
+
+		_, err = db.BeginTx(ctx, false)
+		require.Equal(t, context.Canceled, err)
+
+		n, err := db.QueryDocument("SELECT COUNT(*) FROM test")
+		require.NoError(t, err)
+		var count int
+		require.NoError(t, document.Scan(n, &count))
+		require.Equal(t, 3, count)
+	})
+
+	t.Run("Should fail if the deadline is exceeded", func(t *testing.T) {
+		ctx, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+		defer cancel()
+
+		_, err := db.QueryContext(ctx, "SELECT * FROM test")
+		require.Equal(t, context.DeadlineExceeded, err)
+	})
+
+	t.Run("Should stop the iteration once the context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := db.QueryContext(ctx, "SELECT * FROM test")
+		require.NoError(t, err)
+		defer res.Close()
+
+		var count int
+		err = res.Iterate(func(d document.Document) error {
+			count++
+			cancel()
+			return nil
+		})
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("Should roll back transactions whose context is canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tx, err := db.BeginTx(ctx, true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.Exec("INSERT INTO test (a) VALUES (4)")
+		require.NoError(t, err)
+
+		cancel()
+		err = tx.Commit()
+		require.Equal(t, context.Canceled, err)
+
+		_, err = db.QueryDocument("SELECT * FROM test WHERE a = 4")
+		require.Equal(t, database.ErrDocumentNotFound, err)
+	})
+
+	t.Run("Should bind the statements of a transaction to their own context", func(t *testing.T) {
+		tx, err := db.Begin(true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		err = tx.ExecContext(ctx, "INSERT INTO test (a) VALUES (4)")
+		require.Equal(t, context.Canceled, err)
+
+		ctx, cancel = context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := tx.QueryContext(ctx, "SELECT * FROM test")
+		require.NoError(t, err)
+
+		var count int
+		err = res.Iterate(func(d document.Document) error {
+			count++
+			cancel()
+			return nil
+		})
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, 1, count)
+
+		// the transaction is still usable.
+		err = tx.Exec("INSERT INTO test (a) VALUES (5)")
+		require.NoError(t, err)
+		n, err := tx.QueryDocument("SELECT COUNT(*) FROM test")
+		require.NoError(t, err)
+		var total int
+		require.NoError(t, document.Scan(n, &total))
+		require.Equal(t, 4, total)
+	})
+}
+
+func TestRegisterFunc(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	double := func(args ...document.Value) (document.Value, error) {
+		if args[0].Type == document.NullValue {
+			return args[0], nil
+		}
+
+		return args[0].Mul(document.NewIntValue(2))
+	}
+
+	require.NoError(t, db.RegisterFunc("DOUBLE", double))
+	require.Error(t, db.RegisterFunc("double", double))
+	require.Error(t, db.RegisterFunc("lower", double))
+
+	err = db.Exec(`
+		CREATE TABLE test;
+		INSERT INTO test (a) VALUES (1), (2);
+		INSERT INTO test (b) VALUES (3);
+	`)
+	require.NoError(t, err)
+
+	res, err := db.Query("SELECT double(a) AS d FROM test WHERE double(a) > 2")
+	require.NoError(t, err)
+	defer res.Close()
+
+	var buf bytes.Buffer
+	require.NoError(t, document.IteratorToJSONArray(&buf, res))
+	require.JSONEq(t, `[{"d": 4}]`, buf.String())
+
+	err = db.View(func(tx *genji.Tx) error {
+		d, err := tx.QueryDocument("SELECT double(b) AS d FROM test WHERE b IS NOT NULL")
+		if err != nil {
+			return err
+		}
+
+		var x int
+		err = document.Scan(d, &x)
+		require.Equal(t, 6, x)
+		return err
+	})
+	require.NoError(t, err)
+
+	_, err = db.Query("SELECT triple(a) FROM test")
+	require.Error(t, err)
+}
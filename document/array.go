@@ -6,6 +6,7 @@ import (
 	"errors"
 	"io"
 	"reflect"
+	"strconv"
 )
 
 // ErrValueNotFound must be returned by Array implementations, when calling the GetByIndex method and
@@ -85,7 +86,7 @@ func (vb *ValueBuffer) Copy(a Array) error {
 		return err
 	}
 
-	for _, v := range *vb {
+	for i, v := range *vb {
 		switch v.Type {
 		case DocumentValue:
 			var buf FieldBuffer
@@ -94,7 +95,7 @@ func (vb *ValueBuffer) Copy(a Array) error {
 				return err
 			}
 
-			*vb = vb.Append(NewDocumentValue(&buf))
+			(*vb)[i] = NewDocumentValue(&buf)
 		case ArrayValue:
 			var buf ValueBuffer
 			err = buf.Copy(v.V.(Array))
@@ -102,7 +103,7 @@ func (vb *ValueBuffer) Copy(a Array) error {
 				return err
 			}
 
-			*vb = vb.Append(NewArrayValue(&buf))
+			(*vb)[i] = NewArrayValue(&buf)
 		}
 	}
 
@@ -119,6 +120,68 @@ func (vb *ValueBuffer) Replace(index int, v Value) error {
 	return nil
 }
 
+// SetPath replaces the value at the given path. The first chunk of the path must be an index of the buffer.
+// Documents missing along the path are created, as with FieldBuffer.SetPath.
+// It returns ErrValueNotFound if the index is out of range.
+func (vb *ValueBuffer) SetPath(p ValuePath, v Value) error {
+	i, err := vb.pathIndex(p)
+	if err != nil {
+		return err
+	}
+
+	if len(p) == 1 {
+		return vb.Replace(i, v)
+	}
+
+	elem, err := updateValue((*vb)[i],
+		func(buf *FieldBuffer) error { return buf.SetPath(p[1:], v) },
+		func(buf *ValueBuffer) error { return buf.SetPath(p[1:], v) },
+	)
+	if err != nil {
+		return err
+	}
+
+	return vb.Replace(i, elem)
+}
+
+// DeletePath deletes the field at the given path, within one of the values of the buffer.
+// The first chunk of the path must be an index of the buffer. Values cannot be removed
+// from the buffer itself.
+func (vb *ValueBuffer) DeletePath(p ValuePath) error {
+	i, err := vb.pathIndex(p)
+	if err != nil {
+		return err
+	}
+
+	if len(p) == 1 {
+		return errors.New("cannot delete a value from an array")
+	}
+
+	elem, err := updateValue((*vb)[i],
+		func(buf *FieldBuffer) error { return buf.DeletePath(p[1:]) },
+		func(buf *ValueBuffer) error { return buf.DeletePath(p[1:]) },
+	)
+	if err != nil {
+		return err
+	}
+
+	return vb.Replace(i, elem)
+}
+
+// pathIndex returns the index designated by the first chunk of the path.
+func (vb ValueBuffer) pathIndex(p ValuePath) (int, error) {
+	if len(p) == 0 {
+		return 0, errors.New("empty valuepath")
+	}
+
+	i, err := strconv.Atoi(p[0])
+	if err != nil || i < 0 || i >= len(vb) {
+		return 0, ErrValueNotFound
+	}
+
+	return i, nil
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (vb *ValueBuffer) UnmarshalJSON(data []byte) error {
 	dec := json.NewDecoder(bytes.NewReader(data))
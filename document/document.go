@@ -265,6 +265,113 @@ func (fb *FieldBuffer) Replace(field string, v Value) error {
 	return ErrFieldNotFound
 }
 
+// SetPath sets the value at the given path, creating the last field if it doesn't exist.
+// Documents missing along the path are created as well.
+// Documents and arrays along the path are copied to buffers before being modified.
+// It returns ErrFieldNotFound or ErrValueNotFound if a value along the path
+// is neither a document nor an array, or if an array index is out of range.
+func (fb *FieldBuffer) SetPath(p ValuePath, v Value) error {
+	if len(p) == 0 {
+		return errors.New("empty valuepath")
+	}
+
+	if len(p) == 1 {
+		fb.Set(p[0], v)
+		return nil
+	}
+
+	parent, err := fb.GetByField(p[0])
+	if err == ErrFieldNotFound {
+		parent = NewDocumentValue(NewFieldBuffer())
+	} else if err != nil {
+		return err
+	}
+
+	parent, err = updateValue(parent,
+		func(buf *FieldBuffer) error { return buf.SetPath(p[1:], v) },
+		func(buf *ValueBuffer) error { return buf.SetPath(p[1:], v) },
+	)
+	if err != nil {
+		return err
+	}
+
+	fb.Set(p[0], parent)
+	return nil
+}
+
+// DeletePath deletes the field at the given path.
+// Documents and arrays along the path are copied to buffers before being modified.
+// It returns ErrFieldNotFound or ErrValueNotFound if there is no field at the given path.
+func (fb *FieldBuffer) DeletePath(p ValuePath) error {
+	if len(p) == 0 {
+		return errors.New("empty valuepath")
+	}
+
+	if len(p) == 1 {
+		return fb.Delete(p[0])
+	}
+
+	parent, err := fb.GetByField(p[0])
+	if err != nil {
+		return err
+	}
+
+	parent, err = updateValue(parent,
+		func(buf *FieldBuffer) error { return buf.DeletePath(p[1:]) },
+		func(buf *ValueBuffer) error { return buf.DeletePath(p[1:]) },
+	)
+	if err != nil {
+		return err
+	}
+
+	return fb.Replace(p[0], parent)
+}
+
+// updateValue copies the document or array stored in v to a buffer, modifies the buffer
+// using the matching function and returns it as a new value.
+func updateValue(v Value, docFn func(buf *FieldBuffer) error, arrFn func(buf *ValueBuffer) error) (Value, error) {
+	switch v.Type {
+	case DocumentValue:
+		d, err := v.ConvertToDocument()
+		if err != nil {
+			return Value{}, err
+		}
+
+		var buf FieldBuffer
+		err = buf.ScanDocument(d)
+		if err != nil {
+			return Value{}, err
+		}
+
+		err = docFn(&buf)
+		if err != nil {
+			return Value{}, err
+		}
+
+		return NewDocumentValue(&buf), nil
+	case ArrayValue:
+		a, err := v.ConvertToArray()
+		if err != nil {
+			return Value{}, err
+		}
+
+		var buf ValueBuffer
+		err = buf.ScanArray(a)
+		if err != nil {
+			return Value{}, err
+		}
+
+		err = arrFn(&buf)
+		if err != nil {
+			return Value{}, err
+		}
+
+		return NewArrayValue(buf), nil
+	}
+
+	return Value{}, ErrFieldNotFound
+}
+
 // Copy deep copies every value of the document to the buffer.
 // If a value is a document or an array, it will be stored as a FieldBuffer or ValueBuffer respectively.
 func (fb *FieldBuffer) Copy(d Document) error {
@@ -129,6 +129,82 @@ func TestFieldBuffer(t *testing.T) {
 		require.Error(t, err)
 	})
 
+	t.Run("SetPath", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			data     string
+			path     string
+			expected string
+			fails    bool
+		}{
+			{"existing field", `{"a": 1, "b": 2}`, "a", `{"a": 10, "b": 2}`, false},
+			{"new field", `{"a": 1}`, "b", `{"a": 1, "b": 10}`, false},
+			{"nested field", `{"a": {"b": 1, "c": 2}}`, "a.b", `{"a": {"b": 10, "c": 2}}`, false},
+			{"missing documents", `{"a": 1}`, "b.c.d", `{"a": 1, "b": {"c": {"d": 10}}}`, false},
+			{"array index", `{"a": [1, 2, 3]}`, "a.1", `{"a": [1, 10, 3]}`, false},
+			{"document in array", `{"a": [{"b": 1}, {"b": 2}]}`, "a.1.c", `{"a": [{"b": 1}, {"b": 2, "c": 10}]}`, false},
+			{"index out of range", `{"a": [1, 2, 3]}`, "a.3", ``, true},
+			{"not an index", `{"a": [1, 2, 3]}`, "a.b", ``, true},
+			{"not a document", `{"a": 1}`, "a.b", ``, true},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var buf document.FieldBuffer
+				err := json.Unmarshal([]byte(test.data), &buf)
+				require.NoError(t, err)
+
+				err = buf.SetPath(document.NewValuePath(test.path), document.NewInt64Value(10))
+				if test.fails {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				data, err := json.Marshal(&buf)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, string(data))
+			})
+		}
+	})
+
+	t.Run("DeletePath", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			data     string
+			path     string
+			expected string
+			fails    bool
+		}{
+			{"field", `{"a": 1, "b": 2}`, "a", `{"b": 2}`, false},
+			{"nested field", `{"a": {"b": 1, "c": 2}}`, "a.b", `{"a": {"c": 2}}`, false},
+			{"document in array", `{"a": [{"b": 1}, {"b": 2, "c": 3}]}`, "a.1.c", `{"a": [{"b": 1}, {"b": 2}]}`, false},
+			{"missing field", `{"a": 1}`, "b", ``, true},
+			{"missing nested field", `{"a": {"b": 1}}`, "a.c", ``, true},
+			{"array index", `{"a": [1, 2, 3]}`, "a.1", ``, true},
+			{"not a document", `{"a": 1}`, "a.b", ``, true},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				var buf document.FieldBuffer
+				err := json.Unmarshal([]byte(test.data), &buf)
+				require.NoError(t, err)
+
+				err = buf.DeletePath(document.NewValuePath(test.path))
+				if test.fails {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+
+				data, err := json.Marshal(&buf)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, string(data))
+			})
+		}
+	})
+
 	t.Run("UnmarshalJSON", func(t *testing.T) {
 		tests := []struct {
 			name     string
@@ -19,6 +19,30 @@ func NewIterator(documents ...Document) Iterator {
 	return documentsIterator(documents)
 }
 
+// A Cursor reads documents one at a time. It is positioned before the first document:
+// Next must be called to read it. A cursor must be closed after use.
+type Cursor interface {
+	// Next moves the cursor to the next document. It returns false once there are
+	// no more documents or if an error occurred.
+	Next() bool
+	// Document returns the current document.
+	// It is only valid until the next call to Next.
+	Document() Document
+	// Err returns the error which stopped the cursor, if any.
+	Err() error
+	// Close releases the resources associated with the cursor.
+	Close() error
+}
+
+// A CursorIterator is an iterator whose documents can also be read one at a time.
+type CursorIterator interface {
+	Iterator
+
+	// Cursor returns a cursor reading the documents of the iterator.
+	// The cursor must not read anything until Next is called.
+	Cursor() Cursor
+}
+
 type documentsIterator []Document
 
 func (rr documentsIterator) Iterate(fn func(d Document) error) error {
@@ -34,6 +58,36 @@ func (rr documentsIterator) Iterate(fn func(d Document) error) error {
 	return nil
 }
 
+func (rr documentsIterator) Cursor() Cursor {
+	return &documentsCursor{docs: rr}
+}
+
+type documentsCursor struct {
+	docs    []Document
+	started bool
+}
+
+func (c *documentsCursor) Next() bool {
+	if c.started && len(c.docs) > 0 {
+		c.docs = c.docs[1:]
+	}
+	c.started = true
+
+	return len(c.docs) > 0
+}
+
+func (c *documentsCursor) Document() Document {
+	return c.docs[0]
+}
+
+func (c *documentsCursor) Err() error {
+	return nil
+}
+
+func (c *documentsCursor) Close() error {
+	return nil
+}
+
 // Stream reads documents of an iterator one by one and passes them
 // through a list of functions for transformation.
 type Stream struct {
@@ -85,6 +139,88 @@ func (s Stream) Iterate(fn func(d Document) error) error {
 	return nil
 }
 
+// Cursor returns a cursor reading the documents of the stream one at a time,
+// and passing them to the operators of the stream like Iterate does.
+// It returns false if the stream reads documents from an iterator which doesn't implement
+// CursorIterator, in which case the stream can only be read by Iterate.
+func (s Stream) Cursor() (Cursor, bool) {
+	if s.it == nil {
+		return documentsIterator(nil).Cursor(), true
+	}
+
+	c, ok := newCursor(s.it)
+	if !ok || s.op == nil {
+		return c, ok
+	}
+
+	return &streamCursor{cur: c, opFn: s.op()}, true
+}
+
+// newCursor returns a cursor reading the documents of the iterator, if it can be read one document at a time.
+func newCursor(it Iterator) (Cursor, bool) {
+	switch t := it.(type) {
+	case Stream:
+		return t.Cursor()
+	case multiIterator:
+		return t.cursor()
+	case CursorIterator:
+		return t.Cursor(), true
+	}
+
+	return nil, false
+}
+
+// streamCursor passes the documents of a cursor to the operator of a stream.
+type streamCursor struct {
+	cur  Cursor
+	opFn func(d Document) (Document, error)
+	d    Document
+	err  error
+	// closed is true once the operator closed the stream.
+	closed bool
+}
+
+func (c *streamCursor) Next() bool {
+	if c.err != nil || c.closed {
+		return false
+	}
+
+	for c.cur.Next() {
+		d, err := c.opFn(c.cur.Document())
+		if err == ErrStreamClosed {
+			c.closed = true
+			return false
+		}
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		if d != nil {
+			c.d = d
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *streamCursor) Document() Document {
+	return c.d
+}
+
+func (c *streamCursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.cur.Err()
+}
+
+func (c *streamCursor) Close() error {
+	return c.cur.Close()
+}
+
 // Pipe creates a new Stream who can read its data from s and apply
 // op to every document passed by its Iterate method.
 func (s Stream) Pipe(op StreamOperator) Stream {
@@ -154,18 +290,21 @@ func (s Stream) Offset(n int) Stream {
 	})
 }
 
-// Append adds the given iterator to the stream.
+// Append returns a stream which passes the documents of s, then those of the given iterator.
+// The operator of s, if any, only applies to the documents of s.
 func (s Stream) Append(it Iterator) Stream {
-	if mr, ok := s.it.(multiIterator); ok {
-		mr.iterators = append(mr.iterators, it)
-		s.it = mr
-	} else {
-		s.it = multiIterator{
-			iterators: []Iterator{s, it},
-		}
+	if mr, ok := s.it.(multiIterator); ok && s.op == nil {
+		iterators := make([]Iterator, len(mr.iterators), len(mr.iterators)+1)
+		copy(iterators, mr.iterators)
+
+		return Stream{it: multiIterator{iterators: append(iterators, it)}}
 	}
 
-	return s
+	return Stream{
+		it: multiIterator{
+			iterators: []Iterator{s, it},
+		},
+	}
 }
 
 // Count counts all the documents from the stream.
@@ -219,3 +358,68 @@ func (m multiIterator) Iterate(fn func(d Document) error) error {
 
 	return nil
 }
+
+// cursor returns a cursor reading the documents of the iterators one after the other,
+// if all of them can be read one document at a time.
+func (m multiIterator) cursor() (Cursor, bool) {
+	cursors := make([]Cursor, 0, len(m.iterators))
+	for _, it := range m.iterators {
+		c, ok := newCursor(it)
+		if !ok {
+			for _, c := range cursors {
+				c.Close()
+			}
+
+			return nil, false
+		}
+
+		cursors = append(cursors, c)
+	}
+
+	return &multiCursor{cursors: cursors}, true
+}
+
+// multiCursor reads the documents of several cursors one after the other.
+type multiCursor struct {
+	cursors []Cursor
+	// position of the cursor being read.
+	i int
+}
+
+func (m *multiCursor) Next() bool {
+	for ; m.i < len(m.cursors); m.i++ {
+		c := m.cursors[m.i]
+		if c.Next() {
+			return true
+		}
+
+		if c.Err() != nil {
+			return false
+		}
+	}
+
+	return false
+}
+
+func (m *multiCursor) Document() Document {
+	return m.cursors[m.i].Document()
+}
+
+func (m *multiCursor) Err() error {
+	if m.i < len(m.cursors) {
+		return m.cursors[m.i].Err()
+	}
+
+	return nil
+}
+
+func (m *multiCursor) Close() error {
+	var err error
+	for _, c := range m.cursors {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}
+
+	return err
+}
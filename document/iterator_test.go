@@ -1,13 +1,141 @@
 package document_test
 
 import (
+	"errors"
 	"fmt"
 	"log"
+	"testing"
 
 	"github.com/asdine/genji"
 	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
 )
 
+func TestStreamAppend(t *testing.T) {
+	doc := func(i int) document.Document {
+		return document.NewFieldBuffer().Add("a", document.NewIntValue(i))
+	}
+
+	double := func(d document.Document) (document.Document, error) {
+		v, err := d.GetByField("a")
+		if err != nil {
+			return nil, err
+		}
+
+		a, err := v.ConvertToInt64()
+		return document.NewFieldBuffer().Add("a", document.NewInt64Value(a*2)), err
+	}
+
+	st := document.NewStream(document.NewIterator(doc(1), doc(2))).Map(double)
+	st = st.Append(document.NewIterator(doc(3)))
+	st2 := st.Append(document.NewIterator(doc(4)))
+	st3 := st.Append(document.NewIterator(doc(5)))
+
+	values := func(st document.Stream) []int64 {
+		var res []int64
+		err := st.Iterate(func(d document.Document) error {
+			v, err := d.GetByField("a")
+			if err != nil {
+				return err
+			}
+
+			a, err := v.ConvertToInt64()
+			res = append(res, a)
+			return err
+		})
+		require.NoError(t, err)
+		return res
+	}
+
+	require.Equal(t, []int64{2, 4, 3}, values(st))
+	require.Equal(t, []int64{2, 4, 3, 4}, values(st2))
+	require.Equal(t, []int64{2, 4, 3, 5}, values(st3))
+}
+
+func TestStreamCursor(t *testing.T) {
+	doc := func(i int) document.Document {
+		return document.NewFieldBuffer().Add("a", document.NewIntValue(i))
+	}
+
+	values := func(c document.Cursor) []int64 {
+		defer c.Close()
+
+		var res []int64
+		for c.Next() {
+			v, err := c.Document().GetByField("a")
+			require.NoError(t, err)
+
+			a, err := v.ConvertToInt64()
+			require.NoError(t, err)
+			res = append(res, a)
+		}
+		require.NoError(t, c.Err())
+		return res
+	}
+
+	odd := func(d document.Document) (bool, error) {
+		v, err := d.GetByField("a")
+		if err != nil {
+			return false, err
+		}
+
+		a, err := v.ConvertToInt64()
+		return a%2 == 1, err
+	}
+
+	st := document.NewStream(document.NewIterator(doc(1), doc(2), doc(3), doc(4), doc(5), doc(6), doc(7)))
+
+	tests := []struct {
+		name     string
+		st       document.Stream
+		expected []int64
+	}{
+		{"Empty", document.Stream{}, nil},
+		{"Iterator", st, []int64{1, 2, 3, 4, 5, 6, 7}},
+		{"Filter", st.Filter(odd), []int64{1, 3, 5, 7}},
+		{"Offset and limit", st.Filter(odd).Offset(1).Limit(2), []int64{3, 5}},
+		{"Append", st.Limit(2).Append(document.NewIterator(doc(8))), []int64{1, 2, 8}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			c, ok := test.st.Cursor()
+			require.True(t, ok)
+			require.Equal(t, test.expected, values(c))
+		})
+	}
+
+	t.Run("Error", func(t *testing.T) {
+		c, ok := st.Map(func(d document.Document) (document.Document, error) {
+			return nil, errors.New("some error")
+		}).Cursor()
+		require.True(t, ok)
+		defer c.Close()
+
+		require.False(t, c.Next())
+		require.EqualError(t, c.Err(), "some error")
+		require.False(t, c.Next())
+	})
+
+	t.Run("Iterate only", func(t *testing.T) {
+		it := iteratorFunc(func(fn func(d document.Document) error) error {
+			return fn(doc(1))
+		})
+
+		_, ok := document.NewStream(it).Cursor()
+		require.False(t, ok)
+
+		_, ok = st.Append(it).Cursor()
+		require.False(t, ok)
+	})
+}
+
+type iteratorFunc func(fn func(d document.Document) error) error
+
+func (f iteratorFunc) Iterate(fn func(d document.Document) error) error {
+	return f(fn)
+}
+
 func ExampleStream_First() {
 	db, err := genji.Open(":memory:")
 	if err != nil {
@@ -3,6 +3,7 @@ package badgerengine
 
 import (
 	"bytes"
+	"context"
 
 	"github.com/asdine/genji/engine"
 	"github.com/dgraph-io/badger/v2"
@@ -32,10 +33,18 @@ func NewEngine(opt badger.Options) (*Engine, error) {
 }
 
 // Begin creates a transaction using Badger's transaction API.
-func (e *Engine) Begin(writable bool) (engine.Transaction, error) {
+// The context is checked by every operation of the transaction and its stores.
+func (e *Engine) Begin(ctx context.Context, writable bool) (engine.Transaction, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
 	tx := e.DB.NewTransaction(writable)
 
 	return &Transaction{
+		ctx:      ctx,
 		tx:       tx,
 		writable: writable,
 	}, nil
@@ -48,6 +57,7 @@ func (e *Engine) Close() error {
 
 // A Transaction uses Badger's transactions.
 type Transaction struct {
+	ctx       context.Context
 	tx        *badger.Txn
 	writable  bool
 	discarded bool
@@ -62,11 +72,19 @@ func (t *Transaction) Rollback() error {
 }
 
 // Commit the transaction.
+// If the context is canceled, the transaction is discarded instead.
 func (t *Transaction) Commit() error {
 	if t.discarded {
 		return badger.ErrDiscardedTxn
 	}
 
+	select {
+	case <-t.ctx.Done():
+		_ = t.Rollback()
+		return t.ctx.Err()
+	default:
+	}
+
 	if !t.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -96,6 +114,12 @@ func buildStorePrefixKey(name string) []byte {
 
 // GetStore returns a store by name.
 func (t *Transaction) GetStore(name string) (engine.Store, error) {
+	select {
+	case <-t.ctx.Done():
+		return nil, t.ctx.Err()
+	default:
+	}
+
 	key := buildStoreKey(name)
 
 	_, err := t.tx.Get(key)
@@ -110,6 +134,7 @@ func (t *Transaction) GetStore(name string) (engine.Store, error) {
 	pkey := buildStorePrefixKey(name)
 
 	return &Store{
+		ctx:      t.ctx,
 		tx:       t.tx,
 		prefix:   pkey,
 		writable: t.writable,
@@ -120,6 +145,12 @@ func (t *Transaction) GetStore(name string) (engine.Store, error) {
 // CreateStore creates a store.
 // If the store already exists, returns engine.ErrStoreAlreadyExists.
 func (t *Transaction) CreateStore(name string) error {
+	select {
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	default:
+	}
+
 	if !t.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -138,6 +169,12 @@ func (t *Transaction) CreateStore(name string) error {
 
 // DropStore deletes the store and all its keys.
 func (t *Transaction) DropStore(name string) error {
+	select {
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	default:
+	}
+
 	if !t.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -162,6 +199,12 @@ func (t *Transaction) DropStore(name string) error {
 
 // ListStores returns a list of all the store names.
 func (t *Transaction) ListStores(prefix string) ([]string, error) {
+	select {
+	case <-t.ctx.Done():
+		return nil, t.ctx.Err()
+	default:
+	}
+
 	var names []string
 
 	p := buildStoreKey(prefix)
@@ -2,6 +2,7 @@ package badgerengine
 
 import (
 	"bytes"
+	"context"
 	"errors"
 
 	"github.com/asdine/genji/engine"
@@ -10,6 +11,7 @@ import (
 
 // A Store is an implementation of the engine.Store interface.
 type Store struct {
+	ctx      context.Context
 	tx       *badger.Txn
 	prefix   []byte
 	writable bool
@@ -26,6 +28,12 @@ func buildKey(prefix, k []byte) []byte {
 
 // Put stores a key value pair. If it already exists, it overrides it.
 func (s *Store) Put(k, v []byte) error {
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	default:
+	}
+
 	if !s.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -39,6 +47,12 @@ func (s *Store) Put(k, v []byte) error {
 
 // Get returns a value associated with the given key. If not found, returns engine.ErrKeyNotFound.
 func (s *Store) Get(k []byte) ([]byte, error) {
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	default:
+	}
+
 	it, err := s.tx.Get(buildKey(s.prefix, k))
 	if err != nil {
 		if err == badger.ErrKeyNotFound {
@@ -53,6 +67,12 @@ func (s *Store) Get(k []byte) ([]byte, error) {
 
 // Delete a record by key. If not found, returns engine.ErrKeyNotFound.
 func (s *Store) Delete(k []byte) error {
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	default:
+	}
+
 	if !s.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -73,6 +93,7 @@ func (s *Store) Delete(k []byte) error {
 // AscendGreaterOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in increasing order and calls the given function for each pair.
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the beginning.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
 func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) error {
 	prefix := buildKey(s.prefix, nil)
 
@@ -83,6 +104,12 @@ func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) e
 
 	seek := buildKey(s.prefix, pivot)
 	for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		default:
+		}
+
 		item := it.Item()
 
 		err := item.Value(func(v []byte) error {
@@ -99,6 +126,7 @@ func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) e
 // DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the end.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
 func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) error {
 	prefix := buildKey(s.prefix, nil)
 
@@ -111,6 +139,12 @@ func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) err
 	seek := buildKey(s.prefix, append(pivot, 0xFF))
 
 	for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		default:
+		}
+
 		item := it.Item()
 
 		v, err := item.ValueCopy(nil)
@@ -129,6 +163,12 @@ func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) err
 
 // Truncate deletes all the records of the store.
 func (s *Store) Truncate() error {
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	default:
+	}
+
 	if !s.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -151,3 +191,155 @@ func (s *Store) Truncate() error {
 
 	return nil
 }
+
+// Iterator creates an iterator over the key value pairs of the store.
+// Badger iterators go in one direction only: the iterator is recreated
+// whenever the direction changes.
+// Since read/write transactions allow only one Badger iterator at a time,
+// the iterator must be closed before iterating on the store again.
+func (s *Store) Iterator(opts *engine.IteratorOptions) engine.Iterator {
+	it := iterator{
+		ctx:    s.ctx,
+		tx:     s.tx,
+		prefix: buildKey(s.prefix, nil),
+	}
+
+	if opts != nil && opts.UpperBound != nil {
+		it.upper = buildKey(s.prefix, opts.UpperBound)
+	}
+
+	return &it
+}
+
+type iterator struct {
+	ctx     context.Context
+	tx      *badger.Txn
+	prefix  []byte
+	upper   []byte
+	it      *badger.Iterator
+	reverse bool
+	// done is true if the iterator moved beyond the upper bound.
+	done bool
+	err  error
+}
+
+// open creates a Badger iterator going in the given direction, if needed.
+func (it *iterator) open(reverse bool) {
+	if it.it != nil && it.reverse == reverse {
+		return
+	}
+
+	if it.it != nil {
+		it.it.Close()
+	}
+
+	opt := badger.DefaultIteratorOptions
+	opt.Prefix = it.prefix
+	opt.Reverse = reverse
+	it.it = it.tx.NewIterator(opt)
+	it.reverse = reverse
+}
+
+func (it *iterator) Seek(pivot []byte) {
+	it.open(false)
+	it.it.Seek(append(it.prefix[:len(it.prefix):len(it.prefix)], pivot...))
+	it.check()
+}
+
+func (it *iterator) SeekReverse(pivot []byte) {
+	it.open(true)
+
+	var seek []byte
+	var exclusive bool
+	switch {
+	case it.upper != nil && (pivot == nil || bytes.Compare(pivot, it.upper[len(it.prefix):]) >= 0):
+		seek, exclusive = it.upper, true
+	case pivot == nil:
+		// seek to the first key following all the keys of the store.
+		seek = append([]byte{}, it.prefix...)
+		seek[len(seek)-1]++
+		exclusive = true
+	default:
+		seek = append(it.prefix[:len(it.prefix):len(it.prefix)], pivot...)
+	}
+
+	// reverse seeks return the largest key less than or equal to the given key.
+	it.it.Seek(seek)
+	if exclusive {
+		it.skip(seek)
+	}
+	it.check()
+}
+
+func (it *iterator) Next() {
+	if it.reverse {
+		it.turn(false)
+	} else {
+		it.it.Next()
+	}
+	it.check()
+}
+
+func (it *iterator) Prev() {
+	if !it.reverse {
+		it.turn(true)
+	} else {
+		it.it.Next()
+	}
+	it.check()
+}
+
+// turn recreates the iterator in the given direction and moves it
+// to the key following the current one in that direction.
+func (it *iterator) turn(reverse bool) {
+	key := it.it.Item().KeyCopy(nil)
+	it.open(reverse)
+	it.it.Seek(key)
+	it.skip(key)
+}
+
+// skip moves the iterator if it is positioned on the given key,
+// even if the key doesn't belong to the store.
+func (it *iterator) skip(key []byte) {
+	item := it.it.Item()
+	if item != nil && bytes.Equal(item.Key(), key) {
+		it.it.Next()
+	}
+}
+
+// check stops the iteration if the context is canceled or if the iterator is beyond the upper bound.
+func (it *iterator) check() {
+	select {
+	case <-it.ctx.Done():
+		it.err = it.ctx.Err()
+		return
+	default:
+	}
+
+	it.done = !it.reverse && it.upper != nil && it.it.Valid() && bytes.Compare(it.it.Item().Key(), it.upper) >= 0
+}
+
+func (it *iterator) Valid() bool {
+	return it.err == nil && !it.done && it.it != nil && it.it.Valid()
+}
+
+func (it *iterator) Key() []byte {
+	return it.it.Item().Key()[len(it.prefix):]
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	return it.it.Item().ValueCopy(nil)
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	if it.it != nil {
+		it.it.Close()
+		it.it = nil
+	}
+
+	return nil
+}
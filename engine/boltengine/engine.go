@@ -3,6 +3,7 @@ package boltengine
 
 import (
 	"bytes"
+	"context"
 	"os"
 
 	"github.com/asdine/genji/engine"
@@ -31,13 +32,21 @@ func NewEngine(path string, mode os.FileMode, opts *bolt.Options) (*Engine, erro
 }
 
 // Begin creates a transaction using Bolt's transaction API.
-func (e *Engine) Begin(writable bool) (engine.Transaction, error) {
+// The context is checked by every operation of the transaction and its stores.
+func (e *Engine) Begin(ctx context.Context, writable bool) (engine.Transaction, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	default:
+	}
+
 	tx, err := e.DB.Begin(writable)
 	if err != nil {
 		return nil, err
 	}
 
 	return &Transaction{
+		ctx:      ctx,
 		tx:       tx,
 		writable: writable,
 	}, nil
@@ -50,6 +59,7 @@ func (e *Engine) Close() error {
 
 // A Transaction uses Bolt's transactions.
 type Transaction struct {
+	ctx      context.Context
 	tx       *bolt.Tx
 	writable bool
 }
@@ -65,12 +75,26 @@ func (t *Transaction) Rollback() error {
 }
 
 // Commit the transaction.
+// If the context is canceled, the transaction is rolled back instead.
 func (t *Transaction) Commit() error {
+	select {
+	case <-t.ctx.Done():
+		_ = t.Rollback()
+		return t.ctx.Err()
+	default:
+	}
+
 	return t.tx.Commit()
 }
 
 // GetStore returns a store by name. The store uses a Bolt bucket.
 func (t *Transaction) GetStore(name string) (engine.Store, error) {
+	select {
+	case <-t.ctx.Done():
+		return nil, t.ctx.Err()
+	default:
+	}
+
 	bname := []byte(name)
 	b := t.tx.Bucket(bname)
 	if b == nil {
@@ -78,6 +102,7 @@ func (t *Transaction) GetStore(name string) (engine.Store, error) {
 	}
 
 	return &Store{
+		ctx:    t.ctx,
 		bucket: b,
 		tx:     t.tx,
 		name:   bname,
@@ -87,6 +112,12 @@ func (t *Transaction) GetStore(name string) (engine.Store, error) {
 // CreateStore creates a bolt bucket and returns a store.
 // If the store already exists, returns engine.ErrStoreAlreadyExists.
 func (t *Transaction) CreateStore(name string) error {
+	select {
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	default:
+	}
+
 	if !t.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -101,6 +132,12 @@ func (t *Transaction) CreateStore(name string) error {
 
 // DropStore deletes the underlying bucket.
 func (t *Transaction) DropStore(name string) error {
+	select {
+	case <-t.ctx.Done():
+		return t.ctx.Err()
+	default:
+	}
+
 	if !t.writable {
 		return engine.ErrTransactionReadOnly
 	}
@@ -115,6 +152,12 @@ func (t *Transaction) DropStore(name string) error {
 
 // ListStores returns a list of all the store names.
 func (t *Transaction) ListStores(prefix string) ([]string, error) {
+	select {
+	case <-t.ctx.Done():
+		return nil, t.ctx.Err()
+	default:
+	}
+
 	var names []string
 	p := []byte(prefix)
 	err := t.tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
@@ -2,6 +2,7 @@ package boltengine
 
 import (
 	"bytes"
+	"context"
 
 	"github.com/asdine/genji/engine"
 	bolt "go.etcd.io/bbolt"
@@ -9,6 +10,7 @@ import (
 
 // A Store is an implementation of the engine.Store interface using a bucket.
 type Store struct {
+	ctx    context.Context
 	bucket *bolt.Bucket
 	tx     *bolt.Tx
 	name   []byte
@@ -16,6 +18,12 @@ type Store struct {
 
 // Put stores a key value pair. If it already exists, it overrides it.
 func (s *Store) Put(k, v []byte) error {
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	default:
+	}
+
 	if !s.bucket.Writable() {
 		return engine.ErrTransactionReadOnly
 	}
@@ -25,6 +33,12 @@ func (s *Store) Put(k, v []byte) error {
 
 // Get returns a value associated with the given key. If not found, returns engine.ErrKeyNotFound.
 func (s *Store) Get(k []byte) ([]byte, error) {
+	select {
+	case <-s.ctx.Done():
+		return nil, s.ctx.Err()
+	default:
+	}
+
 	v := s.bucket.Get(k)
 	if v == nil {
 		return nil, engine.ErrKeyNotFound
@@ -35,6 +49,12 @@ func (s *Store) Get(k []byte) ([]byte, error) {
 
 // Delete a record by key. If not found, returns table.ErrDocumentNotFound.
 func (s *Store) Delete(k []byte) error {
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	default:
+	}
+
 	if !s.bucket.Writable() {
 		return engine.ErrTransactionReadOnly
 	}
@@ -50,9 +70,16 @@ func (s *Store) Delete(k []byte) error {
 // AscendGreaterOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in increasing order and calls the given function for each pair.
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the beginning.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
 func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) error {
 	c := s.bucket.Cursor()
 	for k, v := c.Seek(pivot); k != nil; k, v = c.Next() {
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		default:
+		}
+
 		err := fn(k, v)
 		if err != nil {
 			return err
@@ -65,6 +92,7 @@ func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) e
 // DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the end.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
 func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) error {
 	var k, v []byte
 
@@ -83,6 +111,12 @@ func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) err
 	}
 
 	for k != nil {
+		select {
+		case <-s.ctx.Done():
+			return s.ctx.Err()
+		default:
+		}
+
 		err := fn(k, v)
 		if err != nil {
 			return err
@@ -95,6 +129,12 @@ func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) err
 
 // Truncate deletes all the records of the store.
 func (s *Store) Truncate() error {
+	select {
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	default:
+	}
+
 	if !s.bucket.Writable() {
 		return engine.ErrTransactionReadOnly
 	}
@@ -107,3 +147,101 @@ func (s *Store) Truncate() error {
 	_, err = s.tx.CreateBucket(s.name)
 	return err
 }
+
+// Iterator creates an iterator over the key value pairs of the store, using a cursor of the bucket.
+func (s *Store) Iterator(opts *engine.IteratorOptions) engine.Iterator {
+	it := iterator{
+		ctx: s.ctx,
+		c:   s.bucket.Cursor(),
+	}
+
+	if opts != nil {
+		it.upper = opts.UpperBound
+	}
+
+	return &it
+}
+
+type iterator struct {
+	ctx   context.Context
+	c     *bolt.Cursor
+	upper []byte
+	k, v  []byte
+	err   error
+}
+
+func (it *iterator) Seek(pivot []byte) {
+	it.set(it.c.Seek(pivot))
+}
+
+func (it *iterator) SeekReverse(pivot []byte) {
+	if it.upper != nil && (pivot == nil || bytes.Compare(pivot, it.upper) >= 0) {
+		// move to the largest key strictly lower than the upper bound.
+		k, _ := it.c.Seek(it.upper)
+		if k == nil {
+			it.set(it.c.Last())
+		} else {
+			it.set(it.c.Prev())
+		}
+		return
+	}
+
+	if pivot == nil {
+		it.set(it.c.Last())
+		return
+	}
+
+	k, v := it.c.Seek(pivot)
+	switch {
+	case k == nil:
+		k, v = it.c.Last()
+	case bytes.Compare(k, pivot) > 0:
+		k, v = it.c.Prev()
+	}
+	it.set(k, v)
+}
+
+func (it *iterator) Next() {
+	it.set(it.c.Next())
+}
+
+func (it *iterator) Prev() {
+	it.set(it.c.Prev())
+}
+
+// set positions the iterator on the given pair, unless it is beyond the upper bound
+// or the context is canceled.
+func (it *iterator) set(k, v []byte) {
+	select {
+	case <-it.ctx.Done():
+		it.err = it.ctx.Err()
+		k, v = nil, nil
+	default:
+	}
+
+	if k != nil && it.upper != nil && bytes.Compare(k, it.upper) >= 0 {
+		k, v = nil, nil
+	}
+
+	it.k, it.v = k, v
+}
+
+func (it *iterator) Valid() bool {
+	return it.err == nil && it.k != nil
+}
+
+func (it *iterator) Key() []byte {
+	return it.k
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	return it.v, nil
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	return nil
+}
@@ -2,6 +2,7 @@
 package engine
 
 import (
+	"context"
 	"errors"
 )
 
@@ -29,7 +30,10 @@ type Engine interface {
 	// Begin returns a read-only or read/write transaction depending on whether writable is set to false
 	// or true, respectively.
 	// The behaviour of opening a transaction when another one is already opened depends on the implementation.
-	Begin(writable bool) (Transaction, error)
+	// The context is bound to the transaction: once it is canceled or its deadline is exceeded,
+	// the methods of the transaction and of its stores, including iterations in progress,
+	// must return the context error, and Commit must roll the transaction back.
+	Begin(ctx context.Context, writable bool) (Transaction, error)
 	// Close the engine after ensuring all the transactions have completed.
 	Close() error
 }
@@ -76,4 +80,46 @@ type Store interface {
 	// If the given function returns an error, the iteration stops and returns that error.
 	// If the pivot is nil, starts from the end.
 	DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) error
+	// Iterator creates an iterator over the key value pairs of the store, with the given options.
+	// The options can be nil. The iterator is not positioned until one of its Seek methods is called,
+	// and must be closed after use.
+	Iterator(opts *IteratorOptions) Iterator
+}
+
+// IteratorOptions are used to configure an iterator.
+type IteratorOptions struct {
+	// UpperBound is the exclusive upper bound of the iteration.
+	// If it is set, the iterator never returns keys greater than or equal to it.
+	UpperBound []byte
+}
+
+// An Iterator iterates on the key value pairs of a store, in both directions.
+// It reads the pairs of the store as of its creation, and may or may not observe
+// the modifications made by the transaction afterwards.
+// Once the iterator is positioned, Next and Prev must only be called if Valid returns true.
+type Iterator interface {
+	// Seek moves the iterator to the smallest key greater than or equal to the pivot.
+	// If the pivot is nil, it moves to the first key.
+	Seek(pivot []byte)
+	// SeekReverse moves the iterator to the largest key less than or equal to the pivot.
+	// If the pivot is nil, it moves to the last key.
+	SeekReverse(pivot []byte)
+	// Next moves the iterator to the next key.
+	Next()
+	// Prev moves the iterator to the previous key.
+	Prev()
+	// Valid returns whether the iterator is positioned on a key value pair.
+	// It returns false once the iteration is over or if an error occurred.
+	Valid() bool
+	// Key returns the key of the current pair.
+	// It is only valid until the next move of the iterator.
+	Key() []byte
+	// Value returns the value of the current pair.
+	// It is only valid until the next move of the iterator.
+	Value() ([]byte, error)
+	// Err returns the error which stopped the iteration, if any.
+	// If the context of the transaction is canceled, it returns the context error.
+	Err() error
+	// Close releases the resources associated with the iterator.
+	Close() error
 }
@@ -4,6 +4,7 @@ package enginetest
 
 import (
 	"bytes"
+	"context"
 	"errors"
 	"fmt"
 	"testing"
@@ -31,12 +32,14 @@ func TestSuite(t *testing.T, builder Builder) {
 	}{
 		{"Engine", TestEngine},
 		{"Transaction/Commit-Rollback", TestTransactionCommitRollback},
+		{"Transaction/Context", TestTransactionContext},
 		{"Transaction/Store", TestTransactionStore},
 		{"Transaction/CreateStore", TestTransactionCreateStore},
 		{"Transaction/DropStore", TestTransactionDropStore},
 		{"Transaction/ListStores", TestTransactionListStores},
 		{"Store/AscendGreaterOrEqual", TestStoreAscendGreaterOrEqual},
 		{"Store/DescendLessOrEqual", TestStoreDescendLessOrEqual},
+		{"Store/Iterator", TestStoreIterator},
 		{"Store/Put", TestStorePut},
 		{"Store/Get", TestStoreGet},
 		{"Store/Delete", TestStoreDelete},
@@ -69,7 +72,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	defer cleanup()
 
 	t.Run("Commit on read-only transaction should fail", func(t *testing.T) {
-		tx, err := ng.Begin(false)
+		tx, err := ng.Begin(context.Background(), false)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -78,7 +81,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	})
 
 	t.Run("Commit after rollback should fail", func(t *testing.T) {
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -90,7 +93,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	})
 
 	t.Run("Rollback after commit should not fail", func(t *testing.T) {
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -102,7 +105,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	})
 
 	t.Run("Commit after commit should fail", func(t *testing.T) {
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -114,7 +117,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	})
 
 	t.Run("Rollback after rollback should not fail", func(t *testing.T) {
-		tx, err := ng.Begin(false)
+		tx, err := ng.Begin(context.Background(), false)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -126,7 +129,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	})
 
 	t.Run("Read-Only write attempts", func(t *testing.T) {
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 
 		// create store for testing store methods
@@ -137,7 +140,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 		require.NoError(t, err)
 
 		// create a new read-only transaction
-		tx, err = ng.Begin(false)
+		tx, err = ng.Begin(context.Background(), false)
 		defer tx.Rollback()
 
 		// fetch the store and the index
@@ -209,7 +212,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 
 				if test.initFn != nil {
 					func() {
-						tx, err := ng.Begin(true)
+						tx, err := ng.Begin(context.Background(), true)
 						require.NoError(t, err)
 						defer tx.Rollback()
 
@@ -220,7 +223,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 					}()
 				}
 
-				tx, err := ng.Begin(true)
+				tx, err := ng.Begin(context.Background(), true)
 				require.NoError(t, err)
 				defer tx.Rollback()
 
@@ -230,7 +233,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 				err = tx.Rollback()
 				require.NoError(t, err)
 
-				tx, err = ng.Begin(true)
+				tx, err = ng.Begin(context.Background(), true)
 				require.NoError(t, err)
 				defer tx.Rollback()
 
@@ -246,7 +249,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 			t.Run(test.name+"/commit", func(t *testing.T) {
 				if test.initFn != nil {
 					func() {
-						tx, err := ng.Begin(true)
+						tx, err := ng.Begin(context.Background(), true)
 						require.NoError(t, err)
 						defer tx.Rollback()
 
@@ -257,7 +260,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 					}()
 				}
 
-				tx, err := ng.Begin(true)
+				tx, err := ng.Begin(context.Background(), true)
 				require.NoError(t, err)
 				defer tx.Rollback()
 
@@ -267,7 +270,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 				err = tx.Commit()
 				require.NoError(t, err)
 
-				tx, err = ng.Begin(true)
+				tx, err = ng.Begin(context.Background(), true)
 				require.NoError(t, err)
 				defer tx.Rollback()
 
@@ -295,7 +298,7 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 				ng, cleanup := builder()
 				defer cleanup()
 
-				tx, err := ng.Begin(true)
+				tx, err := ng.Begin(context.Background(), true)
 				require.NoError(t, err)
 				defer tx.Rollback()
 
@@ -309,13 +312,124 @@ func TestTransactionCommitRollback(t *testing.T, builder Builder) {
 	})
 }
 
+// TestTransactionContext verifies that transactions stop working once their context is canceled.
+func TestTransactionContext(t *testing.T, builder Builder) {
+	t.Run("Begin should fail if the context is canceled", func(t *testing.T) {
+		ng, cleanup := builder()
+		defer cleanup()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, writable := range []bool{false, true} {
+			_, err := ng.Begin(ctx, writable)
+			require.Equal(t, context.Canceled, err)
+		}
+	})
+
+	t.Run("Operations should fail once the context is canceled", func(t *testing.T) {
+		ng, cleanup := builder()
+		defer cleanup()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tx, err := ng.Begin(ctx, true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore("test")
+		require.NoError(t, err)
+		st, err := tx.GetStore("test")
+		require.NoError(t, err)
+		err = st.Put([]byte("foo"), []byte("FOO"))
+		require.NoError(t, err)
+
+		cancel()
+
+		_, err = tx.GetStore("test")
+		require.Equal(t, context.Canceled, err)
+		err = tx.CreateStore("other")
+		require.Equal(t, context.Canceled, err)
+		_, err = st.Get([]byte("foo"))
+		require.Equal(t, context.Canceled, err)
+		err = st.Put([]byte("bar"), []byte("BAR"))
+		require.Equal(t, context.Canceled, err)
+		err = st.Delete([]byte("foo"))
+		require.Equal(t, context.Canceled, err)
+		err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+			return errors.New("should not iterate")
+		})
+		require.Equal(t, context.Canceled, err)
+	})
+
+	t.Run("Commit should fail and roll back if the context is canceled", func(t *testing.T) {
+		ng, cleanup := builder()
+		defer cleanup()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tx, err := ng.Begin(ctx, true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore("test")
+		require.NoError(t, err)
+
+		cancel()
+		err = tx.Commit()
+		require.Equal(t, context.Canceled, err)
+
+		tx, err = ng.Begin(context.Background(), false)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		_, err = tx.GetStore("test")
+		require.Equal(t, engine.ErrStoreNotFound, err)
+	})
+
+	t.Run("Iterations should stop once the context is canceled", func(t *testing.T) {
+		ng, cleanup := builder()
+		defer cleanup()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tx, err := ng.Begin(ctx, true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore("test")
+		require.NoError(t, err)
+		st, err := tx.GetStore("test")
+		require.NoError(t, err)
+
+		for i := 1; i <= 10; i++ {
+			err := st.Put([]byte{uint8(i)}, []byte{uint8(i)})
+			require.NoError(t, err)
+		}
+
+		var count int
+		err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+			count++
+			if count == 3 {
+				cancel()
+			}
+			return nil
+		})
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, 3, count)
+	})
+}
+
 // TestTransactionCreateStore verifies CreateStore behaviour.
 func TestTransactionCreateStore(t *testing.T, builder Builder) {
 	t.Run("Should create a store", func(t *testing.T) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -331,7 +445,7 @@ func TestTransactionCreateStore(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -348,7 +462,7 @@ func TestTransactionStore(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(false)
+		tx, err := ng.Begin(context.Background(), false)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -360,7 +474,7 @@ func TestTransactionStore(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -400,7 +514,7 @@ func TestTransactionDropStore(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -418,7 +532,7 @@ func TestTransactionDropStore(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -433,7 +547,7 @@ func TestTransactionListStores(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -454,7 +568,7 @@ func TestTransactionListStores(t *testing.T, builder Builder) {
 		ng, cleanup := builder()
 		defer cleanup()
 
-		tx, err := ng.Begin(true)
+		tx, err := ng.Begin(context.Background(), true)
 		require.NoError(t, err)
 		defer tx.Rollback()
 
@@ -478,7 +592,7 @@ func TestTransactionListStores(t *testing.T, builder Builder) {
 
 func storeBuilder(t testing.TB, builder Builder) (engine.Store, func()) {
 	ng, cleanup := builder()
-	tx, err := ng.Begin(true)
+	tx, err := ng.Begin(context.Background(), true)
 	require.NoError(t, err)
 	err = tx.CreateStore("test")
 	require.NoError(t, err)
@@ -694,6 +808,194 @@ func TestStoreDescendLessOrEqual(t *testing.T, builder Builder) {
 	})
 }
 
+// iterate moves the iterator forward or backward until the end of the iteration,
+// and returns the first byte of every key.
+func iterate(t testing.TB, it engine.Iterator, reverse bool) []int {
+	var keys []int
+	for it.Valid() {
+		v, err := it.Value()
+		require.NoError(t, err)
+		require.Equal(t, it.Key(), v)
+		keys = append(keys, int(it.Key()[0]))
+
+		if reverse {
+			it.Prev()
+		} else {
+			it.Next()
+		}
+	}
+	require.NoError(t, it.Err())
+
+	return keys
+}
+
+// TestStoreIterator verifies Iterator behaviour.
+func TestStoreIterator(t *testing.T, builder Builder) {
+	t.Run("Should not fail with no documents", func(t *testing.T) {
+		st, cleanup := storeBuilder(t, builder)
+		defer cleanup()
+
+		it := st.Iterator(nil)
+		defer it.Close()
+
+		it.Seek(nil)
+		require.False(t, it.Valid())
+		it.SeekReverse(nil)
+		require.False(t, it.Valid())
+		require.NoError(t, it.Err())
+	})
+
+	tests := []struct {
+		name         string
+		pivot, upper []byte
+		reverse      bool
+		expected     []int
+	}{
+		{"Seek/No pivot", nil, nil, false, []int{2, 4, 6, 8, 10, 12, 14, 16, 18, 20}},
+		{"Seek/Pivot", []byte{6}, nil, false, []int{6, 8, 10, 12, 14, 16, 18, 20}},
+		{"Seek/Pivot not found", []byte{5}, nil, false, []int{6, 8, 10, 12, 14, 16, 18, 20}},
+		{"Seek/Pivot after the last key", []byte{21}, nil, false, nil},
+		{"Seek/Upper bound", nil, []byte{10}, false, []int{2, 4, 6, 8}},
+		{"Seek/Upper bound not found", []byte{3}, []byte{9}, false, []int{4, 6, 8}},
+		{"Seek/Pivot equal to the upper bound", []byte{10}, []byte{10}, false, nil},
+		{"SeekReverse/No pivot", nil, nil, true, []int{20, 18, 16, 14, 12, 10, 8, 6, 4, 2}},
+		{"SeekReverse/Pivot", []byte{6}, nil, true, []int{6, 4, 2}},
+		{"SeekReverse/Pivot not found", []byte{5}, nil, true, []int{4, 2}},
+		{"SeekReverse/Pivot before the first key", []byte{1}, nil, true, nil},
+		{"SeekReverse/Upper bound", nil, []byte{10}, true, []int{8, 6, 4, 2}},
+		{"SeekReverse/Upper bound not found", nil, []byte{9}, true, []int{8, 6, 4, 2}},
+		{"SeekReverse/Pivot equal to the upper bound", []byte{10}, []byte{10}, true, []int{8, 6, 4, 2}},
+		{"SeekReverse/Pivot after the upper bound", []byte{15}, []byte{10}, true, []int{8, 6, 4, 2}},
+		{"SeekReverse/Pivot before the upper bound", []byte{7}, []byte{10}, true, []int{6, 4, 2}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			st, cleanup := storeBuilder(t, builder)
+			defer cleanup()
+
+			for i := 2; i <= 20; i += 2 {
+				err := st.Put([]byte{uint8(i)}, []byte{uint8(i)})
+				require.NoError(t, err)
+			}
+
+			it := st.Iterator(&engine.IteratorOptions{UpperBound: test.upper})
+			defer it.Close()
+
+			if test.reverse {
+				it.SeekReverse(test.pivot)
+			} else {
+				it.Seek(test.pivot)
+			}
+			require.Equal(t, test.expected, iterate(t, it, test.reverse))
+		})
+	}
+
+	t.Run("Should switch directions", func(t *testing.T) {
+		st, cleanup := storeBuilder(t, builder)
+		defer cleanup()
+
+		for i := 2; i <= 20; i += 2 {
+			err := st.Put([]byte{uint8(i)}, []byte{uint8(i)})
+			require.NoError(t, err)
+		}
+
+		it := st.Iterator(&engine.IteratorOptions{UpperBound: []byte{14}})
+		defer it.Close()
+
+		moves := []struct {
+			move     func()
+			expected byte
+		}{
+			{func() { it.Seek([]byte{5}) }, 6},
+			{it.Next, 8},
+			{it.Prev, 6},
+			{it.Prev, 4},
+			{it.Next, 6},
+			{func() { it.SeekReverse([]byte{11}) }, 10},
+			{it.Prev, 8},
+			{it.Next, 10},
+			{it.Next, 12},
+			{it.Prev, 10},
+		}
+
+		for _, m := range moves {
+			m.move()
+			require.True(t, it.Valid())
+			require.Equal(t, []byte{m.expected}, it.Key())
+		}
+
+		it.Next()
+		it.Next()
+		require.False(t, it.Valid())
+		require.NoError(t, it.Err())
+	})
+
+	t.Run("Should only iterate over the keys of the store", func(t *testing.T) {
+		ng, cleanup := builder()
+		defer cleanup()
+
+		tx, err := ng.Begin(context.Background(), true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		for i, name := range []string{"te", "test", "test1", "tesu", "test "} {
+			err = tx.CreateStore(name)
+			require.NoError(t, err)
+			st, err := tx.GetStore(name)
+			require.NoError(t, err)
+			err = st.Put([]byte{uint8(i)}, []byte{uint8(i)})
+			require.NoError(t, err)
+		}
+
+		st, err := tx.GetStore("test")
+		require.NoError(t, err)
+
+		it := st.Iterator(nil)
+		defer it.Close()
+
+		it.Seek(nil)
+		require.Equal(t, []int{1}, iterate(t, it, false))
+		it.SeekReverse(nil)
+		require.Equal(t, []int{1}, iterate(t, it, true))
+	})
+
+	t.Run("Should stop once the context is canceled", func(t *testing.T) {
+		ng, cleanup := builder()
+		defer cleanup()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		tx, err := ng.Begin(ctx, true)
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		err = tx.CreateStore("test")
+		require.NoError(t, err)
+		st, err := tx.GetStore("test")
+		require.NoError(t, err)
+
+		for i := 1; i <= 10; i++ {
+			err := st.Put([]byte{uint8(i)}, []byte{uint8(i)})
+			require.NoError(t, err)
+		}
+
+		it := st.Iterator(nil)
+		defer it.Close()
+
+		var count int
+		for it.Seek(nil); it.Valid(); it.Next() {
+			count++
+			if count == 3 {
+				cancel()
+			}
+		}
+		require.Equal(t, 3, count)
+		require.Equal(t, context.Canceled, it.Err())
+	})
+}
+
 // TestStorePut verifies Put behaviour.
 func TestStorePut(t *testing.T, builder Builder) {
 	t.Run("Should insert data", func(t *testing.T) {
@@ -1,18 +1,256 @@
+// Package memoryengine implements an in-memory engine.
+// Each store is a persistent ordered tree, and transactions work on snapshots of these trees:
+// read-only transactions read the trees of the last committed transaction, while read/write
+// transactions copy the parts of the trees they modify, which become visible to the other
+// transactions once they are committed.
 package memoryengine
 
 import (
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
 	"github.com/asdine/genji/engine"
-	"github.com/asdine/genji/engine/badgerengine"
-	"github.com/dgraph-io/badger/v2"
 )
 
-// NewEngine creates a badger engine which stores data in memory.
-func NewEngine() engine.Engine {
-	opts := badger.DefaultOptions("").WithInMemory(true).WithLogger(nil)
-	ng, err := badgerengine.NewEngine(opts)
-	if err != nil {
-		panic(err)
+var (
+	errEngineClosed      = errors.New("engine closed")
+	errTransactionClosed = errors.New("transaction closed")
+)
+
+// Engine is an in-memory engine. It supports any number of concurrent read-only transactions,
+// but only one read/write transaction at a time: Begin blocks until the current
+// read/write transaction, if any, is committed or rolled back.
+type Engine struct {
+	// writer is a semaphore held by the read/write transaction currently opened.
+	writer chan struct{}
+
+	// mu protects the fields below.
+	mu     sync.RWMutex
+	closed bool
+	// root of every store, as of the last committed transaction.
+	// The map and the trees are never modified once committed.
+	stores map[string]*node
+
+	// last version used by a read/write transaction.
+	version uint64
+}
+
+// NewEngine creates an in-memory engine.
+func NewEngine() *Engine {
+	return &Engine{
+		writer: make(chan struct{}, 1),
+		stores: make(map[string]*node),
+	}
+}
+
+// nextVersion returns a version that has never been used.
+func (e *Engine) nextVersion() uint64 {
+	return atomic.AddUint64(&e.version, 1)
+}
+
+// Begin creates a transaction working on a snapshot of the stores.
+// If writable is true, it blocks until the current read/write transaction is closed
+// or the context is canceled.
+// The context is checked by every operation of the transaction and its stores.
+func (e *Engine) Begin(ctx context.Context, writable bool) (engine.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if writable {
+		select {
+		case e.writer <- struct{}{}:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	e.mu.RLock()
+	closed, stores := e.closed, e.stores
+	e.mu.RUnlock()
+
+	if closed {
+		if writable {
+			<-e.writer
+		}
+		return nil, errEngineClosed
+	}
+
+	tx := Transaction{
+		ctx:      ctx,
+		ng:       e,
+		writable: writable,
+		stores:   stores,
+	}
+
+	if writable {
+		tx.version = e.nextVersion()
+
+		// the committed map is never modified, the transaction works on a copy.
+		tx.stores = make(map[string]*node, len(stores))
+		for name, root := range stores {
+			tx.stores[name] = root
+		}
+	}
+
+	return &tx, nil
+}
+
+// Close the engine after the current read/write transaction, if any, is closed.
+// The stores are released once the read-only transactions are closed.
+func (e *Engine) Close() error {
+	e.writer <- struct{}{}
+	defer func() { <-e.writer }()
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.closed = true
+	e.stores = nil
+	return nil
+}
+
+// A Transaction works on a snapshot of the stores taken when it began.
+type Transaction struct {
+	ctx      context.Context
+	ng       *Engine
+	writable bool
+	done     bool
+	// version of the trees modified by the transaction. Nodes with the same version
+	// were created by the transaction and can be modified in place.
+	version uint64
+	// dirty reports whether nodes were created using the current version.
+	dirty  bool
+	stores map[string]*node
+}
+
+// Rollback the transaction, discarding its snapshot. Can be used safely after commit.
+func (t *Transaction) Rollback() error {
+	if t.done {
+		return nil
+	}
+
+	t.done = true
+	if t.writable {
+		<-t.ng.writer
+	}
+
+	return nil
+}
+
+// Commit the transaction by replacing the stores of the engine by the ones of the transaction.
+// If the context is canceled, the transaction is rolled back instead.
+func (t *Transaction) Commit() error {
+	if err := t.check(); err != nil {
+		return err
+	}
+
+	if !t.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	if err := t.ctx.Err(); err != nil {
+		_ = t.Rollback()
+		return err
+	}
+
+	t.done = true
+
+	t.ng.mu.Lock()
+	t.ng.stores = t.stores
+	t.ng.mu.Unlock()
+
+	<-t.ng.writer
+	return nil
+}
+
+// check returns an error if the transaction is closed or if its context is canceled.
+func (t *Transaction) check() error {
+	if t.done {
+		return errTransactionClosed
+	}
+
+	return t.ctx.Err()
+}
+
+// freeze prevents the nodes created so far by the transaction from being modified in place,
+// by using a new version for the next modifications.
+// It is called before iterating over a store, so the iteration isn't affected by
+// the modifications made while iterating.
+func (t *Transaction) freeze() {
+	if t.dirty {
+		t.version = t.ng.nextVersion()
+		t.dirty = false
+	}
+}
+
+// GetStore returns a store by name.
+func (t *Transaction) GetStore(name string) (engine.Store, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	if _, ok := t.stores[name]; !ok {
+		return nil, engine.ErrStoreNotFound
+	}
+
+	return &Store{tx: t, name: name}, nil
+}
+
+// CreateStore creates an empty store.
+// If the store already exists, returns engine.ErrStoreAlreadyExists.
+func (t *Transaction) CreateStore(name string) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+
+	if !t.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	if _, ok := t.stores[name]; ok {
+		return engine.ErrStoreAlreadyExists
+	}
+
+	t.stores[name] = nil
+	return nil
+}
+
+// DropStore deletes the store and all its key value pairs.
+func (t *Transaction) DropStore(name string) error {
+	if err := t.check(); err != nil {
+		return err
+	}
+
+	if !t.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	if _, ok := t.stores[name]; !ok {
+		return engine.ErrStoreNotFound
+	}
+
+	delete(t.stores, name)
+	return nil
+}
+
+// ListStores returns a list of all the store names, lexicographically sorted.
+func (t *Transaction) ListStores(prefix string) ([]string, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	names := []string{}
+	for name := range t.stores {
+		if strings.HasPrefix(name, prefix) {
+			names = append(names, name)
+		}
 	}
+	sort.Strings(names)
 
-	return ng
+	return names, nil
 }
@@ -0,0 +1,204 @@
+package memoryengine_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+
+	"github.com/asdine/genji/engine"
+	"github.com/asdine/genji/engine/enginetest"
+	"github.com/asdine/genji/engine/memoryengine"
+	"github.com/stretchr/testify/require"
+)
+
+func builder() (engine.Engine, func()) {
+	ng := memoryengine.NewEngine()
+	return ng, func() { ng.Close() }
+}
+
+func TestMemoryEngine(t *testing.T) {
+	enginetest.TestSuite(t, builder)
+}
+
+func BenchmarkMemoryEngineStorePut(b *testing.B) {
+	enginetest.BenchmarkStorePut(b, builder)
+}
+
+func BenchmarkMemoryEngineTableScan(b *testing.B) {
+	enginetest.BenchmarkStoreScan(b, builder)
+}
+
+func TestTransactionSnapshot(t *testing.T) {
+	ng := memoryengine.NewEngine()
+	defer ng.Close()
+
+	tx, err := ng.Begin(context.Background(), true)
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateStore("test"))
+	st, err := tx.GetStore("test")
+	require.NoError(t, err)
+	require.NoError(t, st.Put([]byte("a"), []byte("A")))
+	require.NoError(t, tx.Commit())
+
+	// the read-only transaction sees the stores as they were when it began.
+	rtx, err := ng.Begin(context.Background(), false)
+	require.NoError(t, err)
+	defer rtx.Rollback()
+	rst, err := rtx.GetStore("test")
+	require.NoError(t, err)
+
+	tx, err = ng.Begin(context.Background(), true)
+	require.NoError(t, err)
+	st, err = tx.GetStore("test")
+	require.NoError(t, err)
+	require.NoError(t, st.Put([]byte("a"), []byte("B")))
+	require.NoError(t, st.Put([]byte("b"), []byte("B")))
+	require.NoError(t, tx.CreateStore("other"))
+	require.NoError(t, tx.Commit())
+
+	v, err := rst.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("A"), v)
+	_, err = rst.Get([]byte("b"))
+	require.Equal(t, engine.ErrKeyNotFound, err)
+	_, err = rtx.GetStore("other")
+	require.Equal(t, engine.ErrStoreNotFound, err)
+
+	rtx, err = ng.Begin(context.Background(), false)
+	require.NoError(t, err)
+	defer rtx.Rollback()
+	rst, err = rtx.GetStore("test")
+	require.NoError(t, err)
+	v, err = rst.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("B"), v)
+}
+
+func TestStoreModifiedWhileIterating(t *testing.T) {
+	ng := memoryengine.NewEngine()
+	defer ng.Close()
+
+	tx, err := ng.Begin(context.Background(), true)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	require.NoError(t, tx.CreateStore("test"))
+	st, err := tx.GetStore("test")
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		require.NoError(t, st.Put([]byte(fmt.Sprintf("%03d", i)), nil))
+	}
+
+	// deleting and inserting keys doesn't affect the iteration.
+	var keys []string
+	err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		keys = append(keys, string(k))
+		if err := st.Delete(k); err != nil {
+			return err
+		}
+		return st.Put(append([]byte("x"), k...), nil)
+	})
+	require.NoError(t, err)
+	require.Len(t, keys, 100)
+	require.Equal(t, "000", keys[0])
+	require.Equal(t, "099", keys[99])
+
+	var n int
+	err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		require.Equal(t, byte('x'), k[0])
+		n++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+}
+
+func TestStoreRandomOperations(t *testing.T) {
+	ng := memoryengine.NewEngine()
+	defer ng.Close()
+
+	rnd := rand.New(rand.NewSource(42))
+	expected := make(map[string]string)
+
+	for i := 0; i < 20; i++ {
+		tx, err := ng.Begin(context.Background(), true)
+		require.NoError(t, err)
+		if i == 0 {
+			require.NoError(t, tx.CreateStore("test"))
+		}
+		st, err := tx.GetStore("test")
+		require.NoError(t, err)
+
+		// odd transactions are rolled back and must not change the content of the store.
+		changes := make(map[string]string)
+		for j := 0; j < 200; j++ {
+			k := fmt.Sprintf("%04d", rnd.Intn(1000))
+			if rnd.Intn(3) == 0 {
+				err := st.Delete([]byte(k))
+				_, ok := changes[k]
+				if !ok {
+					_, ok = expected[k]
+				}
+				if ok && changes[k] != "-" {
+					require.NoError(t, err)
+				} else {
+					require.Equal(t, engine.ErrKeyNotFound, err)
+				}
+				changes[k] = "-"
+				continue
+			}
+
+			v := fmt.Sprint(rnd.Int())
+			require.NoError(t, st.Put([]byte(k), []byte(v)))
+			changes[k] = v
+		}
+
+		if i%2 == 1 {
+			require.NoError(t, tx.Rollback())
+			continue
+		}
+		require.NoError(t, tx.Commit())
+
+		for k, v := range changes {
+			if v == "-" {
+				delete(expected, k)
+			} else {
+				expected[k] = v
+			}
+		}
+	}
+
+	var keys []string
+	for k := range expected {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	tx, err := ng.Begin(context.Background(), false)
+	require.NoError(t, err)
+	defer tx.Rollback()
+	st, err := tx.GetStore("test")
+	require.NoError(t, err)
+
+	var i int
+	err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
+		require.Equal(t, keys[i], string(k))
+		require.Equal(t, expected[keys[i]], string(v))
+		i++
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, len(keys), i)
+
+	i = len(keys) - 1
+	err = st.DescendLessOrEqual(nil, func(k, v []byte) error {
+		require.True(t, bytes.Equal([]byte(keys[i]), k))
+		i--
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, -1, i)
+}
@@ -0,0 +1,38 @@
+package memoryengine_test
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/engine/memoryengine"
+)
+
+func Example() {
+	db, err := genji.New(memoryengine.NewEngine())
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE foo; INSERT INTO foo (a, b) VALUES (1, 'bar')")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	d, err := db.QueryDocument("SELECT a, b FROM foo")
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = document.ToJSON(os.Stdout, d)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Println()
+
+	// Output:
+	// {"a":1,"b":"bar"}
+}
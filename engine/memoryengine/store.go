@@ -0,0 +1,250 @@
+package memoryengine
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/asdine/genji/engine"
+)
+
+// A Store is an implementation of the engine.Store interface using a persistent tree.
+type Store struct {
+	tx   *Transaction
+	name string
+}
+
+// root returns the root of the tree of the store, as seen by the transaction.
+func (s *Store) root() (*node, error) {
+	if err := s.tx.check(); err != nil {
+		return nil, err
+	}
+
+	root, ok := s.tx.stores[s.name]
+	if !ok {
+		return nil, engine.ErrStoreNotFound
+	}
+
+	return root, nil
+}
+
+// writableRoot returns the root of the tree of the store if the transaction is writable.
+func (s *Store) writableRoot() (*node, error) {
+	if !s.tx.writable {
+		return nil, engine.ErrTransactionReadOnly
+	}
+
+	return s.root()
+}
+
+// Put stores a key value pair. If it already exists, it overrides it.
+// The key and the value are copied.
+func (s *Store) Put(k, v []byte) error {
+	root, err := s.writableRoot()
+	if err != nil {
+		return err
+	}
+
+	if len(k) == 0 {
+		return errors.New("empty keys are not supported")
+	}
+
+	k = append([]byte(nil), k...)
+	v = append([]byte{}, v...)
+	s.tx.stores[s.name] = root.insert(k, v, s.tx.version)
+	s.tx.dirty = true
+	return nil
+}
+
+// Get returns a value associated with the given key. If not found, returns engine.ErrKeyNotFound.
+// The returned value must not be modified.
+func (s *Store) Get(k []byte) ([]byte, error) {
+	root, err := s.root()
+	if err != nil {
+		return nil, err
+	}
+
+	n := root.get(k)
+	if n == nil {
+		return nil, engine.ErrKeyNotFound
+	}
+
+	return n.value, nil
+}
+
+// Delete a record by key. If not found, returns engine.ErrKeyNotFound.
+func (s *Store) Delete(k []byte) error {
+	root, err := s.writableRoot()
+	if err != nil {
+		return err
+	}
+
+	root, ok := root.delete(k, s.tx.version)
+	if !ok {
+		return engine.ErrKeyNotFound
+	}
+
+	s.tx.stores[s.name] = root
+	s.tx.dirty = true
+	return nil
+}
+
+// Truncate deletes all the records of the store.
+func (s *Store) Truncate() error {
+	_, err := s.writableRoot()
+	if err != nil {
+		return err
+	}
+
+	s.tx.stores[s.name] = nil
+	return nil
+}
+
+// AscendGreaterOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in increasing order and calls the given function for each pair.
+// If the given function returns an error, the iteration stops and returns that error.
+// If the pivot is nil, starts from the beginning.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
+// The iteration isn't affected by the modifications made by fn.
+func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) error {
+	root, err := s.root()
+	if err != nil {
+		return err
+	}
+
+	if len(pivot) == 0 {
+		pivot = nil
+	}
+
+	s.tx.freeze()
+	return root.ascend(pivot, func(k, v []byte) error {
+		if err := s.tx.ctx.Err(); err != nil {
+			return err
+		}
+
+		return fn(k, v)
+	})
+}
+
+// DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
+// If the given function returns an error, the iteration stops and returns that error.
+// If the pivot is nil, starts from the end.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
+// The iteration isn't affected by the modifications made by fn.
+func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) error {
+	root, err := s.root()
+	if err != nil {
+		return err
+	}
+
+	if len(pivot) == 0 {
+		pivot = nil
+	}
+
+	s.tx.freeze()
+	return root.descend(pivot, func(k, v []byte) error {
+		if err := s.tx.ctx.Err(); err != nil {
+			return err
+		}
+
+		return fn(k, v)
+	})
+}
+
+// Iterator creates an iterator over the key value pairs of the store.
+// The iterator isn't affected by the modifications made by the transaction after its creation.
+func (s *Store) Iterator(opts *engine.IteratorOptions) engine.Iterator {
+	var it iterator
+
+	it.tx = s.tx
+	if opts != nil {
+		it.upper = opts.UpperBound
+	}
+
+	it.root, it.err = s.root()
+	if it.err == nil {
+		s.tx.freeze()
+	}
+
+	return &it
+}
+
+type iterator struct {
+	cursor
+
+	tx    *Transaction
+	upper []byte
+	err   error
+}
+
+func (it *iterator) Seek(pivot []byte) {
+	it.cursor.seekFirst(func(k []byte) bool {
+		return pivot == nil || bytes.Compare(k, pivot) >= 0
+	})
+	it.check()
+}
+
+func (it *iterator) SeekReverse(pivot []byte) {
+	switch {
+	case it.upper != nil && (pivot == nil || bytes.Compare(pivot, it.upper) >= 0):
+		it.cursor.seekLast(func(k []byte) bool {
+			return bytes.Compare(k, it.upper) < 0
+		})
+	case pivot == nil:
+		it.cursor.seekLast(func(k []byte) bool {
+			return true
+		})
+	default:
+		it.cursor.seekLast(func(k []byte) bool {
+			return bytes.Compare(k, pivot) <= 0
+		})
+	}
+	it.check()
+}
+
+func (it *iterator) Next() {
+	it.cursor.next()
+	it.check()
+}
+
+func (it *iterator) Prev() {
+	it.cursor.prev()
+	it.check()
+}
+
+// check stops the iteration if the transaction is closed or its context canceled,
+// or if the cursor is beyond the upper bound.
+func (it *iterator) check() {
+	if it.err != nil {
+		return
+	}
+
+	if err := it.tx.check(); err != nil {
+		it.err = err
+		return
+	}
+
+	n := it.cursor.current()
+	if n != nil && it.upper != nil && bytes.Compare(n.key, it.upper) >= 0 {
+		it.cursor.path = it.cursor.path[:0]
+	}
+}
+
+func (it *iterator) Valid() bool {
+	return it.err == nil && it.cursor.current() != nil
+}
+
+func (it *iterator) Key() []byte {
+	return it.cursor.current().key
+}
+
+// Value returns the value of the current pair, which must not be modified.
+func (it *iterator) Value() ([]byte, error) {
+	return it.cursor.current().value, nil
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	return nil
+}
@@ -0,0 +1,303 @@
+package memoryengine
+
+import (
+	"bytes"
+)
+
+// node is a node of a persistent AVL tree, ordered by key.
+// Trees are modified using path copying: a node is only modified in place if it was
+// created by the same version of the tree, otherwise it is copied, along with its ancestors.
+// This allows transactions to share the nodes of the last committed version
+// of a tree, which are never modified.
+type node struct {
+	key, value  []byte
+	left, right *node
+	height      int
+	// version of the tree that created the node.
+	version uint64
+}
+
+func (n *node) getHeight() int {
+	if n == nil {
+		return 0
+	}
+
+	return n.height
+}
+
+// get returns the node associated with k, or nil.
+func (n *node) get(k []byte) *node {
+	for n != nil {
+		switch c := bytes.Compare(k, n.key); {
+		case c < 0:
+			n = n.left
+		case c > 0:
+			n = n.right
+		default:
+			return n
+		}
+	}
+
+	return nil
+}
+
+// mutable returns n if it belongs to the given version, or a copy of n otherwise.
+func (n *node) mutable(version uint64) *node {
+	if n.version == version {
+		return n
+	}
+
+	cp := *n
+	cp.version = version
+	return &cp
+}
+
+// insert k and v in the tree and returns its new root.
+func (n *node) insert(k, v []byte, version uint64) *node {
+	if n == nil {
+		return &node{key: k, value: v, height: 1, version: version}
+	}
+
+	n = n.mutable(version)
+	switch c := bytes.Compare(k, n.key); {
+	case c < 0:
+		n.left = n.left.insert(k, v, version)
+	case c > 0:
+		n.right = n.right.insert(k, v, version)
+	default:
+		n.value = v
+		return n
+	}
+
+	return n.rebalance(version)
+}
+
+// delete k from the tree and returns its new root. It returns false if k was not found.
+func (n *node) delete(k []byte, version uint64) (*node, bool) {
+	if n == nil {
+		return nil, false
+	}
+
+	switch c := bytes.Compare(k, n.key); {
+	case c < 0:
+		left, ok := n.left.delete(k, version)
+		if !ok {
+			return n, false
+		}
+		n = n.mutable(version)
+		n.left = left
+	case c > 0:
+		right, ok := n.right.delete(k, version)
+		if !ok {
+			return n, false
+		}
+		n = n.mutable(version)
+		n.right = right
+	default:
+		if n.left == nil {
+			return n.right, true
+		}
+		if n.right == nil {
+			return n.left, true
+		}
+
+		// replace the node with the smallest node of its right subtree.
+		min := n.right
+		for min.left != nil {
+			min = min.left
+		}
+
+		right, _ := n.right.delete(min.key, version)
+		n = n.mutable(version)
+		n.key, n.value = min.key, min.value
+		n.right = right
+	}
+
+	return n.rebalance(version), true
+}
+
+// rebalance updates the height of n, which must belong to the given version,
+// and rotates it if its subtrees are unbalanced. It returns the new root of the subtree.
+func (n *node) rebalance(version uint64) *node {
+	n.updateHeight()
+
+	switch balance := n.left.getHeight() - n.right.getHeight(); {
+	case balance > 1:
+		if n.left.left.getHeight() < n.left.right.getHeight() {
+			n.left = n.left.mutable(version).rotateLeft(version)
+		}
+		return n.rotateRight(version)
+	case balance < -1:
+		if n.right.right.getHeight() < n.right.left.getHeight() {
+			n.right = n.right.mutable(version).rotateRight(version)
+		}
+		return n.rotateLeft(version)
+	}
+
+	return n
+}
+
+func (n *node) updateHeight() {
+	n.height = n.left.getHeight()
+	if h := n.right.getHeight(); h > n.height {
+		n.height = h
+	}
+	n.height++
+}
+
+func (n *node) rotateLeft(version uint64) *node {
+	r := n.right.mutable(version)
+	n.right = r.left
+	r.left = n
+	n.updateHeight()
+	r.updateHeight()
+	return r
+}
+
+func (n *node) rotateRight(version uint64) *node {
+	l := n.left.mutable(version)
+	n.left = l.right
+	l.right = n
+	n.updateHeight()
+	l.updateHeight()
+	return l
+}
+
+// ascend calls fn for every node whose key is greater than or equal to the pivot, in increasing order.
+// If the pivot is nil, it calls fn for every node.
+func (n *node) ascend(pivot []byte, fn func(k, v []byte) error) error {
+	if n == nil {
+		return nil
+	}
+
+	if pivot == nil || bytes.Compare(n.key, pivot) >= 0 {
+		err := n.left.ascend(pivot, fn)
+		if err != nil {
+			return err
+		}
+
+		err = fn(n.key, n.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.right.ascend(pivot, fn)
+}
+
+// descend calls fn for every node whose key is less than or equal to the pivot, in decreasing order.
+// If the pivot is nil, it calls fn for every node.
+func (n *node) descend(pivot []byte, fn func(k, v []byte) error) error {
+	if n == nil {
+		return nil
+	}
+
+	if pivot == nil || bytes.Compare(n.key, pivot) <= 0 {
+		err := n.right.descend(pivot, fn)
+		if err != nil {
+			return err
+		}
+
+		err = fn(n.key, n.value)
+		if err != nil {
+			return err
+		}
+	}
+
+	return n.left.descend(pivot, fn)
+}
+
+// cursor moves through the nodes of a tree in both directions.
+// It holds the path from the root of the tree to the current node,
+// which is empty if the cursor isn't positioned.
+type cursor struct {
+	root *node
+	path []*node
+}
+
+// current returns the node on which the cursor is positioned, or nil.
+func (c *cursor) current() *node {
+	if len(c.path) == 0 {
+		return nil
+	}
+
+	return c.path[len(c.path)-1]
+}
+
+// seekFirst moves the cursor to the smallest key matching the given function,
+// which must return true for all the keys following a matching key.
+func (c *cursor) seekFirst(match func(k []byte) bool) {
+	c.path = c.path[:0]
+	last := -1
+	for n := c.root; n != nil; {
+		c.path = append(c.path, n)
+		if match(n.key) {
+			last = len(c.path) - 1
+			n = n.left
+		} else {
+			n = n.right
+		}
+	}
+
+	c.path = c.path[:last+1]
+}
+
+// seekLast moves the cursor to the largest key matching the given function,
+// which must return true for all the keys preceding a matching key.
+func (c *cursor) seekLast(match func(k []byte) bool) {
+	c.path = c.path[:0]
+	last := -1
+	for n := c.root; n != nil; {
+		c.path = append(c.path, n)
+		if match(n.key) {
+			last = len(c.path) - 1
+			n = n.right
+		} else {
+			n = n.left
+		}
+	}
+
+	c.path = c.path[:last+1]
+}
+
+// next moves the cursor to the following key.
+func (c *cursor) next() {
+	n := c.current()
+	if n.right != nil {
+		for n = n.right; n != nil; n = n.left {
+			c.path = append(c.path, n)
+		}
+		return
+	}
+
+	// go up until coming from a left child.
+	for {
+		c.path = c.path[:len(c.path)-1]
+		parent := c.current()
+		if parent == nil || parent.left == n {
+			return
+		}
+		n = parent
+	}
+}
+
+// prev moves the cursor to the preceding key.
+func (c *cursor) prev() {
+	n := c.current()
+	if n.left != nil {
+		for n = n.left; n != nil; n = n.right {
+			c.path = append(c.path, n)
+		}
+		return
+	}
+
+	// go up until coming from a right child.
+	for {
+		c.path = c.path[:len(c.path)-1]
+		parent := c.current()
+		if parent == nil || parent.right == n {
+			return
+		}
+		n = parent
+	}
+}
@@ -0,0 +1,284 @@
+// Package pebbleengine implements a Pebble engine.
+// Pebble is a key value store based on a log-structured merge tree.
+package pebbleengine
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+
+	"github.com/asdine/genji/engine"
+	"github.com/cockroachdb/pebble"
+)
+
+const (
+	separator   byte = 0x1F
+	storeKey         = "__genji.store"
+	storePrefix      = 's'
+)
+
+var errTransactionClosed = errors.New("transaction closed")
+
+// Engine represents a Pebble engine.
+// Read-only transactions read a snapshot of the database, and read/write transactions
+// write to a batch, which is applied atomically on commit. Since Pebble doesn't detect
+// conflicting writes, only one read/write transaction can be opened at a time:
+// Begin blocks until the current one is committed or rolled back.
+type Engine struct {
+	DB *pebble.DB
+
+	// writer is a semaphore held by the read/write transaction currently opened.
+	writer chan struct{}
+}
+
+// NewEngine creates a Pebble engine. It takes the same argument as Pebble's Open function.
+func NewEngine(path string, opts *pebble.Options) (*Engine, error) {
+	db, err := pebble.Open(path, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Engine{
+		DB:     db,
+		writer: make(chan struct{}, 1),
+	}, nil
+}
+
+// Begin creates a transaction. Read-only transactions use a Pebble snapshot and
+// read/write transactions use an indexed batch.
+// If writable is true, it blocks until the current read/write transaction is closed
+// or the context is canceled.
+// The context is checked by every operation of the transaction and its stores.
+func (e *Engine) Begin(ctx context.Context, writable bool) (engine.Transaction, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if !writable {
+		sn := e.DB.NewSnapshot()
+
+		return &Transaction{
+			ctx:    ctx,
+			reader: sn,
+			closer: sn,
+		}, nil
+	}
+
+	select {
+	case e.writer <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	b := e.DB.NewIndexedBatch()
+
+	return &Transaction{
+		ctx:      ctx,
+		reader:   b,
+		closer:   b,
+		batch:    b,
+		writable: true,
+		writer:   e.writer,
+	}, nil
+}
+
+// Close the engine after the current read/write transaction, if any, is closed.
+func (e *Engine) Close() error {
+	e.writer <- struct{}{}
+	defer func() { <-e.writer }()
+
+	return e.DB.Close()
+}
+
+// A Transaction reads a snapshot of the database, or reads and writes a batch.
+type Transaction struct {
+	ctx      context.Context
+	reader   pebble.Reader
+	closer   io.Closer
+	batch    *pebble.Batch
+	writable bool
+	writer   chan struct{}
+	closed   bool
+}
+
+// check returns an error if the transaction is closed or if its context is canceled.
+func (t *Transaction) check() error {
+	if t.closed {
+		return errTransactionClosed
+	}
+
+	return t.ctx.Err()
+}
+
+// close releases the resources of the transaction.
+func (t *Transaction) close() error {
+	t.closed = true
+	if t.writable {
+		defer func() { <-t.writer }()
+	}
+
+	return t.closer.Close()
+}
+
+// Rollback the transaction, discarding the batch. Can be used safely after commit.
+func (t *Transaction) Rollback() error {
+	if t.closed {
+		return nil
+	}
+
+	return t.close()
+}
+
+// Commit the transaction by applying its batch atomically.
+// If the context is canceled, the transaction is rolled back instead.
+func (t *Transaction) Commit() error {
+	if t.closed {
+		return errTransactionClosed
+	}
+
+	if !t.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	if err := t.ctx.Err(); err != nil {
+		_ = t.close()
+		return err
+	}
+
+	err := t.batch.Commit(pebble.Sync)
+	if cerr := t.close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// get returns a copy of the value associated with the given key.
+// If not found, it returns pebble.ErrNotFound.
+func (t *Transaction) get(k []byte) ([]byte, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	v, closer, err := t.reader.Get(k)
+	if err != nil {
+		return nil, err
+	}
+	defer closer.Close()
+
+	return append([]byte{}, v...), nil
+}
+
+func buildStoreKey(name string) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(storeKey) + 1 + len(name))
+	buf.WriteString(storeKey)
+	buf.WriteByte(separator)
+	buf.WriteString(name)
+
+	return buf.Bytes()
+}
+
+func buildStorePrefixKey(name string) []byte {
+	prefix := make([]byte, 0, len(name)+3)
+	prefix = append(prefix, storePrefix)
+	prefix = append(prefix, separator)
+	prefix = append(prefix, name...)
+	prefix = append(prefix, separator)
+
+	return prefix
+}
+
+// prefixUpperBound returns the smallest key greater than all the keys starting with prefix.
+func prefixUpperBound(prefix []byte) []byte {
+	end := append([]byte(nil), prefix...)
+	for i := len(end) - 1; i >= 0; i-- {
+		end[i]++
+		if end[i] != 0 {
+			return end[:i+1]
+		}
+	}
+
+	return nil
+}
+
+// GetStore returns a store by name.
+func (t *Transaction) GetStore(name string) (engine.Store, error) {
+	_, err := t.get(buildStoreKey(name))
+	if err == pebble.ErrNotFound {
+		return nil, engine.ErrStoreNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		tx:     t,
+		prefix: buildStorePrefixKey(name),
+		name:   name,
+	}, nil
+}
+
+// CreateStore creates a store.
+// If the store already exists, returns engine.ErrStoreAlreadyExists.
+func (t *Transaction) CreateStore(name string) error {
+	if !t.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	key := buildStoreKey(name)
+	_, err := t.get(key)
+	if err == nil {
+		return engine.ErrStoreAlreadyExists
+	}
+	if err != pebble.ErrNotFound {
+		return err
+	}
+
+	return t.batch.Set(key, nil, nil)
+}
+
+// DropStore deletes the store and all its keys.
+func (t *Transaction) DropStore(name string) error {
+	if !t.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	s, err := t.GetStore(name)
+	if err != nil {
+		return err
+	}
+
+	err = s.Truncate()
+	if err != nil {
+		return err
+	}
+
+	return t.batch.Delete(buildStoreKey(name), nil)
+}
+
+// ListStores returns a list of all the store names, lexicographically sorted.
+func (t *Transaction) ListStores(prefix string) ([]string, error) {
+	if err := t.check(); err != nil {
+		return nil, err
+	}
+
+	p := buildStoreKey(prefix)
+	it := t.reader.NewIter(&pebble.IterOptions{
+		LowerBound: p,
+		UpperBound: prefixUpperBound(p),
+	})
+
+	names := []string{}
+	for it.First(); it.Valid(); it.Next() {
+		names = append(names, string(it.Key()[len(p)-len(prefix):]))
+	}
+
+	err := it.Error()
+	if cerr := it.Close(); err == nil {
+		err = cerr
+	}
+
+	return names, err
+}
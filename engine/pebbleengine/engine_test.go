@@ -0,0 +1,85 @@
+package pebbleengine_test
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/asdine/genji/engine"
+	"github.com/asdine/genji/engine/enginetest"
+	"github.com/asdine/genji/engine/pebbleengine"
+	"github.com/cockroachdb/pebble"
+	"github.com/stretchr/testify/require"
+)
+
+func builder(t testing.TB) func() (engine.Engine, func()) {
+	return func() (engine.Engine, func()) {
+		dir, cleanup := tempDir(t)
+		ng, err := pebbleengine.NewEngine(path.Join(dir, "pebble"), &pebble.Options{Logger: quietLogger{}})
+		require.NoError(t, err)
+		return ng, cleanup
+	}
+}
+
+func TestPebbleEngine(t *testing.T) {
+	enginetest.TestSuite(t, builder(t))
+}
+
+func TestTransactionSnapshot(t *testing.T) {
+	ng, cleanup := builder(t)()
+	defer cleanup()
+	defer ng.Close()
+
+	tx, err := ng.Begin(context.Background(), true)
+	require.NoError(t, err)
+	require.NoError(t, tx.CreateStore("test"))
+	st, err := tx.GetStore("test")
+	require.NoError(t, err)
+	require.NoError(t, st.Put([]byte("a"), []byte("A")))
+	require.NoError(t, tx.Commit())
+
+	// the read-only transaction reads the database as it was when it began.
+	rtx, err := ng.Begin(context.Background(), false)
+	require.NoError(t, err)
+	defer rtx.Rollback()
+	rst, err := rtx.GetStore("test")
+	require.NoError(t, err)
+
+	tx, err = ng.Begin(context.Background(), true)
+	require.NoError(t, err)
+	st, err = tx.GetStore("test")
+	require.NoError(t, err)
+	require.NoError(t, st.Put([]byte("a"), []byte("B")))
+	require.NoError(t, st.Put([]byte("b"), []byte("B")))
+	require.NoError(t, tx.Commit())
+
+	v, err := rst.Get([]byte("a"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("A"), v)
+	_, err = rst.Get([]byte("b"))
+	require.Equal(t, engine.ErrKeyNotFound, err)
+}
+
+func BenchmarkPebbleEngineStorePut(b *testing.B) {
+	enginetest.BenchmarkStorePut(b, builder(b))
+}
+
+func BenchmarkPebbleEngineTableScan(b *testing.B) {
+	enginetest.BenchmarkStoreScan(b, builder(b))
+}
+
+func tempDir(t require.TestingT) (string, func()) {
+	dir, err := ioutil.TempDir("", "genji")
+	require.NoError(t, err)
+
+	return dir, func() {
+		os.RemoveAll(dir)
+	}
+}
+
+type quietLogger struct{}
+
+func (quietLogger) Infof(format string, args ...interface{})  {}
+func (quietLogger) Fatalf(format string, args ...interface{}) { panic("pebble: fatal error") }
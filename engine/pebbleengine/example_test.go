@@ -0,0 +1,30 @@
+package pebbleengine_test
+
+import (
+	"io/ioutil"
+	"log"
+	"os"
+	"path"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/engine/pebbleengine"
+)
+
+func Example() {
+	dir, err := ioutil.TempDir("", "pebble")
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	ng, err := pebbleengine.NewEngine(path.Join(dir, "pebble"), nil)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	db, err := genji.New(ng)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer db.Close()
+}
@@ -0,0 +1,266 @@
+package pebbleengine
+
+import (
+	"errors"
+
+	"github.com/asdine/genji/engine"
+	"github.com/cockroachdb/pebble"
+)
+
+// A Store is an implementation of the engine.Store interface.
+// All the keys of a store share the same prefix.
+type Store struct {
+	tx     *Transaction
+	prefix []byte
+	name   string
+}
+
+func (s *Store) buildKey(k []byte) []byte {
+	key := make([]byte, 0, len(s.prefix)+len(k))
+	key = append(key, s.prefix...)
+	key = append(key, k...)
+	return key
+}
+
+// Put stores a key value pair. If it already exists, it overrides it.
+func (s *Store) Put(k, v []byte) error {
+	if !s.tx.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	if err := s.tx.check(); err != nil {
+		return err
+	}
+
+	if len(k) == 0 {
+		return errors.New("cannot store empty key")
+	}
+
+	return s.tx.batch.Set(s.buildKey(k), v, nil)
+}
+
+// Get returns a value associated with the given key. If not found, returns engine.ErrKeyNotFound.
+func (s *Store) Get(k []byte) ([]byte, error) {
+	v, err := s.tx.get(s.buildKey(k))
+	if err == pebble.ErrNotFound {
+		return nil, engine.ErrKeyNotFound
+	}
+
+	return v, err
+}
+
+// Delete a record by key. If not found, returns engine.ErrKeyNotFound.
+func (s *Store) Delete(k []byte) error {
+	if !s.tx.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	key := s.buildKey(k)
+	_, err := s.tx.get(key)
+	if err == pebble.ErrNotFound {
+		return engine.ErrKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.tx.batch.Delete(key, nil)
+}
+
+// Truncate deletes all the records of the store, using a range deletion.
+func (s *Store) Truncate() error {
+	if !s.tx.writable {
+		return engine.ErrTransactionReadOnly
+	}
+
+	_, err := s.tx.get(buildStoreKey(s.name))
+	if err == pebble.ErrNotFound {
+		return engine.ErrStoreNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.tx.batch.DeleteRange(s.prefix, prefixUpperBound(s.prefix), nil)
+}
+
+// iterate creates an iterator over the keys of the store, calls fn with it and closes it.
+// The iterator doesn't observe the modifications made after its creation.
+func (s *Store) iterate(fn func(it *pebble.Iterator) error) error {
+	if err := s.tx.check(); err != nil {
+		return err
+	}
+
+	it := s.tx.reader.NewIter(&pebble.IterOptions{
+		LowerBound: s.prefix,
+		UpperBound: prefixUpperBound(s.prefix),
+	})
+
+	err := fn(it)
+	if err == nil {
+		err = it.Error()
+	}
+	if cerr := it.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// AscendGreaterOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in increasing order and calls the given function for each pair.
+// If the given function returns an error, the iteration stops and returns that error.
+// If the pivot is nil, starts from the beginning.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
+func (s *Store) AscendGreaterOrEqual(pivot []byte, fn func(k, v []byte) error) error {
+	return s.iterate(func(it *pebble.Iterator) error {
+		for it.SeekGE(s.buildKey(pivot)); it.Valid(); it.Next() {
+			if err := s.tx.ctx.Err(); err != nil {
+				return err
+			}
+
+			err := fn(it.Key()[len(s.prefix):], it.Value())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
+// If the given function returns an error, the iteration stops and returns that error.
+// If the pivot is nil, starts from the end.
+// If the context of the transaction is canceled, the iteration stops and returns the context error.
+func (s *Store) DescendLessOrEqual(pivot []byte, fn func(k, v []byte) error) error {
+	return s.iterate(func(it *pebble.Iterator) error {
+		var valid bool
+		if len(pivot) == 0 {
+			valid = it.Last()
+		} else {
+			// the smallest key greater than the pivot is the pivot followed by a zero byte.
+			valid = it.SeekLT(s.buildKey(append(pivot[:len(pivot):len(pivot)], 0)))
+		}
+
+		for ; valid; valid = it.Prev() {
+			if err := s.tx.ctx.Err(); err != nil {
+				return err
+			}
+
+			err := fn(it.Key()[len(s.prefix):], it.Value())
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// Iterator creates an iterator over the key value pairs of the store.
+// The iterator doesn't observe the modifications made after its creation.
+func (s *Store) Iterator(opts *engine.IteratorOptions) engine.Iterator {
+	it := iterator{
+		tx:     s.tx,
+		prefix: s.prefix,
+	}
+
+	if it.err = s.tx.check(); it.err != nil {
+		return &it
+	}
+
+	upper := prefixUpperBound(s.prefix)
+	if opts != nil && opts.UpperBound != nil {
+		upper = s.buildKey(opts.UpperBound)
+	}
+
+	it.it = s.tx.reader.NewIter(&pebble.IterOptions{
+		LowerBound: s.prefix,
+		UpperBound: upper,
+	})
+
+	return &it
+}
+
+type iterator struct {
+	tx     *Transaction
+	prefix []byte
+	it     *pebble.Iterator
+	err    error
+}
+
+func (it *iterator) Seek(pivot []byte) {
+	if it.check() {
+		it.it.SeekGE(append(it.prefix[:len(it.prefix):len(it.prefix)], pivot...))
+	}
+}
+
+func (it *iterator) SeekReverse(pivot []byte) {
+	if !it.check() {
+		return
+	}
+
+	if pivot == nil {
+		it.it.Last()
+		return
+	}
+
+	// the smallest key greater than the pivot is the pivot followed by a zero byte.
+	// keys beyond the upper bound are clamped by Pebble.
+	key := append(it.prefix[:len(it.prefix):len(it.prefix)], pivot...)
+	it.it.SeekLT(append(key, 0))
+}
+
+func (it *iterator) Next() {
+	if it.check() {
+		it.it.Next()
+	}
+}
+
+func (it *iterator) Prev() {
+	if it.check() {
+		it.it.Prev()
+	}
+}
+
+// check returns false and stops the iteration if the transaction is closed or its context canceled.
+func (it *iterator) check() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.err = it.tx.check()
+	return it.err == nil
+}
+
+func (it *iterator) Valid() bool {
+	return it.err == nil && it.it.Valid()
+}
+
+func (it *iterator) Key() []byte {
+	return it.it.Key()[len(it.prefix):]
+}
+
+func (it *iterator) Value() ([]byte, error) {
+	return it.it.Value(), nil
+}
+
+func (it *iterator) Err() error {
+	if it.err != nil {
+		return it.err
+	}
+
+	if it.it == nil {
+		return nil
+	}
+
+	return it.it.Error()
+}
+
+func (it *iterator) Close() error {
+	if it.it == nil {
+		return nil
+	}
+
+	return it.it.Close()
+}
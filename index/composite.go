@@ -0,0 +1,372 @@
+package index
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/engine"
+)
+
+// CompositeIndex is an index that associates multiple values with keys, one for each indexed field.
+// Values are passed to the index as arrays, and are sorted by the first value of the array,
+// then by the second one, and so on. Each value can be sorted in ascending or descending order.
+//
+// All the values are stored in a single store, using an order-preserving tuple encoding:
+// each value is prefixed by its index type and variable length values are escaped and delimited,
+// so that entries sharing the same first values are contiguous and sorted by the next value.
+// Values sorted in descending order have all their bits inverted.
+type CompositeIndex struct {
+	tx     engine.Transaction
+	name   string
+	unique bool
+	desc   []bool
+}
+
+// NewCompositeIndex creates an index that associates arrays of values with keys.
+// If unique is true, every array of values can only be associated with one key.
+// desc indicates, for every value of the arrays, if they must be sorted in descending order.
+func NewCompositeIndex(tx engine.Transaction, idxName string, unique bool, desc []bool) *CompositeIndex {
+	return &CompositeIndex{
+		tx:     tx,
+		name:   idxName,
+		unique: unique,
+		desc:   desc,
+	}
+}
+
+// Set associates an array of values with a key.
+func (i *CompositeIndex) Set(val document.Value, key []byte) error {
+	v, err := i.encode(val, false)
+	if err != nil {
+		return err
+	}
+
+	st, err := i.getOrCreateStore()
+	if err != nil {
+		return err
+	}
+
+	if !i.unique {
+		return st.Put(append(v, key...), nil)
+	}
+
+	_, err = st.Get(v)
+	if err == nil {
+		return ErrDuplicate
+	}
+	if err != engine.ErrKeyNotFound {
+		return err
+	}
+
+	return st.Put(v, key)
+}
+
+// Get returns the key associated with the array of values. It can only be used with unique indexes.
+// If the array is not associated with any key, it returns ErrNotFound.
+func (i *CompositeIndex) Get(val document.Value) ([]byte, error) {
+	if !i.unique {
+		return nil, errors.New("cannot get the key of a value from a non unique index")
+	}
+
+	v, err := i.encode(val, false)
+	if err != nil {
+		return nil, err
+	}
+
+	st, err := i.getStore()
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, ErrNotFound
+	}
+
+	key, err := st.Get(v)
+	if err == engine.ErrKeyNotFound {
+		return nil, ErrNotFound
+	}
+
+	return key, err
+}
+
+// Delete all the references to the key from the index.
+func (i *CompositeIndex) Delete(val document.Value, key []byte) error {
+	v, err := i.encode(val, false)
+	if err != nil {
+		return err
+	}
+
+	st, err := i.getOrCreateStore()
+	if err != nil {
+		return err
+	}
+
+	if !i.unique {
+		return st.Delete(append(v, key...))
+	}
+
+	return st.Delete(v)
+}
+
+// AscendGreaterOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in increasing order and calls the given function for each pair.
+// The value of the pivot must be an array, which can contain less values than the indexed arrays.
+// If the given function returns an error, the iteration stops and returns that error.
+// If the pivot is nil, starts from the beginning.
+func (i *CompositeIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
+	return iterate(i.Iterator(nil), pivot, fn)
+}
+
+// DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
+// The value of the pivot must be an array, which can contain less values than the indexed arrays,
+// in which case the iteration starts from the last array starting with the values of the pivot.
+// If the given function returns an error, the iteration stops and returns that error.
+// If the pivot is nil, starts from the end.
+func (i *CompositeIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
+	return iterate(i.Iterator(&IteratorOptions{Reverse: true}), pivot, fn)
+}
+
+// Iterator creates an iterator over the arrays of values of the index, with the given options.
+// The options can be nil. The pivots used to seek must be arrays, which can contain less values
+// than the indexed arrays: in reverse order, the iterator then moves to the last array
+// starting with the values of the pivot.
+func (i *CompositeIndex) Iterator(opts *IteratorOptions) Iterator {
+	return &iterator{
+		tx:      i.tx,
+		reverse: opts != nil && opts.Reverse,
+		stores: func(pivot *Pivot, reverse bool) ([]iteratorStore, []byte, error) {
+			stores := []iteratorStore{{name: i.storeName()}}
+			if pivot == nil {
+				return stores, nil, nil
+			}
+
+			seek, err := i.encode(pivot.Value, true)
+			if err != nil {
+				return nil, nil, err
+			}
+
+			if reverse {
+				// encoded values never start with 0xFF, this byte
+				// is greater than any value following the pivot.
+				seek = append(seek, 0xFF)
+			}
+
+			return stores, seek, nil
+		},
+		decode: i.decode,
+	}
+}
+
+// Truncate deletes all the index data.
+func (i *CompositeIndex) Truncate() error {
+	_, err := i.tx.GetStore(i.storeName())
+	if err == engine.ErrStoreNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	return i.tx.DropStore(i.storeName())
+}
+
+func (i *CompositeIndex) storeName() string {
+	return StorePrefix + i.name
+}
+
+func (i *CompositeIndex) getOrCreateStore() (engine.Store, error) {
+	st, err := i.tx.GetStore(i.storeName())
+	if err == nil {
+		return st, nil
+	}
+
+	if err != engine.ErrStoreNotFound {
+		return nil, err
+	}
+
+	err = i.tx.CreateStore(i.storeName())
+	if err != nil {
+		return nil, err
+	}
+
+	return i.tx.GetStore(i.storeName())
+}
+
+func (i *CompositeIndex) getStore() (engine.Store, error) {
+	st, err := i.tx.GetStore(i.storeName())
+	if err == engine.ErrStoreNotFound {
+		return nil, nil
+	}
+
+	return st, err
+}
+
+// encode the array of values. If prefix is true, the array may contain less values
+// than the number of indexed values.
+func (i *CompositeIndex) encode(val document.Value, prefix bool) ([]byte, error) {
+	if val.Type != document.ArrayValue {
+		return nil, fmt.Errorf("composite index values must be arrays, got %s", val.Type)
+	}
+
+	a := val.V.(document.Array)
+
+	l, err := document.ArrayLength(a)
+	if err != nil {
+		return nil, err
+	}
+
+	if l > len(i.desc) || (!prefix && l != len(i.desc)) {
+		return nil, fmt.Errorf("expected %d values, got %d", len(i.desc), l)
+	}
+
+	var buf []byte
+	err = a.Iterate(func(j int, v document.Value) error {
+		buf, err = encodeTupleValue(buf, v, i.desc[j])
+		return err
+	})
+
+	return buf, err
+}
+
+// decode the values of the current entry of the store iterator and the key they are associated with.
+func (i *CompositeIndex) decode(t Type, it engine.Iterator) (document.Value, []byte, error) {
+	k := it.Key()
+	values := make(document.ValueBuffer, len(i.desc))
+
+	n := 0
+	for j, desc := range i.desc {
+		val, l, err := decodeTupleValue(k[n:], desc)
+		if err != nil {
+			return val, nil, err
+		}
+
+		values[j] = val
+		n += l
+	}
+
+	if !i.unique {
+		return document.NewArrayValue(values), k[n:], nil
+	}
+
+	key, err := it.Value()
+	return document.NewArrayValue(values), key, err
+}
+
+var errInvalidTuple = errors.New("invalid composite index value")
+
+// encodeTupleValue appends the encoded value to buf.
+// Values that cannot be indexed, like documents and arrays, are indexed as null values.
+func encodeTupleValue(buf []byte, v document.Value, desc bool) ([]byte, error) {
+	start := len(buf)
+
+	t := NewTypeFromValueType(v.Type)
+	buf = append(buf, byte(t))
+
+	switch t {
+	case Bool, Float:
+		enc, err := EncodeFieldToIndexValue(v)
+		if err != nil {
+			return nil, err
+		}
+		buf = append(buf, enc...)
+	case Bytes:
+		enc, err := EncodeFieldToIndexValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		// zero bytes are escaped so that the delimiter,
+		// which is smaller than any escaped byte, marks the end of the value.
+		for _, b := range enc {
+			if b == 0 {
+				buf = append(buf, 0, 0xFF)
+			} else {
+				buf = append(buf, b)
+			}
+		}
+		buf = append(buf, 0, 0x01)
+	}
+
+	if desc {
+		for j := start; j < len(buf); j++ {
+			buf[j] = ^buf[j]
+		}
+	}
+
+	return buf, nil
+}
+
+// decodeTupleValue decodes the first value of data and returns it with its encoded length.
+func decodeTupleValue(data []byte, desc bool) (document.Value, int, error) {
+	at := func(j int) (byte, error) {
+		if j >= len(data) {
+			return 0, errInvalidTuple
+		}
+
+		if desc {
+			return ^data[j], nil
+		}
+
+		return data[j], nil
+	}
+
+	b, err := at(0)
+	if err != nil {
+		return document.Value{}, 0, err
+	}
+	t := Type(b)
+
+	var n int
+	var enc []byte
+
+	switch t {
+	case Null:
+		return document.NewNullValue(), 1, nil
+	case Bool:
+		n = 1
+	case Float:
+		n = 8
+	case Bytes:
+		j := 1
+		for {
+			b, err := at(j)
+			if err != nil {
+				return document.Value{}, 0, err
+			}
+
+			if b != 0 {
+				enc = append(enc, b)
+				j++
+				continue
+			}
+
+			next, err := at(j + 1)
+			if err != nil {
+				return document.Value{}, 0, err
+			}
+			j += 2
+
+			if next != 0xFF {
+				break
+			}
+			enc = append(enc, 0)
+		}
+
+		v, err := decodeIndexValueToField(t, enc)
+		return v, j, err
+	default:
+		return document.Value{}, 0, errInvalidTuple
+	}
+
+	if len(data) < n+1 {
+		return document.Value{}, 0, errInvalidTuple
+	}
+
+	enc = make([]byte, n)
+	for j := range enc {
+		enc[j], _ = at(j + 1)
+	}
+
+	v, err := decodeIndexValueToField(t, enc)
+	return v, n + 1, err
+}
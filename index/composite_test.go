@@ -0,0 +1,218 @@
+package index_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/engine/memoryengine"
+	"github.com/asdine/genji/index"
+	"github.com/stretchr/testify/require"
+)
+
+func getCompositeIndex(t testing.TB, unique bool, desc ...bool) (*index.CompositeIndex, func()) {
+	ng := memoryengine.NewEngine()
+	tx, err := ng.Begin(context.Background(), true)
+	require.NoError(t, err)
+
+	return index.NewCompositeIndex(tx, "foo", unique, desc), func() {
+		tx.Rollback()
+	}
+}
+
+func tuple(values ...document.Value) document.Value {
+	return document.NewArrayValue(document.NewValueBuffer(values...))
+}
+
+func TestCompositeIndexSet(t *testing.T) {
+	t.Run("Set array succeeds", func(t *testing.T) {
+		idx, cleanup := getCompositeIndex(t, false, false, false)
+		defer cleanup()
+
+		require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewTextValue("a")), []byte("key")))
+	})
+
+	t.Run("Set non array fails", func(t *testing.T) {
+		idx, cleanup := getCompositeIndex(t, false, false, false)
+		defer cleanup()
+
+		require.Error(t, idx.Set(document.NewIntValue(1), []byte("key")))
+	})
+
+	t.Run("Set wrong number of values fails", func(t *testing.T) {
+		idx, cleanup := getCompositeIndex(t, false, false, false)
+		defer cleanup()
+
+		require.Error(t, idx.Set(tuple(document.NewIntValue(1)), []byte("key")))
+	})
+
+	t.Run("Unique: true, Duplicate", func(t *testing.T) {
+		idx, cleanup := getCompositeIndex(t, true, false, false)
+		defer cleanup()
+
+		require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewIntValue(2)), []byte("a")))
+		require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewIntValue(3)), []byte("b")))
+		require.Equal(t, index.ErrDuplicate, idx.Set(tuple(document.NewIntValue(1), document.NewIntValue(2)), []byte("c")))
+	})
+}
+
+func TestCompositeIndexDelete(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		idx, cleanup := getCompositeIndex(t, unique, false, true)
+		defer cleanup()
+
+		require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewTextValue("a")), []byte("a")))
+		require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewTextValue("b")), []byte("b")))
+		require.NoError(t, idx.Delete(tuple(document.NewIntValue(1), document.NewTextValue("a")), []byte("a")))
+
+		var keys []string
+		err := idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+			keys = append(keys, string(key))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, []string{"b"}, keys)
+	}
+}
+
+func TestCompositeIndexGet(t *testing.T) {
+	idx, cleanup := getCompositeIndex(t, true, false, true)
+	defer cleanup()
+
+	_, err := idx.Get(tuple(document.NewIntValue(1), document.NewTextValue("a")))
+	require.Equal(t, index.ErrNotFound, err)
+
+	require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewTextValue("a")), []byte("a")))
+	require.NoError(t, idx.Set(tuple(document.NewIntValue(1), document.NewTextValue("b")), []byte("b")))
+
+	key, err := idx.Get(tuple(document.NewIntValue(1), document.NewTextValue("b")))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), key)
+
+	_, err = idx.Get(tuple(document.NewIntValue(1)))
+	require.Error(t, err)
+
+	list, cleanup := getCompositeIndex(t, false, false, true)
+	defer cleanup()
+
+	_, err = list.Get(tuple(document.NewIntValue(1), document.NewTextValue("a")))
+	require.Error(t, err)
+}
+
+func TestCompositeIndexOrder(t *testing.T) {
+	values := []document.Value{
+		tuple(document.NewTextValue("b"), document.NewIntValue(-10)),
+		tuple(document.NewTextValue("a"), document.NewIntValue(2)),
+		tuple(document.NewTextValue("a\x00"), document.NewIntValue(1)),
+		tuple(document.NewTextValue("ab"), document.NewIntValue(3)),
+		tuple(document.NewTextValue("a"), document.NewFloat64Value(1.5)),
+		tuple(document.NewTextValue("a"), document.NewNullValue()),
+		tuple(document.NewTextValue("a"), document.NewTextValue("x")),
+		tuple(document.NewNullValue(), document.NewIntValue(100)),
+		tuple(document.NewBoolValue(true), document.NewBoolValue(false)),
+	}
+
+	tests := []struct {
+		name     string
+		desc     []bool
+		expected []string
+	}{
+		{"ASC, ASC", []bool{false, false}, []string{
+			`[null, 100]`, `[true, false]`, `["a", null]`, `["a", 1.5]`, `["a", 2]`, `["a", "x"]`, `["a\u0000", 1]`, `["ab", 3]`, `["b", -10]`,
+		}},
+		{"ASC, DESC", []bool{false, true}, []string{
+			`[null, 100]`, `[true, false]`, `["a", "x"]`, `["a", 2]`, `["a", 1.5]`, `["a", null]`, `["a\u0000", 1]`, `["ab", 3]`, `["b", -10]`,
+		}},
+		{"DESC, ASC", []bool{true, false}, []string{
+			`["b", -10]`, `["ab", 3]`, `["a\u0000", 1]`, `["a", null]`, `["a", 1.5]`, `["a", 2]`, `["a", "x"]`, `[true, false]`, `[null, 100]`,
+		}},
+	}
+
+	for _, test := range tests {
+		for _, unique := range []bool{true, false} {
+			t.Run(test.name, func(t *testing.T) {
+				idx, cleanup := getCompositeIndex(t, unique, test.desc...)
+				defer cleanup()
+
+				for i, v := range values {
+					require.NoError(t, idx.Set(v, []byte{byte(i)}))
+				}
+
+				var ascending []string
+				err := idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+					data, err := val.MarshalJSON()
+					require.NoError(t, err)
+					ascending = append(ascending, string(data))
+					return nil
+				})
+				require.NoError(t, err)
+				require.Len(t, ascending, len(test.expected))
+				for i := range test.expected {
+					require.JSONEq(t, test.expected[i], ascending[i])
+				}
+
+				var descending []string
+				err = idx.DescendLessOrEqual(nil, func(val document.Value, key []byte) error {
+					data, err := val.MarshalJSON()
+					require.NoError(t, err)
+					descending = append([]string{string(data)}, descending...)
+					return nil
+				})
+				require.NoError(t, err)
+				require.Equal(t, ascending, descending)
+			})
+		}
+	}
+}
+
+func TestCompositeIndexPivot(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		idx, cleanup := getCompositeIndex(t, unique, false, true)
+		defer cleanup()
+
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				require.NoError(t, idx.Set(tuple(document.NewIntValue(i), document.NewIntValue(j)), []byte{byte(i), byte(j)}))
+			}
+		}
+
+		var keys [][]byte
+		err := idx.AscendGreaterOrEqual(&index.Pivot{Value: tuple(document.NewIntValue(1))}, func(val document.Value, key []byte) error {
+			keys = append(keys, append([]byte{}, key...))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{{1, 2}, {1, 1}, {1, 0}, {2, 2}, {2, 1}, {2, 0}}, keys)
+
+		keys = nil
+		err = idx.AscendGreaterOrEqual(&index.Pivot{Value: tuple(document.NewIntValue(1), document.NewIntValue(1))}, func(val document.Value, key []byte) error {
+			keys = append(keys, append([]byte{}, key...))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{{1, 1}, {1, 0}, {2, 2}, {2, 1}, {2, 0}}, keys)
+
+		keys = nil
+		err = idx.DescendLessOrEqual(&index.Pivot{Value: tuple(document.NewIntValue(1))}, func(val document.Value, key []byte) error {
+			keys = append(keys, append([]byte{}, key...))
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, [][]byte{{1, 0}, {1, 1}, {1, 2}, {0, 0}, {0, 1}, {0, 2}}, keys)
+	}
+}
+
+func TestCompositeIndexTruncate(t *testing.T) {
+	idx, cleanup := getCompositeIndex(t, false, false)
+	defer cleanup()
+
+	require.NoError(t, idx.Truncate())
+	require.NoError(t, idx.Set(tuple(document.NewIntValue(1)), []byte("a")))
+	require.NoError(t, idx.Truncate())
+
+	err := idx.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
+		t.Fatal("index should be empty")
+		return nil
+	})
+	require.NoError(t, err)
+}
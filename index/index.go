@@ -54,6 +54,9 @@ func NewTypeFromValueType(t document.ValueType) Type {
 var (
 	// ErrDuplicate is returned when a value is already associated with a key
 	ErrDuplicate = errors.New("duplicate")
+
+	// ErrNotFound is returned when a value is not associated with any key
+	ErrNotFound = errors.New("not found")
 )
 
 // An Index associates encoded values with keys.
@@ -75,6 +78,10 @@ type Index interface {
 	// If the pivot is nil, starts from the end.
 	DescendLessOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error
 
+	// Iterator creates an iterator over the values of the index, with the given options.
+	// The options can be nil.
+	Iterator(opts *IteratorOptions) Iterator
+
 	// Truncate deletes all the index data.
 	Truncate() error
 }
@@ -172,123 +179,52 @@ func (i *ListIndex) Delete(val document.Value, key []byte) error {
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the beginning.
 func (i *ListIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
-	// iterate over all stores in order
-	if pivot == nil {
-		for t := Null; t <= Bytes; t++ {
-			st, err := getStore(i.tx, t, i.name)
-			if err != nil {
-				return err
-			}
-			if st == nil {
-				continue
-			}
-
-			err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
-				idx := bytes.LastIndexByte(k, separator)
-				f, err := decodeIndexValueToField(t, k[:idx])
-				if err != nil {
-					return err
-				}
-
-				return fn(f, k[idx+1:])
-			})
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}
-
-	st, err := getStore(i.tx, NewTypeFromValueType(pivot.Value.Type), i.name)
-	if err != nil {
-		return err
-	}
-	if st == nil {
-		return nil
-	}
-
-	var data []byte
-	if !pivot.empty {
-		data, err = EncodeFieldToIndexValue(pivot.Value)
-		if err != nil {
-			return err
-		}
-	}
-
-	return st.AscendGreaterOrEqual(data, func(k, v []byte) error {
-		idx := bytes.LastIndexByte(k, separator)
-		f, err := decodeIndexValueToField(NewTypeFromValueType(pivot.Value.Type), k[:idx])
-		if err != nil {
-			return err
-		}
-
-		return fn(f, k[idx+1:])
-	})
+	return iterate(i.Iterator(nil), pivot, fn)
 }
 
 // DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the end.
 func (i *ListIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
-	// iterate over all stores in order
-	if pivot == nil {
-		for t := Bytes; t >= Null; t-- {
-			st, err := getStore(i.tx, t, i.name)
-			if err != nil {
-				return err
-			}
-			if st == nil {
-				continue
+	return iterate(i.Iterator(&IteratorOptions{Reverse: true}), pivot, fn)
+}
+
+// Iterator creates an iterator over the values of the index, with the given options.
+// The options can be nil.
+func (i *ListIndex) Iterator(opts *IteratorOptions) Iterator {
+	return &iterator{
+		tx:      i.tx,
+		reverse: opts != nil && opts.Reverse,
+		stores: func(pivot *Pivot, reverse bool) ([]iteratorStore, []byte, error) {
+			if pivot == nil {
+				return typedStores(i.name, reverse), nil, nil
 			}
 
-			err = st.DescendLessOrEqual(nil, func(k, v []byte) error {
-				idx := bytes.LastIndexByte(k, separator)
-				f, err := decodeIndexValueToField(t, k[:idx])
-				if err != nil {
-					return err
-				}
+			t := NewTypeFromValueType(pivot.Value.Type)
+			stores := []iteratorStore{{name: buildIndexName(i.name, t), t: t}}
+			if pivot.empty {
+				return stores, nil, nil
+			}
 
-				return fn(f, k[idx+1:])
-			})
+			data, err := EncodeFieldToIndexValue(pivot.Value)
 			if err != nil {
-				return err
+				return nil, nil, err
 			}
-		}
 
-		return nil
-	}
-
-	st, err := getStore(i.tx, NewTypeFromValueType(pivot.Value.Type), i.name)
-	if err != nil {
-		return err
-	}
-	if st == nil {
-		return nil
-	}
-
-	var data []byte
-	if !pivot.empty {
-		data, err = EncodeFieldToIndexValue(pivot.Value)
-		if err != nil {
-			return err
-		}
-	}
+			if reverse && len(data) > 0 {
+				// ensure the pivot is bigger than the requested value so it doesn't get skipped.
+				data = append(data, separator, 0xFF)
+			}
 
-	if len(data) > 0 {
-		// ensure the pivot is bigger than the requested value so it doesn't get skipped.
-		data = append(data, separator, 0xFF)
+			return stores, data, nil
+		},
+		decode: func(t Type, it engine.Iterator) (document.Value, []byte, error) {
+			k := it.Key()
+			idx := bytes.LastIndexByte(k, separator)
+			f, err := decodeIndexValueToField(t, k[:idx])
+			return f, k[idx+1:], err
+		},
 	}
-
-	return st.DescendLessOrEqual(data, func(k, v []byte) error {
-		idx := bytes.LastIndexByte(k, separator)
-		f, err := decodeIndexValueToField(NewTypeFromValueType(pivot.Value.Type), k[:idx])
-		if err != nil {
-			return err
-		}
-
-		return fn(f, k[idx+1:])
-	})
 }
 
 // Truncate deletes all the index data.
@@ -341,6 +277,36 @@ func (i *UniqueIndex) Set(val document.Value, key []byte) error {
 	return st.Put(buf, key)
 }
 
+// Get returns the key associated with the value.
+// If the value is not associated with any key, it returns ErrNotFound.
+func (i *UniqueIndex) Get(val document.Value) ([]byte, error) {
+	v, err := EncodeFieldToIndexValue(val)
+	if err != nil {
+		return nil, err
+	}
+
+	t := NewTypeFromValueType(val.Type)
+	st, err := getStore(i.tx, t, i.name)
+	if err != nil {
+		return nil, err
+	}
+	if st == nil {
+		return nil, ErrNotFound
+	}
+
+	buf := make([]byte, 0, len(v)+2)
+	buf = append(buf, uint8(t))
+	buf = append(buf, separator)
+	buf = append(buf, v...)
+
+	key, err := st.Get(buf)
+	if err == engine.ErrKeyNotFound {
+		return nil, ErrNotFound
+	}
+
+	return key, err
+}
+
 // Delete all the references to the key from the index.
 func (i *UniqueIndex) Delete(val document.Value, key []byte) error {
 	v, err := EncodeFieldToIndexValue(val)
@@ -365,125 +331,58 @@ func (i *UniqueIndex) Delete(val document.Value, key []byte) error {
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the beginning.
 func (i *UniqueIndex) AscendGreaterOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
-	// iterate over all stores in order
-	if pivot == nil {
-		for t := Null; t <= Bytes; t++ {
-			st, err := getStore(i.tx, t, i.name)
-			if err != nil {
-				return err
-			}
-			if st == nil {
-				continue
-			}
-
-			err = st.AscendGreaterOrEqual(nil, func(k, v []byte) error {
-				f, err := decodeIndexValueToField(t, k[2:])
-				if err != nil {
-					return err
-				}
-
-				return fn(f, v)
-			})
-			if err != nil {
-				return err
-			}
-		}
-
-		return nil
-	}
-
-	st, err := getStore(i.tx, NewTypeFromValueType(pivot.Value.Type), i.name)
-	if err != nil {
-		return err
-	}
-	if st == nil {
-		return nil
-	}
-
-	var data []byte
-	if !pivot.empty {
-		data, err = EncodeFieldToIndexValue(pivot.Value)
-		if err != nil {
-			return err
-		}
-	}
-
-	buf := make([]byte, 0, len(data)+2)
-	buf = append(buf, uint8(NewTypeFromValueType(pivot.Value.Type)))
-	buf = append(buf, separator)
-	buf = append(buf, data...)
-
-	return st.AscendGreaterOrEqual(buf, func(vv []byte, key []byte) error {
-		f, err := decodeIndexValueToField(NewTypeFromValueType(pivot.Value.Type), vv[2:])
-		if err != nil {
-			return err
-		}
-
-		return fn(f, key)
-	})
+	return iterate(i.Iterator(nil), pivot, fn)
 }
 
 // DescendLessOrEqual seeks for the pivot and then goes through all the subsequent key value pairs in descreasing order and calls the given function for each pair.
 // If the given function returns an error, the iteration stops and returns that error.
 // If the pivot is nil, starts from the end.
 func (i *UniqueIndex) DescendLessOrEqual(pivot *Pivot, fn func(val document.Value, key []byte) error) error {
-	// iterate over all stores in order
-	if pivot == nil {
-		for t := Bytes; t >= Null; t-- {
-			st, err := getStore(i.tx, t, i.name)
-			if err != nil {
-				return err
-			}
-			if st == nil {
-				continue
+	return iterate(i.Iterator(&IteratorOptions{Reverse: true}), pivot, fn)
+}
+
+// Iterator creates an iterator over the values of the index, with the given options.
+// The options can be nil.
+func (i *UniqueIndex) Iterator(opts *IteratorOptions) Iterator {
+	return &iterator{
+		tx:      i.tx,
+		reverse: opts != nil && opts.Reverse,
+		stores: func(pivot *Pivot, reverse bool) ([]iteratorStore, []byte, error) {
+			if pivot == nil {
+				return typedStores(i.name, reverse), nil, nil
 			}
 
-			err = st.DescendLessOrEqual(nil, func(k, v []byte) error {
-				f, err := decodeIndexValueToField(t, k[2:])
+			t := NewTypeFromValueType(pivot.Value.Type)
+
+			var data []byte
+			if !pivot.empty {
+				var err error
+				data, err = EncodeFieldToIndexValue(pivot.Value)
 				if err != nil {
-					return err
+					return nil, nil, err
 				}
-
-				return fn(f, v)
-			})
-			if err != nil {
-				return err
 			}
-		}
 
-		return nil
-	}
+			buf := make([]byte, 0, len(data)+3)
+			buf = append(buf, uint8(t))
+			buf = append(buf, separator)
+			buf = append(buf, data...)
+			if reverse {
+				buf = append(buf, 0xFF)
+			}
 
-	st, err := getStore(i.tx, NewTypeFromValueType(pivot.Value.Type), i.name)
-	if err != nil {
-		return err
-	}
-	if st == nil {
-		return nil
-	}
+			return []iteratorStore{{name: buildIndexName(i.name, t), t: t}}, buf, nil
+		},
+		decode: func(t Type, it engine.Iterator) (document.Value, []byte, error) {
+			f, err := decodeIndexValueToField(t, it.Key()[2:])
+			if err != nil {
+				return f, nil, err
+			}
 
-	var data []byte
-	if !pivot.empty {
-		data, err = EncodeFieldToIndexValue(pivot.Value)
-		if err != nil {
-			return err
-		}
+			key, err := it.Value()
+			return f, key, err
+		},
 	}
-
-	buf := make([]byte, 0, len(data)+3)
-	buf = append(buf, uint8(NewTypeFromValueType(pivot.Value.Type)))
-	buf = append(buf, separator)
-	buf = append(buf, data...)
-	buf = append(buf, 0xFF)
-
-	return st.DescendLessOrEqual(buf, func(vv []byte, key []byte) error {
-		f, err := decodeIndexValueToField(NewTypeFromValueType(pivot.Value.Type), vv[2:])
-		if err != nil {
-			return err
-		}
-
-		return fn(f, key)
-	})
 }
 
 // Truncate deletes all the index data.
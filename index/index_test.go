@@ -2,6 +2,7 @@
 package index_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strconv"
@@ -15,7 +16,7 @@ import (
 
 func getIndex(t testing.TB, unique bool) (index.Index, func()) {
 	ng := memoryengine.NewEngine()
-	tx, err := ng.Begin(true)
+	tx, err := ng.Begin(context.Background(), true)
 	require.NoError(t, err)
 
 	var idx index.Index
@@ -129,6 +130,28 @@ func TestIndexDelete(t *testing.T) {
 	}
 }
 
+func TestUniqueIndexGet(t *testing.T) {
+	idx, cleanup := getIndex(t, true)
+	defer cleanup()
+
+	_, err := idx.(*index.UniqueIndex).Get(document.NewIntValue(10))
+	require.Equal(t, index.ErrNotFound, err)
+
+	require.NoError(t, idx.Set(document.NewIntValue(10), []byte("a")))
+	require.NoError(t, idx.Set(document.NewTextValue("10"), []byte("b")))
+
+	key, err := idx.(*index.UniqueIndex).Get(document.NewFloat64Value(10))
+	require.NoError(t, err)
+	require.Equal(t, []byte("a"), key)
+
+	key, err = idx.(*index.UniqueIndex).Get(document.NewTextValue("10"))
+	require.NoError(t, err)
+	require.Equal(t, []byte("b"), key)
+
+	_, err = idx.(*index.UniqueIndex).Get(document.NewIntValue(11))
+	require.Equal(t, index.ErrNotFound, err)
+}
+
 func TestIndexAscendGreaterThan(t *testing.T) {
 	for _, unique := range []bool{true, false} {
 		text := fmt.Sprintf("Unique: %v, ", unique)
@@ -347,6 +370,79 @@ func TestIndexDescendLessOrEqual(t *testing.T) {
 }
 
 // BenchmarkIndexSet benchmarks the Set method with 1, 10, 1000 and 10000 successive insertions.
+func TestIndexIterator(t *testing.T) {
+	for _, unique := range []bool{true, false} {
+		text := fmt.Sprintf("Unique: %v, ", unique)
+
+		setup := func(t *testing.T) (index.Index, func()) {
+			idx, cleanup := getIndex(t, unique)
+
+			for i := 0; i < 5; i++ {
+				require.NoError(t, idx.Set(document.NewIntValue(i), []byte{'i', byte('a' + i)}))
+				require.NoError(t, idx.Set(document.NewTextValue(strconv.Itoa(i)), []byte{'s', byte('a' + i)}))
+			}
+			require.NoError(t, idx.Set(document.NewBoolValue(true), []byte("b")))
+
+			return idx, cleanup
+		}
+
+		// read the keys returned by the iterator from the pivot.
+		read := func(t *testing.T, it index.Iterator, pivot *index.Pivot) []string {
+			var keys []string
+			for it.Seek(pivot); it.Valid(); it.Next() {
+				keys = append(keys, string(it.Key()))
+			}
+			require.NoError(t, it.Err())
+			return keys
+		}
+
+		t.Run(text+"Should not iterate if index is empty", func(t *testing.T) {
+			idx, cleanup := getIndex(t, unique)
+			defer cleanup()
+
+			it := idx.Iterator(nil)
+			defer it.Close()
+
+			require.Empty(t, read(t, it, nil))
+		})
+
+		t.Run(text+"Should iterate over the values of every type without pivot", func(t *testing.T) {
+			idx, cleanup := setup(t)
+			defer cleanup()
+
+			it := idx.Iterator(nil)
+			defer it.Close()
+			require.Equal(t, []string{"b", "ia", "ib", "ic", "id", "ie", "sa", "sb", "sc", "sd", "se"}, read(t, it, nil))
+
+			it = idx.Iterator(&index.IteratorOptions{Reverse: true})
+			defer it.Close()
+			require.Equal(t, []string{"se", "sd", "sc", "sb", "sa", "ie", "id", "ic", "ib", "ia", "b"}, read(t, it, nil))
+		})
+
+		t.Run(text+"Should iterate over the values of the type of the pivot", func(t *testing.T) {
+			idx, cleanup := setup(t)
+			defer cleanup()
+
+			it := idx.Iterator(nil)
+			defer it.Close()
+			require.Equal(t, []string{"ic", "id", "ie"}, read(t, it, &index.Pivot{Value: document.NewFloat64Value(2)}))
+
+			// the iterator can be positioned again.
+			it.Seek(&index.Pivot{Value: document.NewTextValue("4")})
+			require.True(t, it.Valid())
+			require.Equal(t, document.NewBlobValue([]byte("4")), it.Value())
+			require.Equal(t, []byte("se"), it.Key())
+			it.Next()
+			require.False(t, it.Valid())
+
+			it = idx.Iterator(&index.IteratorOptions{Reverse: true})
+			defer it.Close()
+			require.Equal(t, []string{"ic", "ib", "ia"}, read(t, it, &index.Pivot{Value: document.NewFloat64Value(2)}))
+			require.Equal(t, []string{"se", "sd", "sc", "sb", "sa"}, read(t, it, index.EmptyPivot(document.TextValue)))
+		})
+	}
+}
+
 func BenchmarkIndexSet(b *testing.B) {
 	for size := 10; size <= 10000; size *= 10 {
 		b.Run(fmt.Sprintf("%.05d", size), func(b *testing.B) {
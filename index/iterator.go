@@ -0,0 +1,194 @@
+package index
+
+import (
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/engine"
+)
+
+// IteratorOptions are used to configure an iterator.
+type IteratorOptions struct {
+	// Reverse makes the iterator go through the values in decreasing order.
+	Reverse bool
+}
+
+// An Iterator iterates on the values of an index and the keys associated with them,
+// in increasing order, or in decreasing order if the Reverse option is set.
+// The iterator is not positioned until Seek is called, and must be closed after use.
+// Once the iterator is positioned, Next must only be called if Valid returns true.
+type Iterator interface {
+	// Seek moves the iterator to the first value greater than or equal to the pivot,
+	// or less than or equal to the pivot in reverse order, among the values of the same type.
+	// If the pivot is nil, the iterator moves to the first value of the index,
+	// or to the last one in reverse order, and goes through the values of every type.
+	Seek(pivot *Pivot)
+	// Next moves the iterator to the next value.
+	Next()
+	// Valid returns whether the iterator is positioned on a value.
+	// It returns false once the iteration is over or if an error occurred.
+	Valid() bool
+	// Value returns the current value.
+	// It is only valid until the next move of the iterator.
+	Value() document.Value
+	// Key returns the key associated with the current value.
+	// It is only valid until the next move of the iterator.
+	Key() []byte
+	// Err returns the error which stopped the iteration, if any.
+	Err() error
+	// Close releases the resources associated with the iterator.
+	Close() error
+}
+
+// iteratorStore is a store read by an iterator, and the type of the values it contains.
+type iteratorStore struct {
+	name string
+	t    Type
+}
+
+// iterator reads the stores of an index one after the other, using one store iterator at a time.
+type iterator struct {
+	tx      engine.Transaction
+	reverse bool
+	// stores returns the stores to read to find the values following the pivot,
+	// and the key to seek in each of them.
+	stores func(pivot *Pivot, reverse bool) ([]iteratorStore, []byte, error)
+	// decode returns the value and the key of the current entry of the store iterator.
+	decode func(t Type, it engine.Iterator) (document.Value, []byte, error)
+
+	// stores left to read after the current one.
+	pending []iteratorStore
+	seek    []byte
+	t       Type
+	cur     engine.Iterator
+	val     document.Value
+	key     []byte
+	err     error
+}
+
+func (it *iterator) Seek(pivot *Pivot) {
+	it.closeStore()
+	if it.err != nil {
+		return
+	}
+
+	it.pending, it.seek, it.err = it.stores(pivot, it.reverse)
+	it.load()
+}
+
+func (it *iterator) Next() {
+	if it.reverse {
+		it.cur.Prev()
+	} else {
+		it.cur.Next()
+	}
+
+	it.load()
+}
+
+// load decodes the current entry of the store iterator. If the store has been entirely read,
+// it moves to the first entry of the next store which contains entries.
+func (it *iterator) load() {
+	for it.err == nil {
+		if it.cur != nil {
+			if it.cur.Valid() {
+				it.val, it.key, it.err = it.decode(it.t, it.cur)
+				return
+			}
+
+			it.err = it.cur.Err()
+			it.closeStore()
+			continue
+		}
+
+		if len(it.pending) == 0 {
+			return
+		}
+
+		s := it.pending[0]
+		it.pending = it.pending[1:]
+
+		st, err := it.tx.GetStore(s.name)
+		if err == engine.ErrStoreNotFound {
+			continue
+		}
+		if err != nil {
+			it.err = err
+			return
+		}
+
+		it.t = s.t
+		it.cur = st.Iterator(nil)
+		if it.reverse {
+			it.cur.SeekReverse(it.seek)
+		} else {
+			it.cur.Seek(it.seek)
+		}
+	}
+}
+
+// closeStore closes the store iterator, if any.
+func (it *iterator) closeStore() {
+	if it.cur == nil {
+		return
+	}
+
+	if err := it.cur.Close(); it.err == nil {
+		it.err = err
+	}
+	it.cur = nil
+}
+
+func (it *iterator) Valid() bool {
+	return it.err == nil && it.cur != nil
+}
+
+func (it *iterator) Value() document.Value {
+	return it.val
+}
+
+func (it *iterator) Key() []byte {
+	return it.key
+}
+
+func (it *iterator) Err() error {
+	return it.err
+}
+
+func (it *iterator) Close() error {
+	it.closeStore()
+	return it.err
+}
+
+// iterate calls fn with every value read by the iterator from the pivot, and closes the iterator.
+// If fn returns an error, the iteration stops and returns that error.
+func iterate(it Iterator, pivot *Pivot, fn func(val document.Value, key []byte) error) (err error) {
+	defer func() {
+		if cerr := it.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	for it.Seek(pivot); it.Valid(); it.Next() {
+		err = fn(it.Value(), it.Key())
+		if err != nil {
+			return err
+		}
+	}
+
+	return it.Err()
+}
+
+// typedStores returns the stores of an index storing its values in one store per type, in order.
+func typedStores(name string, reverse bool) []iteratorStore {
+	stores := make([]iteratorStore, 0, Bytes-Null+1)
+	for t := Null; t <= Bytes; t++ {
+		stores = append(stores, iteratorStore{name: buildIndexName(name, t), t: t})
+	}
+
+	if reverse {
+		for i, j := 0, len(stores)-1; i < j; i, j = i+1, j-1 {
+			stores[i], stores[j] = stores[j], stores[i]
+		}
+	}
+
+	return stores
+}
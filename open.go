@@ -4,9 +4,8 @@ package genji
 
 import (
 	"github.com/asdine/genji/engine"
-	"github.com/asdine/genji/engine/badgerengine"
 	"github.com/asdine/genji/engine/boltengine"
-	"github.com/dgraph-io/badger/v2"
+	"github.com/asdine/genji/engine/memoryengine"
 )
 
 // Open creates a Genji database at the given path.
@@ -18,7 +17,7 @@ func Open(path string) (*DB, error) {
 
 	switch path {
 	case ":memory:":
-		ng, err = badgerengine.NewEngine(badger.DefaultOptions("").WithInMemory(true).WithLogger(nil))
+		ng = memoryengine.NewEngine()
 	default:
 		ng, err = boltengine.NewEngine(path, 0660, nil)
 	}
@@ -0,0 +1,19 @@
+// +build wasm
+
+package genji
+
+import (
+	"errors"
+
+	"github.com/asdine/genji/engine/memoryengine"
+)
+
+// Open creates a Genji database. Only in memory databases are supported
+// on this platform, path must be equal to ":memory:".
+func Open(path string) (*DB, error) {
+	if path != ":memory:" {
+		return nil, errors.New("only in memory databases are supported")
+	}
+
+	return New(memoryengine.NewEngine())
+}
@@ -6,7 +6,6 @@ import (
 	"database/sql/driver"
 	"errors"
 	"io"
-	"sync"
 
 	"github.com/asdine/genji"
 	"github.com/asdine/genji/document"
@@ -28,7 +27,7 @@ func (d sqlDriver) Open(name string) (driver.Conn, error) {
 		return nil, err
 	}
 
-	return &conn{db: db}, nil
+	return newConn(db), nil
 }
 
 // proxyDriver is used to turn an existing DB into a driver.Driver.
@@ -43,7 +42,7 @@ func newDriver(db *genji.DB) driver.Driver {
 }
 
 func (d proxyDriver) Open(name string) (driver.Conn, error) {
-	return &conn{db: d.db}, nil
+	return newConn(d.db), nil
 }
 
 type proxyConnector struct {
@@ -66,21 +65,30 @@ func (c proxyConnector) Driver() driver.Driver {
 
 // conn represents a connection to the Genji database.
 // It implements the database/sql/driver.Conn interface.
+// A transaction started by a BEGIN statement is kept by the session of the connection.
 type conn struct {
 	db            *genji.DB
+	session       *query.Session
 	tx            *genji.Tx
 	nonPromotable bool
 }
 
+func newConn(db *genji.DB) *conn {
+	return &conn{
+		db:      db,
+		session: query.NewSession(db.DB),
+	}
+}
+
 // Prepare returns a prepared statement, bound to this connection.
 func (c *conn) Prepare(q string) (driver.Stmt, error) {
-	pq, err := parser.ParseQuery(q)
+	pq, err := parser.ParseQueryWithFunctions(q, c.db.Functions())
 	if err != nil {
 		return nil, err
 	}
 
 	return stmt{
-		db:            c.db,
+		session:       c.session,
 		tx:            c.tx,
 		q:             pq,
 		nonPromotable: c.nonPromotable,
@@ -90,10 +98,13 @@ func (c *conn) Prepare(q string) (driver.Stmt, error) {
 // Close closes any ongoing transaction.
 func (c *conn) Close() error {
 	if c.tx != nil {
-		return c.tx.Rollback()
+		err := c.tx.Rollback()
+		if err != nil {
+			return err
+		}
 	}
 
-	return nil
+	return c.session.Close()
 }
 
 // Begin starts and returns a new transaction.
@@ -101,7 +112,7 @@ func (c *conn) Begin() (driver.Tx, error) {
 	return c.BeginTx(context.Background(), driver.TxOptions{})
 }
 
-// BeginTx starts and returns a new transaction.
+// BeginTx starts and returns a new transaction, bound to ctx until it is committed or rolled back.
 // It uses the ReadOnly option to determine whether to start a read-only or read/write transaction.
 // If the Isolation option is non zero, an error is returned.
 func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
@@ -109,6 +120,10 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 		return nil, errors.New("isolation levels are not supported")
 	}
 
+	if c.session.Tx() != nil {
+		return nil, query.ErrTransactionInProgress
+	}
+
 	var err error
 
 	// if the ReadOnly flag is explicitly specified, create a non promotable transaction,
@@ -117,7 +132,7 @@ func (c *conn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, e
 		c.nonPromotable = true
 	}
 
-	c.tx, err = c.db.Begin(false)
+	c.tx, err = c.db.BeginTx(ctx, false)
 	return c, err
 }
 
@@ -138,7 +153,7 @@ func (c *conn) Rollback() error {
 // Stmt is a prepared statement. It is bound to a Conn and not
 // used by multiple goroutines concurrently.
 type stmt struct {
-	db            *genji.DB
+	session       *query.Session
 	tx            *genji.Tx
 	q             query.Query
 	nonPromotable bool
@@ -188,11 +203,12 @@ func (s stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver
 	var err error
 
 	// if calling ExecContext within a transaction, use it,
-	// otherwise use DB.
+	// otherwise use the session of the connection. The statements run within a transaction are
+	// bound to ctx as well as to the context the transaction was started with.
 	if s.tx != nil {
-		res, err = s.q.Exec(s.tx.Transaction, args, s.nonPromotable)
+		res, err = s.q.ExecContext(ctx, s.tx.Transaction, args, s.nonPromotable)
 	} else {
-		res, err = s.q.Run(s.db.DB, args)
+		res, err = s.session.Run(ctx, s.q, args)
 	}
 
 	if err != nil {
@@ -222,11 +238,12 @@ func (s stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (drive
 	var err error
 
 	// if calling QueryContext within a transaction, use it,
-	// otherwise use DB.
+	// otherwise use the session of the connection. The statements run within a transaction are
+	// bound to ctx as well as to the context the transaction was started with.
 	if s.tx != nil {
-		res, err = s.q.Exec(s.tx.Transaction, args, s.nonPromotable)
+		res, err = s.q.ExecContext(ctx, s.tx.Transaction, args, s.nonPromotable)
 	} else {
-		res, err = s.q.Run(s.db.DB, args)
+		res, err = s.session.Run(ctx, s.q, args)
 	}
 
 	if err != nil {
@@ -238,13 +255,27 @@ func (s stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (drive
 		return rs, nil
 	}
 
-	lastStmt := s.q.Statements[len(s.q.Statements)-1]
+	// the columns are the fields selected by the last statement,
+	// by the first statement of a compound SELECT statement,
+	// or those of its RETURNING clause for write statements.
+	var selectors []query.ResultField
+	switch t := s.q.Statements[len(s.q.Statements)-1].(type) {
+	case query.SelectStmt:
+		selectors = t.Selectors
+	case query.CompoundSelectStmt:
+		selectors = t.Selects[0].Selectors
+	case query.InsertStmt:
+		selectors = t.Returning
+	case query.UpdateStmt:
+		selectors = t.Returning
+	case query.DeleteStmt:
+		selectors = t.Returning
+	}
 
-	slct, ok := lastStmt.(query.SelectStmt)
-	if ok && len(slct.Selectors) > 0 {
-		rs.fields = make([]string, len(slct.Selectors))
-		for i := range slct.Selectors {
-			rs.fields[i] = slct.Selectors[i].Name()
+	if len(selectors) > 0 {
+		rs.fields = make([]string, len(selectors))
+		for i := range selectors {
+			rs.fields[i] = selectors[i].Name()
 		}
 	}
 
@@ -256,72 +287,18 @@ func (s stmt) Close() error {
 	return nil
 }
 
-var errStop = errors.New("stop")
-
+// documentStream returns the documents of a query result as driver rows.
+// Every call to Next moves the cursor of the result to the next document.
 type documentStream struct {
-	res      *query.Result
-	cancelFn func()
-	c        chan doc
-	wg       sync.WaitGroup
-	fields   []string
-}
-
-type doc struct {
-	d   document.Document
-	err error
+	res    *query.Result
+	cur    document.Cursor
+	fields []string
 }
 
 func newRecordStream(res *query.Result) *documentStream {
-	ctx, cancel := context.WithCancel(context.Background())
-
-	ds := documentStream{
-		res:      res,
-		cancelFn: cancel,
-		c:        make(chan doc),
-	}
-	ds.wg.Add(1)
-
-	go ds.iterate(ctx)
-
-	return &ds
-}
-
-func (rs *documentStream) iterate(ctx context.Context) {
-	defer rs.wg.Done()
-	defer close(rs.c)
-
-	select {
-	case <-ctx.Done():
-		return
-	case <-rs.c:
-	}
-
-	err := rs.res.Iterate(func(d document.Document) error {
-		select {
-		case <-ctx.Done():
-			return errStop
-		case rs.c <- doc{
-			d: d,
-		}:
-
-			select {
-			case <-ctx.Done():
-				return errStop
-			case <-rs.c:
-				return nil
-			}
-		}
-
-	})
-
-	if err == errStop || err == nil {
-		return
-	}
-	if err != nil {
-		rs.c <- doc{
-			err: err,
-		}
-		return
+	return &documentStream{
+		res: res,
+		cur: res.Cursor(),
 	}
 }
 
@@ -332,30 +309,33 @@ func (rs *documentStream) Columns() []string {
 
 // Close closes the rows iterator.
 func (rs *documentStream) Close() error {
-	rs.cancelFn()
-	return rs.res.Close()
+	err := rs.cur.Close()
+	if rerr := rs.res.Close(); err == nil {
+		err = rerr
+	}
+
+	return err
 }
 
 func (rs *documentStream) Next(dest []driver.Value) error {
-	rs.c <- doc{}
+	if !rs.cur.Next() {
+		if err := rs.cur.Err(); err != nil {
+			return err
+		}
 
-	doc, ok := <-rs.c
-	if !ok {
 		return io.EOF
 	}
 
-	if doc.err != nil {
-		return doc.err
-	}
+	d := rs.cur.Document()
 
 	for i := range rs.fields {
 		if rs.fields[i] == "*" {
-			dest[i] = doc.d
+			dest[i] = d
 
 			continue
 		}
 
-		f, err := doc.d.GetByField(rs.fields[i])
+		f, err := d.GetByField(rs.fields[i])
 		if err != nil {
 			return err
 		}
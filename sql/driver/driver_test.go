@@ -5,7 +5,9 @@ import (
 	"database/sql"
 	"testing"
 
+	"github.com/asdine/genji"
 	"github.com/asdine/genji/engine"
+	"github.com/asdine/genji/sql/query"
 	"github.com/stretchr/testify/require"
 )
 
@@ -132,6 +134,27 @@ func TestDriver(t *testing.T) {
 		require.Equal(t, 1, count)
 	})
 
+	t.Run("Close before the end", func(t *testing.T) {
+		rows, err := db.Query("SELECT a FROM test WHERE a > 2 ORDER BY a DESC")
+		require.NoError(t, err)
+
+		var a int
+		require.True(t, rows.Next())
+		err = rows.Scan(&a)
+		require.NoError(t, err)
+		require.Equal(t, 9, a)
+		require.NoError(t, rows.Close())
+
+		res, err := db.Exec("UPDATE test SET d = 1 WHERE a = 9")
+		require.NoError(t, err)
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		require.EqualValues(t, 1, n)
+
+		_, err = db.Exec("UPDATE test UNSET d")
+		require.NoError(t, err)
+	})
+
 	t.Run("Transactions", func(t *testing.T) {
 		tx, err := db.Begin()
 		require.NoError(t, err)
@@ -211,4 +234,99 @@ func TestDriver(t *testing.T) {
 		`)
 		require.Equal(t, err, engine.ErrTransactionReadOnly)
 	})
+
+	t.Run("Rows affected", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		res, err := tx.Exec("UPDATE test SET a = a + 100 WHERE a < 5")
+		require.NoError(t, err)
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		require.EqualValues(t, 5, n)
+
+		res, err = tx.Exec("DELETE FROM test WHERE a >= 100")
+		require.NoError(t, err)
+		n, err = res.RowsAffected()
+		require.NoError(t, err)
+		require.EqualValues(t, 5, n)
+	})
+
+	t.Run("Returning", func(t *testing.T) {
+		tx, err := db.Begin()
+		require.NoError(t, err)
+		defer tx.Rollback()
+
+		scan := func(q string) []int {
+			rows, err := tx.Query(q)
+			require.NoError(t, err)
+			defer rows.Close()
+
+			cols, err := rows.Columns()
+			require.NoError(t, err)
+			require.Equal(t, []string{"a"}, cols)
+
+			var list []int
+			for rows.Next() {
+				var a int
+				err = rows.Scan(&a)
+				require.NoError(t, err)
+				list = append(list, a)
+			}
+			require.NoError(t, rows.Err())
+			return list
+		}
+
+		require.Equal(t, []int{20, 21}, scan("INSERT INTO test (a) VALUES (20), (21) RETURNING a"))
+		require.Equal(t, []int{21, 22}, scan("UPDATE test SET a = a + 1 WHERE a >= 20 RETURNING a"))
+		require.Equal(t, []int{21, 22}, scan("DELETE FROM test WHERE a >= 20 RETURNING a"))
+		require.Empty(t, scan("DELETE FROM test WHERE a >= 20 RETURNING a"))
+	})
+}
+
+func TestDriverSessions(t *testing.T) {
+	gdb, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer gdb.Close()
+
+	db := sql.OpenDB(newProxyConnector(gdb))
+	defer db.Close()
+
+	ctx := context.Background()
+
+	_, err = db.ExecContext(ctx, "CREATE TABLE test")
+	require.NoError(t, err)
+
+	c1, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer c1.Close()
+	c2, err := db.Conn(ctx)
+	require.NoError(t, err)
+	defer c2.Close()
+
+	count := func(c *sql.Conn) int {
+		var n int
+		err := c.QueryRowContext(ctx, "SELECT COUNT(*) FROM test").Scan(&n)
+		require.NoError(t, err)
+		return n
+	}
+
+	_, err = c1.ExecContext(ctx, "BEGIN")
+	require.NoError(t, err)
+	_, err = c1.ExecContext(ctx, "INSERT INTO test (a) VALUES (1)")
+	require.NoError(t, err)
+	require.Equal(t, 1, count(c1))
+
+	// the transaction is only used by the connection that started it.
+	require.Equal(t, 0, count(c2))
+	_, err = c2.ExecContext(ctx, "COMMIT")
+	require.Equal(t, query.ErrNoTransaction, err)
+
+	_, err = c1.BeginTx(ctx, nil)
+	require.Equal(t, query.ErrTransactionInProgress, err)
+
+	_, err = c1.ExecContext(ctx, "COMMIT")
+	require.NoError(t, err)
+	require.Equal(t, 1, count(c2))
 }
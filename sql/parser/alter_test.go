@@ -0,0 +1,47 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserAlter(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		expected query.Statement
+		errored  bool
+	}{
+		{"Rename", "ALTER TABLE foo RENAME TO bar", query.AlterTableRenameStmt{TableName: "foo", NewTableName: "bar"}, false},
+		{"Rename missing TO", "ALTER TABLE foo RENAME bar", nil, true},
+		{"Add field with type", "ALTER TABLE foo ADD FIELD a.b INTEGER",
+			query.AlterTableAddFieldStmt{TableName: "foo", Constraint: database.FieldConstraint{Path: document.NewValuePath("a.b"), Type: document.Int64Value}}, false},
+		{"Add field with not null", "ALTER TABLE foo ADD FIELD a NOT NULL",
+			query.AlterTableAddFieldStmt{TableName: "foo", Constraint: database.FieldConstraint{Path: document.NewValuePath("a"), IsNotNull: true}}, false},
+		{"Add field with type and not null", "ALTER TABLE foo ADD FIELD a TEXT NOT NULL",
+			query.AlterTableAddFieldStmt{TableName: "foo", Constraint: database.FieldConstraint{Path: document.NewValuePath("a"), Type: document.TextValue, IsNotNull: true}}, false},
+		{"Add field with primary key", "ALTER TABLE foo ADD FIELD a PRIMARY KEY", nil, true},
+		{"Add missing FIELD", "ALTER TABLE foo ADD a INTEGER", nil, true},
+		{"Drop field", "ALTER TABLE foo DROP FIELD a.b", query.AlterTableDropFieldStmt{TableName: "foo", Path: document.NewValuePath("a.b")}, false},
+		{"Drop missing field", "ALTER TABLE foo DROP FIELD", nil, true},
+		{"Missing table", "ALTER foo RENAME TO bar", nil, true},
+		{"Unknown action", "ALTER TABLE foo SET a = 1", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := ParseQuery(test.s)
+			if test.errored {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, q.Statements, 1)
+			require.EqualValues(t, test.expected, q.Statements[0])
+		})
+	}
+}
@@ -205,20 +205,64 @@ func (p *Parser) parseCreateIndexStatement(unique bool) (query.CreateIndexStmt,
 		return stmt, err
 	}
 
-	paths, err := p.parsePathList()
+	fields, err := p.parseIndexFieldList()
 	if err != nil {
 		return stmt, err
 	}
-	if len(paths) == 0 {
-		tok, pos, lit := p.ScanIgnoreWhitespace()
-		return stmt, newParseError(scanner.Tokstr(tok, lit), []string{"("}, pos)
+
+	// indexes on a single field sorted in ascending order are not composite.
+	if len(fields) == 1 && !fields[0].Desc {
+		stmt.Path = fields[0].Path
+	} else {
+		stmt.Fields = fields
 	}
 
-	if len(paths) != 1 {
-		return stmt, &ParseError{Message: "indexes on more than one field are not supported"}
+	return stmt, nil
+}
+
+// parseIndexFieldList parses a list of paths in the form: (path [ASC|DESC], path [ASC|DESC], ...)
+func (p *Parser) parseIndexFieldList() ([]database.IndexField, error) {
+	// Parse ( token.
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.LPAREN {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"("}, pos)
 	}
 
-	stmt.Path = paths[0]
+	var fields []database.IndexField
+	for {
+		path, err := p.parseFieldRef()
+		if err != nil {
+			return nil, err
+		}
 
-	return stmt, nil
+		f := database.IndexField{Path: path}
+
+		// Parse optional ASC or DESC
+		switch tok, _, _ := p.ScanIgnoreWhitespace(); tok {
+		case scanner.DESC:
+			f.Desc = true
+		case scanner.ASC:
+		default:
+			p.Unscan()
+		}
+
+		for _, prev := range fields {
+			if prev.Path.String() == f.Path.String() {
+				return nil, &ParseError{Message: fmt.Sprintf("field %q is indexed more than once", f.Path.String())}
+			}
+		}
+
+		fields = append(fields, f)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			break
+		}
+	}
+
+	// Parse required ) token.
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.RPAREN {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{")"}, pos)
+	}
+
+	return fields, nil
 }
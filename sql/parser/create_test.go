@@ -116,7 +116,17 @@ func TestParserCreateIndex(t *testing.T) {
 		{"If not exists", "CREATE INDEX IF NOT EXISTS idx ON test (foo.bar.1)", query.CreateIndexStmt{IndexName: "idx", TableName: "test", Path: document.NewValuePath("foo.bar.1"), IfNotExists: true}, false},
 		{"Unique", "CREATE UNIQUE INDEX IF NOT EXISTS idx ON test (foo.3.baz)", query.CreateIndexStmt{IndexName: "idx", TableName: "test", Path: document.NewValuePath("foo.3.baz"), IfNotExists: true, Unique: true}, false},
 		{"No fields", "CREATE INDEX idx ON test", nil, true},
-		{"More than 1 field", "CREATE INDEX idx ON test (foo, bar)", nil, true},
+		{"Ascending", "CREATE INDEX idx ON test (foo ASC)", query.CreateIndexStmt{IndexName: "idx", TableName: "test", Path: document.NewValuePath("foo")}, false},
+		{"Descending", "CREATE INDEX idx ON test (foo DESC)", query.CreateIndexStmt{IndexName: "idx", TableName: "test", Fields: []database.IndexField{
+			{Path: document.NewValuePath("foo"), Desc: true},
+		}}, false},
+		{"Composite", "CREATE UNIQUE INDEX idx ON test (foo, bar.baz DESC, a ASC)", query.CreateIndexStmt{IndexName: "idx", TableName: "test", Unique: true, Fields: []database.IndexField{
+			{Path: document.NewValuePath("foo")},
+			{Path: document.NewValuePath("bar.baz"), Desc: true},
+			{Path: document.NewValuePath("a")},
+		}}, false},
+		{"Duplicate field", "CREATE INDEX idx ON test (foo, bar, foo DESC)", nil, true},
+		{"Empty list", "CREATE INDEX idx ON test ()", nil, true},
 	}
 
 	for _, test := range tests {
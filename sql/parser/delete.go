@@ -28,5 +28,11 @@ func (p *Parser) parseDeleteStatement() (query.DeleteStmt, error) {
 		return stmt, err
 	}
 
+	// Parse "RETURNING field, ..."
+	stmt.Returning, err = p.parseReturning()
+	if err != nil {
+		return stmt, err
+	}
+
 	return stmt, nil
 }
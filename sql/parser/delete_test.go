@@ -15,6 +15,15 @@ func TestParserDelete(t *testing.T) {
 	}{
 		{"NoCond", "DELETE FROM test", query.DeleteStmt{TableName: "test"}},
 		{"WithCond", "DELETE FROM test WHERE age = 10", query.DeleteStmt{TableName: "test", WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10))}},
+		{"Returning", "DELETE FROM test WHERE age = 10 RETURNING *, age AS a",
+			query.DeleteStmt{
+				TableName: "test",
+				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+				Returning: []query.ResultField{
+					query.Wildcard{},
+					query.ResultFieldExpr{Expr: query.FieldSelector([]string{"age"}), ExprName: "a"},
+				},
+			}},
 	}
 
 	for _, test := range tests {
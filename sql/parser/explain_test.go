@@ -0,0 +1,41 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserExplain(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		expected query.Statement
+		errored  bool
+	}{
+		{"Explain select", "EXPLAIN SELECT * FROM test",
+			query.ExplainStmt{Statement: query.SelectStmt{TableName: "test", Selectors: []query.ResultField{query.Wildcard{}}}}, false},
+		{"Explain update", "EXPLAIN UPDATE test SET a = 1",
+			query.ExplainStmt{Statement: query.UpdateStmt{TableName: "test", Pairs: []query.SetPair{{Path: document.NewValuePath("a"), Expr: query.IntValue(1)}}}}, false},
+		{"Explain delete", "EXPLAIN DELETE FROM test",
+			query.ExplainStmt{Statement: query.DeleteStmt{TableName: "test"}}, false},
+		{"Explain insert", "EXPLAIN INSERT INTO test (a) VALUES (1)", nil, true},
+		{"Explain explain", "EXPLAIN EXPLAIN SELECT * FROM test", nil, true},
+		{"Explain nothing", "EXPLAIN", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := ParseQuery(test.s)
+			if test.errored {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, q.Statements, 1)
+			require.EqualValues(t, test.expected, q.Statements[0])
+		})
+	}
+}
@@ -3,6 +3,7 @@ package parser
 import (
 	"bytes"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +30,21 @@ func (p *Parser) parseExpr() (query.Expr, string, error) {
 		defer func() { p.buf = nil }()
 	}
 
+	// expressions can be nested, i.e. in subqueries,
+	// only keep the part of the buffer written by this expression
+	start := p.buf.Len()
+
+	e, err := p.parseOperatorExpr(0)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return e, strings.TrimSpace(p.buf.String()[start:]), nil
+}
+
+// parseOperatorExpr parses an expression, stopping at the first operator
+// whose precedence is lower than or equal to the given precedence.
+func (p *Parser) parseOperatorExpr(precedence int) (query.Expr, error) {
 	var err error
 	// Dummy root node.
 	var root operator = query.NewCmpOp(nil, nil, 0)
@@ -37,7 +53,7 @@ func (p *Parser) parseExpr() (query.Expr, string, error) {
 	// This variable will always be the root of the expression tree.
 	e, err := p.parseUnaryExpr()
 	if err != nil {
-		return nil, "", err
+		return nil, err
 	}
 	root.SetRightHandExpr(e)
 
@@ -45,15 +61,29 @@ func (p *Parser) parseExpr() (query.Expr, string, error) {
 	for {
 		// If the next token is NOT an operator then return the expression.
 		op, _, _ := p.ScanIgnoreWhitespace()
-		if !op.IsOperator() {
+		if !isOperator(op) || operatorPrecedence(op) <= precedence {
 			p.Unscan()
-			return root.RightHand(), strings.TrimSpace(p.buf.String()), nil
+			return root.RightHand(), nil
+		}
+
+		op, negate, err := p.parseNegation(op)
+		if err != nil {
+			return nil, err
 		}
 
 		var rhs query.Expr
 
-		if rhs, err = p.parseUnaryExpr(); err != nil {
-			return nil, "", err
+		switch {
+		// the right hand side of regex operators is a regular expression literal
+		case scanner.IsRegexOp(op):
+			rhs, err = p.parseRegex()
+		case op == scanner.BETWEEN:
+			rhs, err = p.parseBetweenBounds()
+		default:
+			rhs, err = p.parseUnaryExpr()
+		}
+		if err != nil {
+			return nil, err
 		}
 
 		// Find the right spot in the tree to add the new expression by
@@ -64,7 +94,11 @@ func (p *Parser) parseExpr() (query.Expr, string, error) {
 			p, ok := node.RightHand().(operator)
 			if !ok || p.Precedence() >= op.Precedence() {
 				// Add the new expression here and break.
-				node.SetRightHandExpr(opToExpr(op, node.RightHand(), rhs))
+				e := opToExpr(op, node.RightHand(), rhs)
+				if negate {
+					e = query.Not(e)
+				}
+				node.SetRightHandExpr(e)
 				break
 			}
 			node = p
@@ -72,6 +106,65 @@ func (p *Parser) parseExpr() (query.Expr, string, error) {
 	}
 }
 
+// isOperator returns true if tok is a binary operator or the NOT keyword,
+// which starts the NOT IN, NOT LIKE and NOT BETWEEN operators.
+func isOperator(tok scanner.Token) bool {
+	return tok.IsOperator() || tok == scanner.NOT
+}
+
+// operatorPrecedence returns the precedence of the operator starting with tok.
+func operatorPrecedence(tok scanner.Token) int {
+	if tok == scanner.NOT {
+		return scanner.IN.Precedence()
+	}
+
+	return tok.Precedence()
+}
+
+// parseNegation parses the rest of the negated operators, NOT IN, NOT LIKE, NOT BETWEEN and IS NOT,
+// and returns the operator and whether it is negated.
+// This function assumes the first token of the operator has already been consumed.
+func (p *Parser) parseNegation(op scanner.Token) (scanner.Token, bool, error) {
+	switch op {
+	case scanner.NOT:
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		switch tok {
+		case scanner.IN, scanner.LIKE, scanner.BETWEEN:
+			return tok, true, nil
+		}
+
+		return 0, false, newParseError(scanner.Tokstr(tok, lit), []string{"IN", "LIKE", "BETWEEN"}, pos)
+	case scanner.IS:
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.NOT {
+			return op, true, nil
+		}
+		p.Unscan()
+	}
+
+	return op, false, nil
+}
+
+// parseBetweenBounds parses the bounds of a BETWEEN operator in the form: expr AND expr.
+// The bounds can't contain comparison or logical operators.
+// This function assumes the BETWEEN token has already been consumed.
+func (p *Parser) parseBetweenBounds() (query.Expr, error) {
+	a, err := p.parseOperatorExpr(scanner.BETWEEN.Precedence())
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.AND {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"AND"}, pos)
+	}
+
+	b, err := p.parseOperatorExpr(scanner.BETWEEN.Precedence())
+	if err != nil {
+		return nil, err
+	}
+
+	return query.LiteralExprList{a, b}, nil
+}
+
 func opToExpr(op scanner.Token, lhs, rhs query.Expr) query.Expr {
 	switch op {
 	case scanner.EQ:
@@ -86,6 +179,23 @@ func opToExpr(op scanner.Token, lhs, rhs query.Expr) query.Expr {
 		return query.Lt(lhs, rhs)
 	case scanner.LTE:
 		return query.Lte(lhs, rhs)
+	case scanner.EQREGEX:
+		return query.EqRegex(lhs, rhs)
+	case scanner.NEQREGEX:
+		return query.NeqRegex(lhs, rhs)
+	case scanner.IN:
+		// the right hand side of IN can be a subquery returning several documents
+		if sq, ok := rhs.(*query.Subquery); ok {
+			return query.In(lhs, query.SubqueryArray{Subquery: sq})
+		}
+		return query.In(lhs, rhs)
+	case scanner.IS:
+		return query.Is(lhs, rhs)
+	case scanner.LIKE:
+		return query.Like(lhs, rhs)
+	case scanner.BETWEEN:
+		bounds := rhs.(query.LiteralExprList)
+		return query.Between(lhs, bounds[0], bounds[1])
 	case scanner.AND:
 		return query.And(lhs, rhs)
 	case scanner.OR:
@@ -111,10 +221,37 @@ func opToExpr(op scanner.Token, lhs, rhs query.Expr) query.Expr {
 	panic(fmt.Sprintf("unknown operator %q", op))
 }
 
+// parseRegex parses a regular expression literal in the form /pattern/.
+// The regular expression is compiled once, when the query is parsed.
+func (p *Parser) parseRegex() (query.Expr, error) {
+	ti := p.s.ScanRegex()
+	if p.buf != nil {
+		p.buf.WriteString(ti.Raw)
+	}
+
+	if ti.Tok != scanner.REGEX {
+		return nil, newParseError(scanner.Tokstr(ti.Tok, ti.Raw), []string{"regex"}, ti.Pos)
+	}
+
+	re, err := regexp.Compile(ti.Lit)
+	if err != nil {
+		return nil, &ParseError{Message: err.Error(), Pos: ti.Pos}
+	}
+
+	return query.Regex{Regexp: re}, nil
+}
+
 // parseUnaryExpr parses an non-binary expression.
 func (p *Parser) parseUnaryExpr() (query.Expr, error) {
 	tok, pos, lit := p.ScanIgnoreWhitespace()
 	switch tok {
+	case scanner.NOT:
+		// NOT applies to the whole following comparison, e.g. NOT a = 1.
+		e, err := p.parseOperatorExpr(scanner.AND.Precedence())
+		if err != nil {
+			return nil, err
+		}
+		return query.Not(e), nil
 	case scanner.CAST:
 		p.Unscan()
 		return p.parseCastExpression()
@@ -184,8 +321,24 @@ func (p *Parser) parseUnaryExpr() (query.Expr, error) {
 		p.Unscan()
 		return p.parseExprList(scanner.LSBRACKET, scanner.RSBRACKET)
 	case scanner.LPAREN:
+		// if the parenthesis is followed by SELECT, this is a subquery
+		if tok1, _, _ := p.ScanIgnoreWhitespace(); tok1 == scanner.SELECT {
+			return p.parseSubquery()
+		}
 		p.Unscan()
-		return p.parseExprList(scanner.LPAREN, scanner.RPAREN)
+		return p.parseExprListItems(scanner.RPAREN)
+	case scanner.EXISTS:
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.LPAREN {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"("}, pos)
+		}
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.SELECT {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"SELECT"}, pos)
+		}
+		sq, err := p.parseSubquery()
+		if err != nil {
+			return nil, err
+		}
+		return query.Exists(sq), nil
 	default:
 		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"identifier", "string", "number", "bool"}, pos)
 	}
@@ -384,12 +537,33 @@ LOOP:
 	return fieldRef, nil
 }
 
+// parseSubquery parses a SELECT statement enclosed in parentheses.
+// This function assumes the left parenthesis and the SELECT token have already been consumed.
+func (p *Parser) parseSubquery() (*query.Subquery, error) {
+	stmt, err := p.parseSelectStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.RPAREN {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{")"}, pos)
+	}
+
+	return &query.Subquery{Statement: stmt}, nil
+}
+
 func (p *Parser) parseExprList(leftToken, rightToken scanner.Token) (query.LiteralExprList, error) {
 	// Parse ( or [ token.
 	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != leftToken {
 		return nil, newParseError(scanner.Tokstr(tok, lit), []string{leftToken.String()}, pos)
 	}
 
+	return p.parseExprListItems(rightToken)
+}
+
+// parseExprListItems parses a comma delimited list of expressions and the closing token.
+// This function assumes the opening token has already been consumed.
+func (p *Parser) parseExprListItems(rightToken scanner.Token) (query.LiteralExprList, error) {
 	var exprList query.LiteralExprList
 	var expr query.Expr
 	var err error
@@ -417,10 +591,80 @@ func (p *Parser) parseExprList(leftToken, rightToken scanner.Token) (query.Liter
 	return exprList, nil
 }
 
-// parseFunction parses a function call.
+// parseFunction parses a function call, followed by an optional OVER clause.
+func (p *Parser) parseFunction() (query.Expr, error) {
+	fn, err := p.parseFunctionCall()
+	if err != nil {
+		return nil, err
+	}
+
+	// Parse optional OVER clause.
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.OVER {
+		p.Unscan()
+		return fn, nil
+	}
+
+	w, err := p.parseWindow()
+	if err != nil {
+		return nil, err
+	}
+
+	return query.NewWindowFunc(fn, w)
+}
+
+// parseWindow parses the window of an OVER clause, of the form
+// ( [PARTITION BY expr [, expr...]] [ORDER BY term [, term...]] ).
+// This function assumes the OVER token has already been consumed.
+func (p *Parser) parseWindow() (query.Window, error) {
+	var w query.Window
+
+	// Parse required ( token.
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.LPAREN {
+		return w, newParseError(scanner.Tokstr(tok, lit), []string{"("}, pos)
+	}
+
+	// Parse optional PARTITION BY clause.
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.PARTITION {
+		// Parse required BY token.
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.BY {
+			return w, newParseError(scanner.Tokstr(tok, lit), []string{"BY"}, pos)
+		}
+
+		for {
+			expr, _, err := p.parseExpr()
+			if err != nil {
+				return w, err
+			}
+			w.PartitionBy = append(w.PartitionBy, expr)
+
+			if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+				p.Unscan()
+				break
+			}
+		}
+	} else {
+		p.Unscan()
+	}
+
+	// Parse optional ORDER BY clause.
+	var err error
+	w.OrderBy, err = p.parseOrderBy()
+	if err != nil {
+		return w, err
+	}
+
+	// Parse required ) token.
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.RPAREN {
+		return w, newParseError(scanner.Tokstr(tok, lit), []string{")"}, pos)
+	}
+
+	return w, nil
+}
+
+// parseFunctionCall parses a function call.
 // a function is an identifier followed by a parenthesis,
 // an optional coma-separated list of expressions and a closing parenthesis.
-func (p *Parser) parseFunction() (query.Expr, error) {
+func (p *Parser) parseFunctionCall() (query.Expr, error) {
 	// Parse function name.
 	fname, err := p.parseIdent()
 	if err != nil {
@@ -434,7 +678,18 @@ func (p *Parser) parseFunction() (query.Expr, error) {
 
 	// Check if the function is called without arguments.
 	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.RPAREN {
-		return query.GetFunc(fname)
+		return p.functions.GetFunc(fname)
+	}
+	p.Unscan()
+
+	// Check if the function is called with a wildcard, like in COUNT(*).
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.MUL {
+		// Parse required ) token.
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.RPAREN {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{")"}, pos)
+		}
+
+		return p.functions.GetFunc(fname, query.Wildcard{})
 	}
 	p.Unscan()
 
@@ -449,9 +704,13 @@ func (p *Parser) parseFunction() (query.Expr, error) {
 
 		exprs = append(exprs, expr)
 
-		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
-			p.Unscan()
-			return query.GetFunc(fname, exprs...)
+		tok, pos, lit := p.ScanIgnoreWhitespace()
+		switch tok {
+		case scanner.COMMA:
+		case scanner.RPAREN:
+			return p.functions.GetFunc(fname, exprs...)
+		default:
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{",", ")"}, pos)
 		}
 	}
 }
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"regexp"
 	"strings"
 	"testing"
 	"time"
@@ -124,6 +125,30 @@ func TestParserExpr(t *testing.T) {
 		{"/", "age / 10", query.Div(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
 		{"%", "age % 10", query.Mod(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
 		{"&", "age & 10", query.BitwiseAnd(query.FieldSelector([]string{"age"}), query.IntValue(10)), false},
+		{"=~", "name =~ /^foo.*$/", query.EqRegex(query.FieldSelector([]string{"name"}), query.Regex{Regexp: regexp.MustCompile("^foo.*$")}), false},
+		{"!~", "name !~ /a\\/b/", query.NeqRegex(query.FieldSelector([]string{"name"}), query.Regex{Regexp: regexp.MustCompile("a/b")}), false},
+		{"=~ without regex", "name =~ 'foo'", nil, true},
+		{"=~ with invalid regex", "name =~ /(/", nil, true},
+		{"IN", "age IN (10, 11)", query.In(query.FieldSelector([]string{"age"}), query.LiteralExprList{query.IntValue(10), query.IntValue(11)}), false},
+		{"NOT IN", "age NOT IN (10, 11)", query.Not(query.In(query.FieldSelector([]string{"age"}), query.LiteralExprList{query.IntValue(10), query.IntValue(11)})), false},
+		{"IS", "age IS NULL", query.Is(query.FieldSelector([]string{"age"}), query.NullValue()), false},
+		{"IS NOT", "age IS NOT NULL", query.Not(query.Is(query.FieldSelector([]string{"age"}), query.NullValue())), false},
+		{"LIKE", "name LIKE 'foo%'", query.Like(query.FieldSelector([]string{"name"}), query.TextValue("foo%")), false},
+		{"NOT LIKE", "name NOT LIKE 'foo%'", query.Not(query.Like(query.FieldSelector([]string{"name"}), query.TextValue("foo%"))), false},
+		{"BETWEEN", "age BETWEEN 1 AND 10 + 1", query.Between(query.FieldSelector([]string{"age"}), query.IntValue(1), query.Add(query.IntValue(10), query.IntValue(1))), false},
+		{"NOT BETWEEN", "age NOT BETWEEN 1 AND 10", query.Not(query.Between(query.FieldSelector([]string{"age"}), query.IntValue(1), query.IntValue(10))), false},
+		{"BETWEEN then AND", "age BETWEEN 1 AND 10 AND a",
+			query.And(
+				query.Between(query.FieldSelector([]string{"age"}), query.IntValue(1), query.IntValue(10)),
+				query.FieldSelector([]string{"a"}),
+			), false},
+		{"BETWEEN without AND", "age BETWEEN 1 OR 10", nil, true},
+		{"NOT without operator", "age NOT 10", nil, true},
+		{"NOT", "NOT age = 10 AND a", query.And(
+			query.Not(query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10))),
+			query.FieldSelector([]string{"a"}),
+		), false},
+		{"NOT NOT", "NOT NOT a", query.Not(query.Not(query.FieldSelector([]string{"a"}))), false},
 		{"precedence", "4 > 1 + 2", query.Gt(
 			query.IntValue(4),
 			query.Add(
@@ -152,6 +177,37 @@ func TestParserExpr(t *testing.T) {
 		{"with NULL", "age > NULL", query.Gt(query.FieldSelector([]string{"age"}), query.NullValue()), false},
 		{"pk() function", "pk()", &query.PKFunc{}, false},
 		{"CAST", "CAST(a.b.1.0 AS TEXT)", query.Cast{Expr: query.FieldSelector([]string{"a", "b", "1", "0"}), ConvertTo: document.TextValue}, false},
+
+		// subqueries
+		{"subquery", "a = (SELECT b FROM test)",
+			query.Eq(
+				query.FieldSelector([]string{"a"}),
+				&query.Subquery{Statement: query.SelectStmt{
+					Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"b"}), ExprName: "b"}},
+					TableName: "test",
+				}},
+			), false},
+		{"IN subquery", "a IN (SELECT b FROM test WHERE c > 1)",
+			query.In(
+				query.FieldSelector([]string{"a"}),
+				query.SubqueryArray{Subquery: &query.Subquery{Statement: query.SelectStmt{
+					Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"b"}), ExprName: "b"}},
+					TableName: "test",
+					WhereExpr: query.Gt(query.FieldSelector([]string{"c"}), query.IntValue(1)),
+				}}},
+			), false},
+		{"EXISTS", "EXISTS (SELECT * FROM test)",
+			query.Exists(&query.Subquery{Statement: query.SelectStmt{
+				Selectors: []query.ResultField{query.Wildcard{}},
+				TableName: "test",
+			}}), false},
+		{"NOT EXISTS", "NOT EXISTS (SELECT * FROM test)",
+			query.Not(query.Exists(&query.Subquery{Statement: query.SelectStmt{
+				Selectors: []query.ResultField{query.Wildcard{}},
+				TableName: "test",
+			}})), false},
+		{"EXISTS without SELECT", "EXISTS (1)", nil, true},
+		{"unclosed subquery", "a = (SELECT b FROM test", nil, true},
 	}
 
 	for _, test := range tests {
@@ -31,8 +31,32 @@ func (p *Parser) parseInsertStatement() (query.InsertStmt, error) {
 		stmt.FieldNames = fields
 	}
 
-	// Parse VALUES (v1, v2, v3)
-	stmt.Values, err = p.parseValues()
+	// Parse "SELECT ..."
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.SELECT {
+		slct, err := p.parseSelectStatement()
+		if err != nil {
+			return stmt, err
+		}
+
+		stmt.SelectStmt = &slct
+	} else {
+		p.Unscan()
+
+		// Parse VALUES (v1, v2, v3)
+		stmt.Values, err = p.parseValues()
+		if err != nil {
+			return stmt, err
+		}
+	}
+
+	// Parse "ON CONFLICT DO NOTHING" or "ON CONFLICT DO UPDATE SET ..."
+	stmt.OnConflict, err = p.parseOnConflictClause()
+	if err != nil {
+		return stmt, err
+	}
+
+	// Parse "RETURNING field, ..."
+	stmt.Returning, err = p.parseReturning()
 	if err != nil {
 		return stmt, err
 	}
@@ -40,6 +64,37 @@ func (p *Parser) parseInsertStatement() (query.InsertStmt, error) {
 	return stmt, nil
 }
 
+// parseOnConflictClause parses the "ON CONFLICT" clause of the query, if it exists.
+func (p *Parser) parseOnConflictClause() (*query.OnConflictClause, error) {
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.ON {
+		p.Unscan()
+		return nil, nil
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.CONFLICT {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"CONFLICT"}, pos)
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.DO {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"DO"}, pos)
+	}
+
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	switch tok {
+	case scanner.NOTHING:
+		return &query.OnConflictClause{DoNothing: true}, nil
+	case scanner.UPDATE:
+		pairs, err := p.parseSetClause()
+		if err != nil {
+			return nil, err
+		}
+
+		return &query.OnConflictClause{Pairs: pairs}, nil
+	}
+
+	return nil, newParseError(scanner.Tokstr(tok, lit), []string{"NOTHING", "UPDATE"}, pos)
+}
+
 // parseFieldList parses a list of fields in the form: (field, field, ...), if exists
 func (p *Parser) parseFieldList() ([]string, bool, error) {
 	// Parse ( token.
@@ -133,3 +188,13 @@ func (p *Parser) parseValue() (query.Expr, error) {
 
 	return expr, nil
 }
+
+// parseReturning parses the "RETURNING" clause of the query, if it exists.
+func (p *Parser) parseReturning() ([]query.ResultField, error) {
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.RETURNING {
+		p.Unscan()
+		return nil, nil
+	}
+
+	return p.parseResultFields()
+}
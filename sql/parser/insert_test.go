@@ -3,6 +3,7 @@ package parser
 import (
 	"testing"
 
+	"github.com/asdine/genji/document"
 	"github.com/asdine/genji/sql/query"
 	"github.com/stretchr/testify/require"
 )
@@ -68,6 +69,75 @@ func TestParserInsert(t *testing.T) {
 					query.LiteralExprList{query.TextValue("e"), query.TextValue("f")},
 				},
 			}, false},
+		{"Select", "INSERT INTO test SELECT * FROM foo",
+			query.InsertStmt{
+				TableName: "test",
+				SelectStmt: &query.SelectStmt{
+					Selectors: []query.ResultField{query.Wildcard{}},
+					TableName: "foo",
+				},
+			}, false},
+		{"Select / With columns", "INSERT INTO test (a, b) SELECT c, d FROM foo WHERE c > 1",
+			query.InsertStmt{
+				TableName:  "test",
+				FieldNames: []string{"a", "b"},
+				SelectStmt: &query.SelectStmt{
+					Selectors: []query.ResultField{
+						query.ResultFieldExpr{Expr: query.FieldSelector([]string{"c"}), ExprName: "c"},
+						query.ResultFieldExpr{Expr: query.FieldSelector([]string{"d"}), ExprName: "d"},
+					},
+					TableName: "foo",
+					WhereExpr: query.Gt(query.FieldSelector([]string{"c"}), query.IntValue(1)),
+				},
+			}, false},
+		{"On conflict / Do nothing", "INSERT INTO test (a) VALUES (1) ON CONFLICT DO NOTHING",
+			query.InsertStmt{
+				TableName:  "test",
+				FieldNames: []string{"a"},
+				Values: query.LiteralExprList{
+					query.LiteralExprList{query.IntValue(1)},
+				},
+				OnConflict: &query.OnConflictClause{DoNothing: true},
+			}, false},
+		{"On conflict / Do update", "INSERT INTO test VALUES {a: 1, b: 2} ON CONFLICT DO UPDATE SET b = excluded.b, c = c + 1",
+			query.InsertStmt{
+				TableName: "test",
+				Values: query.LiteralExprList{
+					query.KVPairs{
+						query.KVPair{K: "a", V: query.IntValue(1)},
+						query.KVPair{K: "b", V: query.IntValue(2)},
+					},
+				},
+				OnConflict: &query.OnConflictClause{Pairs: []query.SetPair{
+					{Path: document.NewValuePath("b"), Expr: query.FieldSelector([]string{"excluded", "b"})},
+					{Path: document.NewValuePath("c"), Expr: query.Add(query.FieldSelector([]string{"c"}), query.IntValue(1))},
+				}},
+			}, false},
+		{"On conflict / Select", "INSERT INTO test SELECT * FROM foo ON CONFLICT DO NOTHING",
+			query.InsertStmt{
+				TableName: "test",
+				SelectStmt: &query.SelectStmt{
+					Selectors: []query.ResultField{query.Wildcard{}},
+					TableName: "foo",
+				},
+				OnConflict: &query.OnConflictClause{DoNothing: true},
+			}, false},
+		{"Returning", "INSERT INTO test (a) VALUES (1) ON CONFLICT DO NOTHING RETURNING pk(), a",
+			query.InsertStmt{
+				TableName:  "test",
+				FieldNames: []string{"a"},
+				Values: query.LiteralExprList{
+					query.LiteralExprList{query.IntValue(1)},
+				},
+				OnConflict: &query.OnConflictClause{DoNothing: true},
+				Returning: []query.ResultField{
+					query.ResultFieldExpr{Expr: &query.PKFunc{}, ExprName: "pk()"},
+					query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "a"},
+				},
+			}, false},
+		{"Returning / Missing fields", "INSERT INTO test (a) VALUES (1) RETURNING", nil, true},
+		{"On conflict / Missing action", "INSERT INTO test (a) VALUES (1) ON CONFLICT", nil, true},
+		{"On conflict / Invalid action", "INSERT INTO test (a) VALUES (1) ON CONFLICT DO DELETE", nil, true},
 	}
 
 	for _, test := range tests {
@@ -17,6 +17,7 @@ type Parser struct {
 	orderedParams int
 	namedParams   int
 	buf           *bytes.Buffer
+	functions     *query.Functions
 }
 
 // NewParser returns a new instance of Parser.
@@ -27,6 +28,14 @@ func NewParser(r io.Reader) *Parser {
 // ParseQuery parses a query string and returns its AST representation.
 func ParseQuery(s string) (query.Query, error) { return NewParser(strings.NewReader(s)).ParseQuery() }
 
+// ParseQueryWithFunctions parses a query string and returns its AST representation.
+// Function calls are resolved using the builtin functions and the given ones.
+func ParseQueryWithFunctions(s string, fns *query.Functions) (query.Query, error) {
+	p := NewParser(strings.NewReader(s))
+	p.functions = fns
+	return p.ParseQuery()
+}
+
 // ParseQuery parses a Genji SQL string and returns a Query.
 func (p *Parser) ParseQuery() (query.Query, error) {
 	var statements []query.Statement
@@ -57,7 +66,7 @@ func (p *Parser) ParseStatement() (query.Statement, error) {
 	tok, pos, lit := p.ScanIgnoreWhitespace()
 	switch tok {
 	case scanner.SELECT:
-		return p.parseSelectStatement()
+		return p.parseCompoundSelectStatement()
 	case scanner.DELETE:
 		return p.parseDeleteStatement()
 	case scanner.UPDATE:
@@ -68,10 +77,20 @@ func (p *Parser) ParseStatement() (query.Statement, error) {
 		return p.parseCreateStatement()
 	case scanner.DROP:
 		return p.parseDropStatement()
+	case scanner.ALTER:
+		return p.parseAlterStatement()
+	case scanner.EXPLAIN:
+		return p.parseExplainStatement()
+	case scanner.BEGIN:
+		return p.parseBeginStatement()
+	case scanner.COMMIT:
+		return p.parseCommitStatement()
+	case scanner.ROLLBACK:
+		return p.parseRollbackStatement()
 	}
 
 	return nil, newParseError(scanner.Tokstr(tok, lit), []string{
-		"SELECT", "DELETE", "UPDATE", "INSERT", "CREATE", "DROP",
+		"SELECT", "DELETE", "UPDATE", "INSERT", "CREATE", "DROP", "ALTER", "EXPLAIN", "BEGIN", "COMMIT", "ROLLBACK",
 	}, pos)
 }
 
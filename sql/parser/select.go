@@ -11,6 +11,13 @@ func (p *Parser) parseSelectStatement() (query.SelectStmt, error) {
 	var stmt query.SelectStmt
 	var err error
 
+	// Parse "DISTINCT".
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.DISTINCT {
+		stmt.Distinct = true
+	} else {
+		p.Unscan()
+	}
+
 	// Parse field list or query.Wildcard
 	stmt.Selectors, err = p.parseResultFields()
 	if err != nil {
@@ -24,14 +31,32 @@ func (p *Parser) parseSelectStatement() (query.SelectStmt, error) {
 		return stmt, err
 	}
 
+	// Parse table alias: "[AS] alias"
+	stmt.TableAlias, err = p.parseTableAlias()
+	if err != nil {
+		return stmt, err
+	}
+
+	// Parse joins: "[INNER | LEFT [OUTER]] JOIN table [[AS] alias] ON EXPR"
+	stmt.Joins, err = p.parseJoins()
+	if err != nil {
+		return stmt, err
+	}
+
 	// Parse condition: "WHERE EXPR".
 	stmt.WhereExpr, err = p.parseCondition()
 	if err != nil {
 		return stmt, err
 	}
 
-	// Parse order by: "ORDER BY fieldRef [ASC|DESC]?"
-	stmt.OrderBy, stmt.OrderByDirection, err = p.parseOrderBy()
+	// Parse group by: "GROUP BY fieldRef [, fieldRef]*"
+	stmt.GroupBy, err = p.parseGroupBy()
+	if err != nil {
+		return stmt, err
+	}
+
+	// Parse order by: "ORDER BY expr [ASC|DESC]? [NULLS FIRST|LAST]? [, ...]*"
+	stmt.OrderBy, err = p.parseOrderBy()
 	if err != nil {
 		return stmt, err
 	}
@@ -51,6 +76,81 @@ func (p *Parser) parseSelectStatement() (query.SelectStmt, error) {
 	return stmt, nil
 }
 
+// parseCompoundSelectStatement parses a SELECT statement, optionally followed by other SELECT
+// statements combined with the UNION [ALL], INTERSECT and EXCEPT operators.
+// If there is only one statement, it returns a query.SelectStmt. Otherwise, the ORDER BY, LIMIT
+// and OFFSET clauses of the last statement apply to the combined result, and the other
+// statements cannot have them.
+// This function assumes the first SELECT token has already been consumed.
+func (p *Parser) parseCompoundSelectStatement() (query.Statement, error) {
+	first, err := p.parseSelectStatement()
+	if err != nil {
+		return nil, err
+	}
+
+	stmt := query.CompoundSelectStmt{
+		Selects: []query.SelectStmt{first},
+	}
+
+	for {
+		op, ok := p.parseCompoundOperator()
+		if !ok {
+			break
+		}
+
+		last := stmt.Selects[len(stmt.Selects)-1]
+		if len(last.OrderBy) != 0 || last.LimitExpr != nil || last.OffsetExpr != nil {
+			return nil, &ParseError{Message: "ORDER BY, LIMIT and OFFSET clauses must follow the last SELECT statement of a compound statement"}
+		}
+
+		// parse SELECT token
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.SELECT {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"SELECT"}, pos)
+		}
+
+		slct, err := p.parseSelectStatement()
+		if err != nil {
+			return nil, err
+		}
+
+		stmt.Operators = append(stmt.Operators, op)
+		stmt.Selects = append(stmt.Selects, slct)
+	}
+
+	if len(stmt.Selects) == 1 {
+		return first, nil
+	}
+
+	last := &stmt.Selects[len(stmt.Selects)-1]
+	stmt.OrderBy, stmt.LimitExpr, stmt.OffsetExpr = last.OrderBy, last.LimitExpr, last.OffsetExpr
+	last.OrderBy, last.LimitExpr, last.OffsetExpr = nil, nil, nil
+
+	return stmt, nil
+}
+
+// parseCompoundOperator parses "UNION [ALL]", "INTERSECT" or "EXCEPT", if it exists.
+func (p *Parser) parseCompoundOperator() (query.CompoundOperator, bool) {
+	var op query.CompoundOperator
+
+	tok, _, _ := p.ScanIgnoreWhitespace()
+	switch tok {
+	case scanner.UNION:
+		// parse optional ALL token
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.ALL {
+			op.All = true
+		} else {
+			p.Unscan()
+		}
+	case scanner.INTERSECT, scanner.EXCEPT:
+	default:
+		p.Unscan()
+		return op, false
+	}
+
+	op.Token = tok
+	return op, true
+}
+
 // parseResultFields parses the list of result fields.
 func (p *Parser) parseResultFields() ([]query.ResultField, error) {
 	// Parse first (required) result field.
@@ -115,31 +215,164 @@ func (p *Parser) parseFrom() (string, bool, error) {
 	return ident, true, err
 }
 
-func (p *Parser) parseOrderBy() (query.FieldSelector, scanner.Token, error) {
+func (p *Parser) parseTableAlias() (string, error) {
+	tok, _, lit := p.ScanIgnoreWhitespace()
+	switch tok {
+	case scanner.AS:
+		return p.parseIdent()
+	case scanner.IDENT:
+		return lit, nil
+	}
+	p.Unscan()
+
+	return "", nil
+}
+
+func (p *Parser) parseJoins() ([]query.JoinClause, error) {
+	var joins []query.JoinClause
+
+	for {
+		var j query.JoinClause
+
+		// parse join type
+		tok, _, _ := p.ScanIgnoreWhitespace()
+		switch tok {
+		case scanner.JOIN:
+			j.Type = scanner.INNER
+			p.Unscan()
+		case scanner.INNER:
+			j.Type = scanner.INNER
+		case scanner.LEFT:
+			j.Type = scanner.LEFT
+
+			// parse optional OUTER token
+			if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.OUTER {
+				p.Unscan()
+			}
+		default:
+			p.Unscan()
+			return joins, nil
+		}
+
+		// parse JOIN token
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.JOIN {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"JOIN"}, pos)
+		}
+
+		// parse table name
+		var err error
+		j.TableName, err = p.parseIdent()
+		if err != nil {
+			return nil, err
+		}
+
+		j.TableAlias, err = p.parseTableAlias()
+		if err != nil {
+			return nil, err
+		}
+
+		// parse ON token
+		if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.ON {
+			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"ON"}, pos)
+		}
+
+		j.On, _, err = p.parseExpr()
+		if err != nil {
+			return nil, err
+		}
+
+		joins = append(joins, j)
+	}
+}
+
+func (p *Parser) parseGroupBy() ([]query.FieldSelector, error) {
+	// parse GROUP token
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.GROUP {
+		p.Unscan()
+		return nil, nil
+	}
+
+	// parse BY token
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.BY {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"BY"}, pos)
+	}
+
+	var fields []query.FieldSelector
+
+	// parse field references
+	for {
+		ref, err := p.parseFieldRef()
+		if err != nil {
+			return nil, err
+		}
+
+		fields = append(fields, query.FieldSelector(ref))
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			return fields, nil
+		}
+	}
+}
+
+func (p *Parser) parseOrderBy() ([]query.OrderingTerm, error) {
 	// parse ORDER token
 	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.ORDER {
 		p.Unscan()
-		return nil, 0, nil
+		return nil, nil
 	}
 
 	// parse BY token
 	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.BY {
-		return nil, 0, newParseError(scanner.Tokstr(tok, lit), []string{"BY"}, pos)
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"BY"}, pos)
+	}
+
+	var terms []query.OrderingTerm
+	for {
+		term, err := p.parseOrderingTerm()
+		if err != nil {
+			return nil, err
+		}
+		terms = append(terms, term)
+
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			return terms, nil
+		}
 	}
+}
+
+// parseOrderingTerm parses an expression followed by an optional direction
+// and an optional "NULLS FIRST" or "NULLS LAST" clause.
+func (p *Parser) parseOrderingTerm() (query.OrderingTerm, error) {
+	var term query.OrderingTerm
+	var err error
 
-	// parse field reference
-	ref, err := p.parseFieldRef()
+	term.Expr, term.ExprName, err = p.parseExpr()
 	if err != nil {
-		return nil, 0, err
+		return term, err
 	}
 
 	// parse optional ASC or DESC
 	if tok, _, _ := p.ScanIgnoreWhitespace(); tok == scanner.ASC || tok == scanner.DESC {
-		return query.FieldSelector(ref), tok, nil
+		term.Direction = tok
+	} else {
+		p.Unscan()
 	}
-	p.Unscan()
 
-	return query.FieldSelector(ref), 0, nil
+	// parse optional NULLS FIRST or NULLS LAST
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.NULLS {
+		p.Unscan()
+		return term, nil
+	}
+
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	if tok != scanner.FIRST && tok != scanner.LAST {
+		return term, newParseError(scanner.Tokstr(tok, lit), []string{"FIRST", "LAST"}, pos)
+	}
+	term.Nulls = tok
+
+	return term, nil
 }
 
 func (p *Parser) parseLimit() (query.Expr, error) {
@@ -29,6 +29,19 @@ func TestParserSelect(t *testing.T) {
 				Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "a"}, query.ResultFieldExpr{Expr: query.FieldSelector([]string{"b"}), ExprName: "b"}},
 				TableName: "test",
 			}, false},
+		{"WithDistinct", "SELECT DISTINCT a, b FROM test",
+			query.SelectStmt{
+				Distinct:  true,
+				Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "a"}, query.ResultFieldExpr{Expr: query.FieldSelector([]string{"b"}), ExprName: "b"}},
+				TableName: "test",
+			}, false},
+		{"WithDistinct and wildcard", "SELECT DISTINCT * FROM test",
+			query.SelectStmt{
+				Distinct:  true,
+				Selectors: []query.ResultField{query.Wildcard{}},
+				TableName: "test",
+			}, false},
+		{"WithDistinct and no fields", "SELECT DISTINCT FROM test", nil, true},
 		{"WithAlias", "SELECT a AS A, b FROM test",
 			query.SelectStmt{
 				Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "A"}, query.ResultFieldExpr{Expr: query.FieldSelector([]string{"b"}), ExprName: "b"}},
@@ -55,24 +68,113 @@ func TestParserSelect(t *testing.T) {
 				TableName: "test",
 				Selectors: []query.ResultField{query.Wildcard{}},
 				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
-				OrderBy:   []string{"a", "b", "c"},
+				OrderBy: []query.OrderingTerm{
+					{Expr: query.FieldSelector([]string{"a", "b", "c"}), ExprName: "a.b.c"},
+				},
 			}, false},
 		{"WithOrderBy ASC", "SELECT * FROM test WHERE age = 10 ORDER BY a.b.c ASC",
 			query.SelectStmt{
-				TableName:        "test",
-				Selectors:        []query.ResultField{query.Wildcard{}},
-				WhereExpr:        query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
-				OrderBy:          []string{"a", "b", "c"},
-				OrderByDirection: scanner.ASC,
+				TableName: "test",
+				Selectors: []query.ResultField{query.Wildcard{}},
+				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+				OrderBy: []query.OrderingTerm{
+					{Expr: query.FieldSelector([]string{"a", "b", "c"}), ExprName: "a.b.c", Direction: scanner.ASC},
+				},
 			}, false},
 		{"WithOrderBy DESC", "SELECT * FROM test WHERE age = 10 ORDER BY a.b.c DESC",
 			query.SelectStmt{
-				TableName:        "test",
-				Selectors:        []query.ResultField{query.Wildcard{}},
-				WhereExpr:        query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
-				OrderBy:          []string{"a", "b", "c"},
-				OrderByDirection: scanner.DESC,
+				TableName: "test",
+				Selectors: []query.ResultField{query.Wildcard{}},
+				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+				OrderBy: []query.OrderingTerm{
+					{Expr: query.FieldSelector([]string{"a", "b", "c"}), ExprName: "a.b.c", Direction: scanner.DESC},
+				},
+			}, false},
+		{"WithOrderBy multiple terms", "SELECT * FROM test ORDER BY a DESC NULLS FIRST, b + 1, c ASC NULLS LAST",
+			query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{query.Wildcard{}},
+				OrderBy: []query.OrderingTerm{
+					{Expr: query.FieldSelector([]string{"a"}), ExprName: "a", Direction: scanner.DESC, Nulls: scanner.FIRST},
+					{Expr: query.Add(query.FieldSelector([]string{"b"}), query.IntValue(1)), ExprName: "b + 1"},
+					{Expr: query.FieldSelector([]string{"c"}), ExprName: "c", Direction: scanner.ASC, Nulls: scanner.LAST},
+				},
+			}, false},
+		{"WithOrderBy missing NULLS order", "SELECT * FROM test ORDER BY a NULLS", nil, true},
+		{"WithOrderBy trailing comma", "SELECT * FROM test ORDER BY a,", nil, true},
+		{"WithTableAlias", "SELECT * FROM test AS t",
+			query.SelectStmt{
+				Selectors:  []query.ResultField{query.Wildcard{}},
+				TableName:  "test",
+				TableAlias: "t",
+			}, false},
+		{"WithJoin", "SELECT * FROM test t JOIN foo f ON f.a = t.b",
+			query.SelectStmt{
+				Selectors:  []query.ResultField{query.Wildcard{}},
+				TableName:  "test",
+				TableAlias: "t",
+				Joins: []query.JoinClause{
+					{Type: scanner.INNER, TableName: "foo", TableAlias: "f", On: query.Eq(query.FieldSelector([]string{"f", "a"}), query.FieldSelector([]string{"t", "b"}))},
+				},
+			}, false},
+		{"WithMultipleJoins", "SELECT * FROM test INNER JOIN foo ON foo.a = test.b LEFT OUTER JOIN bar AS b ON b.c = foo.d LEFT JOIN baz ON true WHERE age = 10",
+			query.SelectStmt{
+				Selectors: []query.ResultField{query.Wildcard{}},
+				TableName: "test",
+				Joins: []query.JoinClause{
+					{Type: scanner.INNER, TableName: "foo", On: query.Eq(query.FieldSelector([]string{"foo", "a"}), query.FieldSelector([]string{"test", "b"}))},
+					{Type: scanner.LEFT, TableName: "bar", TableAlias: "b", On: query.Eq(query.FieldSelector([]string{"b", "c"}), query.FieldSelector([]string{"foo", "d"}))},
+					{Type: scanner.LEFT, TableName: "baz", On: query.BoolValue(true)},
+				},
+				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+			}, false},
+		{"WithJoin missing ON", "SELECT * FROM test JOIN foo", nil, true},
+		{"WithJoin missing JOIN", "SELECT * FROM test LEFT foo ON a = b", nil, true},
+		{"WithGroupBy", "SELECT a.b, COUNT(*) FROM test WHERE age = 10 GROUP BY a.b, c",
+			query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{
+					query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a", "b"}), ExprName: "a.b"},
+					query.ResultFieldExpr{Expr: &query.CountFunc{Expr: query.Wildcard{}}, ExprName: "COUNT(*)"},
+				},
+				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+				GroupBy:   []query.FieldSelector{[]string{"a", "b"}, []string{"c"}},
+			}, false},
+		{"WithGroupBy and OrderBy", "SELECT SUM(a) AS s FROM test GROUP BY c ORDER BY s DESC",
+			query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{query.ResultFieldExpr{Expr: &query.SumFunc{Expr: query.FieldSelector([]string{"a"})}, ExprName: "s"}},
+				GroupBy:   []query.FieldSelector{[]string{"c"}},
+				OrderBy: []query.OrderingTerm{
+					{Expr: query.FieldSelector([]string{"s"}), ExprName: "s", Direction: scanner.DESC},
+				},
 			}, false},
+		{"WithGroupBy missing BY", "SELECT * FROM test GROUP a", nil, true},
+		{"WithWindow", "SELECT ROW_NUMBER() OVER (PARTITION BY a, b ORDER BY c DESC) AS n, SUM(c) OVER (ORDER BY c), LAG(c, 2, 0) OVER () FROM test",
+			query.SelectStmt{
+				TableName: "test",
+				Selectors: []query.ResultField{
+					query.ResultFieldExpr{Expr: &query.WindowFunc{
+						Func: query.RowNumberFunc{},
+						Window: query.Window{
+							PartitionBy: []query.Expr{query.FieldSelector([]string{"a"}), query.FieldSelector([]string{"b"})},
+							OrderBy:     []query.OrderingTerm{{Expr: query.FieldSelector([]string{"c"}), ExprName: "c", Direction: scanner.DESC}},
+						},
+					}, ExprName: "n"},
+					query.ResultFieldExpr{Expr: &query.WindowFunc{
+						Func: &query.SumFunc{Expr: query.FieldSelector([]string{"c"})},
+						Window: query.Window{
+							OrderBy: []query.OrderingTerm{{Expr: query.FieldSelector([]string{"c"}), ExprName: "c"}},
+						},
+					}, ExprName: "SUM(c) OVER (ORDER BY c)"},
+					query.ResultFieldExpr{Expr: &query.WindowFunc{
+						Func: &query.LagFunc{Expr: query.FieldSelector([]string{"c"}), Offset: query.IntValue(2), Default: query.IntValue(0)},
+					}, ExprName: "LAG(c, 2, 0) OVER ()"},
+				},
+			}, false},
+		{"WithWindow scalar function", "SELECT LOWER(a) OVER () FROM test", nil, true},
+		{"WithWindow missing parenthesis", "SELECT RANK() OVER PARTITION BY a FROM test", nil, true},
+		{"WithWindow missing BY", "SELECT RANK() OVER (PARTITION a) FROM test", nil, true},
 		{"WithLimit", "SELECT * FROM test WHERE age = 10 LIMIT 20",
 			query.SelectStmt{
 				Selectors: []query.ResultField{query.Wildcard{}},
@@ -96,6 +198,35 @@ func TestParserSelect(t *testing.T) {
 				LimitExpr:  query.IntValue(10),
 			}, false},
 		{"WithOffsetThenLimit", "SELECT * FROM test WHERE age = 10 OFFSET 20 LIMIT 10", nil, true},
+		{"Union", "SELECT a FROM foo UNION SELECT b FROM bar",
+			query.CompoundSelectStmt{
+				Selects: []query.SelectStmt{
+					{Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "a"}}, TableName: "foo"},
+					{Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.FieldSelector([]string{"b"}), ExprName: "b"}}, TableName: "bar"},
+				},
+				Operators: []query.CompoundOperator{{Token: scanner.UNION}},
+			}, false},
+		{"Compound with order by and limit", "SELECT 1 AS a UNION ALL SELECT * FROM foo WHERE a > 1 INTERSECT SELECT * FROM bar EXCEPT SELECT * FROM baz ORDER BY a DESC LIMIT 10 OFFSET 2",
+			query.CompoundSelectStmt{
+				Selects: []query.SelectStmt{
+					{Selectors: []query.ResultField{query.ResultFieldExpr{Expr: query.IntValue(1), ExprName: "a"}}},
+					{Selectors: []query.ResultField{query.Wildcard{}}, TableName: "foo", WhereExpr: query.Gt(query.FieldSelector([]string{"a"}), query.IntValue(1))},
+					{Selectors: []query.ResultField{query.Wildcard{}}, TableName: "bar"},
+					{Selectors: []query.ResultField{query.Wildcard{}}, TableName: "baz"},
+				},
+				Operators: []query.CompoundOperator{
+					{Token: scanner.UNION, All: true},
+					{Token: scanner.INTERSECT},
+					{Token: scanner.EXCEPT},
+				},
+				OrderBy:    []query.OrderingTerm{{Expr: query.FieldSelector([]string{"a"}), ExprName: "a", Direction: scanner.DESC}},
+				LimitExpr:  query.IntValue(10),
+				OffsetExpr: query.IntValue(2),
+			}, false},
+		{"Compound with order by before the last statement", "SELECT * FROM foo ORDER BY a UNION SELECT * FROM bar", nil, true},
+		{"Compound with limit before the last statement", "SELECT * FROM foo LIMIT 1 EXCEPT SELECT * FROM bar", nil, true},
+		{"Compound without select", "SELECT * FROM foo UNION bar", nil, true},
+		{"Intersect all", "SELECT * FROM foo INTERSECT ALL SELECT * FROM bar", nil, true},
 	}
 
 	for _, test := range tests {
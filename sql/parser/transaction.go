@@ -0,0 +1,34 @@
+package parser
+
+import (
+	"github.com/asdine/genji/sql/query"
+	"github.com/asdine/genji/sql/scanner"
+)
+
+// parseBeginStatement parses a BEGIN statement.
+// This function assumes the BEGIN token has already been consumed.
+func (p *Parser) parseBeginStatement() (query.Statement, error) {
+	// Parse optional "READ ONLY"
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.READ {
+		p.Unscan()
+		return query.BeginStmt{Writable: true}, nil
+	}
+
+	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.ONLY {
+		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"ONLY"}, pos)
+	}
+
+	return query.BeginStmt{Writable: false}, nil
+}
+
+// parseCommitStatement parses a COMMIT statement.
+// This function assumes the COMMIT token has already been consumed.
+func (p *Parser) parseCommitStatement() (query.Statement, error) {
+	return query.CommitStmt{}, nil
+}
+
+// parseRollbackStatement parses a ROLLBACK statement.
+// This function assumes the ROLLBACK token has already been consumed.
+func (p *Parser) parseRollbackStatement() (query.Statement, error) {
+	return query.RollbackStmt{}, nil
+}
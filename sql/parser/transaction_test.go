@@ -0,0 +1,37 @@
+package parser
+
+import (
+	"testing"
+
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParserTransaction(t *testing.T) {
+	tests := []struct {
+		name     string
+		s        string
+		expected query.Statement
+		errored  bool
+	}{
+		{"Begin", "BEGIN", query.BeginStmt{Writable: true}, false},
+		{"Begin read only", "BEGIN READ ONLY", query.BeginStmt{Writable: false}, false},
+		{"Begin read", "BEGIN READ", nil, true},
+		{"Begin with table", "BEGIN test", nil, true},
+		{"Commit", "COMMIT", query.CommitStmt{}, false},
+		{"Rollback", "ROLLBACK", query.RollbackStmt{}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			q, err := ParseQuery(test.s)
+			if test.errored {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Len(t, q.Statements, 1)
+			require.EqualValues(t, test.expected, q.Statements[0])
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"github.com/asdine/genji/document"
 	"github.com/asdine/genji/sql/query"
 	"github.com/asdine/genji/sql/scanner"
 )
@@ -17,10 +18,27 @@ func (p *Parser) parseUpdateStatement() (query.UpdateStmt, error) {
 		return stmt, err
 	}
 
-	// Parse assignment: "SET field = EXPR".
-	stmt.Pairs, err = p.parseSetClause()
-	if err != nil {
-		return stmt, err
+	// Parse assignment: "SET field = EXPR" and deletion: "UNSET field".
+	tok, pos, lit := p.ScanIgnoreWhitespace()
+	p.Unscan()
+	switch tok {
+	case scanner.SET:
+		stmt.Pairs, err = p.parseSetClause()
+		if err != nil {
+			return stmt, err
+		}
+
+		stmt.UnsetFields, err = p.parseUnsetClause()
+		if err != nil {
+			return stmt, err
+		}
+	case scanner.UNSET:
+		stmt.UnsetFields, err = p.parseUnsetClause()
+		if err != nil {
+			return stmt, err
+		}
+	default:
+		return stmt, newParseError(scanner.Tokstr(tok, lit), []string{"SET", "UNSET"}, pos)
 	}
 
 	// Parse condition: "WHERE EXPR".
@@ -29,17 +47,23 @@ func (p *Parser) parseUpdateStatement() (query.UpdateStmt, error) {
 		return stmt, err
 	}
 
+	// Parse "RETURNING field, ..."
+	stmt.Returning, err = p.parseReturning()
+	if err != nil {
+		return stmt, err
+	}
+
 	return stmt, nil
 }
 
 // parseSetClause parses the "SET" clause of the query.
-func (p *Parser) parseSetClause() (map[string]query.Expr, error) {
+func (p *Parser) parseSetClause() ([]query.SetPair, error) {
 	// Check if the SET token exists.
 	if tok, pos, lit := p.ScanIgnoreWhitespace(); tok != scanner.SET {
 		return nil, newParseError(scanner.Tokstr(tok, lit), []string{"SET"}, pos)
 	}
 
-	pairs := make(map[string]query.Expr)
+	var pairs []query.SetPair
 
 	firstPair := true
 	for {
@@ -52,10 +76,10 @@ func (p *Parser) parseSetClause() (map[string]query.Expr, error) {
 			}
 		}
 
-		// Scan the identifier for the field name.
-		tok, pos, lit := p.ScanIgnoreWhitespace()
-		if tok != scanner.IDENT {
-			return nil, newParseError(scanner.Tokstr(tok, lit), []string{"identifier"}, pos)
+		// Scan the path of the field.
+		path, err := p.parseFieldRef()
+		if err != nil {
+			return nil, err
 		}
 
 		// Scan the eq sign
@@ -68,10 +92,38 @@ func (p *Parser) parseSetClause() (map[string]query.Expr, error) {
 		if err != nil {
 			return nil, err
 		}
-		pairs[lit] = expr
+		pairs = append(pairs, query.SetPair{Path: document.ValuePath(path), Expr: expr})
 
 		firstPair = false
 	}
 
 	return pairs, nil
 }
+
+// parseUnsetClause parses the optional "UNSET" clause of the query.
+func (p *Parser) parseUnsetClause() ([]document.ValuePath, error) {
+	// Check if the UNSET token exists.
+	if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.UNSET {
+		p.Unscan()
+		return nil, nil
+	}
+
+	var paths []document.ValuePath
+
+	for {
+		// Scan the path of the field.
+		path, err := p.parseFieldRef()
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, document.ValuePath(path))
+
+		// Scan for a comma.
+		if tok, _, _ := p.ScanIgnoreWhitespace(); tok != scanner.COMMA {
+			p.Unscan()
+			break
+		}
+	}
+
+	return paths, nil
+}
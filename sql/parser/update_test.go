@@ -3,6 +3,7 @@ package parser
 import (
 	"testing"
 
+	"github.com/asdine/genji/document"
 	"github.com/asdine/genji/sql/query"
 	"github.com/stretchr/testify/require"
 )
@@ -17,26 +18,64 @@ func TestParserUdpate(t *testing.T) {
 		{"No cond", "UPDATE test SET a = 1",
 			query.UpdateStmt{
 				TableName: "test",
-				Pairs: map[string]query.Expr{
-					"a": query.IntValue(1),
+				Pairs: []query.SetPair{
+					{Path: document.NewValuePath("a"), Expr: query.IntValue(1)},
+				},
+			},
+			false},
+		{"Returning", "UPDATE test SET a = 1 RETURNING a",
+			query.UpdateStmt{
+				TableName: "test",
+				Pairs: []query.SetPair{
+					{Path: document.NewValuePath("a"), Expr: query.IntValue(1)},
+				},
+				Returning: []query.ResultField{
+					query.ResultFieldExpr{Expr: query.FieldSelector([]string{"a"}), ExprName: "a"},
 				},
 			},
 			false},
 		{"With cond", "UPDATE test SET a = 1, b = 2 WHERE age = 10",
 			query.UpdateStmt{
 				TableName: "test",
-				Pairs: map[string]query.Expr{
-					"a": query.IntValue(1),
-					"b": query.IntValue(2),
+				Pairs: []query.SetPair{
+					{Path: document.NewValuePath("a"), Expr: query.IntValue(1)},
+					{Path: document.NewValuePath("b"), Expr: query.IntValue(2)},
 				},
 				WhereExpr: query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
 			},
 			false},
+		{"Nested paths", "UPDATE test SET a.b = 1, c.0.d = 2",
+			query.UpdateStmt{
+				TableName: "test",
+				Pairs: []query.SetPair{
+					{Path: document.ValuePath{"a", "b"}, Expr: query.IntValue(1)},
+					{Path: document.ValuePath{"c", "0", "d"}, Expr: query.IntValue(2)},
+				},
+			},
+			false},
+		{"Unset", "UPDATE test UNSET a, b.c WHERE age = 10",
+			query.UpdateStmt{
+				TableName:   "test",
+				UnsetFields: []document.ValuePath{{"a"}, {"b", "c"}},
+				WhereExpr:   query.Eq(query.FieldSelector([]string{"age"}), query.IntValue(10)),
+			},
+			false},
+		{"Set and unset", "UPDATE test SET a = 1 UNSET b",
+			query.UpdateStmt{
+				TableName: "test",
+				Pairs: []query.SetPair{
+					{Path: document.NewValuePath("a"), Expr: query.IntValue(1)},
+				},
+				UnsetFields: []document.ValuePath{{"b"}},
+			},
+			false},
 		{"Trailing comma", "UPDATE test SET a = 1, WHERE age = 10", nil, true},
 		{"No SET", "UPDATE test WHERE age = 10", nil, true},
 		{"No pair", "UPDATE test SET WHERE age = 10", nil, true},
 		{"query.Field only", "UPDATE test SET a WHERE age = 10", nil, true},
 		{"No value", "UPDATE test SET a = WHERE age = 10", nil, true},
+		{"No unset field", "UPDATE test UNSET WHERE age = 10", nil, true},
+		{"Unset trailing comma", "UPDATE test UNSET a, WHERE age = 10", nil, true},
 	}
 
 	for _, test := range tests {
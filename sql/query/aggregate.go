@@ -0,0 +1,507 @@
+package query
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+)
+
+// An AggregatorBuilder is an expression that folds the documents of a group into a single value.
+// Every time a group is created, a new Aggregator is built to keep track of the state of
+// the aggregation for this group.
+// Once the group is complete, evaluating the AggregatorBuilder within the context of
+// the group returns the result of its Aggregator.
+type AggregatorBuilder interface {
+	Expr
+
+	Aggregator() Aggregator
+}
+
+// An Aggregator accumulates the documents of a group.
+type Aggregator interface {
+	// Aggregate is called once for every document of the group.
+	// The document is available in the stack.
+	Aggregate(stack EvalStack) error
+	// Eval returns the result of the aggregation.
+	Eval(stack EvalStack) (document.Value, error)
+}
+
+// aggregatedValue returns the value computed by the aggregator associated with b
+// for the group currently being evaluated.
+func aggregatedValue(stack EvalStack, b AggregatorBuilder) (document.Value, error) {
+	g, ok := stack.Document.(*groupDocument)
+	if !ok {
+		return nilLitteral, errors.New("misuse of aggregate function")
+	}
+
+	agg, ok := g.aggregators[b]
+	if !ok {
+		return nilLitteral, errors.New("misuse of aggregate function")
+	}
+
+	return agg.Eval(stack)
+}
+
+// evalAggregatorArg evaluates the argument of an aggregate function.
+// It returns false if the argument cannot be found in the document or if it is null.
+func evalAggregatorArg(stack EvalStack, e Expr) (document.Value, bool, error) {
+	v, err := e.Eval(stack)
+	if err == document.ErrFieldNotFound {
+		return nilLitteral, false, nil
+	}
+	if err != nil {
+		return nilLitteral, false, err
+	}
+
+	return v, v.Type != document.NullValue, nil
+}
+
+// CountFunc is the COUNT aggregate function.
+// COUNT(*) counts all the documents of the group,
+// while COUNT(expr) counts the documents for which expr is neither missing nor null.
+type CountFunc struct {
+	Expr Expr
+}
+
+// Eval returns the number of documents of the current group.
+func (c *CountFunc) Eval(stack EvalStack) (document.Value, error) {
+	return aggregatedValue(stack, c)
+}
+
+// Aggregator returns a new counter. It implements the AggregatorBuilder interface.
+func (c *CountFunc) Aggregator() Aggregator {
+	_, wildcard := c.Expr.(Wildcard)
+
+	return &countAggregator{expr: c.Expr, wildcard: wildcard}
+}
+
+type countAggregator struct {
+	expr     Expr
+	wildcard bool
+	count    int64
+}
+
+func (c *countAggregator) Aggregate(stack EvalStack) error {
+	if c.wildcard {
+		c.count++
+		return nil
+	}
+
+	_, ok, err := evalAggregatorArg(stack, c.expr)
+	if err != nil {
+		return err
+	}
+	if ok {
+		c.count++
+	}
+
+	return nil
+}
+
+func (c *countAggregator) Eval(EvalStack) (document.Value, error) {
+	return document.NewInt64Value(c.count), nil
+}
+
+// SumFunc is the SUM aggregate function.
+// It adds all the numeric values of the group and ignores the others.
+// If the group doesn't contain any number, it returns null.
+type SumFunc struct {
+	Expr Expr
+}
+
+// Eval returns the sum of the current group.
+func (s *SumFunc) Eval(stack EvalStack) (document.Value, error) {
+	return aggregatedValue(stack, s)
+}
+
+// Aggregator returns a new summing aggregator. It implements the AggregatorBuilder interface.
+func (s *SumFunc) Aggregator() Aggregator {
+	return &sumAggregator{expr: s.Expr, sum: nilLitteral}
+}
+
+type sumAggregator struct {
+	expr Expr
+	sum  document.Value
+}
+
+func (s *sumAggregator) Aggregate(stack EvalStack) error {
+	v, ok, err := evalAggregatorArg(stack, s.expr)
+	if err != nil || !ok || !v.Type.IsNumber() {
+		return err
+	}
+
+	if s.sum.Type == document.NullValue {
+		s.sum = v
+		return nil
+	}
+
+	s.sum, err = s.sum.Add(v)
+	return err
+}
+
+func (s *sumAggregator) Eval(EvalStack) (document.Value, error) {
+	return s.sum, nil
+}
+
+// AvgFunc is the AVG aggregate function.
+// It returns the average of all the numeric values of the group as a float64
+// and ignores the other values.
+// If the group doesn't contain any number, it returns null.
+type AvgFunc struct {
+	Expr Expr
+}
+
+// Eval returns the average of the current group.
+func (a *AvgFunc) Eval(stack EvalStack) (document.Value, error) {
+	return aggregatedValue(stack, a)
+}
+
+// Aggregator returns a new averaging aggregator. It implements the AggregatorBuilder interface.
+func (a *AvgFunc) Aggregator() Aggregator {
+	return &avgAggregator{expr: a.Expr}
+}
+
+type avgAggregator struct {
+	expr  Expr
+	sum   float64
+	count int64
+}
+
+func (a *avgAggregator) Aggregate(stack EvalStack) error {
+	v, ok, err := evalAggregatorArg(stack, a.expr)
+	if err != nil || !ok || !v.Type.IsNumber() {
+		return err
+	}
+
+	f, err := v.ConvertToFloat64()
+	if err != nil {
+		return err
+	}
+
+	a.sum += f
+	a.count++
+	return nil
+}
+
+func (a *avgAggregator) Eval(EvalStack) (document.Value, error) {
+	if a.count == 0 {
+		return nilLitteral, nil
+	}
+
+	return document.NewFloat64Value(a.sum / float64(a.count)), nil
+}
+
+// MinFunc is the MIN aggregate function.
+// It returns the smallest value of the group, ignoring null and missing values.
+// Values of different types are compared using the order of the ORDER BY clause.
+type MinFunc struct {
+	Expr Expr
+}
+
+// Eval returns the minimum value of the current group.
+func (m *MinFunc) Eval(stack EvalStack) (document.Value, error) {
+	return aggregatedValue(stack, m)
+}
+
+// Aggregator returns a new aggregator. It implements the AggregatorBuilder interface.
+func (m *MinFunc) Aggregator() Aggregator {
+	return &minMaxAggregator{expr: m.Expr, min: true, value: nilLitteral}
+}
+
+// MaxFunc is the MAX aggregate function.
+// It returns the largest value of the group, ignoring null and missing values.
+// Values of different types are compared using the order of the ORDER BY clause.
+type MaxFunc struct {
+	Expr Expr
+}
+
+// Eval returns the maximum value of the current group.
+func (m *MaxFunc) Eval(stack EvalStack) (document.Value, error) {
+	return aggregatedValue(stack, m)
+}
+
+// Aggregator returns a new aggregator. It implements the AggregatorBuilder interface.
+func (m *MaxFunc) Aggregator() Aggregator {
+	return &minMaxAggregator{expr: m.Expr, value: nilLitteral}
+}
+
+type minMaxAggregator struct {
+	expr  Expr
+	min   bool
+	value document.Value
+	// ordering key of the value, used to compare values of different types
+	// the same way the ORDER BY clause does.
+	key, buf []byte
+}
+
+func (m *minMaxAggregator) Aggregate(stack EvalStack) error {
+	v, ok, err := evalAggregatorArg(stack, m.expr)
+	if err != nil || !ok {
+		return err
+	}
+
+	m.buf, err = appendOrderingValue(m.buf[:0], v)
+	if err != nil {
+		return err
+	}
+
+	if m.value.Type != document.NullValue {
+		c := bytes.Compare(m.buf, m.key)
+		if (m.min && c >= 0) || (!m.min && c <= 0) {
+			return nil
+		}
+	}
+
+	m.key, m.buf = m.buf, m.key
+	m.value, err = copyValue(v)
+	return err
+}
+
+func (m *minMaxAggregator) Eval(EvalStack) (document.Value, error) {
+	return m.value, nil
+}
+
+// collectAggregators returns the list of aggregate functions used by the given result fields.
+func collectAggregators(fields []ResultField) []AggregatorBuilder {
+	var builders []AggregatorBuilder
+
+	for _, rf := range fields {
+		rfe, ok := rf.(ResultFieldExpr)
+		if !ok {
+			continue
+		}
+
+		walkExpr(rfe.Expr, func(e Expr) {
+			if b, ok := e.(AggregatorBuilder); ok {
+				builders = append(builders, b)
+			}
+		})
+	}
+
+	return builders
+}
+
+// checkGroupedFields returns an error if one of the result fields of a grouped query reads
+// a field of the documents which is neither one of the GROUP BY expressions, or part of one,
+// nor an argument of an aggregate function, since its value can differ within a group.
+func checkGroupedFields(fields []ResultField, groupBy []FieldSelector) error {
+	for _, rf := range fields {
+		rfe, ok := rf.(ResultFieldExpr)
+		if !ok {
+			return fmt.Errorf("%s cannot be selected in a query using GROUP BY or aggregate functions", rf.Name())
+		}
+
+		var err error
+		walkExpr(rfe.Expr, func(e Expr) {
+			if err != nil {
+				return
+			}
+
+			switch t := e.(type) {
+			case FieldSelector:
+				if !isGroupedField(t, groupBy) {
+					err = fmt.Errorf("field %q must appear in the GROUP BY clause or be used in an aggregate function", t.Name())
+				}
+			case PKFunc:
+				err = errors.New("pk() cannot be selected in a query using GROUP BY or aggregate functions")
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// isGroupedField returns true if f is one of the GROUP BY expressions or a path within one of them.
+func isGroupedField(f FieldSelector, groupBy []FieldSelector) bool {
+	for _, g := range groupBy {
+		if len(g) > len(f) {
+			continue
+		}
+
+		match := true
+		for i := range g {
+			if g[i] != f[i] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return true
+		}
+	}
+
+	return false
+}
+
+// groupDocument is the document returned for every group created by a GROUP BY clause.
+// Its fields are the fields of the first document of the group, and it holds
+// the aggregators of the group.
+type groupDocument struct {
+	d           document.Document
+	aggregators map[AggregatorBuilder]Aggregator
+}
+
+func (g *groupDocument) GetByField(field string) (document.Value, error) {
+	if g.d == nil {
+		return document.Value{}, document.ErrFieldNotFound
+	}
+
+	return g.d.GetByField(field)
+}
+
+func (g *groupDocument) Iterate(fn func(field string, value document.Value) error) error {
+	if g.d == nil {
+		return nil
+	}
+
+	return g.d.Iterate(fn)
+}
+
+// groupIterator reads all the documents of a stream and groups them by the value of
+// the groupBy expressions. Documents for which the expressions evaluate to the same values
+// belong to the same group.
+// If there are no expressions, all the documents belong to a single group, which
+// is returned even if the stream is empty.
+// Groups are returned in the order in which they were created.
+type groupIterator struct {
+	st          document.Stream
+	groupBy     []FieldSelector
+	aggregators []AggregatorBuilder
+	stack       EvalStack
+}
+
+func (it groupIterator) Iterate(fn func(d document.Document) error) error {
+	var groups []*groupDocument
+	lookup := make(map[string]*groupDocument)
+
+	stack := it.stack
+	var key []byte
+
+	err := it.st.Iterate(func(d document.Document) error {
+		stack.Document = d
+
+		key = key[:0]
+		for _, e := range it.groupBy {
+			v, err := e.Eval(stack)
+			if err != nil && err != document.ErrFieldNotFound {
+				return err
+			}
+			if err == document.ErrFieldNotFound {
+				v = nilLitteral
+			}
+
+			key, err = appendGroupKey(key, v)
+			if err != nil {
+				return err
+			}
+		}
+
+		g, ok := lookup[string(key)]
+		if !ok {
+			fd, err := copyDocument(d)
+			if err != nil {
+				return err
+			}
+
+			g = newGroupDocument(fd, it.aggregators)
+			lookup[string(key)] = g
+			groups = append(groups, g)
+		}
+
+		for _, agg := range g.aggregators {
+			err := agg.Aggregate(stack)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(groups) == 0 && len(it.groupBy) == 0 {
+		groups = append(groups, newGroupDocument(nil, it.aggregators))
+	}
+
+	for _, g := range groups {
+		err = fn(g)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newGroupDocument(d document.Document, builders []AggregatorBuilder) *groupDocument {
+	g := groupDocument{
+		d:           d,
+		aggregators: make(map[AggregatorBuilder]Aggregator, len(builders)),
+	}
+
+	for _, b := range builders {
+		g.aggregators[b] = b.Aggregator()
+	}
+
+	return &g
+}
+
+// appendGroupKey appends a binary representation of v to key.
+// Values considered equal by the comparison operators have the same representation,
+// including numbers of different types, within arrays and documents as well,
+// so that they end up in the same group.
+func appendGroupKey(key []byte, v document.Value) ([]byte, error) {
+	return appendOrderingValue(key, v)
+}
+
+// copyDocument returns an encoded copy of d that remains valid after
+// the iteration that returned d moves on to the next document.
+// If d has a key, it is copied as well.
+func copyDocument(d document.Document) (document.Document, error) {
+	data, err := encoding.EncodeDocument(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := d.(encoding.EncodedDocument); ok {
+		data = append([]byte(nil), data...)
+	}
+
+	k, ok := d.(document.Keyer)
+	if !ok {
+		return encoding.EncodedDocument(data), nil
+	}
+
+	return &encodedDocumentWithKey{
+		EncodedDocument: data,
+		key:             append([]byte(nil), k.Key()...),
+	}, nil
+}
+
+type encodedDocumentWithKey struct {
+	encoding.EncodedDocument
+
+	key []byte
+}
+
+func (e encodedDocumentWithKey) Key() []byte {
+	return e.key
+}
+
+// copyValue returns a copy of v that doesn't share memory with the document it was read from.
+func copyValue(v document.Value) (document.Value, error) {
+	data, err := encoding.EncodeValue(v)
+	if err != nil {
+		return document.Value{}, err
+	}
+
+	return encoding.DecodeValue(v.Type, append([]byte(nil), data...))
+}
@@ -0,0 +1,111 @@
+package query
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+)
+
+// AlterTableRenameStmt is a DSL that allows creating a full ALTER TABLE ... RENAME TO statement.
+type AlterTableRenameStmt struct {
+	TableName    string
+	NewTableName string
+}
+
+// IsReadOnly always returns false. It implements the Statement interface.
+func (stmt AlterTableRenameStmt) IsReadOnly() bool {
+	return false
+}
+
+// Run runs the ALTER TABLE ... RENAME TO statement in the given transaction.
+// It implements the Statement interface.
+func (stmt AlterTableRenameStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	var res Result
+
+	if stmt.TableName == "" {
+		return res, errors.New("missing table name")
+	}
+
+	if stmt.NewTableName == "" {
+		return res, errors.New("missing new table name")
+	}
+
+	if stmt.TableName == stmt.NewTableName {
+		return res, database.ErrTableAlreadyExists
+	}
+
+	err := tx.RenameTable(stmt.TableName, stmt.NewTableName)
+	return res, err
+}
+
+// AlterTableAddFieldStmt is a DSL that allows creating a full ALTER TABLE ... ADD FIELD statement.
+type AlterTableAddFieldStmt struct {
+	TableName  string
+	Constraint database.FieldConstraint
+}
+
+// IsReadOnly always returns false. It implements the Statement interface.
+func (stmt AlterTableAddFieldStmt) IsReadOnly() bool {
+	return false
+}
+
+// Run runs the ALTER TABLE ... ADD FIELD statement in the given transaction.
+// It implements the Statement interface.
+func (stmt AlterTableAddFieldStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	var res Result
+
+	if stmt.TableName == "" {
+		return res, errors.New("missing table name")
+	}
+
+	if len(stmt.Constraint.Path) == 0 {
+		return res, errors.New("missing path")
+	}
+
+	if stmt.Constraint.Type == 0 && !stmt.Constraint.IsNotNull && !stmt.Constraint.IsPrimaryKey {
+		return res, errors.New("missing field constraint")
+	}
+
+	t, err := tx.GetTable(stmt.TableName)
+	if err != nil {
+		return res, err
+	}
+
+	err = t.AddFieldConstraint(stmt.Constraint)
+	return res, err
+}
+
+// AlterTableDropFieldStmt is a DSL that allows creating a full ALTER TABLE ... DROP FIELD statement.
+type AlterTableDropFieldStmt struct {
+	TableName string
+	Path      document.ValuePath
+}
+
+// IsReadOnly always returns false. It implements the Statement interface.
+func (stmt AlterTableDropFieldStmt) IsReadOnly() bool {
+	return false
+}
+
+// Run runs the ALTER TABLE ... DROP FIELD statement in the given transaction.
+// It implements the Statement interface.
+func (stmt AlterTableDropFieldStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	var res Result
+
+	if stmt.TableName == "" {
+		return res, errors.New("missing table name")
+	}
+
+	if len(stmt.Path) == 0 {
+		return res, errors.New("missing path")
+	}
+
+	t, err := tx.GetTable(stmt.TableName)
+	if err != nil {
+		return res, err
+	}
+
+	err = t.DropFieldConstraint(stmt.Path)
+	return res, err
+}
@@ -0,0 +1,62 @@
+package query_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlterTable(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		fails    bool
+		expected string
+	}{
+		{"Rename", "ALTER TABLE test RENAME TO foo; SELECT * FROM foo WHERE a = 1", false, `[{"a":1,"b":"x"}]`},
+		{"Rename, same name", "ALTER TABLE test RENAME TO test", true, ``},
+		{"Rename, existing table", "CREATE TABLE foo; ALTER TABLE test RENAME TO foo", true, ``},
+		{"Rename, unknown table", "ALTER TABLE foo RENAME TO bar", true, ``},
+		{"Add field, type", "ALTER TABLE test ADD FIELD a FLOAT64; SELECT a FROM test WHERE a >= 1.5", false, `[{"a":2.0}]`},
+		{"Add field, type mismatch", "ALTER TABLE test ADD FIELD b INTEGER", true, ``},
+		{"Add field, not null", "ALTER TABLE test ADD FIELD b NOT NULL; SELECT b FROM test", false, `[{"b":"x"},{"b":"y"},{"b":"z"}]`},
+		{"Add field, not null violated", "ALTER TABLE test ADD FIELD a NOT NULL", true, ``},
+		{"Add field, insert violates constraint", "ALTER TABLE test ADD FIELD b NOT NULL; INSERT INTO test (a) VALUES (4)", true, ``},
+		{"Add field, twice", "ALTER TABLE test ADD FIELD b NOT NULL; ALTER TABLE test ADD FIELD b TEXT", true, ``},
+		{"Drop field", "ALTER TABLE test ADD FIELD b NOT NULL; ALTER TABLE test DROP FIELD b; INSERT INTO test (a) VALUES (4); SELECT a FROM test WHERE a = 4", false, `[{"a":4}]`},
+		{"Drop field, no constraint", "ALTER TABLE test DROP FIELD b", true, ``},
+		{"Drop field, primary key", "CREATE TABLE foo (a INTEGER PRIMARY KEY); ALTER TABLE foo DROP FIELD a", true, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE test;
+				CREATE INDEX idx_a ON test (a);
+				INSERT INTO test (a, b) VALUES (1, 'x'), (2, 'y');
+				INSERT INTO test (b) VALUES ('z');
+			`)
+			require.NoError(t, err)
+
+			st, err := db.Query(test.query)
+			defer st.Close()
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+		})
+	}
+}
@@ -0,0 +1,328 @@
+package query
+
+import (
+	"bytes"
+	"database/sql/driver"
+	"encoding/binary"
+	"errors"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+	"github.com/asdine/genji/sql/scanner"
+)
+
+// CompoundSelectStmt combines the results of several SELECT statements
+// using the UNION, INTERSECT and EXCEPT operators.
+// Operators are applied from left to right: the first one combines the results
+// of the first two statements, the next one combines that result with the result
+// of the third statement, and so on.
+// Documents are compared like with SELECT DISTINCT, see appendDistinctKey.
+type CompoundSelectStmt struct {
+	Selects []SelectStmt
+	// Operators[i] combines the result of the statements preceding Selects[i+1]
+	// with the result of Selects[i+1].
+	Operators []CompoundOperator
+	// The ORDER BY, LIMIT and OFFSET clauses apply to the combined result.
+	OrderBy    []OrderingTerm
+	OffsetExpr Expr
+	LimitExpr  Expr
+}
+
+// A CompoundOperator combines the results of two SELECT statements.
+type CompoundOperator struct {
+	// Token is either scanner.UNION, scanner.INTERSECT or scanner.EXCEPT.
+	// UNION returns the documents of both results, INTERSECT the documents
+	// of the first result which are part of the second one, and EXCEPT the documents
+	// of the first result which are not part of the second one.
+	Token scanner.Token
+	// All keeps the duplicate documents. It is only supported by UNION.
+	All bool
+}
+
+// IsReadOnly always returns true. It implements the Statement interface.
+func (stmt CompoundSelectStmt) IsReadOnly() bool {
+	return true
+}
+
+// Run the statements and combine their results within the given transaction.
+// It implements the Statement interface.
+func (stmt CompoundSelectStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	var res Result
+
+	if len(stmt.Selects) < 2 || len(stmt.Operators) != len(stmt.Selects)-1 {
+		return res, errors.New("compound statements must combine at least two SELECT statements")
+	}
+
+	// the number of fields projected by statements selecting
+	// a wildcard is only known once the documents are read.
+	n := -1
+	for _, sel := range stmt.Selects {
+		if hasWildcard(sel.Selectors) {
+			continue
+		}
+		if n != -1 && len(sel.Selectors) != n {
+			return res, errors.New("the statements of a compound statement must select the same number of fields")
+		}
+		n = len(sel.Selectors)
+	}
+
+	var misused bool
+	for _, t := range stmt.OrderBy {
+		walkExpr(t.Expr, func(e Expr) {
+			if _, ok := e.(AggregatorBuilder); ok {
+				misused = true
+			}
+		})
+	}
+	if misused {
+		return res, errors.New("aggregate functions are not allowed in ORDER BY clause of compound statements")
+	}
+
+	exprs := []Expr{stmt.OffsetExpr, stmt.LimitExpr}
+	for _, t := range stmt.OrderBy {
+		exprs = append(exprs, t.Expr)
+	}
+	err := prepareSubqueries(tx, args, exprs...)
+	if err != nil {
+		return res, err
+	}
+
+	stack := EvalStack{
+		Tx:     tx,
+		Params: args,
+	}
+
+	offset, err := evalIntClause(stmt.OffsetExpr, stack, "offset")
+	if err != nil {
+		return res, err
+	}
+
+	limit, err := evalIntClause(stmt.LimitExpr, stack, "limit")
+	if err != nil {
+		return res, err
+	}
+
+	r, err := stmt.Selects[0].Run(tx, args)
+	if err != nil {
+		return res, err
+	}
+	st := r.Stream
+
+	cfg := newSortConfig(tx)
+	for i, op := range stmt.Operators {
+		r, err := stmt.Selects[i+1].Run(tx, args)
+		if err != nil {
+			return res, err
+		}
+
+		switch {
+		case op.Token == scanner.UNION:
+			st = st.Append(r.Stream)
+		case op.Token == scanner.INTERSECT && !op.All:
+			st = document.NewStream(setOperationIterator{left: st, right: r.Stream, intersect: true, cfg: cfg})
+		case op.Token == scanner.EXCEPT && !op.All:
+			st = document.NewStream(setOperationIterator{left: st, right: r.Stream, cfg: cfg})
+		default:
+			return res, errors.New("unsupported compound operator")
+		}
+
+		if !op.All {
+			st = document.NewStream(distinctIterator{it: st, cfg: cfg})
+		}
+	}
+
+	if len(stmt.OrderBy) != 0 {
+		k := 0
+		if limit != -1 {
+			k = limit
+			if offset > 0 {
+				k += offset
+			}
+		}
+
+		// the documents are already projected, the ordering terms
+		// refer to their fields.
+		st = document.NewStream(&sortedIterator{
+			it:    st,
+			terms: stmt.OrderBy,
+			stack: stack,
+			cfg:   cfg,
+			k:     k,
+		})
+	}
+
+	if offset > 0 {
+		st = st.Offset(offset)
+	}
+
+	if limit >= 0 {
+		st = st.Limit(limit)
+	}
+
+	return Result{Stream: st}, nil
+}
+
+// hasWildcard returns true if one of the fields is a wildcard.
+func hasWildcard(fields []ResultField) bool {
+	for _, f := range fields {
+		if _, ok := f.(Wildcard); ok {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Keys marking the entries sorted by a setOperationIterator, depending on
+// the iterator they were read from.
+var (
+	rightEntry = []byte{0}
+	leftEntry  = []byte{1}
+)
+
+// setOperationIterator returns the documents of the left iterator which are,
+// or which are not, returned by the right iterator.
+// Documents are compared using their distinct key, see appendDistinctKey.
+// The keys of the documents of the right iterator are read first and kept in memory, in a set.
+// If the size of the set exceeds the memory limit, they are added to a sorter instead,
+// along with the documents of the left iterator: sorting them by key, the documents of the right
+// iterator preceding the others, brings the equal documents next to each other.
+// The selected documents are then sorted again, to return them in the order in which they were read.
+type setOperationIterator struct {
+	left, right document.Iterator
+	// if true, the documents returned by the right iterator are kept,
+	// otherwise they are removed.
+	intersect bool
+	cfg       sortConfig
+}
+
+func (it setOperationIterator) Iterate(fn func(d document.Document) error) (err error) {
+	limit := it.cfg.MemoryLimit
+	if limit <= 0 {
+		limit = database.DefaultSortMemoryLimit
+	}
+
+	set := make(map[string]struct{})
+	var size int
+	// set once the keys no longer fit in memory.
+	var srt *sorter
+	defer func() {
+		if srt == nil {
+			return
+		}
+
+		if cerr := srt.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var key []byte
+	err = it.right.Iterate(func(d document.Document) error {
+		key, err = appendDistinctKey(key[:0], d)
+		if err != nil {
+			return err
+		}
+
+		if srt == nil {
+			if _, ok := set[string(key)]; ok {
+				return nil
+			}
+
+			if size+len(key)+sortEntryOverhead <= limit {
+				set[string(key)] = struct{}{}
+				size += len(key) + sortEntryOverhead
+				return nil
+			}
+
+			srt = newSorter(it.cfg, 0)
+			for k := range set {
+				err = srt.add(sortEntry{value: []byte(k), key: rightEntry})
+				if err != nil {
+					return err
+				}
+			}
+			set = nil
+		}
+
+		return srt.add(sortEntry{value: append([]byte(nil), key...), key: rightEntry})
+	})
+	if err != nil {
+		return err
+	}
+
+	if srt == nil {
+		return it.left.Iterate(func(d document.Document) error {
+			key, err = appendDistinctKey(key[:0], d)
+			if err != nil {
+				return err
+			}
+
+			if _, ok := set[string(key)]; ok != it.intersect {
+				return nil
+			}
+
+			return fn(d)
+		})
+	}
+
+	err = it.left.Iterate(func(d document.Document) error {
+		key, err = appendDistinctKey(key[:0], d)
+		if err != nil {
+			return err
+		}
+
+		data, err := encoding.EncodeDocument(d)
+		if err != nil {
+			return err
+		}
+
+		return srt.add(sortEntry{
+			value: append([]byte(nil), key...),
+			key:   leftEntry,
+			data:  append([]byte(nil), data...),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	// sort the selected documents using their position.
+	// the entries of the right iterator were added first, so they
+	// precede the entries of the left iterator with the same key.
+	selected := newSorter(it.cfg, 0)
+	defer func() {
+		if cerr := selected.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var prev []byte
+	var found bool
+	err = srt.iterate(func(e *sortEntry) error {
+		if prev == nil || !bytes.Equal(prev, e.value) {
+			prev = e.value
+			found = false
+		}
+
+		if bytes.Equal(e.key, rightEntry) {
+			found = true
+			return nil
+		}
+
+		if found != it.intersect {
+			return nil
+		}
+
+		var seq [8]byte
+		binary.BigEndian.PutUint64(seq[:], e.seq)
+		return selected.add(sortEntry{value: seq[:], data: e.data})
+	})
+	if err != nil {
+		return err
+	}
+
+	return selected.iterate(func(e *sortEntry) error {
+		return fn(encoding.EncodedDocument(e.data))
+	})
+}
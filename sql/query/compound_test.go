@@ -0,0 +1,139 @@
+package query_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompoundSelectStmt(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		fails    bool
+		expected string
+		params   []interface{}
+	}{
+		{"Union", "SELECT a FROM foo UNION SELECT a FROM bar", false, `[{"a":1},{"a":2},{"a":3},{"a":4}]`, nil},
+		{"Union all", "SELECT a FROM foo UNION ALL SELECT a FROM bar", false, `[{"a":1},{"a":2},{"a":3},{"a":2},{"a":3},{"a":4}]`, nil},
+		{"Union removes duplicates of the same statement", "SELECT b FROM foo UNION SELECT b FROM bar WHERE a = 4", false, `[{"b":{"c":1}},{"b":[1]}]`, nil},
+		{"Intersect", "SELECT a FROM foo INTERSECT SELECT a FROM bar", false, `[{"a":2},{"a":3}]`, nil},
+		{"Except", "SELECT a FROM foo EXCEPT SELECT a FROM bar", false, `[{"a":1}]`, nil},
+		{"Except with documents", "SELECT * FROM foo EXCEPT SELECT * FROM bar", false, `[{"a":1,"b":{"c":1}},{"a":3,"b":{"c":1}}]`, nil},
+		{"Left to right", "SELECT a FROM foo UNION SELECT a FROM bar EXCEPT SELECT 2 AS a", false, `[{"a":1},{"a":3},{"a":4}]`, nil},
+		{"No table", "SELECT 1 AS a UNION SELECT 2 AS a UNION SELECT 1 AS a", false, `[{"a":1},{"a":2}]`, nil},
+		{"Numbers", "SELECT 1 AS a UNION SELECT 1.0 AS a UNION SELECT [1.0] AS a UNION SELECT [1] AS a", false, `[{"a":1},{"a":[1]}]`, nil},
+		{"Intersect numbers", "SELECT a FROM foo INTERSECT SELECT 2.0 AS a", false, `[{"a":2}]`, nil},
+		{"Order by", "SELECT a FROM foo UNION SELECT a FROM bar ORDER BY a DESC", false, `[{"a":4},{"a":3},{"a":2},{"a":1}]`, nil},
+		{"Order by alias", "SELECT a AS x FROM foo UNION ALL SELECT a + 10 AS x FROM bar ORDER BY x DESC LIMIT 2", false, `[{"x":14},{"x":13}]`, nil},
+		{"Limit and offset", "SELECT a FROM foo UNION SELECT a FROM bar LIMIT 2 OFFSET 1", false, `[{"a":2},{"a":3}]`, nil},
+		{"Params", "SELECT a FROM foo WHERE a > ? UNION SELECT a FROM bar WHERE a < ? LIMIT ?", false, `[{"a":2},{"a":3}]`, []interface{}{1, 3, 2}},
+		{"Order by aggregate", "SELECT a FROM foo UNION SELECT a FROM bar ORDER BY COUNT(*)", true, ``, nil},
+		{"Unknown table", "SELECT a FROM foo UNION SELECT a FROM baz", true, ``, nil},
+		{"Different number of fields", "SELECT a FROM foo UNION SELECT a, b FROM bar", true, ``, nil},
+		{"Different number of fields after the first operator", "SELECT a FROM foo EXCEPT SELECT a FROM bar INTERSECT SELECT 1 AS a, 2 AS b", true, ``, nil},
+		{"Wildcard", "SELECT * FROM foo EXCEPT SELECT a, b FROM bar", false, `[{"a":1,"b":{"c":1}},{"a":3,"b":{"c":1}}]`, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE foo;
+				CREATE TABLE bar;
+				INSERT INTO foo (a, b) VALUES (1, {c: 1}), (2, [1]), (3, {c: 1});
+				INSERT INTO bar (a, b) VALUES (2, [1]), (3, {c: 2}), (4, [1]);
+			`)
+			require.NoError(t, err)
+
+			st, err := db.Query(test.query, test.params...)
+			if test.fails {
+				if err == nil {
+					err = st.Iterate(func(d document.Document) error { return nil })
+					st.Close()
+				}
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer st.Close()
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+		})
+	}
+	t.Run("Spill", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "genji")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		db.DB.Sort = database.SortOptions{MemoryLimit: 256, TempDir: dir}
+
+		var foo, bar []string
+		for i := 0; i < 100; i++ {
+			foo = append(foo, fmt.Sprintf("(%d)", 99-i))
+			if i%3 == 0 {
+				bar = append(bar, fmt.Sprintf("(%d.0)", i))
+			}
+		}
+		err = db.Exec(`
+			CREATE TABLE foo;
+			CREATE TABLE bar;
+			INSERT INTO foo (a) VALUES ` + strings.Join(foo, ", ") + `;
+			INSERT INTO bar (a) VALUES ` + strings.Join(bar, ", "))
+		require.NoError(t, err)
+
+		run := func(q string) []int64 {
+			st, err := db.Query(q)
+			require.NoError(t, err)
+			defer st.Close()
+
+			var res []int64
+			err = st.Iterate(func(d document.Document) error {
+				v, err := d.GetByField("a")
+				if err != nil {
+					return err
+				}
+
+				a, err := v.ConvertToInt64()
+				res = append(res, a)
+				return err
+			})
+			require.NoError(t, err)
+			return res
+		}
+
+		var intersect, except []int64
+		for i := int64(99); i >= 0; i-- {
+			if i%3 == 0 {
+				intersect = append(intersect, i)
+			} else {
+				except = append(except, i)
+			}
+		}
+
+		require.Equal(t, intersect, run("SELECT a FROM foo INTERSECT SELECT a FROM bar"))
+		require.Equal(t, except, run("SELECT a FROM foo EXCEPT SELECT a FROM bar"))
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
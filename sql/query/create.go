@@ -40,9 +40,11 @@ func (stmt CreateTableStmt) Run(tx *database.Transaction, args []driver.NamedVal
 // CreateIndexStmt is a DSL that allows creating a full CREATE INDEX statement.
 // It is typically created using the CreateIndex function.
 type CreateIndexStmt struct {
-	IndexName   string
-	TableName   string
-	Path        document.ValuePath
+	IndexName string
+	TableName string
+	Path      document.ValuePath
+	// Fields indexed by a composite index, in order. If set, Path is ignored.
+	Fields      []database.IndexField
 	IfNotExists bool
 	Unique      bool
 }
@@ -65,16 +67,23 @@ func (stmt CreateIndexStmt) Run(tx *database.Transaction, args []driver.NamedVal
 		return res, errors.New("missing index name")
 	}
 
-	if len(stmt.Path) == 0 {
-		return res, errors.New("missing path")
-	}
-
-	err := tx.CreateIndex(database.IndexConfig{
+	cfg := database.IndexConfig{
 		Unique:    stmt.Unique,
 		IndexName: stmt.IndexName,
 		TableName: stmt.TableName,
 		Path:      stmt.Path,
-	})
+	}
+
+	if len(stmt.Fields) > 0 {
+		cfg.Path = stmt.Fields[0].Path
+		cfg.Fields = stmt.Fields
+	}
+
+	if len(cfg.Path) == 0 {
+		return res, errors.New("missing path")
+	}
+
+	err := tx.CreateIndex(cfg)
 	if stmt.IfNotExists && err == database.ErrIndexAlreadyExists {
 		err = nil
 	}
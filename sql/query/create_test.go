@@ -81,7 +81,8 @@ func TestCreateIndex(t *testing.T) {
 		{"If not exists", "CREATE INDEX IF NOT EXISTS idx ON test (foo.bar)", false},
 		{"Unique", "CREATE UNIQUE INDEX IF NOT EXISTS idx ON test (foo.1)", false},
 		{"No fields", "CREATE INDEX idx ON test", true},
-		{"More than 1 field", "CREATE INDEX idx ON test (foo, bar)", true},
+		{"Composite", "CREATE INDEX idx ON test (foo, bar DESC)", false},
+		{"Descending", "CREATE UNIQUE INDEX idx ON test (foo DESC)", false},
 	}
 
 	for _, test := range tests {
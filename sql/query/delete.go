@@ -15,6 +15,7 @@ const deleteBufferSize = 100
 type DeleteStmt struct {
 	TableName string
 	WhereExpr Expr
+	Returning []ResultField
 }
 
 // IsReadOnly always returns false. It implements the Statement interface.
@@ -42,6 +43,16 @@ func (stmt DeleteStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		return res, err
 	}
 
+	err = prepareSubqueries(tx, args, stmt.WhereExpr)
+	if err != nil {
+		return res, err
+	}
+
+	ret, err := newReturning(tx, args, t, stmt.Returning)
+	if err != nil {
+		return res, err
+	}
+
 	st := document.NewStream(t)
 	st = st.Filter(whereClause(stmt.WhereExpr, stack)).Limit(deleteBufferSize)
 
@@ -67,10 +78,18 @@ func (stmt DeleteStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		keys = keys[:i]
 
 		for _, key := range keys {
+			// the returned documents are evaluated before being deleted
+			err = ret.addKey(t, key)
+			if err != nil {
+				return res, err
+			}
+
 			err = t.Delete(key)
 			if err != nil {
 				return res, err
 			}
+
+			res.rowsAffected++
 		}
 
 		if i < deleteBufferSize {
@@ -78,5 +97,6 @@ func (stmt DeleteStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		}
 	}
 
+	res.Stream = ret.stream()
 	return res, nil
 }
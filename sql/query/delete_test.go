@@ -59,3 +59,37 @@ func TestDeleteStmt(t *testing.T) {
 		})
 	}
 }
+
+func TestDeleteStmtRowsAffected(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test")
+	require.NoError(t, err)
+
+	// insert more documents than the size of the delete buffer
+	for i := 0; i < 250; i++ {
+		err = db.Exec("INSERT INTO test (a) VALUES (?)", i)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		query    string
+		affected int64
+	}{
+		{"DELETE FROM test WHERE a < 0", 0},
+		{"DELETE FROM test WHERE a >= 120", 130},
+		{"DELETE FROM test", 120},
+		{"DELETE FROM test", 0},
+	}
+
+	for _, test := range tests {
+		res, err := db.Query(test.query)
+		require.NoError(t, err)
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		require.Equal(t, test.affected, n)
+		require.NoError(t, res.Close())
+	}
+}
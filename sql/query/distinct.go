@@ -0,0 +1,136 @@
+package query
+
+import (
+	"bytes"
+	"encoding/binary"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+)
+
+// Keys marking the entries sorted by a distinctIterator, depending on whether
+// their document has already been returned or not.
+var (
+	returnedEntry = []byte{0}
+	pendingEntry  = []byte{1}
+)
+
+// distinctIterator removes the duplicate documents from the documents of an iterator,
+// keeping the first occurrence of each document.
+// Documents are compared using their distinct key, see appendDistinctKey.
+// The keys are kept in memory, in a set, and the documents returned as soon as they are read.
+// If the size of the set exceeds the memory limit, the documents read afterwards are
+// deduplicated using a sorter, which writes them to temporary files if necessary: sorting
+// them by key, along with the content of the set, brings the duplicates next to
+// each other. The remaining documents are then sorted again, to return them in the order
+// in which they were read.
+type distinctIterator struct {
+	it  document.Iterator
+	cfg sortConfig
+}
+
+func (it distinctIterator) Iterate(fn func(d document.Document) error) (err error) {
+	limit := it.cfg.MemoryLimit
+	if limit <= 0 {
+		limit = database.DefaultSortMemoryLimit
+	}
+
+	seen := make(map[string]struct{})
+	var size int
+	// set once the documents no longer fit in memory.
+	var srt *sorter
+	defer func() {
+		if srt == nil {
+			return
+		}
+
+		if cerr := srt.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var buf []byte
+	err = it.it.Iterate(func(d document.Document) error {
+		buf, err = appendDistinctKey(buf[:0], d)
+		if err != nil {
+			return err
+		}
+
+		if srt == nil {
+			if _, ok := seen[string(buf)]; ok {
+				return nil
+			}
+
+			if size+len(buf)+sortEntryOverhead <= limit {
+				seen[string(buf)] = struct{}{}
+				size += len(buf) + sortEntryOverhead
+
+				return fn(d)
+			}
+
+			srt = newSorter(it.cfg, 0)
+			for k := range seen {
+				err = srt.add(sortEntry{value: []byte(k), key: returnedEntry})
+				if err != nil {
+					return err
+				}
+			}
+			seen = nil
+		}
+
+		data, err := encoding.EncodeDocument(d)
+		if err != nil {
+			return err
+		}
+
+		return srt.add(sortEntry{
+			value: append([]byte(nil), buf...),
+			key:   pendingEntry,
+			data:  append([]byte(nil), data...),
+		})
+	})
+	if err != nil || srt == nil {
+		return err
+	}
+
+	// sort the documents that were not returned using their position.
+	// the entries of the set were added first, so they precede
+	// the other entries with the same value.
+	pending := newSorter(it.cfg, 0)
+	defer func() {
+		if cerr := pending.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	var prev []byte
+	err = srt.iterate(func(e *sortEntry) error {
+		if prev != nil && bytes.Equal(prev, e.value) {
+			return nil
+		}
+		prev = e.value
+
+		if bytes.Equal(e.key, returnedEntry) {
+			return nil
+		}
+
+		var seq [8]byte
+		binary.BigEndian.PutUint64(seq[:], e.seq)
+		return pending.add(sortEntry{value: seq[:], data: e.data})
+	})
+	if err != nil {
+		return err
+	}
+
+	return pending.iterate(func(e *sortEntry) error {
+		return fn(encoding.EncodedDocument(e.data))
+	})
+}
+
+// appendDistinctKey appends the key used to compare d with other documents.
+// Numbers are normalized, like in group keys, so that documents which only differ
+// by the type of their numbers, like 1 and 1.0, are considered duplicates.
+func appendDistinctKey(key []byte, d document.Document) ([]byte, error) {
+	return appendOrderingValue(key, document.NewDocumentValue(d))
+}
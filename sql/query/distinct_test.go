@@ -0,0 +1,123 @@
+package query_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectStmtDistinct(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"Scalars", "SELECT DISTINCT a FROM test", `[{"a":1},{"a":2},{"a":"foo"},{"a":3}]`},
+		{"Documents and arrays", "SELECT DISTINCT b FROM test", `[{"b":{"c":1}},{"b":[1,2]},{"b":{"c":2}},{"b":[1,3]},{"b":null}]`},
+		{"Multiple fields", "SELECT DISTINCT a, b FROM test", `[{"a":1,"b":{"c":1}},{"a":2,"b":[1,2]},{"a":"foo","b":{"c":2}},{"a":1,"b":[1,3]},{"a":3,"b":null}]`},
+		{"Wildcard", "SELECT DISTINCT * FROM test WHERE t = 'x'", `[{"t":"x","a":1,"b":{"c":1}},{"t":"x","a":2,"b":[1,2]},{"t":"x","a":1,"b":[1,3]}]`},
+		{"Order by with limit", "SELECT DISTINCT a FROM test ORDER BY a DESC LIMIT 2 OFFSET 1", `[{"a":3},{"a":2}]`},
+		{"Index", "SELECT DISTINCT t FROM test", `[{"t":null},{"t":"x"},{"t":"y"},{"t":"z"}]`},
+		{"Index with limit", "SELECT DISTINCT t FROM test LIMIT 2", `[{"t":null},{"t":"x"}]`},
+		{"Index with order by", "SELECT DISTINCT t AS u FROM test ORDER BY t DESC", `[{"u":"z"},{"u":"y"},{"u":"x"},{"u":null}]`},
+		{"Index with where", "SELECT DISTINCT t FROM test WHERE a = 1", `[{"t":"x"},{"t":"y"}]`},
+		{"Group by", "SELECT DISTINCT COUNT(*) AS n FROM test GROUP BY t ORDER BY n", `[{"n":1},{"n":2},{"n":4}]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE test (t TEXT);
+				CREATE INDEX idx_t ON test (t);
+				INSERT INTO test (t, a, b) VALUES
+					('x', 1, {c: 1}), ('y', 1, {c: 1}), ('x', 2, [1, 2]), ('z', 2, [1, 2]),
+					('y', 'foo', {c: 2}), ('x', 1, [1, 3]), ('x', 1, {c: 1});
+				INSERT INTO test (a) VALUES (3);
+			`)
+			require.NoError(t, err)
+
+			st, err := db.Query(test.query)
+			require.NoError(t, err)
+			defer st.Close()
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+		})
+	}
+
+	t.Run("Numbers", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec(`
+			CREATE TABLE test;
+			INSERT INTO test (a) VALUES (1), (1.0), (2.5), ([1, 2.0]), ([1.0, 2]), ({c: 1.0}), ({c: 1});
+		`)
+		require.NoError(t, err)
+
+		st, err := db.Query("SELECT DISTINCT a FROM test")
+		require.NoError(t, err)
+		defer st.Close()
+
+		var buf bytes.Buffer
+		err = document.IteratorToJSONArray(&buf, st)
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"a":1},{"a":2.5},{"a":[1,2]},{"a":{"c":1}}]`, buf.String())
+	})
+
+	t.Run("Spill", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "genji")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		db.DB.Sort = database.SortOptions{MemoryLimit: 256, TempDir: dir}
+
+		var values []string
+		for i := 0; i < 200; i++ {
+			values = append(values, fmt.Sprintf("(%d, %d)", i, i*3%7))
+		}
+		err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY); INSERT INTO test (k, a) VALUES " + strings.Join(values, ", "))
+		require.NoError(t, err)
+
+		st, err := db.Query("SELECT DISTINCT a, 'some padding to exceed the limit' AS b FROM test")
+		require.NoError(t, err)
+		defer st.Close()
+
+		var res []int64
+		err = st.Iterate(func(d document.Document) error {
+			v, err := d.GetByField("a")
+			if err != nil {
+				return err
+			}
+
+			a, err := v.ConvertToInt64()
+			res = append(res, a)
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, []int64{0, 3, 6, 2, 5, 1, 4}, res)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
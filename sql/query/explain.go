@@ -0,0 +1,322 @@
+package query
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/scanner"
+)
+
+// ExplainStmt is a DSL that allows creating EXPLAIN statements.
+// Instead of running the statement, it returns a document describing
+// how the statement would be executed.
+type ExplainStmt struct {
+	Statement Statement
+}
+
+// IsReadOnly always returns true. It implements the Statement interface.
+func (stmt ExplainStmt) IsReadOnly() bool {
+	return true
+}
+
+// Run analyses the statement and returns a stream containing a single document
+// describing the query plan.
+// It implements the Statement interface.
+func (stmt ExplainStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	var res Result
+	var fb *document.FieldBuffer
+	var err error
+
+	switch t := stmt.Statement.(type) {
+	case SelectStmt:
+		fb, err = t.explain(tx, args)
+	case UpdateStmt:
+		fb, err = explainTableScan(tx, "UPDATE", t.TableName, t.WhereExpr)
+	case DeleteStmt:
+		fb, err = explainTableScan(tx, "DELETE", t.TableName, t.WhereExpr)
+	default:
+		return res, errors.New("EXPLAIN only supports SELECT, UPDATE and DELETE statements")
+	}
+	if err != nil {
+		return res, err
+	}
+
+	return Result{Stream: document.NewStream(document.NewIterator(fb))}, nil
+}
+
+// explain describes how the SELECT statement reads the table, joins, groups and sorts the documents.
+func (stmt SelectStmt) explain(tx *database.Transaction, args []driver.NamedValue) (*document.FieldBuffer, error) {
+	fb := document.NewFieldBuffer().Add("statement", document.NewTextValue("SELECT"))
+
+	if stmt.TableName == "" {
+		return fb, nil
+	}
+
+	qo, err := stmt.prepare(tx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	qp, err := qo.buildQueryPlan()
+	if err != nil {
+		return nil, err
+	}
+
+	fb.Add("table", document.NewTextValue(stmt.TableName))
+	fb.Add("scan", document.NewDocumentValue(qo.explainScan(qp)))
+
+	if len(stmt.Joins) > 0 {
+		alias := stmt.TableAlias
+		if alias == "" {
+			alias = stmt.TableName
+		}
+
+		aliases := []string{alias}
+		var joins document.ValueBuffer
+		for _, j := range stmt.Joins {
+			it, err := newJoinIterator(tx, args, document.Stream{}, aliases, j)
+			if err != nil {
+				return nil, err
+			}
+			aliases = append(aliases, j.Alias())
+
+			joins = joins.Append(document.NewDocumentValue(it.explain()))
+		}
+
+		fb.Add("joins", document.NewArrayValue(joins))
+	}
+
+	fb.Add("filter", document.NewBoolValue(stmt.WhereExpr != nil))
+
+	aggregators := collectAggregators(stmt.Selectors)
+	if len(stmt.GroupBy) > 0 || len(aggregators) > 0 {
+		var paths document.ValueBuffer
+		for _, fs := range stmt.GroupBy {
+			paths = paths.Append(document.NewTextValue(fs.Name()))
+		}
+
+		fb.Add("groupBy", document.NewArrayValue(paths))
+	}
+
+	if stmt.Distinct {
+		fb.Add("distinct", document.NewDocumentValue(qo.explainDistinct()))
+	}
+
+	if len(stmt.OrderBy) != 0 {
+		// grouped and joined queries are always sorted in memory.
+		if len(qo.orderBy) == 0 {
+			qo.orderBy = resolveOrderingTerms(stmt.OrderBy, stmt.Selectors)
+			qp.sorted = false
+		}
+
+		fb.Add("sort", document.NewDocumentValue(qo.explainSort(qp)))
+	}
+
+	if qo.limit >= 0 {
+		fb.Add("limit", document.NewIntValue(qo.limit))
+	}
+
+	if qo.offset > 0 {
+		fb.Add("offset", document.NewIntValue(qo.offset))
+	}
+
+	return fb, nil
+}
+
+// explainScan describes how the documents are read from the table.
+func (qo *queryOptimizer) explainScan(qp queryPlan) *document.FieldBuffer {
+	switch {
+	case qp.scanTable:
+		return document.NewFieldBuffer().Add("type", document.NewTextValue("table"))
+	case qp.node != nil:
+		return qo.explainNode(qp.node)
+	}
+
+	return qo.explainField(qp.field)
+}
+
+// explainNode describes how the keys selected by a node of the query plan
+// are read and combined.
+func (qo *queryOptimizer) explainNode(n *queryPlanNode) *document.FieldBuffer {
+	if n.field != nil {
+		return qo.explainField(n.field)
+	}
+
+	fb := document.NewFieldBuffer()
+	if n.op == scanner.OR {
+		fb.Add("type", document.NewTextValue("union"))
+	} else {
+		fb.Add("type", document.NewTextValue("intersection"))
+	}
+
+	var scans document.ValueBuffer
+	for _, c := range n.children {
+		scans = scans.Append(document.NewDocumentValue(qo.explainNode(c)))
+	}
+
+	return fb.Add("scans", document.NewArrayValue(scans))
+}
+
+// explainField describes how the primary key or an index is read.
+func (qo *queryOptimizer) explainField(f *queryPlanField) *document.FieldBuffer {
+	fb := document.NewFieldBuffer()
+
+	if f.composite != nil {
+		return qo.explainComposite(f)
+	}
+
+	if f.isPrimaryKey {
+		fb.Add("type", document.NewTextValue("primary key"))
+	} else {
+		fb.Add("type", document.NewTextValue("index"))
+		fb.Add("index", document.NewTextValue(qo.indexes[f.indexedField.Name()].IndexName))
+	}
+
+	fb.Add("path", document.NewTextValue(f.indexedField.Name()))
+
+	switch {
+	case f.lowerOp != 0:
+		fb.Add("operator", document.NewTextValue(f.lowerOp.String()+" AND "+f.upperOp.String()))
+	case f.e != nil:
+		fb.Add("operator", document.NewTextValue(f.op.String()))
+	}
+
+	return fb
+}
+
+// explainComposite describes how a composite index is read.
+// The path lists the fields of the index that are compared, the first ones being
+// compared for equality and the last one using the operator.
+func (qo *queryOptimizer) explainComposite(f *queryPlanField) *document.FieldBuffer {
+	fb := document.NewFieldBuffer().
+		Add("type", document.NewTextValue("index")).
+		Add("index", document.NewTextValue(f.composite.IndexName))
+
+	n := len(f.prefix)
+	if f.e != nil {
+		n++
+	}
+
+	var paths []string
+	for _, field := range f.composite.Fields[:n] {
+		paths = append(paths, field.Path.String())
+	}
+
+	if len(paths) == 0 {
+		paths = append(paths, f.indexedField.Name())
+	}
+
+	fb.Add("path", document.NewTextValue(strings.Join(paths, ", ")))
+
+	switch {
+	case f.e != nil:
+		fb.Add("operator", document.NewTextValue(f.op.String()))
+	case len(f.prefix) > 0:
+		fb.Add("operator", document.NewTextValue(scanner.EQ.String()))
+	}
+
+	return fb
+}
+
+// explainDistinct describes how the duplicate documents are removed.
+// If the distinct values are read from an index, the repeated values are skipped while reading it.
+// Otherwise, the projected documents are stored in a set, or sorted using temporary files
+// if they exceed the memory limit.
+func (qo *queryOptimizer) explainDistinct() *document.FieldBuffer {
+	if !qo.distinct {
+		return document.NewFieldBuffer().Add("type", document.NewTextValue("index"))
+	}
+
+	return document.NewFieldBuffer().Add("type", document.NewTextValue("set"))
+}
+
+// explainSort describes how the documents are sorted.
+// If the documents are read in order from the primary key or an index, no sorting is necessary.
+// Otherwise, if the query has a limit, they are sorted using a heap which only keeps the limit + offset
+// first documents, unless they must be deduplicated afterwards. If not, they are sorted in memory,
+// or using temporary files if they exceed the memory limit.
+func (qo *queryOptimizer) explainSort(qp queryPlan) *document.FieldBuffer {
+	fb := document.NewFieldBuffer()
+
+	switch {
+	case qp.sorted && qp.field.isPrimaryKey:
+		fb.Add("type", document.NewTextValue("primary key"))
+	case qp.sorted:
+		fb.Add("type", document.NewTextValue("index"))
+	case qo.limit != -1 && !qo.distinct:
+		fb.Add("type", document.NewTextValue("heap"))
+	default:
+		fb.Add("type", document.NewTextValue("sort"))
+	}
+
+	var terms document.ValueBuffer
+	for _, t := range qo.orderBy {
+		direction, nulls := scanner.ASC, scanner.LAST
+		if t.desc() {
+			direction = scanner.DESC
+		}
+		if t.nullsFirst() {
+			nulls = scanner.FIRST
+		}
+
+		terms = terms.Append(document.NewDocumentValue(document.NewFieldBuffer().
+			Add("expr", document.NewTextValue(t.ExprName)).
+			Add("direction", document.NewTextValue(direction.String())).
+			Add("nulls", document.NewTextValue(nulls.String())),
+		))
+	}
+	fb.Add("terms", document.NewArrayValue(terms))
+
+	if !qp.sorted && qo.limit != -1 && !qo.distinct {
+		k := qo.limit
+		if qo.offset > 0 {
+			k += qo.offset
+		}
+
+		fb.Add("limit", document.NewIntValue(k))
+	}
+
+	return fb
+}
+
+// explain describes how the documents of the right table of the join are read.
+func (it *joinIterator) explain() *document.FieldBuffer {
+	fb := document.NewFieldBuffer().
+		Add("type", document.NewTextValue(it.join.Type.String())).
+		Add("table", document.NewTextValue(it.join.TableName)).
+		Add("alias", document.NewTextValue(it.join.Alias()))
+
+	scan := document.NewFieldBuffer()
+	switch {
+	case it.lookup == nil:
+		scan.Add("type", document.NewTextValue("table"))
+	case it.lookup.isPrimaryKey:
+		scan.Add("type", document.NewTextValue("primary key"))
+		scan.Add("path", document.NewTextValue(it.cfg.GetPrimaryKey().Path.String()))
+		scan.Add("operator", document.NewTextValue(scanner.EQ.String()))
+	default:
+		scan.Add("type", document.NewTextValue("index"))
+		scan.Add("index", document.NewTextValue(it.lookup.index.IndexName))
+		scan.Add("path", document.NewTextValue(it.lookup.index.Path.String()))
+		scan.Add("operator", document.NewTextValue(scanner.EQ.String()))
+	}
+
+	return fb.Add("scan", document.NewDocumentValue(scan))
+}
+
+// explainTableScan describes UPDATE and DELETE statements, which always read the entire table.
+func explainTableScan(tx *database.Transaction, statement, tableName string, where Expr) (*document.FieldBuffer, error) {
+	_, err := tx.GetTable(tableName)
+	if err != nil {
+		return nil, err
+	}
+
+	return document.NewFieldBuffer().
+		Add("statement", document.NewTextValue(statement)).
+		Add("table", document.NewTextValue(tableName)).
+		Add("scan", document.NewDocumentValue(document.NewFieldBuffer().Add("type", document.NewTextValue("table")))).
+		Add("filter", document.NewBoolValue(where != nil)), nil
+}
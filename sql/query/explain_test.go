@@ -0,0 +1,90 @@
+package query_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplainStmt(t *testing.T) {
+	tests := []struct {
+		query    string
+		fails    bool
+		expected string
+	}{
+		{"EXPLAIN SELECT 1 + 1", false, `{"statement":"SELECT"}`},
+		{"EXPLAIN SELECT * FROM test", false, `{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":false}`},
+		{"EXPLAIN SELECT * FROM test WHERE c = 10", false, `{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":true}`},
+		{"EXPLAIN SELECT * FROM test WHERE a = 10", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"primary key","path":"a","operator":"="},"filter":true}`},
+		{"EXPLAIN SELECT * FROM test WHERE a = 'foo'", false, `{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":true}`},
+		{"EXPLAIN SELECT * FROM test WHERE b > ?", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"index","index":"idx_b","path":"b","operator":">"},"filter":true}`},
+		{"EXPLAIN SELECT * FROM test ORDER BY b DESC", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"index","index":"idx_b","path":"b"},"filter":false,"sort":{"type":"index","terms":[{"expr":"b","direction":"DESC","nulls":"LAST"}]}}`},
+		{"EXPLAIN SELECT * FROM test ORDER BY a", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"primary key","path":"a"},"filter":false,"sort":{"type":"primary key","terms":[{"expr":"a","direction":"ASC","nulls":"FIRST"}]}}`},
+		{"EXPLAIN SELECT * FROM test WHERE b > 1 ORDER BY c LIMIT 10 OFFSET 5", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"index","index":"idx_b","path":"b","operator":">"},"filter":true,"sort":{"type":"heap","terms":[{"expr":"c","direction":"ASC","nulls":"FIRST"}],"limit":15},"limit":10,"offset":5}`},
+		{"EXPLAIN SELECT * FROM test ORDER BY c LIMIT 10", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":false,"sort":{"type":"heap","terms":[{"expr":"c","direction":"ASC","nulls":"FIRST"}],"limit":10},"limit":10}`},
+		{"EXPLAIN SELECT c, COUNT(*) AS n FROM test GROUP BY c ORDER BY n", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":false,"groupBy":["c"],"sort":{"type":"sort","terms":[{"expr":"n","direction":"ASC","nulls":"FIRST"}]}}`},
+		{"EXPLAIN SELECT DISTINCT c FROM test ORDER BY c LIMIT 10", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":false,"distinct":{"type":"set"},"sort":{"type":"sort","terms":[{"expr":"c","direction":"ASC","nulls":"FIRST"}]},"limit":10}`},
+		{"EXPLAIN SELECT DISTINCT d FROM test ORDER BY d DESC", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"index","index":"idx_d","path":"d"},"filter":false,"distinct":{"type":"index"},"sort":{"type":"index","terms":[{"expr":"d","direction":"DESC","nulls":"LAST"}]}}`},
+		{"EXPLAIN SELECT DISTINCT b FROM test", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"table"},"filter":false,"distinct":{"type":"set"}}`},
+		{"EXPLAIN SELECT * FROM test t JOIN foo f ON f.a = t.b LEFT JOIN test t2 ON t2.a = f.b", false,
+			`{"statement":"SELECT","table":"test","scan":{"type":"table"},"joins":[
+				{"type":"INNER","table":"foo","alias":"f","scan":{"type":"index","index":"idx_foo_a","path":"a","operator":"="}},
+				{"type":"LEFT","table":"test","alias":"t2","scan":{"type":"primary key","path":"a","operator":"="}}
+			],"filter":false}`},
+		{"EXPLAIN SELECT * FROM unknown", true, ``},
+		{"EXPLAIN UPDATE test SET c = 1 WHERE a = 10", false, `{"statement":"UPDATE","table":"test","scan":{"type":"table"},"filter":true}`},
+		{"EXPLAIN DELETE FROM test", false, `{"statement":"DELETE","table":"test","scan":{"type":"table"},"filter":false}`},
+		{"EXPLAIN DELETE FROM unknown", true, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE test (a INTEGER PRIMARY KEY, d TEXT);
+				CREATE INDEX idx_b ON test (b);
+				CREATE INDEX idx_d ON test (d);
+				CREATE TABLE foo;
+				CREATE INDEX idx_foo_a ON foo (a);
+				INSERT INTO test (a, b, c) VALUES (10, 1, 'x');
+			`)
+			require.NoError(t, err)
+
+			st, err := db.Query(test.query, 1)
+			defer st.Close()
+			if test.fails {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, "["+test.expected+"]", buf.String())
+
+			// the statement must not have been executed
+			d, err := db.QueryDocument("SELECT COUNT(*) AS n FROM test")
+			require.NoError(t, err)
+			v, err := d.GetByField("n")
+			require.NoError(t, err)
+			require.Equal(t, document.NewInt64Value(1), v)
+		})
+	}
+}
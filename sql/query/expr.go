@@ -4,6 +4,7 @@ import (
 	"database/sql/driver"
 	"errors"
 	"fmt"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -337,6 +338,312 @@ func (op CmpOp) compare(l, r document.Value) (bool, error) {
 	}
 }
 
+// A Regex is a regular expression literal, used as the right hand side
+// of the regex operators.
+type Regex struct {
+	*regexp.Regexp
+}
+
+// Eval returns the pattern of the regular expression as a text value.
+// It implements the Expr interface.
+func (r Regex) Eval(EvalStack) (document.Value, error) {
+	return document.NewTextValue(r.String()), nil
+}
+
+// A RegexOp is an operator that matches text and blob values against a regular expression.
+type RegexOp struct {
+	*simpleOperator
+}
+
+// EqRegex creates an expression that returns true if a matches the regular expression b.
+func EqRegex(a, b Expr) RegexOp {
+	return RegexOp{&simpleOperator{a, b, scanner.EQREGEX}}
+}
+
+// NeqRegex creates an expression that returns true if a doesn't match the regular expression b.
+func NeqRegex(a, b Expr) RegexOp {
+	return RegexOp{&simpleOperator{a, b, scanner.NEQREGEX}}
+}
+
+// Eval matches the value of a against the regular expression b.
+// Values that are neither text nor blob, and missing fields, never match.
+func (op RegexOp) Eval(ctx EvalStack) (document.Value, error) {
+	re, ok := op.b.(Regex)
+	if !ok {
+		return falseLitteral, fmt.Errorf("%s operator expects a regular expression", op.Token)
+	}
+
+	v, err := op.a.Eval(ctx)
+	if err != nil && err != document.ErrFieldNotFound {
+		return falseLitteral, err
+	}
+
+	var match bool
+	if err == nil && (v.Type == document.TextValue || v.Type == document.BlobValue) {
+		match = re.Match(v.V.([]byte))
+	}
+
+	if match == (op.Token == scanner.EQREGEX) {
+		return trueLitteral, nil
+	}
+
+	return falseLitteral, nil
+}
+
+// InOp is the IN operator.
+type InOp struct {
+	*simpleOperator
+}
+
+// In creates an expression that returns true if a is equal to one of the values of the array b.
+func In(a, b Expr) InOp {
+	return InOp{&simpleOperator{a, b, scanner.IN}}
+}
+
+// Eval compares a with every value of b and returns true if one of them is equal to a.
+// If b is not an array, it returns false.
+func (op InOp) Eval(ctx EvalStack) (document.Value, error) {
+	a, b, err := op.simpleOperator.eval(ctx)
+	if err != nil {
+		if err == document.ErrFieldNotFound {
+			return falseLitteral, nil
+		}
+
+		return falseLitteral, err
+	}
+
+	if b.Type != document.ArrayValue {
+		return falseLitteral, nil
+	}
+
+	var found bool
+	err = b.V.(document.Array).Iterate(func(i int, v document.Value) error {
+		found, err = a.IsEqual(v)
+		if err != nil || found {
+			return errStop
+		}
+
+		return nil
+	})
+	if err != nil && err != errStop {
+		return falseLitteral, err
+	}
+
+	if found {
+		return trueLitteral, nil
+	}
+
+	return falseLitteral, nil
+}
+
+// IsOp is the IS operator.
+type IsOp struct {
+	*simpleOperator
+}
+
+// Is creates an expression that returns true if a is equal to b.
+// Unlike the = operator, missing fields are considered equal to NULL.
+func Is(a, b Expr) IsOp {
+	return IsOp{&simpleOperator{a, b, scanner.IS}}
+}
+
+// Eval evaluates a and b, replacing missing fields by NULL, and returns true if they are equal.
+func (op IsOp) Eval(ctx EvalStack) (document.Value, error) {
+	a, err := op.a.Eval(ctx)
+	if err != nil && err != document.ErrFieldNotFound {
+		return falseLitteral, err
+	}
+	if err == document.ErrFieldNotFound {
+		a = nilLitteral
+	}
+
+	b, err := op.b.Eval(ctx)
+	if err != nil && err != document.ErrFieldNotFound {
+		return falseLitteral, err
+	}
+	if err == document.ErrFieldNotFound {
+		b = nilLitteral
+	}
+
+	ok, err := a.IsEqual(b)
+	if ok {
+		return trueLitteral, err
+	}
+
+	return falseLitteral, err
+}
+
+// LikeOp is the LIKE operator.
+type LikeOp struct {
+	*simpleOperator
+}
+
+// Like creates an expression that returns true if a matches the pattern b.
+// In the pattern, the % character matches any sequence of characters,
+// the _ character matches any single character, and a backslash escapes the next character.
+func Like(a, b Expr) LikeOp {
+	return LikeOp{&simpleOperator{a, b, scanner.LIKE}}
+}
+
+// Eval matches the value of a against the pattern.
+// Values that are neither text nor blob, and missing fields, never match.
+func (op LikeOp) Eval(ctx EvalStack) (document.Value, error) {
+	a, b, err := op.simpleOperator.eval(ctx)
+	if err != nil {
+		if err == document.ErrFieldNotFound {
+			return falseLitteral, nil
+		}
+
+		return falseLitteral, err
+	}
+
+	if (a.Type != document.TextValue && a.Type != document.BlobValue) ||
+		(b.Type != document.TextValue && b.Type != document.BlobValue) {
+		return falseLitteral, nil
+	}
+
+	if like(string(a.V.([]byte)), string(b.V.([]byte))) {
+		return trueLitteral, nil
+	}
+
+	return falseLitteral, nil
+}
+
+// like returns true if s matches the pattern of a LIKE operator.
+func like(s, pattern string) bool {
+	const (
+		literal = iota
+		anyChar
+		anySequence
+	)
+
+	type elem struct {
+		kind int
+		r    rune
+	}
+
+	var elems []elem
+	var escaped bool
+	for _, r := range pattern {
+		switch {
+		case escaped:
+			elems = append(elems, elem{literal, r})
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '%':
+			elems = append(elems, elem{kind: anySequence})
+		case r == '_':
+			elems = append(elems, elem{kind: anyChar})
+		default:
+			elems = append(elems, elem{literal, r})
+		}
+	}
+	if escaped {
+		elems = append(elems, elem{literal, '\\'})
+	}
+
+	// greedy matching, going back to the last % when the following characters don't match.
+	runes := []rune(s)
+	i, j := 0, 0
+	lastSeq, lastI := -1, 0
+	for i < len(runes) {
+		switch {
+		case j < len(elems) && (elems[j].kind == anyChar || (elems[j].kind == literal && elems[j].r == runes[i])):
+			i++
+			j++
+		case j < len(elems) && elems[j].kind == anySequence:
+			lastSeq, lastI = j, i
+			j++
+		case lastSeq >= 0:
+			lastI++
+			i, j = lastI, lastSeq+1
+		default:
+			return false
+		}
+	}
+
+	for j < len(elems) && elems[j].kind == anySequence {
+		j++
+	}
+
+	return j == len(elems)
+}
+
+// BetweenOp is the BETWEEN operator.
+type BetweenOp struct {
+	*simpleOperator
+	X Expr
+}
+
+// Between creates an expression that returns true if x is greater than or equal to a
+// and lesser than or equal to b.
+func Between(x, a, b Expr) BetweenOp {
+	return BetweenOp{&simpleOperator{a, b, scanner.BETWEEN}, x}
+}
+
+// Eval compares x with both bounds and returns true if x is between them.
+func (op BetweenOp) Eval(ctx EvalStack) (document.Value, error) {
+	x, err := op.X.Eval(ctx)
+	if err != nil {
+		if err == document.ErrFieldNotFound {
+			return falseLitteral, nil
+		}
+
+		return falseLitteral, err
+	}
+
+	a, b, err := op.simpleOperator.eval(ctx)
+	if err != nil {
+		if err == document.ErrFieldNotFound {
+			return falseLitteral, nil
+		}
+
+		return falseLitteral, err
+	}
+
+	ok, err := x.IsGreaterThanOrEqual(a)
+	if err != nil || !ok {
+		return falseLitteral, err
+	}
+
+	ok, err = x.IsLesserThanOrEqual(b)
+	if err != nil || !ok {
+		return falseLitteral, err
+	}
+
+	return trueLitteral, nil
+}
+
+// NotOp is the NOT operator.
+type NotOp struct {
+	Expr Expr
+}
+
+// Not creates an expression that returns true if e is falsy.
+func Not(e Expr) NotOp {
+	return NotOp{Expr: e}
+}
+
+// Eval evaluates e and returns true if it is falsy, false otherwise.
+// Missing fields are falsy.
+func (op NotOp) Eval(ctx EvalStack) (document.Value, error) {
+	v, err := op.Expr.Eval(ctx)
+	if err != nil {
+		if err == document.ErrFieldNotFound {
+			return trueLitteral, nil
+		}
+
+		return falseLitteral, err
+	}
+
+	if v.IsTruthy() {
+		return falseLitteral, nil
+	}
+
+	return trueLitteral, nil
+}
+
 // AndOp is the And operator.
 type AndOp struct {
 	*simpleOperator
@@ -564,25 +871,6 @@ func (kvp KVPairs) Eval(ctx EvalStack) (document.Value, error) {
 	return document.NewDocumentValue(&fb), nil
 }
 
-var functions = map[string]func(args ...Expr) (Expr, error){
-	"pk": func(args ...Expr) (Expr, error) {
-		if len(args) != 0 {
-			return nil, fmt.Errorf("pk() takes no arguments")
-		}
-		return new(PKFunc), nil
-	},
-}
-
-// GetFunc return a function expression by name.
-func GetFunc(name string, args ...Expr) (Expr, error) {
-	fn, ok := functions[name]
-	if !ok {
-		return nil, fmt.Errorf("no such function: %q", name)
-	}
-
-	return fn(args...)
-}
-
 // PKFunc represents the pk() function.
 // It returns the primary key of the current document.
 type PKFunc struct{}
@@ -617,3 +905,47 @@ func (c Cast) Eval(ctx EvalStack) (document.Value, error) {
 
 	return v.ConvertTo(c.ConvertTo)
 }
+
+// walkExpr calls fn for e and for each of its sub-expressions, in depth-first order.
+// Arguments of aggregate and window functions and statements of subqueries are not visited.
+func walkExpr(e Expr, fn func(Expr)) {
+	if e == nil {
+		return
+	}
+
+	fn(e)
+
+	switch t := e.(type) {
+	case AggregatorBuilder, *WindowFunc:
+	case BetweenOp:
+		walkExpr(t.X, fn)
+		walkExpr(t.LeftHand(), fn)
+		walkExpr(t.RightHand(), fn)
+	case NotOp:
+		walkExpr(t.Expr, fn)
+	case interface {
+		LeftHand() Expr
+		RightHand() Expr
+	}:
+		walkExpr(t.LeftHand(), fn)
+		walkExpr(t.RightHand(), fn)
+	case LiteralExprList:
+		for _, e := range t {
+			walkExpr(e, fn)
+		}
+	case KVPairs:
+		for _, kv := range t {
+			walkExpr(kv.V, fn)
+		}
+	case Cast:
+		walkExpr(t.Expr, fn)
+	case ScalarFunction:
+		for _, e := range t.Args {
+			walkExpr(e, fn)
+		}
+	case SubqueryArray:
+		walkExpr(t.Subquery, fn)
+	case ExistsOp:
+		walkExpr(t.Subquery, fn)
+	}
+}
@@ -0,0 +1,534 @@
+package query
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/asdine/genji/document"
+)
+
+var functions = map[string]func(args ...Expr) (Expr, error){
+	"pk": func(args ...Expr) (Expr, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("pk() takes no arguments")
+		}
+		return new(PKFunc), nil
+	},
+	"count": func(args ...Expr) (Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("count() takes 1 argument")
+		}
+		return &CountFunc{Expr: args[0]}, nil
+	},
+	"sum": func(args ...Expr) (Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("sum() takes 1 argument")
+		}
+		return &SumFunc{Expr: args[0]}, nil
+	},
+	"avg": func(args ...Expr) (Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("avg() takes 1 argument")
+		}
+		return &AvgFunc{Expr: args[0]}, nil
+	},
+	"min": func(args ...Expr) (Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("min() takes 1 argument")
+		}
+		return &MinFunc{Expr: args[0]}, nil
+	},
+	"max": func(args ...Expr) (Expr, error) {
+		if len(args) != 1 {
+			return nil, fmt.Errorf("max() takes 1 argument")
+		}
+		return &MaxFunc{Expr: args[0]}, nil
+	},
+
+	// window functions
+	"row_number": func(args ...Expr) (Expr, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("row_number() takes no arguments")
+		}
+		return RowNumberFunc{}, nil
+	},
+	"rank": func(args ...Expr) (Expr, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("rank() takes no arguments")
+		}
+		return RankFunc{}, nil
+	},
+	"dense_rank": func(args ...Expr) (Expr, error) {
+		if len(args) != 0 {
+			return nil, fmt.Errorf("dense_rank() takes no arguments")
+		}
+		return DenseRankFunc{}, nil
+	},
+	"lag": offsetFunction("lag", func(expr, offset, def Expr) Expr {
+		return &LagFunc{Expr: expr, Offset: offset, Default: def}
+	}),
+	"lead": offsetFunction("lead", func(expr, offset, def Expr) Expr {
+		return &LeadFunc{Expr: expr, Offset: offset, Default: def}
+	}),
+
+	// string functions
+	"lower":  scalarFunction("lower", 1, 1, nullIfAnyNull(lowerFunc)),
+	"upper":  scalarFunction("upper", 1, 1, nullIfAnyNull(upperFunc)),
+	"trim":   scalarFunction("trim", 1, 2, nullIfAnyNull(trimFunc)),
+	"substr": scalarFunction("substr", 2, 3, nullIfAnyNull(substrFunc)),
+	"length": scalarFunction("length", 1, 1, nullIfAnyNull(lengthFunc)),
+	"concat": scalarFunction("concat", 1, -1, concatFunc),
+
+	// math functions
+	"abs":   scalarFunction("abs", 1, 1, nullIfAnyNull(absFunc)),
+	"round": scalarFunction("round", 1, 2, nullIfAnyNull(roundFunc)),
+	"floor": scalarFunction("floor", 1, 1, nullIfAnyNull(floorFunc)),
+	"ceil":  scalarFunction("ceil", 1, 1, nullIfAnyNull(ceilFunc)),
+
+	// type functions
+	"typeof":   scalarFunction("typeof", 1, 1, typeofFunc),
+	"coalesce": scalarFunction("coalesce", 1, -1, coalesceFunc),
+	"ifnull":   scalarFunction("ifnull", 2, 2, coalesceFunc),
+
+	// array and document functions
+	"len":            scalarFunction("len", 1, 1, nullIfAnyNull(lenFunc)),
+	"array_contains": scalarFunction("array_contains", 2, 2, nullIfAnyNull(arrayContainsFunc)),
+	"keys":           scalarFunction("keys", 1, 1, nullIfAnyNull(keysFunc)),
+}
+
+// GetFunc return a builtin function expression by name.
+// Function names are case insensitive.
+func GetFunc(name string, args ...Expr) (Expr, error) {
+	fn, ok := functions[strings.ToLower(name)]
+	if !ok {
+		return nil, fmt.Errorf("no such function: %q", name)
+	}
+
+	return fn(args...)
+}
+
+// A ScalarFunc returns a value computed from the values of its arguments.
+// Missing fields are passed as null values.
+type ScalarFunc func(args ...document.Value) (document.Value, error)
+
+// Functions holds the scalar functions registered by the user, in addition
+// to the builtin ones. The zero value is ready to use and it is safe for concurrent use.
+type Functions struct {
+	mu sync.RWMutex
+	m  map[string]ScalarFunc
+}
+
+// Register makes fn available to queries under the given name.
+// Function names are case insensitive and cannot replace a builtin function
+// or a function that was already registered.
+func (f *Functions) Register(name string, fn ScalarFunc) error {
+	if name == "" {
+		return errors.New("missing function name")
+	}
+	if fn == nil {
+		return errors.New("missing function")
+	}
+
+	name = strings.ToLower(name)
+	if _, ok := functions[name]; ok {
+		return fmt.Errorf("function %q already exists", name)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if _, ok := f.m[name]; ok {
+		return fmt.Errorf("function %q already exists", name)
+	}
+
+	if f.m == nil {
+		f.m = make(map[string]ScalarFunc)
+	}
+	f.m[name] = fn
+	return nil
+}
+
+// GetFunc returns a function expression by name, looking up the builtin functions first
+// and then the registered ones. Function names are case insensitive.
+// If f is nil, only builtin functions are returned.
+func (f *Functions) GetFunc(name string, args ...Expr) (Expr, error) {
+	lname := strings.ToLower(name)
+	if _, ok := functions[lname]; ok || f == nil {
+		return GetFunc(name, args...)
+	}
+
+	f.mu.RLock()
+	fn, ok := f.m[lname]
+	f.mu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("no such function: %q", name)
+	}
+
+	return ScalarFunction{Name: lname, Args: args, Func: fn}, nil
+}
+
+// ScalarFunction is an expression that calls a ScalarFunc
+// with the values of its arguments.
+type ScalarFunction struct {
+	Name string
+	Args []Expr
+	Func ScalarFunc
+}
+
+// Eval evaluates the arguments and returns the result of the function.
+func (f ScalarFunction) Eval(ctx EvalStack) (document.Value, error) {
+	values := make([]document.Value, len(f.Args))
+	for i, e := range f.Args {
+		v, err := e.Eval(ctx)
+		if err == document.ErrFieldNotFound {
+			v = nilLitteral
+		} else if err != nil {
+			return nilLitteral, err
+		}
+
+		values[i] = v
+	}
+
+	return f.Func(values...)
+}
+
+// scalarFunction returns a builder for the function fn, that checks the number of arguments.
+// If maxArgs is -1, the number of arguments is unbounded.
+func scalarFunction(name string, minArgs, maxArgs int, fn ScalarFunc) func(args ...Expr) (Expr, error) {
+	return func(args ...Expr) (Expr, error) {
+		if len(args) < minArgs || (maxArgs != -1 && len(args) > maxArgs) {
+			switch {
+			case maxArgs == -1:
+				return nil, fmt.Errorf("%s() takes at least %d argument(s)", name, minArgs)
+			case minArgs == maxArgs && minArgs == 1:
+				return nil, fmt.Errorf("%s() takes 1 argument", name)
+			case minArgs == maxArgs:
+				return nil, fmt.Errorf("%s() takes %d arguments", name, minArgs)
+			}
+
+			return nil, fmt.Errorf("%s() takes %d to %d arguments", name, minArgs, maxArgs)
+		}
+
+		return ScalarFunction{Name: name, Args: args, Func: fn}, nil
+	}
+}
+
+// nullIfAnyNull returns a function that returns null if any of its arguments is null
+// and calls fn otherwise.
+func nullIfAnyNull(fn ScalarFunc) ScalarFunc {
+	return func(args ...document.Value) (document.Value, error) {
+		for _, a := range args {
+			if a.Type == document.NullValue {
+				return nilLitteral, nil
+			}
+		}
+
+		return fn(args...)
+	}
+}
+
+// textArg returns the content of a text or blob argument.
+func textArg(fname string, v document.Value) (string, error) {
+	if v.Type != document.TextValue && v.Type != document.BlobValue {
+		return "", fmt.Errorf("%s() expects a text argument, got %s", fname, v.Type)
+	}
+
+	return string(v.V.([]byte)), nil
+}
+
+// intArg converts a numeric argument to an integer.
+func intArg(fname string, v document.Value) (int64, error) {
+	if !v.Type.IsNumber() {
+		return 0, fmt.Errorf("%s() expects an integer argument, got %s", fname, v.Type)
+	}
+
+	return v.ConvertToInt64()
+}
+
+func lowerFunc(args ...document.Value) (document.Value, error) {
+	s, err := textArg("lower", args[0])
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	return document.NewTextValue(strings.ToLower(s)), nil
+}
+
+func upperFunc(args ...document.Value) (document.Value, error) {
+	s, err := textArg("upper", args[0])
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	return document.NewTextValue(strings.ToUpper(s)), nil
+}
+
+// trimFunc removes the leading and trailing whitespaces of the text,
+// or the characters of the second argument if it is provided.
+func trimFunc(args ...document.Value) (document.Value, error) {
+	s, err := textArg("trim", args[0])
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	if len(args) == 1 {
+		return document.NewTextValue(strings.TrimSpace(s)), nil
+	}
+
+	cutset, err := textArg("trim", args[1])
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	return document.NewTextValue(strings.Trim(s, cutset)), nil
+}
+
+// substrFunc returns the characters of the text starting at the given position,
+// the first character being at position 1. The third argument limits the number
+// of characters returned.
+func substrFunc(args ...document.Value) (document.Value, error) {
+	s, err := textArg("substr", args[0])
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	start, err := intArg("substr", args[1])
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	runes := []rune(s)
+	from := start - 1
+	to := int64(len(runes))
+
+	if len(args) == 3 {
+		l, err := intArg("substr", args[2])
+		if err != nil {
+			return nilLitteral, err
+		}
+		if l < 0 {
+			return nilLitteral, errors.New("substr() expects a positive length")
+		}
+
+		if from+l < to {
+			to = from + l
+		}
+	}
+
+	if to < 0 {
+		to = 0
+	}
+	if from < 0 {
+		from = 0
+	}
+	if from > to {
+		from = to
+	}
+
+	return document.NewTextValue(string(runes[from:to])), nil
+}
+
+// lengthFunc returns the number of characters of a text, or the number of bytes of a blob.
+func lengthFunc(args ...document.Value) (document.Value, error) {
+	switch args[0].Type {
+	case document.TextValue:
+		return document.NewInt64Value(int64(utf8.RuneCount(args[0].V.([]byte)))), nil
+	case document.BlobValue:
+		return document.NewInt64Value(int64(len(args[0].V.([]byte)))), nil
+	}
+
+	return nilLitteral, fmt.Errorf("length() expects a text argument, got %s", args[0].Type)
+}
+
+// concatFunc concatenates the text representation of its arguments, ignoring null values.
+func concatFunc(args ...document.Value) (document.Value, error) {
+	var sb strings.Builder
+
+	for _, a := range args {
+		switch a.Type {
+		case document.NullValue:
+		case document.TextValue, document.BlobValue:
+			sb.Write(a.V.([]byte))
+		default:
+			sb.WriteString(a.String())
+		}
+	}
+
+	return document.NewTextValue(sb.String()), nil
+}
+
+func absFunc(args ...document.Value) (document.Value, error) {
+	v := args[0]
+
+	switch {
+	case v.Type == document.Float64Value:
+		return document.NewFloat64Value(math.Abs(v.V.(float64))), nil
+	case v.Type.IsInteger():
+		x, err := v.ConvertToInt64()
+		if err != nil {
+			return nilLitteral, err
+		}
+		if x >= 0 {
+			return v, nil
+		}
+		if x == math.MinInt64 {
+			return nilLitteral, errors.New("abs() overflows int64")
+		}
+
+		return document.NewInt64Value(-x).ConvertTo(v.Type)
+	}
+
+	return nilLitteral, fmt.Errorf("abs() expects a numeric argument, got %s", v.Type)
+}
+
+// roundFunc rounds the number to the nearest integer, or to the given number of decimal places.
+// Halfway values are rounded away from zero.
+func roundFunc(args ...document.Value) (document.Value, error) {
+	v := args[0]
+	if !v.Type.IsNumber() {
+		return nilLitteral, fmt.Errorf("round() expects a numeric argument, got %s", v.Type)
+	}
+
+	var digits int64
+	if len(args) == 2 {
+		var err error
+		digits, err = intArg("round", args[1])
+		if err != nil {
+			return nilLitteral, err
+		}
+	}
+
+	if v.Type.IsInteger() && digits >= 0 {
+		return v, nil
+	}
+
+	f, err := v.ConvertToFloat64()
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	p := math.Pow10(int(digits))
+	f = math.Round(f*p) / p
+
+	if v.Type.IsInteger() {
+		return document.NewInt64Value(int64(f)).ConvertTo(v.Type)
+	}
+
+	return document.NewFloat64Value(f), nil
+}
+
+func floorFunc(args ...document.Value) (document.Value, error) {
+	v := args[0]
+
+	switch {
+	case v.Type == document.Float64Value:
+		return document.NewFloat64Value(math.Floor(v.V.(float64))), nil
+	case v.Type.IsInteger():
+		return v, nil
+	}
+
+	return nilLitteral, fmt.Errorf("floor() expects a numeric argument, got %s", v.Type)
+}
+
+func ceilFunc(args ...document.Value) (document.Value, error) {
+	v := args[0]
+
+	switch {
+	case v.Type == document.Float64Value:
+		return document.NewFloat64Value(math.Ceil(v.V.(float64))), nil
+	case v.Type.IsInteger():
+		return v, nil
+	}
+
+	return nilLitteral, fmt.Errorf("ceil() expects a numeric argument, got %s", v.Type)
+}
+
+// typeofFunc returns the name of the type of the argument.
+func typeofFunc(args ...document.Value) (document.Value, error) {
+	return document.NewTextValue(args[0].Type.String()), nil
+}
+
+// coalesceFunc returns the first argument that is not null.
+func coalesceFunc(args ...document.Value) (document.Value, error) {
+	for _, a := range args {
+		if a.Type != document.NullValue {
+			return a, nil
+		}
+	}
+
+	return nilLitteral, nil
+}
+
+// lenFunc returns the number of values of an array, or the number of fields of a document.
+func lenFunc(args ...document.Value) (document.Value, error) {
+	var l int
+
+	switch args[0].Type {
+	case document.ArrayValue:
+		var err error
+		l, err = document.ArrayLength(args[0].V.(document.Array))
+		if err != nil {
+			return nilLitteral, err
+		}
+	case document.DocumentValue:
+		err := args[0].V.(document.Document).Iterate(func(string, document.Value) error {
+			l++
+			return nil
+		})
+		if err != nil {
+			return nilLitteral, err
+		}
+	default:
+		return nilLitteral, fmt.Errorf("len() expects an array or a document, got %s", args[0].Type)
+	}
+
+	return document.NewInt64Value(int64(l)), nil
+}
+
+// arrayContainsFunc returns whether the array contains a value equal to the second argument.
+func arrayContainsFunc(args ...document.Value) (document.Value, error) {
+	if args[0].Type != document.ArrayValue {
+		return nilLitteral, fmt.Errorf("array_contains() expects an array, got %s", args[0].Type)
+	}
+
+	var found bool
+	err := args[0].V.(document.Array).Iterate(func(i int, v document.Value) error {
+		ok, err := v.IsEqual(args[1])
+		if err != nil {
+			return err
+		}
+		if ok {
+			found = true
+			return errStop
+		}
+
+		return nil
+	})
+	if err != nil && err != errStop {
+		return nilLitteral, err
+	}
+
+	return document.NewBoolValue(found), nil
+}
+
+// keysFunc returns the names of the fields of a document.
+func keysFunc(args ...document.Value) (document.Value, error) {
+	if args[0].Type != document.DocumentValue {
+		return nilLitteral, fmt.Errorf("keys() expects a document, got %s", args[0].Type)
+	}
+
+	var keys document.ValueBuffer
+	err := args[0].V.(document.Document).Iterate(func(f string, _ document.Value) error {
+		keys = keys.Append(document.NewTextValue(f))
+		return nil
+	})
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	return document.NewArrayValue(keys), nil
+}
@@ -10,10 +10,26 @@ import (
 )
 
 // InsertStmt is a DSL that allows creating a full Insert query.
+// Documents are either created from a list of values or from the result of a SELECT statement.
 type InsertStmt struct {
 	TableName  string
 	FieldNames []string
 	Values     LiteralExprList
+	SelectStmt *SelectStmt
+	OnConflict *OnConflictClause
+	Returning  []ResultField
+}
+
+// OnConflictClause describes what an INSERT statement does when a document conflicts
+// with existing documents, either because they have the same primary key or because
+// they have the same values for the fields of a unique index.
+// Without this clause, the statement fails.
+type OnConflictClause struct {
+	// DoNothing skips the documents that conflict with existing ones.
+	DoNothing bool
+	// Pairs update the fields of the first conflicting document.
+	// The document that couldn't be inserted can be referred to using the "excluded" field.
+	Pairs []SetPair
 }
 
 // IsReadOnly always returns false. It implements the Statement interface.
@@ -30,7 +46,7 @@ func (stmt InsertStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		return res, errors.New("missing table name")
 	}
 
-	if stmt.Values == nil {
+	if stmt.Values == nil && stmt.SelectStmt == nil {
 		return res, errors.New("values are empty")
 	}
 
@@ -39,23 +55,49 @@ func (stmt InsertStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		return res, err
 	}
 
+	exprs := []Expr{stmt.Values}
+	if stmt.OnConflict != nil {
+		for _, pair := range stmt.OnConflict.Pairs {
+			exprs = append(exprs, pair.Expr)
+		}
+	}
+	err = prepareSubqueries(tx, args, exprs...)
+	if err != nil {
+		return res, err
+	}
+
 	stack := EvalStack{
 		Tx:     tx,
 		Params: args,
 	}
 
-	if len(stmt.FieldNames) > 0 {
-		return stmt.insertExprList(t, stack)
+	ret, err := newReturning(tx, args, t, stmt.Returning)
+	if err != nil {
+		return res, err
 	}
 
-	return stmt.insertDocuments(t, stack)
+	switch {
+	case stmt.SelectStmt != nil:
+		res, err = stmt.insertSelect(t, stack, ret)
+	case len(stmt.FieldNames) > 0:
+		res, err = stmt.insertExprList(t, stack, ret)
+	default:
+		res, err = stmt.insertDocuments(t, stack, ret)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	// if the statement has a RETURNING clause, return the inserted documents
+	res.Stream = ret.stream()
+	return res, nil
 }
 
 type paramExtractor interface {
 	extract(params []driver.NamedValue) (interface{}, error)
 }
 
-func (stmt InsertStmt) insertDocuments(t *database.Table, stack EvalStack) (Result, error) {
+func (stmt InsertStmt) insertDocuments(t *database.Table, stack EvalStack, ret *returning) (Result, error) {
 	var res Result
 	var err error
 
@@ -103,18 +145,16 @@ func (stmt InsertStmt) insertDocuments(t *database.Table, stack EvalStack) (Resu
 			return res, fmt.Errorf("values must be a list of documents if field list is empty")
 		}
 
-		res.lastInsertKey, err = t.Insert(d)
+		err = stmt.insert(t, d, stack, &res, ret)
 		if err != nil {
 			return res, err
 		}
-
-		res.rowsAffected++
 	}
 
 	return res, nil
 }
 
-func (stmt InsertStmt) insertExprList(t *database.Table, stack EvalStack) (Result, error) {
+func (stmt InsertStmt) insertExprList(t *database.Table, stack EvalStack, ret *returning) (Result, error) {
 	var res Result
 
 	// iterate over all of the documents (r1, r2, r3, ...)
@@ -157,13 +197,150 @@ func (stmt InsertStmt) insertExprList(t *database.Table, stack EvalStack) (Resul
 			return nil
 		})
 
-		res.lastInsertKey, err = t.Insert(&fb)
+		err = stmt.insert(t, &fb, stack, &res, ret)
 		if err != nil {
 			return res, err
 		}
+	}
 
-		res.rowsAffected++
+	return res, nil
+}
+
+// insertSelect inserts the documents returned by the SELECT statement.
+// If a field list was specified, the values of every document are assigned to the fields in order.
+// The documents are copied before being inserted, since some engines can't write to a transaction
+// while iterating over it.
+func (stmt InsertStmt) insertSelect(t *database.Table, stack EvalStack, ret *returning) (Result, error) {
+	var res Result
+
+	r, err := stmt.SelectStmt.Run(stack.Tx, stack.Params)
+	if err != nil {
+		return res, err
+	}
+
+	var docs []document.Document
+	err = r.Iterate(func(d document.Document) error {
+		if len(stmt.FieldNames) == 0 {
+			d, err := copyDocument(d)
+			if err != nil {
+				return err
+			}
+
+			docs = append(docs, d)
+			return nil
+		}
+
+		var fb document.FieldBuffer
+		var i int
+		err := d.Iterate(func(f string, v document.Value) error {
+			if i < len(stmt.FieldNames) {
+				v, err := copyValue(v)
+				if err != nil {
+					return err
+				}
+
+				fb.Add(stmt.FieldNames[i], v)
+			}
+
+			i++
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if i != len(stmt.FieldNames) {
+			return fmt.Errorf("%d values for %d fields", i, len(stmt.FieldNames))
+		}
+
+		docs = append(docs, &fb)
+		return nil
+	})
+	if err != nil {
+		return res, err
+	}
+
+	for _, d := range docs {
+		err = stmt.insert(t, d, stack, &res, ret)
+		if err != nil {
+			return res, err
+		}
 	}
 
 	return res, nil
 }
+
+// insert the document in the table and update the result.
+// If the statement has an ON CONFLICT clause and the document conflicts with existing documents,
+// it is either skipped or the first conflicting document is updated.
+// The inserted or updated document is added to ret.
+func (stmt InsertStmt) insert(t *database.Table, d document.Document, stack EvalStack, res *Result, ret *returning) error {
+	var keys [][]byte
+	var err error
+
+	if stmt.OnConflict != nil {
+		keys, err = t.Conflicts(d)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(keys) == 0 {
+		res.lastInsertKey, err = t.Insert(d)
+		if err != nil {
+			return err
+		}
+
+		res.rowsAffected++
+		return ret.addKey(t, res.lastInsertKey)
+	}
+
+	if stmt.OnConflict.DoNothing {
+		return nil
+	}
+
+	old, err := t.GetDocument(keys[0])
+	if err != nil {
+		return err
+	}
+
+	var fb document.FieldBuffer
+	err = fb.Copy(old)
+	if err != nil {
+		return err
+	}
+
+	stack.Document = excludedDocument{Document: old, excluded: d}
+	err = setFields(&fb, stmt.OnConflict.Pairs, stack)
+	if err != nil {
+		return err
+	}
+
+	err = t.Replace(keys[0], &fb)
+	if err != nil {
+		return err
+	}
+
+	res.lastInsertKey = keys[0]
+	res.rowsAffected++
+	return ret.addKey(t, keys[0])
+}
+
+// excludedDocument is the document evaluated by the ON CONFLICT DO UPDATE clause.
+// The fields of the conflicting document are selected directly, while the document
+// that couldn't be inserted is the value of the "excluded" field.
+type excludedDocument struct {
+	document.Document
+
+	excluded document.Document
+}
+
+// GetByField returns the document that couldn't be inserted if the field is "excluded",
+// otherwise it returns the field of the conflicting document.
+func (d excludedDocument) GetByField(field string) (document.Value, error) {
+	if field == "excluded" {
+		return document.NewDocumentValue(d.excluded), nil
+	}
+
+	return d.Document.GetByField(field)
+}
@@ -92,6 +92,62 @@ func TestInsertStmt(t *testing.T) {
 		require.Equal(t, err, database.ErrDuplicateDocument)
 	})
 
+	t.Run("on conflict", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			query    string
+			affected int64
+			expected string
+		}{
+			{"do nothing / primary key", "INSERT INTO test (id, email, n) VALUES (1, 'c', 0), (3, 'd', 0) ON CONFLICT DO NOTHING", 1,
+				`[{"id": 1, "email": "a", "n": 1}, {"id": 2, "email": "b", "n": 1}, {"id": 3, "email": "d", "n": 0}]`},
+			{"do nothing / unique index", "INSERT INTO test (id, email, n) VALUES (3, 'b', 0) ON CONFLICT DO NOTHING", 0,
+				`[{"id": 1, "email": "a", "n": 1}, {"id": 2, "email": "b", "n": 1}]`},
+			{"do update / primary key", "INSERT INTO test (id, email, n) VALUES (1, 'c', 5) ON CONFLICT DO UPDATE SET n = n + excluded.n", 1,
+				`[{"id": 1, "email": "a", "n": 6}, {"id": 2, "email": "b", "n": 1}]`},
+			{"do update / unique index", "INSERT INTO test VALUES {id: 3, email: 'b', n: 5} ON CONFLICT DO UPDATE SET n = excluded.n * 2", 1,
+				`[{"id": 1, "email": "a", "n": 1}, {"id": 2, "email": "b", "n": 10}]`},
+			{"do update / unique violation", "INSERT INTO test (id, email) VALUES (1, 'c') ON CONFLICT DO UPDATE SET email = 'b'", -1, ``},
+			{"do update / select", "INSERT INTO test SELECT id + 1 AS id, email, 10 AS n FROM test ON CONFLICT DO UPDATE SET n = n + excluded.n", 2,
+				`[{"id": 1, "email": "a", "n": 1}, {"id": 2, "email": "b", "n": 21}]`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec(`
+					CREATE TABLE test (id INTEGER PRIMARY KEY);
+					CREATE UNIQUE INDEX idx_email ON test (email);
+					INSERT INTO test (id, email, n) VALUES (1, 'a', 1), (2, 'b', 1);
+				`)
+				require.NoError(t, err)
+
+				res, err := db.Query(test.query)
+				if test.affected < 0 {
+					require.Equal(t, database.ErrDuplicateDocument, err)
+					return
+				}
+				require.NoError(t, err)
+				n, err := res.RowsAffected()
+				require.NoError(t, err)
+				require.Equal(t, test.affected, n)
+				require.NoError(t, res.Close())
+
+				st, err := db.Query("SELECT * FROM test")
+				require.NoError(t, err)
+				defer st.Close()
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, buf.String())
+			})
+		}
+	})
+
 	t.Run("with shadowing", func(t *testing.T) {
 		db, err := genji.Open(":memory:")
 		require.NoError(t, err)
@@ -0,0 +1,388 @@
+package query
+
+import (
+	"database/sql/driver"
+	"fmt"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/scanner"
+)
+
+// A JoinClause combines the documents of the table selected by a SELECT statement
+// with the documents of another table.
+type JoinClause struct {
+	// Type of the join, either scanner.INNER or scanner.LEFT.
+	Type       scanner.Token
+	TableName  string
+	TableAlias string
+	On         Expr
+}
+
+// Alias returns the name used to refer to the documents of the joined table.
+// It defaults to the table name if no alias was specified.
+func (j JoinClause) Alias() string {
+	if j.TableAlias != "" {
+		return j.TableAlias
+	}
+
+	return j.TableName
+}
+
+// joinedDocument is the document returned by a query that joins multiple tables.
+// It contains one field per table, named after the alias of the table, whose value
+// is the document read from that table.
+// A nil document means no document of the table matched the join condition
+// of a LEFT JOIN, in which case the field is null.
+type joinedDocument struct {
+	aliases []string
+	docs    []document.Document
+}
+
+func (j *joinedDocument) GetByField(field string) (document.Value, error) {
+	for i, alias := range j.aliases {
+		if alias == field {
+			return j.value(i), nil
+		}
+	}
+
+	return document.Value{}, document.ErrFieldNotFound
+}
+
+func (j *joinedDocument) Iterate(fn func(field string, value document.Value) error) error {
+	for i, alias := range j.aliases {
+		err := fn(alias, j.value(i))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (j *joinedDocument) value(i int) document.Value {
+	if j.docs[i] == nil {
+		return nilLitteral
+	}
+
+	return document.NewDocumentValue(j.docs[i])
+}
+
+// newJoinStream turns a stream of documents read from a table into a stream of joined documents,
+// then joins it with every table of the given clauses, one after the other.
+func newJoinStream(tx *database.Transaction, args []driver.NamedValue, st document.Stream, alias string, joins []JoinClause) (document.Stream, error) {
+	aliases := []string{alias}
+
+	st = st.Map(func(d document.Document) (document.Document, error) {
+		return &joinedDocument{aliases: aliases[:1], docs: []document.Document{d}}, nil
+	})
+
+	for _, j := range joins {
+		for _, a := range aliases {
+			if a == j.Alias() {
+				return st, fmt.Errorf("table name or alias %q specified more than once", a)
+			}
+		}
+
+		it, err := newJoinIterator(tx, args, st, aliases, j)
+		if err != nil {
+			return st, err
+		}
+
+		aliases = append(aliases, j.Alias())
+		st = document.NewStream(it)
+	}
+
+	return st, nil
+}
+
+// joinIterator joins every document of a stream of joined documents with the documents of a table
+// for which the join condition is true.
+// If the join condition compares a field of the table with an expression that only depends on
+// the left side of the join, and if this field is the primary key of the table or is indexed,
+// documents are looked up using the primary key or the index instead of reading the whole table.
+type joinIterator struct {
+	tx      *database.Transaction
+	args    []driver.NamedValue
+	st      document.Stream
+	join    JoinClause
+	aliases []string
+	tb      *database.Table
+	cfg     *database.TableConfig
+	lookup  *joinLookup
+}
+
+// joinLookup describes how to look up the documents of the right table of a join.
+type joinLookup struct {
+	// expression evaluated against the left side of the join.
+	// its value is looked up in the index or the primary key.
+	e            Expr
+	index        database.Index
+	isPrimaryKey bool
+}
+
+func newJoinIterator(tx *database.Transaction, args []driver.NamedValue, st document.Stream, left []string, j JoinClause) (*joinIterator, error) {
+	tb, err := tx.GetTable(j.TableName)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := tb.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	indexes, err := tb.Indexes()
+	if err != nil {
+		return nil, err
+	}
+
+	it := joinIterator{
+		tx:      tx,
+		args:    args,
+		st:      st,
+		join:    j,
+		aliases: append(left[:len(left):len(left)], j.Alias()),
+		tb:      tb,
+		cfg:     cfg,
+	}
+
+	it.lookup = it.analyseCondition(j.On, left, indexes)
+
+	return &it, nil
+}
+
+// analyseCondition looks for a comparison of the form alias.field = expr in the join condition
+// that can be used to look up the documents of the right table.
+// The expression must only refer to the tables of the left side of the join
+// and the field must be either the primary key or an indexed field.
+// If the condition is an AND operator, both operands are analysed and the primary key
+// is preferred over unique indexes which are preferred over other indexes.
+func (it *joinIterator) analyseCondition(e Expr, left []string, indexes map[string]database.Index) *joinLookup {
+	switch t := e.(type) {
+	case CmpOp:
+		if t.Token != scanner.EQ {
+			return nil
+		}
+
+		if l := it.lookupFor(t.LeftHand(), t.RightHand(), left, indexes); l != nil {
+			return l
+		}
+
+		return it.lookupFor(t.RightHand(), t.LeftHand(), left, indexes)
+	case *AndOp:
+		nodeL := it.analyseCondition(t.LeftHand(), left, indexes)
+		nodeR := it.analyseCondition(t.RightHand(), left, indexes)
+
+		switch {
+		case nodeL == nil:
+			return nodeR
+		case nodeR == nil:
+			return nodeL
+		case nodeR.isPrimaryKey && !nodeL.isPrimaryKey:
+			return nodeR
+		case !nodeL.isPrimaryKey && !nodeL.index.Unique && nodeR.index.Unique:
+			return nodeR
+		}
+
+		return nodeL
+	}
+
+	return nil
+}
+
+// lookupFor returns a joinLookup if field selects a field of the right table that
+// can be looked up using the value of e.
+func (it *joinIterator) lookupFor(field, e Expr, left []string, indexes map[string]database.Index) *joinLookup {
+	fs, ok := field.(FieldSelector)
+	if !ok || len(fs) < 2 || fs[0] != it.join.Alias() {
+		return nil
+	}
+
+	if !refersOnlyTo(e, left) {
+		return nil
+	}
+
+	path := FieldSelector(fs[1:]).Name()
+
+	pk := it.cfg.GetPrimaryKey()
+	if pk != nil && pk.Path.String() == path {
+		return &joinLookup{e: e, isPrimaryKey: true}
+	}
+
+	idx, ok := indexes[path]
+	if ok {
+		return &joinLookup{e: e, index: idx}
+	}
+
+	return nil
+}
+
+// refersOnlyTo returns true if all the fields selected by e are prefixed
+// by one of the given aliases.
+func refersOnlyTo(e Expr, aliases []string) bool {
+	ok := true
+
+	walkExpr(e, func(e Expr) {
+		switch t := e.(type) {
+		case FieldSelector:
+			for _, a := range aliases {
+				if t[0] == a {
+					return
+				}
+			}
+			ok = false
+		case AggregatorBuilder, Wildcard, PKFunc:
+			ok = false
+		}
+	})
+
+	return ok
+}
+
+func (it *joinIterator) Iterate(fn func(d document.Document) error) error {
+	st := it.st
+
+	// some engines don't allow opening an iterator while another one is still open
+	// within a read-write transaction, so the left side is read entirely
+	// before reading the right table.
+	if it.tx.Writable() {
+		var docs []document.Document
+
+		err := st.Iterate(func(d document.Document) error {
+			jd, err := copyJoinedDocument(d.(*joinedDocument))
+			if err != nil {
+				return err
+			}
+
+			docs = append(docs, jd)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		st = document.NewStream(document.NewIterator(docs...))
+	}
+
+	jd := joinedDocument{
+		aliases: it.aliases,
+	}
+	right := len(it.aliases) - 1
+
+	stack := EvalStack{
+		Tx:       it.tx,
+		Params:   it.args,
+		Document: &jd,
+	}
+
+	return st.Iterate(func(d document.Document) error {
+		left := d.(*joinedDocument)
+		jd.docs = append(append(jd.docs[:0], left.docs...), nil)
+
+		var matched bool
+		err := it.iterateRight(left, func(r document.Document) error {
+			jd.docs[right] = r
+
+			if it.join.On != nil {
+				v, err := it.join.On.Eval(stack)
+				if err != nil {
+					return err
+				}
+
+				if !v.IsTruthy() {
+					return nil
+				}
+			}
+
+			matched = true
+			return fn(&jd)
+		})
+		if err != nil {
+			return err
+		}
+
+		if !matched && it.join.Type == scanner.LEFT {
+			jd.docs[right] = nil
+			return fn(&jd)
+		}
+
+		return nil
+	})
+}
+
+// iterateRight calls fn for every document of the right table that may match the
+// join condition for the given left side.
+func (it *joinIterator) iterateRight(left *joinedDocument, fn func(d document.Document) error) error {
+	if it.lookup == nil {
+		return it.tb.Iterate(fn)
+	}
+
+	v, err := it.lookup.e.Eval(EvalStack{
+		Tx:       it.tx,
+		Params:   it.args,
+		Document: left,
+	})
+	// comparing a missing field always returns false.
+	if err == document.ErrFieldNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// only numbers and strings are looked up, other values might be considered
+	// equal to values of different types by the comparison operators.
+	if !v.Type.IsNumber() && v.Type != document.TextValue {
+		return it.tb.Iterate(fn)
+	}
+
+	if it.lookup.isPrimaryKey {
+		pkv, err := v.ConvertTo(it.cfg.GetPrimaryKey().Type)
+		if err != nil {
+			return it.tb.Iterate(fn)
+		}
+
+		return pkIterator{
+			tx:        it.tx,
+			tb:        it.tb,
+			cfg:       it.cfg,
+			args:      it.args,
+			op:        scanner.EQ,
+			e:         LiteralValue(v),
+			evalValue: pkv,
+		}.Iterate(fn)
+	}
+
+	return indexIterator{
+		tx:    it.tx,
+		tb:    it.tb,
+		args:  it.args,
+		index: it.lookup.index,
+		op:    scanner.EQ,
+		e:     LiteralValue(v),
+	}.Iterate(fn)
+}
+
+// copyJoinedDocument returns a copy of jd whose documents remain valid
+// after the iteration that returned them moves on to the next document.
+func copyJoinedDocument(jd *joinedDocument) (*joinedDocument, error) {
+	cp := joinedDocument{
+		aliases: jd.aliases,
+		docs:    make([]document.Document, len(jd.docs)),
+	}
+
+	for i, d := range jd.docs {
+		if d == nil {
+			continue
+		}
+
+		var err error
+		cp.docs[i], err = copyDocument(d)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &cp, nil
+}
@@ -0,0 +1,185 @@
+package query
+
+import (
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/index"
+	"github.com/asdine/genji/sql/scanner"
+)
+
+// An OrderingTerm is one of the keys of an ORDER BY clause.
+// Documents are sorted by the value of the first term, then by the value of the next
+// terms when the previous ones are equal.
+type OrderingTerm struct {
+	Expr Expr
+	// ExprName is the text of the expression. If it matches the name of one of
+	// the result fields of the statement, the term refers to that result field.
+	ExprName string
+	// Direction is either scanner.ASC or scanner.DESC. It defaults to scanner.ASC.
+	Direction scanner.Token
+	// Nulls is either scanner.FIRST or scanner.LAST. By default, null values are
+	// considered smaller than any other value: they come first in ascending order
+	// and last in descending order.
+	Nulls scanner.Token
+}
+
+// desc returns true if the term sorts the documents in descending order.
+func (t OrderingTerm) desc() bool {
+	return t.Direction == scanner.DESC
+}
+
+// nullsFirst returns true if the null values come before the other values.
+func (t OrderingTerm) nullsFirst() bool {
+	if t.Nulls == 0 {
+		return !t.desc()
+	}
+
+	return t.Nulls == scanner.FIRST
+}
+
+// resolveOrderingTerms returns a copy of the terms in which the terms referring to
+// result fields, either by their alias or by the same expression, are replaced by
+// the expression of the result field.
+// This allows sorting the documents by aliases, or by aggregate functions, before they are projected.
+func resolveOrderingTerms(terms []OrderingTerm, fields []ResultField) []OrderingTerm {
+	if len(terms) == 0 {
+		return nil
+	}
+
+	resolved := make([]OrderingTerm, len(terms))
+	for i, t := range terms {
+		for _, rf := range fields {
+			if rfe, ok := rf.(ResultFieldExpr); ok && rfe.ExprName == t.ExprName {
+				t.Expr = rfe.Expr
+				break
+			}
+		}
+
+		resolved[i] = t
+	}
+
+	return resolved
+}
+
+// Ranks of the types in the ordering keys.
+// Values of different types are ordered as follows:
+// null < booleans < numbers < text and blobs < arrays < documents.
+const (
+	rankNull byte = iota + 1
+	rankBool
+	rankNumber
+	rankBytes
+	rankArray
+	rankDocument
+)
+
+// Markers used to sort null values according to the NULLS FIRST or NULLS LAST clause.
+const (
+	nullsFirstMarker byte = iota
+	notNullMarker
+	nullsLastMarker
+)
+
+// appendOrderingKey appends to key the representation of the value of a term, so that
+// the keys of two documents compared using bytes.Compare follow the order of the terms.
+// The encoding is prefix-free, which allows concatenating the encoded values
+// of several terms and reversing the order of a term by inverting its bytes.
+func appendOrderingKey(key []byte, v document.Value, t OrderingTerm) ([]byte, error) {
+	if v.Type == document.NullValue {
+		if t.nullsFirst() {
+			return append(key, nullsFirstMarker), nil
+		}
+
+		return append(key, nullsLastMarker), nil
+	}
+
+	key = append(key, notNullMarker)
+	start := len(key)
+
+	key, err := appendOrderingValue(key, v)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.desc() {
+		for i := start; i < len(key); i++ {
+			key[i] = ^key[i]
+		}
+	}
+
+	return key, nil
+}
+
+// appendOrderingValue appends the rank of the type of v followed by its encoded value.
+// Numbers are converted to the same representation so that they can be compared with each other.
+// Arrays are compared element by element and documents field by field, in the order of their fields.
+func appendOrderingValue(key []byte, v document.Value) ([]byte, error) {
+	switch {
+	case v.Type == document.NullValue:
+		return append(key, rankNull), nil
+	case v.Type == document.BoolValue:
+		key = append(key, rankBool)
+	case v.Type.IsNumber():
+		key = append(key, rankNumber)
+	case v.Type == document.TextValue || v.Type == document.BlobValue:
+		enc, err := index.EncodeFieldToIndexValue(v)
+		if err != nil {
+			return nil, err
+		}
+
+		return appendOrderingBytes(append(key, rankBytes), enc), nil
+	case v.Type == document.ArrayValue:
+		a, err := v.ConvertToArray()
+		if err != nil {
+			return nil, err
+		}
+
+		key = append(key, rankArray)
+		err = a.Iterate(func(i int, v document.Value) error {
+			key, err = appendOrderingValue(append(key, 1), v)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return append(key, 0), nil
+	case v.Type == document.DocumentValue:
+		d, err := v.ConvertToDocument()
+		if err != nil {
+			return nil, err
+		}
+
+		key = append(key, rankDocument)
+		err = d.Iterate(func(f string, v document.Value) error {
+			key = appendOrderingBytes(append(key, 1), []byte(f))
+			key, err = appendOrderingValue(key, v)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return append(key, 0), nil
+	}
+
+	enc, err := index.EncodeFieldToIndexValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return append(key, enc...), nil
+}
+
+// appendOrderingBytes appends b to key, escaping its zero bytes, followed by a delimiter
+// which is smaller than any escaped byte.
+func appendOrderingBytes(key, b []byte) []byte {
+	for _, c := range b {
+		if c == 0 {
+			key = append(key, 0, 0xFF)
+		} else {
+			key = append(key, c)
+		}
+	}
+
+	return append(key, 0, 0x01)
+}
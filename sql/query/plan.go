@@ -2,9 +2,12 @@ package query
 
 import (
 	"bytes"
-	"container/heap"
 	"database/sql/driver"
 	"errors"
+	"math"
+	"regexp"
+	"regexp/syntax"
+	"sort"
 
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
@@ -17,7 +20,10 @@ import (
 type queryPlan struct {
 	scanTable bool
 	field     *queryPlanField
-	sorted    bool
+	// node is set when the documents are selected by combining
+	// the keys returned by multiple indexes.
+	node   *queryPlanNode
+	sorted bool
 }
 
 type queryPlanField struct {
@@ -26,8 +32,57 @@ type queryPlanField struct {
 	e            Expr
 	uniqueIndex  bool
 	isPrimaryKey bool
+	// value of e, converted to the type of the primary key.
+	pkValue document.Value
+	// composite index used to select the documents, if any.
+	// prefix contains the expressions compared for equality with its first fields,
+	// op and e describe the optional comparison of the next field.
+	composite *database.Index
+	prefix    []Expr
+	// if true, the composite index is read in reverse order.
+	reverse bool
+	// if true, only the first document of each indexed value is read.
+	distinct bool
+	// comparisons with the bounds of a range selected by comparing a field
+	// with a lower bound and an upper bound, like a > 1 AND a < 3.
+	// The op of such ranges is BETWEEN, and e contains both bounds.
+	// They are zero for the BETWEEN operator, whose bounds are included.
+	lowerOp, upperOp scanner.Token
 }
 
+// queryPlanNode is a node of the tree describing how the documents that may match
+// the where clause are selected.
+// A leaf reads a single index or the primary key. Other nodes combine the keys
+// selected by their children, using a union for OR operators and an intersection
+// for AND operators.
+type queryPlanNode struct {
+	field    *queryPlanField
+	op       scanner.Token
+	children []*queryPlanNode
+	// estimated number of documents selected by the node.
+	rows float64
+	// estimated cost of selecting the keys of the documents.
+	readCost float64
+	// estimated cost of selecting the keys and reading the documents.
+	cost float64
+}
+
+// Costs of the operations performed by a query plan.
+// They are used to compare the plans, their unit doesn't matter.
+const (
+	// reading a document while iterating over the table or the primary key.
+	scanCost = 1
+	// reading a key from an index.
+	indexReadCost = 1
+	// reading a document by key.
+	lookupCost = 2
+	// fraction of the values of an index selected by a range comparison.
+	rangeSelectivity = 0.25
+	// fraction of the values of an index selected by an equality comparison,
+	// if the cardinality of the index is unknown.
+	equalitySelectivity = 0.1
+)
+
 func newQueryOptimizer(tx *database.Transaction, tableName string) (qo queryOptimizer, err error) {
 	t, err := tx.GetTable(tableName)
 	if err != nil {
@@ -44,85 +99,60 @@ func newQueryOptimizer(tx *database.Transaction, tableName string) (qo queryOpti
 		return
 	}
 
+	// every document of the table is stored in each index,
+	// the biggest one gives the best estimate of the size of the table.
+	var tableSize float64
+	for _, idx := range indexes {
+		if float64(idx.Count) > tableSize {
+			tableSize = float64(idx.Count)
+		}
+	}
+
 	return queryOptimizer{
 		tx:        tx,
 		t:         t,
 		tableName: tableName,
 		cfg:       cfg,
 		indexes:   indexes,
+		tableSize: tableSize,
 	}, nil
 }
 
 // queryOptimizer is a really dumb query optimizer. gotta start somewhere. please don't be mad at me.
 type queryOptimizer struct {
-	tx               *database.Transaction
-	t                *database.Table
-	tableName        string
-	whereExpr        Expr
-	args             []driver.NamedValue
-	cfg              *database.TableConfig
-	indexes          map[string]database.Index
-	orderBy          FieldSelector
-	orderByDirection scanner.Token
-	limit            int
-	offset           int
+	tx        *database.Transaction
+	t         *database.Table
+	tableName string
+	whereExpr Expr
+	args      []driver.NamedValue
+	cfg       *database.TableConfig
+	indexes   map[string]database.Index
+	orderBy   []OrderingTerm
+	limit     int
+	offset    int
+	// distinct is true if the documents must be deduplicated once projected,
+	// in which case the limit cannot be used to sort them.
+	distinct bool
+	// distinctField is the only field selected by a SELECT DISTINCT statement,
+	// if the statement could be deduplicated using an index, see distinctIndex.
+	distinctField FieldSelector
+	// estimated number of documents in the table, zero if unknown.
+	tableSize float64
 }
 
 func (qo *queryOptimizer) optimizeQuery() (st document.Stream, err error) {
-	qp := qo.buildQueryPlan()
+	qp, err := qo.buildQueryPlan()
+	if err != nil {
+		return
+	}
 
 	switch {
 	case qp.scanTable:
 		st = document.NewStream(qo.t)
-	case qp.field.isPrimaryKey:
-		if qp.field.e == nil {
-			st = document.NewStream(pkIterator{
-				tx:               qo.tx,
-				tb:               qo.t,
-				cfg:              qo.cfg,
-				args:             qo.args,
-				op:               qp.field.op,
-				e:                qp.field.e,
-				orderByDirection: qo.orderByDirection,
-			})
-			break
-		}
-
-		var v document.Value
-		v, err = qp.field.e.Eval(EvalStack{
-			Tx:     qo.tx,
-			Params: qo.args,
-		})
-		if err != nil {
-			return
-		}
-
-		v, err := v.ConvertTo(qo.cfg.GetPrimaryKey().Type)
-		if err != nil {
-			st = document.NewStream(qo.t)
-			break
-		}
-
-		st = document.NewStream(pkIterator{
-			tx:               qo.tx,
-			tb:               qo.t,
-			cfg:              qo.cfg,
-			args:             qo.args,
-			op:               qp.field.op,
-			e:                qp.field.e,
-			orderByDirection: qo.orderByDirection,
-			evalValue:        v,
-		})
+	case qp.node != nil:
+		st = document.NewStream(nodeIterator{qo: qo, node: qp.node})
 	default:
-		st = document.NewStream(indexIterator{
-			tx:               qo.tx,
-			tb:               qo.t,
-			args:             qo.args,
-			op:               qp.field.op,
-			e:                qp.field.e,
-			index:            qo.indexes[qp.field.indexedField.Name()],
-			orderByDirection: qo.orderByDirection,
-		})
+		st = document.NewStream(qo.newLeafIterator(qp.field))
 	}
 
 	st = st.Filter(whereClause(qo.whereExpr, EvalStack{
@@ -131,465 +161,1905 @@ func (qo *queryOptimizer) optimizeQuery() (st document.Stream, err error) {
 	}))
 
 	if len(qo.orderBy) != 0 && !qp.sorted {
-		st, err = qo.sortIterator(st)
+		st, err = qo.sortIterator(st, nil)
 	}
 
 	return
 }
 
-func (qo *queryOptimizer) buildQueryPlan() queryPlan {
+func (qo *queryOptimizer) buildQueryPlan() (queryPlan, error) {
 	var qp queryPlan
 
-	qp.field = qo.analyseExpr(qo.whereExpr)
-	if qp.field == nil {
-		if len(qo.orderBy) != 0 {
-			_, ok := qo.indexes[qo.orderBy.Name()]
+	node, err := qo.analyseExpr(qo.whereExpr)
+	if err != nil {
+		return qp, err
+	}
+
+	// if most of the documents are selected anyway,
+	// reading the entire table is cheaper than using indexes.
+	if node != nil && qo.tableSize > 0 && node.cost > qo.tableSize*scanCost {
+		node = nil
+	}
+
+	switch {
+	case node == nil:
+		if f, ok := qo.distinctIndex(); ok {
+			qp.field = &queryPlanField{
+				indexedField: f,
+				distinct:     true,
+			}
+			qp.sorted = len(qo.orderBy) != 0
+
+			return qp, nil
+		}
+
+		if orderBy, _, ok := qo.sortedField(); ok {
+			_, ok := qo.indexes[orderBy.Name()]
 			pk := qo.cfg.GetPrimaryKey()
-			if ok || (pk != nil && pk.Path.String() == qo.orderBy.Name()) {
+			if ok || (pk != nil && pk.Path.String() == orderBy.Name()) {
 				qp.field = &queryPlanField{
-					indexedField: qo.orderBy,
-					isPrimaryKey: pk.Path.String() == qo.orderBy.Name(),
+					indexedField: orderBy,
+					isPrimaryKey: pk != nil && pk.Path.String() == orderBy.Name(),
 				}
 				qp.sorted = true
 
-				return qp
+				return qp, nil
+			}
+
+			// composite indexes starting with the field are sorted as well
+			for _, name := range qo.compositeIndexes() {
+				idx := qo.indexes[name]
+				if idx.Fields[0].Path.String() == orderBy.Name() {
+					qp.field = &queryPlanField{
+						indexedField: orderBy,
+						composite:    &idx,
+					}
+					qp.sorted = qo.sortWithComposite(qp.field)
+
+					return qp, nil
+				}
 			}
 		}
 
 		qp.scanTable = true
+	case node.field != nil:
+		qp.field = node.field
+		if qp.field.composite != nil && len(qo.orderBy) != 0 {
+			qp.sorted = qo.sortWithComposite(qp.field)
+		}
+	default:
+		qp.node = node
 	}
 
-	return qp
+	return qp, nil
 }
 
-// analyseExpr is a recursive function that scans each node the e Expr tree.
-// If it contains a comparison operator, it checks if this operator and its operands
-// can benefit from using an index. This check is done in the cmpOpCanUseIndex function.
-// If it contains an AND operator it checks if one of the operands can use an index.
-func (qo *queryOptimizer) analyseExpr(e Expr) *queryPlanField {
-	switch t := e.(type) {
-	case CmpOp:
-		ok, fs, e := cmpOpCanUseIndex(&t)
-		if !ok || !evaluatesToScalarOrParam(e) {
-			return nil
-		}
-
-		idx, ok := qo.indexes[fs.Name()]
-		if ok {
-			return &queryPlanField{
-				indexedField: fs,
-				op:           t.Token,
-				e:            e,
-				uniqueIndex:  idx.Unique,
-			}
-		}
-
-		pk := qo.cfg.GetPrimaryKey()
-		if pk != nil && pk.Path.String() == fs.Name() {
-			return &queryPlanField{
-				indexedField: fs,
-				op:           t.Token,
-				e:            e,
-				uniqueIndex:  true,
-				isPrimaryKey: true,
-			}
-		}
+// sortWithComposite returns true if the documents selected by the composite index
+// are sorted by the ORDER BY field, i.e. if it is the first field following the prefix.
+// If so, it determines in which order the index must be read.
+func (qo *queryOptimizer) sortWithComposite(f *queryPlanField) bool {
+	orderBy, direction, ok := qo.sortedField()
+	if !ok {
+		return false
+	}
 
-		return nil
+	fields := f.composite.Fields
+	if len(f.prefix) >= len(fields) {
+		return false
+	}
 
-	case *AndOp:
-		nodeL := qo.analyseExpr(t.LeftHand())
-		nodeR := qo.analyseExpr(t.LeftHand())
+	next := fields[len(f.prefix)]
+	if next.Path.String() != orderBy.Name() {
+		return false
+	}
 
-		if nodeL == nil && nodeR == nil {
-			return nil
-		}
+	f.reverse = (direction == scanner.DESC) != next.Desc
+	return true
+}
 
-		if nodeL != nil && nodeL.uniqueIndex {
-			return nodeL
-		}
+// sortedField returns the field and the direction of the ORDER BY clause if the documents
+// can be read in order from an index or from the primary key.
+// This requires the clause to have a single term, which selects a field and sorts the null values
+// in the default order, as indexes do.
+func (qo *queryOptimizer) sortedField() (FieldSelector, scanner.Token, bool) {
+	if len(qo.orderBy) != 1 {
+		return nil, 0, false
+	}
 
-		if nodeR != nil && nodeR.uniqueIndex {
-			return nodeR
-		}
+	t := qo.orderBy[0]
+	fs, ok := t.Expr.(FieldSelector)
+	if !ok || t.nullsFirst() == t.desc() {
+		return nil, 0, false
+	}
 
-		return nodeL
+	if t.desc() {
+		return fs, scanner.DESC, true
 	}
 
-	return nil
+	return fs, scanner.ASC, true
 }
 
-func cmpOpCanUseIndex(cmp *CmpOp) (bool, FieldSelector, Expr) {
-	switch cmp.Token {
-	case scanner.EQ, scanner.GT, scanner.GTE, scanner.LT, scanner.LTE:
-	default:
-		return false, nil, nil
+// distinctIndex returns the field of a SELECT DISTINCT statement if the distinct values
+// can be read by walking an index and skipping the repeated values.
+// This requires the statement to select that field only, without a WHERE clause, and
+// the field to have a type constraint. Indexes store numbers as doubles, and text as blobs,
+// and they store null values, arrays and documents together, so that values which are not
+// equal can share the same indexed value otherwise.
+// Since the index is sorted, the documents can also be ordered by that field.
+func (qo *queryOptimizer) distinctIndex() (FieldSelector, bool) {
+	if qo.distinctField == nil || qo.whereExpr != nil {
+		return nil, false
 	}
 
-	lf, leftIsField := cmp.LeftHand().(FieldSelector)
-	rf, rightIsField := cmp.RightHand().(FieldSelector)
+	name := qo.distinctField.Name()
+	idx, ok := qo.indexes[name]
+	if !ok || idx.IsComposite() {
+		return nil, false
+	}
 
-	// field OP expr
-	if leftIsField && !rightIsField {
-		return true, lf, cmp.RightHand()
+	var typed bool
+	for _, fc := range qo.cfg.FieldConstraints {
+		if fc.Path.String() == name {
+			typed = fc.Type != 0 && fc.Type != document.DocumentValue && fc.Type != document.ArrayValue
+			break
+		}
+	}
+	if !typed {
+		return nil, false
 	}
 
-	// expr OP field
-	if rightIsField && !leftIsField {
-		return true, rf, cmp.LeftHand()
+	if len(qo.orderBy) != 0 {
+		orderBy, _, ok := qo.sortedField()
+		if !ok || orderBy.Name() != name {
+			return nil, false
+		}
 	}
 
-	return false, nil, nil
+	return qo.distinctField, true
 }
 
-func evaluatesToScalarOrParam(e Expr) bool {
-	switch e.(type) {
-	case LiteralValue:
-		return true
-	case NamedParam, PositionalParam:
-		return true
+// sortDirection returns the order in which the primary key and the indexes must be read.
+func (qo *queryOptimizer) sortDirection() scanner.Token {
+	if _, direction, ok := qo.sortedField(); ok {
+		return direction
 	}
 
-	return false
+	return scanner.ASC
 }
 
-type indexIterator struct {
-	tx               *database.Transaction
-	tb               *database.Table
-	args             []driver.NamedValue
-	index            index.Index
-	op               scanner.Token
-	e                Expr
-	orderByDirection scanner.Token
+// compositeIndexes returns the keys of the composite indexes of the table, sorted.
+func (qo *queryOptimizer) compositeIndexes() []string {
+	var names []string
+	for name, idx := range qo.indexes {
+		if idx.IsComposite() {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names
 }
 
-var errStop = errors.New("stop")
+// analyseExpr is a recursive function that scans each node the e Expr tree
+// and returns the cheapest way of selecting the documents that may match e,
+// or nil if the entire table must be read.
+// If it contains a comparison operator, it checks if this operator and its operands
+// can benefit from using an index. This check is done in the cmpOpCanUseIndex function.
+// If it contains an AND operator, it selects the cheapest of its operands, a range
+// if a field is compared with a lower and an upper bound, or the intersection of both
+// operands if they read different indexes.
+// If it contains an OR operator, it selects the union of its operands, provided
+// that both of them can use an index.
+func (qo *queryOptimizer) analyseExpr(e Expr) (*queryPlanNode, error) {
+	switch t := e.(type) {
+	case CmpOp:
+		f, err := qo.analyseCmpOp(t)
+		if err != nil {
+			return nil, err
+		}
 
-func (it indexIterator) Iterate(fn func(d document.Document) error) error {
-	if it.e == nil {
-		var err error
+		var node *queryPlanNode
+		if f != nil {
+			node = qo.newLeafNode(f)
+		}
 
-		if it.orderByDirection == scanner.DESC {
-			err = it.index.DescendLessOrEqual(nil, func(val document.Value, key []byte) error {
-				r, err := it.tb.GetDocument(key)
-				if err != nil {
-					return err
-				}
+		return cheapest(node, qo.analyseCompositeIndexes([]Expr{t})), nil
 
-				return fn(r)
-			})
-		} else {
-			err = it.index.AscendGreaterOrEqual(nil, func(val document.Value, key []byte) error {
-				r, err := it.tb.GetDocument(key)
-				if err != nil {
-					return err
-				}
+	case RegexOp:
+		f := qo.analyseRegexOp(t)
+		if f == nil {
+			return nil, nil
+		}
+
+		return qo.newLeafNode(f), nil
 
-				return fn(r)
-			})
+	case InOp:
+		f, err := qo.analyseInOp(t)
+		if f == nil || err != nil {
+			return nil, err
 		}
 
-		return err
-	}
+		return qo.newLeafNode(f), nil
 
-	v, err := it.e.Eval(EvalStack{
-		Tx:     it.tx,
-		Params: it.args,
-	})
-	if err != nil {
-		return err
-	}
+	case BetweenOp:
+		f, err := qo.analyseBetweenOp(t)
+		if f == nil || err != nil {
+			return nil, err
+		}
 
-	if v.Type.IsNumber() {
-		v, err = v.ConvertTo(document.Float64Value)
+		return qo.newLeafNode(f), nil
+
+	case *AndOp:
+		nodeL, err := qo.analyseExpr(t.LeftHand())
 		if err != nil {
-			return err
+			return nil, err
+		}
+		nodeR, err := qo.analyseExpr(t.RightHand())
+		if err != nil {
+			return nil, err
 		}
-	}
 
-	switch it.op {
-	case scanner.EQ:
-		err = it.index.AscendGreaterOrEqual(&index.Pivot{Value: v}, func(val document.Value, key []byte) error {
-			ok, err := v.IsEqual(val)
-			if err != nil {
-				return err
-			}
+		best := cheapest(nodeL, nodeR)
+		if nodeL != nil && nodeR != nil && !readSameIndex(nodeL, nodeR) {
+			best = cheapest(best, qo.newCombinedNode(scanner.AND, nodeL, nodeR))
+		}
 
-			if ok {
-				r, err := it.tb.GetDocument(key)
-				if err != nil {
-					return err
-				}
+		// ranges and composite indexes use multiple operands at once.
+		exprs := conjuncts(t)
+		ranges, err := qo.analyseRanges(exprs)
+		if err != nil {
+			return nil, err
+		}
+		best = cheapest(best, ranges)
 
-				return fn(r)
-			}
+		return cheapest(best, qo.analyseCompositeIndexes(exprs)), nil
 
-			return errStop
-		})
-	case scanner.GT:
-		err = it.index.AscendGreaterOrEqual(&index.Pivot{Value: v}, func(val document.Value, key []byte) error {
-			ok, err := v.IsEqual(val)
-			if err != nil {
-				return err
-			}
+	case *OrOp:
+		nodeL, err := qo.analyseExpr(t.LeftHand())
+		if err != nil {
+			return nil, err
+		}
+		nodeR, err := qo.analyseExpr(t.RightHand())
+		if err != nil {
+			return nil, err
+		}
 
-			if ok {
-				return nil
-			}
+		// documents that don't match one of the operands may match the other,
+		// both of them must use an index.
+		if nodeL == nil || nodeR == nil {
+			return nil, nil
+		}
 
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
+		return qo.newCombinedNode(scanner.OR, nodeL, nodeR), nil
+	}
 
-			return fn(r)
-		})
-	case scanner.GTE:
-		err = it.index.AscendGreaterOrEqual(&index.Pivot{Value: v}, func(val document.Value, key []byte) error {
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
+	return nil, nil
+}
 
-			return fn(r)
-		})
-	case scanner.LT:
-		err = it.index.AscendGreaterOrEqual(index.EmptyPivot(v.Type), func(val document.Value, key []byte) error {
-			ok, err := v.IsLesserThanOrEqual(val)
-			if err != nil {
-				return err
-			}
+// cheapest returns the node with the lowest cost, ignoring nil nodes.
+// If both nodes have the same cost, the first one is returned.
+func cheapest(a, b *queryPlanNode) *queryPlanNode {
+	if a == nil || (b != nil && b.cost < a.cost) {
+		return b
+	}
 
-			if ok {
-				return errStop
-			}
+	return a
+}
 
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
+// conjuncts returns the operands of e, and of the AND operators it contains.
+func conjuncts(e Expr) []Expr {
+	if t, ok := e.(*AndOp); ok {
+		return append(conjuncts(t.LeftHand()), conjuncts(t.RightHand())...)
+	}
 
-			return fn(r)
-		})
-	case scanner.LTE:
-		err = it.index.AscendGreaterOrEqual(index.EmptyPivot(v.Type), func(val document.Value, key []byte) error {
-			ok, err := v.IsLesserThan(val)
-			if err != nil {
-				return err
-			}
+	return []Expr{e}
+}
 
-			if ok {
-				return errStop
+// readSameIndex returns true if a leaf of a and a leaf of b read the same index or the primary key.
+// Intersecting the keys they select would read the same index twice.
+func readSameIndex(a, b *queryPlanNode) bool {
+	for _, x := range a.leaves() {
+		for _, y := range b.leaves() {
+			if x.source() == y.source() {
+				return true
 			}
+		}
+	}
 
-			r, err := it.tb.GetDocument(key)
-			if err != nil {
-				return err
-			}
+	return false
+}
 
-			return fn(r)
-		})
+// leaves returns the fields read by the leaves of the node.
+func (n *queryPlanNode) leaves() []*queryPlanField {
+	if n.field != nil {
+		return []*queryPlanField{n.field}
 	}
 
-	if err != nil && err != errStop {
-		return err
+	var fields []*queryPlanField
+	for _, c := range n.children {
+		fields = append(fields, c.leaves()...)
 	}
 
-	return nil
+	return fields
 }
 
-type pkIterator struct {
-	tx               *database.Transaction
-	tb               *database.Table
-	cfg              *database.TableConfig
-	args             []driver.NamedValue
-	op               scanner.Token
-	e                Expr
-	orderByDirection scanner.Token
-	evalValue        document.Value
+// planSource identifies the index or the primary key read by a field of a query plan.
+type planSource struct {
+	primaryKey bool
+	composite  string
+	path       string
 }
 
-func (it pkIterator) Iterate(fn func(d document.Document) error) error {
-	if it.e == nil {
-		var err error
-
-		if it.orderByDirection == scanner.DESC {
-			err = it.tb.Store.DescendLessOrEqual(nil, func(k []byte, v []byte) error {
-				return fn(encoding.EncodedDocument(v))
-			})
-		} else {
-			err = it.tb.Store.AscendGreaterOrEqual(nil, func(k []byte, v []byte) error {
-				return fn(encoding.EncodedDocument(v))
-			})
-		}
-
-		return err
+func (f *queryPlanField) source() planSource {
+	if f.composite != nil {
+		return planSource{composite: f.composite.IndexName}
 	}
 
-	data, err := encoding.EncodeValue(it.evalValue)
-	if err != nil {
-		return err
-	}
+	return planSource{primaryKey: f.isPrimaryKey, path: f.indexedField.Name()}
+}
 
-	switch it.op {
-	case scanner.EQ:
-		val, err := it.tb.Store.Get(data)
-		if err != nil {
-			if err == engine.ErrKeyNotFound {
-				return nil
-			}
+// analyseRanges returns the cheapest node reading a single range of an index or of the primary key
+// to select the documents whose field is compared with a lower bound and with an upper bound
+// by two of the given expressions, or nil if no field is compared with both bounds.
+func (qo *queryOptimizer) analyseRanges(exprs []Expr) (*queryPlanNode, error) {
+	var best *queryPlanNode
 
-			return err
+	for _, lower := range exprs {
+		fs, lowerOp, lowerE := rangeComparison(lower)
+		if lowerOp != scanner.GT && lowerOp != scanner.GTE {
+			continue
 		}
-		return fn(encoding.EncodedDocument(val))
-	case scanner.GT:
-		err = it.tb.Store.AscendGreaterOrEqual(data, func(key, val []byte) error {
-			if bytes.Equal(data, val) {
-				return nil
-			}
 
-			return fn(encoding.EncodedDocument(val))
-		})
-	case scanner.GTE:
-		err = it.tb.Store.AscendGreaterOrEqual(data, func(key, val []byte) error {
-			return fn(encoding.EncodedDocument(val))
-		})
-	case scanner.LT:
-		err = it.tb.Store.AscendGreaterOrEqual(nil, func(key, val []byte) error {
-			if bytes.Compare(data, val) <= 0 {
-				return errStop
+		for _, upper := range exprs {
+			ufs, upperOp, upperE := rangeComparison(upper)
+			if upperOp != scanner.LT && upperOp != scanner.LTE || ufs.Name() != fs.Name() {
+				continue
 			}
 
-			return fn(encoding.EncodedDocument(val))
-		})
-	case scanner.LTE:
-		err = it.tb.Store.AscendGreaterOrEqual(nil, func(key, val []byte) error {
-			if bytes.Compare(data, val) < 0 {
-				return errStop
+			f, err := qo.newQueryPlanField(fs, scanner.BETWEEN, LiteralExprList{lowerE, upperE})
+			if err != nil {
+				return nil, err
+			}
+			if f == nil {
+				continue
 			}
 
-			return fn(encoding.EncodedDocument(val))
-		})
-	}
-
-	if err != nil && err != errStop {
-		return err
+			f.lowerOp, f.upperOp = lowerOp, upperOp
+			best = cheapest(best, qo.newLeafNode(f))
+		}
 	}
 
-	return nil
+	return best, nil
 }
 
-// sortIterator operates a partial sort on the iterator using a heap.
-// This ensures a O(n+klog n) time complexity
-// with k being the limit of the query, or the sum of the limit + offset, when both offset and limit are used.
-// if there are no limit or offsets, k = n, the number of elements in the table.
-// If the sorting is in ascending order, a min-heap will be used
-// otherwise a max-heap will be used instead.
-// Once the heap is filled entirely with the content of the table a stream is returned.
-// During iteration, the stream will pop the k-smallest or k-largest elements, depending on
-// the chosen sorting order (ASC or DESC).
-// This function is not memory efficient as it's loading the entire table in memory before
-// returning the k-smallest or k-largest elements.
-func (qo *queryOptimizer) sortIterator(it document.Iterator) (st document.Stream, err error) {
-	k := 0
-	if qo.limit != -1 {
-		k += qo.limit
-		if qo.offset != -1 {
-			k += qo.offset
-		}
+// rangeComparison returns the field, the operator and the expression of e
+// if it is a comparison which can use an index. Otherwise, the operator is zero.
+func rangeComparison(e Expr) (FieldSelector, scanner.Token, Expr) {
+	cmp, ok := e.(CmpOp)
+	if !ok {
+		return nil, 0, nil
 	}
 
-	path := document.ValuePath(qo.orderBy)
-
-	var h heap.Interface
-	if qo.orderByDirection == scanner.ASC {
-		h = new(minHeap)
-	} else {
-		h = new(maxHeap)
+	ok, fs, op, e := cmpOpCanUseIndex(&cmp)
+	if !ok || !evaluatesToScalarOrParam(e) {
+		return nil, 0, nil
 	}
 
-	heap.Init(h)
+	return fs, op, e
+}
 
-	err = it.Iterate(func(d document.Document) error {
-		v, err := path.GetValue(d)
-		if err != nil && err != document.ErrFieldNotFound {
-			return err
-		}
-		if err == document.ErrFieldNotFound {
-			v = document.NewNullValue()
+// analyseCompositeIndexes returns the cheapest node using a composite index to select
+// the documents matching all the given expressions, or nil if none of them can be used.
+// A composite index can be used if its first fields are compared for equality,
+// optionally followed by a range comparison on the next field.
+func (qo *queryOptimizer) analyseCompositeIndexes(exprs []Expr) *queryPlanNode {
+	var best *queryPlanNode
+
+	for _, name := range qo.compositeIndexes() {
+		idx := qo.indexes[name]
+		f := queryPlanField{
+			composite: &idx,
 		}
 
-		value, err := index.EncodeFieldToIndexValue(v)
-		if err != nil {
-			return err
+		for _, field := range idx.Fields {
+			e := findComparison(exprs, field.Path, scanner.EQ)
+			if e == nil {
+				break
+			}
+
+			f.indexedField = FieldSelector(field.Path)
+			f.prefix = append(f.prefix, e)
 		}
 
-		data, err := encoding.EncodeDocument(d)
-		if err != nil {
-			return err
+		if len(f.prefix) < len(idx.Fields) {
+			next := idx.Fields[len(f.prefix)]
+			for _, op := range []scanner.Token{scanner.GT, scanner.GTE, scanner.LT, scanner.LTE} {
+				if e := findComparison(exprs, next.Path, op); e != nil {
+					f.indexedField = FieldSelector(next.Path)
+					f.op = op
+					f.e = e
+					break
+				}
+			}
 		}
 
-		heap.Push(h, heapNode{
-			value: value,
-			data:  data,
-		})
+		if len(f.prefix) == 0 && f.e == nil {
+			continue
+		}
 
-		return nil
-	})
-	if err != nil {
-		return
+		best = cheapest(best, qo.newLeafNode(&f))
 	}
 
-	st = document.NewStream(&sortedIterator{h, k})
+	return best
+}
+
+// findComparison returns the expression compared with the given path
+// using the given operator, if any of exprs is such a comparison.
+func findComparison(exprs []Expr, path document.ValuePath, op scanner.Token) Expr {
+	for _, e := range exprs {
+		cmp, ok := e.(CmpOp)
+		if !ok {
+			continue
+		}
+
+		ok, fs, cmpOp, e := cmpOpCanUseIndex(&cmp)
+		if ok && cmpOp == op && fs.Name() == path.String() && evaluatesToScalarOrParam(e) {
+			return e
+		}
+	}
+
+	return nil
+}
+
+// analyseCmpOp returns the field describing how to use the primary key or an index
+// to select the documents matching the comparison, or nil if it can't.
+func (qo *queryOptimizer) analyseCmpOp(cmp CmpOp) (*queryPlanField, error) {
+	ok, fs, op, e := cmpOpCanUseIndex(&cmp)
+	if !ok || !evaluatesToScalarOrParam(e) {
+		return nil, nil
+	}
+
+	return qo.newQueryPlanField(fs, op, e)
+}
+
+// analyseInOp returns the field describing how to use the primary key or an index
+// to select the documents matching the IN operator, or nil if it can't.
+// Every value of the list is looked up separately.
+func (qo *queryOptimizer) analyseInOp(in InOp) (*queryPlanField, error) {
+	fs, ok := in.LeftHand().(FieldSelector)
+	if !ok || !evaluatesToListOfScalarsOrParam(in.RightHand()) {
+		return nil, nil
+	}
+
+	return qo.newQueryPlanField(fs, scanner.IN, in.RightHand())
+}
+
+// analyseBetweenOp returns the field describing how to use the primary key or an index
+// to select the documents matching the BETWEEN operator, or nil if it can't.
+// The documents are read from the lower bound to the upper bound.
+func (qo *queryOptimizer) analyseBetweenOp(op BetweenOp) (*queryPlanField, error) {
+	fs, ok := op.X.(FieldSelector)
+	if !ok || !evaluatesToScalarOrParam(op.LeftHand()) || !evaluatesToScalarOrParam(op.RightHand()) {
+		return nil, nil
+	}
+
+	return qo.newQueryPlanField(fs, scanner.BETWEEN, LiteralExprList{op.LeftHand(), op.RightHand()})
+}
+
+// newQueryPlanField returns the field describing how to select the documents whose field fs
+// is compared to e using op, if fs is indexed or is the primary key, or nil otherwise.
+// For the IN and BETWEEN operators, e must evaluate to an array containing respectively
+// the values of the list and the bounds.
+func (qo *queryOptimizer) newQueryPlanField(fs FieldSelector, op scanner.Token, e Expr) (*queryPlanField, error) {
+	idx, ok := qo.indexes[fs.Name()]
+	if ok {
+		return &queryPlanField{
+			indexedField: fs,
+			op:           op,
+			e:            e,
+			uniqueIndex:  idx.Unique,
+		}, nil
+	}
+
+	pk := qo.cfg.GetPrimaryKey()
+	if pk == nil || pk.Path.String() != fs.Name() {
+		return nil, nil
+	}
+
+	// the value compared to the primary key must be converted to the type
+	// of the primary key. if the conversion fails, the primary key can't be used.
+	v, err := e.Eval(EvalStack{
+		Tx:     qo.tx,
+		Params: qo.args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var pkv document.Value
+	if op == scanner.IN || op == scanner.BETWEEN {
+		pkv, err = convertArrayValues(v, pk.Type)
+	} else {
+		pkv, err = v.ConvertTo(pk.Type)
+	}
+	if err != nil {
+		return nil, nil
+	}
+
+	return &queryPlanField{
+		indexedField: fs,
+		op:           op,
+		e:            e,
+		uniqueIndex:  true,
+		isPrimaryKey: true,
+		pkValue:      pkv,
+	}, nil
+}
+
+// convertArrayValues converts every value of the array to the given type.
+func convertArrayValues(v document.Value, t document.ValueType) (document.Value, error) {
+	if v.Type != document.ArrayValue {
+		return v, errors.New("expected array")
+	}
+
+	var values document.ValueBuffer
+	err := v.V.(document.Array).Iterate(func(i int, v document.Value) error {
+		v, err := v.ConvertTo(t)
+		if err != nil {
+			return err
+		}
+
+		values = values.Append(v)
+		return nil
+	})
+
+	return document.NewArrayValue(values), err
+}
+
+// analyseRegexOp returns the field describing how to use an index to select the documents
+// matching the regular expression, or nil if it can't.
+// If the regular expression is anchored at the beginning of the text and starts with a literal prefix,
+// the matching values are contiguous in the index, starting from the prefix.
+func (qo *queryOptimizer) analyseRegexOp(op RegexOp) *queryPlanField {
+	fs, ok := op.LeftHand().(FieldSelector)
+	if !ok || op.Token != scanner.EQREGEX {
+		return nil
+	}
+
+	re, ok := op.RightHand().(Regex)
+	if !ok {
+		return nil
+	}
+
+	idx, ok := qo.indexes[fs.Name()]
+	if !ok {
+		return nil
+	}
+
+	prefix := literalPrefix(re.Regexp)
+	if prefix == "" {
+		return nil
+	}
+
+	return &queryPlanField{
+		indexedField: fs,
+		op:           scanner.EQREGEX,
+		e:            TextValue(prefix),
+		uniqueIndex:  idx.Unique,
+	}
+}
+
+// literalPrefix returns the literal text every value matching the regular expression
+// starts with, or an empty string if the regular expression isn't anchored at the beginning of the text.
+func literalPrefix(re *regexp.Regexp) string {
+	r, err := syntax.Parse(re.String(), syntax.Perl)
+	if err != nil || r.Op != syntax.OpConcat || r.Sub[0].Op != syntax.OpBeginText {
+		return ""
+	}
+
+	var prefix []rune
+	for _, sub := range r.Sub[1:] {
+		if sub.Op != syntax.OpLiteral || sub.Flags&syntax.FoldCase != 0 {
+			break
+		}
+
+		prefix = append(prefix, sub.Rune...)
+	}
+
+	return string(prefix)
+}
+
+// newLeafNode estimates the number of documents selected by f and the cost of reading them.
+func (qo *queryOptimizer) newLeafNode(f *queryPlanField) *queryPlanNode {
+	n := queryPlanNode{field: f}
+
+	if f.isPrimaryKey {
+		switch f.op {
+		case scanner.EQ:
+			n.rows = 1
+		case scanner.IN:
+			n.rows = float64(arrayLength(f.pkValue))
+		default:
+			n.rows = qo.tableSize * rangeSelectivity
+		}
+		if f.op == scanner.BETWEEN {
+			// both bounds of the range restrict the selected documents.
+			n.rows *= rangeSelectivity
+		}
+
+		// documents are read while iterating over the primary key.
+		n.readCost = n.rows * scanCost
+		n.cost = n.readCost
+		return &n
+	}
+
+	switch {
+	case f.composite != nil:
+		n.rows = estimateIndexRows(*f.composite, len(f.prefix), f.e != nil)
+	case f.op == scanner.EQ:
+		n.rows = estimateIndexRows(qo.indexes[f.indexedField.Name()], 1, false)
+	case f.op == scanner.IN:
+		// every value of the list is looked up like an equality comparison.
+		v, err := f.e.Eval(EvalStack{Tx: qo.tx, Params: qo.args})
+		if err == nil {
+			n.rows = float64(arrayLength(v)) * estimateIndexRows(qo.indexes[f.indexedField.Name()], 1, false)
+		}
+	case f.op == scanner.BETWEEN:
+		// both bounds of the range restrict the selected documents.
+		n.rows = estimateIndexRows(qo.indexes[f.indexedField.Name()], 0, true) * rangeSelectivity
+	default:
+		n.rows = estimateIndexRows(qo.indexes[f.indexedField.Name()], 0, true)
+	}
+
+	n.readCost = n.rows * indexReadCost
+	n.cost = n.readCost + n.rows*lookupCost
+	return &n
+}
+
+// estimateIndexRows estimates the number of documents selected by comparing the first eq fields
+// of the index for equality, optionally followed by a range comparison on the next field.
+// Equality comparisons on all the fields select count / cardinality documents on average.
+// Distinct values are assumed to be evenly spread among the fields of composite indexes,
+// and range comparisons are assumed to select a fixed fraction of the index.
+func estimateIndexRows(idx database.Index, eq int, hasRange bool) float64 {
+	fields := 1
+	if idx.IsComposite() {
+		fields = len(idx.Fields)
+	}
+
+	rows := float64(idx.Count)
+
+	switch {
+	case eq == 0:
+	case eq == fields && idx.Unique:
+		rows = math.Min(1, rows)
+	case idx.Cardinality > 0:
+		rows /= math.Pow(float64(idx.Cardinality), float64(eq)/float64(fields))
+	default:
+		rows *= math.Pow(equalitySelectivity, float64(eq))
+	}
+
+	if hasRange {
+		rows *= rangeSelectivity
+	}
+
+	return rows
+}
+
+// newCombinedNode returns a node selecting the union (OR) or the intersection (AND)
+// of the documents selected by the given nodes.
+// Nodes combined using the same operator are merged.
+func (qo *queryOptimizer) newCombinedNode(op scanner.Token, nodes ...*queryPlanNode) *queryPlanNode {
+	n := queryPlanNode{op: op}
+
+	for _, c := range nodes {
+		if c.field == nil && c.op == op {
+			n.children = append(n.children, c.children...)
+		} else {
+			n.children = append(n.children, c)
+		}
+	}
+
+	for i, c := range n.children {
+		n.readCost += c.readCost
+
+		switch {
+		case i == 0:
+			n.rows = c.rows
+		case op == scanner.OR:
+			n.rows += c.rows
+		case qo.tableSize > 0:
+			// operands are assumed to select documents independently of each other.
+			n.rows *= c.rows / qo.tableSize
+		default:
+			n.rows = math.Min(n.rows, c.rows)
+		}
+	}
+
+	if qo.tableSize > 0 {
+		n.rows = math.Min(n.rows, qo.tableSize)
+	}
+
+	n.cost = n.readCost + n.rows*lookupCost
+	return &n
+}
+
+func cmpOpCanUseIndex(cmp *CmpOp) (bool, FieldSelector, scanner.Token, Expr) {
+	switch cmp.Token {
+	case scanner.EQ, scanner.GT, scanner.GTE, scanner.LT, scanner.LTE:
+	default:
+		return false, nil, 0, nil
+	}
+
+	lf, leftIsField := cmp.LeftHand().(FieldSelector)
+	rf, rightIsField := cmp.RightHand().(FieldSelector)
+
+	// field OP expr
+	if leftIsField && !rightIsField {
+		return true, lf, cmp.Token, cmp.RightHand()
+	}
+
+	// expr OP field, which is the same as field reverse(OP) expr
+	if rightIsField && !leftIsField {
+		op := cmp.Token
+		switch op {
+		case scanner.GT:
+			op = scanner.LT
+		case scanner.GTE:
+			op = scanner.LTE
+		case scanner.LT:
+			op = scanner.GT
+		case scanner.LTE:
+			op = scanner.GTE
+		}
+
+		return true, rf, op, cmp.LeftHand()
+	}
+
+	return false, nil, 0, nil
+}
+
+// arrayLength returns the number of values of v if it is an array, zero otherwise.
+func arrayLength(v document.Value) int {
+	if v.Type != document.ArrayValue {
+		return 0
+	}
+
+	l, err := document.ArrayLength(v.V.(document.Array))
+	if err != nil {
+		return 0
+	}
+
+	return l
+}
+
+// evaluatesToListOfScalarsOrParam returns true if e is a parameter
+// or a list of scalars and parameters.
+func evaluatesToListOfScalarsOrParam(e Expr) bool {
+	switch t := e.(type) {
+	case NamedParam, PositionalParam, SubqueryArray:
+		return true
+	case LiteralExprList:
+		for _, e := range t {
+			if !evaluatesToScalarOrParam(e) {
+				return false
+			}
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func evaluatesToScalarOrParam(e Expr) bool {
+	switch e.(type) {
+	case LiteralValue:
+		return true
+	case NamedParam, PositionalParam, *Subquery:
+		return true
+	}
+
+	return false
+}
+
+// leafIterator reads the documents selected by a single index or by the primary key.
+type leafIterator interface {
+	document.CursorIterator
+
+	// keys returns a cursor reading the key of every selected document.
+	keys() keyCursor
+}
+
+// keyCursor reads the keys of the documents selected by a leaf iterator one at a time.
+// Like a document cursor, it is positioned before the first key and must be closed after use.
+type keyCursor interface {
+	Next() bool
+	// Key returns the current key. It is only valid until the next call to Next.
+	Key() []byte
+	Err() error
+	Close() error
+}
+
+// iterateCursor calls fn with every document read by the cursor, then closes it.
+// If fn returns an error, the iteration stops and returns that error.
+func iterateCursor(c document.Cursor, fn func(d document.Document) error) (err error) {
+	defer func() {
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	for c.Next() {
+		err = fn(c.Document())
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.Err()
+}
+
+func (qo *queryOptimizer) newLeafIterator(f *queryPlanField) leafIterator {
+	if f.composite != nil {
+		return compositeIndexIterator{
+			tx:      qo.tx,
+			tb:      qo.t,
+			args:    qo.args,
+			index:   f.composite,
+			prefix:  f.prefix,
+			op:      f.op,
+			e:       f.e,
+			reverse: f.reverse,
+		}
+	}
+
+	if f.isPrimaryKey {
+		return pkIterator{
+			tx:               qo.tx,
+			tb:               qo.t,
+			cfg:              qo.cfg,
+			args:             qo.args,
+			op:               f.op,
+			e:                f.e,
+			orderByDirection: qo.sortDirection(),
+			evalValue:        f.pkValue,
+			lowerOp:          f.lowerOp,
+			upperOp:          f.upperOp,
+		}
+	}
+
+	return indexIterator{
+		tx:               qo.tx,
+		tb:               qo.t,
+		args:             qo.args,
+		op:               f.op,
+		e:                f.e,
+		index:            qo.indexes[f.indexedField.Name()],
+		orderByDirection: qo.sortDirection(),
+		distinct:         f.distinct,
+		lowerOp:          f.lowerOp,
+		upperOp:          f.upperOp,
+	}
+}
+
+// nodeIterator reads the documents selected by a node combining multiple indexes.
+// The keys selected by every leaf of the node are loaded in memory and combined,
+// then the documents are read from the table in the order of their keys.
+type nodeIterator struct {
+	qo   *queryOptimizer
+	node *queryPlanNode
+}
+
+func (it nodeIterator) Iterate(fn func(d document.Document) error) error {
+	return iterateCursor(it.Cursor(), fn)
+}
+
+func (it nodeIterator) Cursor() document.Cursor {
+	return &nodeCursor{it: it}
+}
+
+// nodeCursor reads the documents selected by a nodeIterator.
+// The keys of the documents are selected by the first call to Next.
+type nodeCursor struct {
+	it      nodeIterator
+	started bool
+	// keys of the documents left to read, in order.
+	keys []string
+	d    document.Document
+	err  error
+}
+
+func (c *nodeCursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if !c.started {
+		c.started = true
+
+		keys, err := c.it.qo.selectKeys(c.it.node)
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		c.keys = make([]string, 0, len(keys))
+		for k := range keys {
+			c.keys = append(c.keys, k)
+		}
+		sort.Strings(c.keys)
+	}
+
+	if len(c.keys) == 0 {
+		return false
+	}
+
+	c.d, c.err = c.it.qo.t.GetDocument([]byte(c.keys[0]))
+	c.keys = c.keys[1:]
+	return c.err == nil
+}
+
+func (c *nodeCursor) Document() document.Document {
+	return c.d
+}
+
+func (c *nodeCursor) Err() error {
+	return c.err
+}
+
+func (c *nodeCursor) Close() error {
+	return nil
+}
+
+// selectKeys returns the set of keys of the documents selected by the node.
+func (qo *queryOptimizer) selectKeys(n *queryPlanNode) (map[string]struct{}, error) {
+	if n.field != nil {
+		keys := make(map[string]struct{})
+		c := qo.newLeafIterator(n.field).keys()
+		for c.Next() {
+			keys[string(c.Key())] = struct{}{}
+		}
+
+		err := c.Err()
+		if cerr := c.Close(); err == nil {
+			err = cerr
+		}
+
+		return keys, err
+	}
+
+	var keys map[string]struct{}
+	for i, c := range n.children {
+		ck, err := qo.selectKeys(c)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case i == 0:
+			keys = ck
+		case n.op == scanner.OR:
+			for k := range ck {
+				keys[k] = struct{}{}
+			}
+		default:
+			for k := range keys {
+				if _, ok := ck[k]; !ok {
+					delete(keys, k)
+				}
+			}
+		}
+	}
+
+	return keys, nil
+}
+
+type indexIterator struct {
+	tx               *database.Transaction
+	tb               *database.Table
+	args             []driver.NamedValue
+	index            index.Index
+	op               scanner.Token
+	e                Expr
+	orderByDirection scanner.Token
+	// if true, only the key of the first document of each value is returned.
+	// It is only used when reading the entire index.
+	distinct bool
+	// comparisons with the bounds of the range, see queryPlanField.
+	lowerOp, upperOp scanner.Token
+}
+
+func (it indexIterator) Iterate(fn func(d document.Document) error) error {
+	return iterateCursor(it.Cursor(), fn)
+}
+
+func (it indexIterator) Cursor() document.Cursor {
+	return &documentCursor{keys: it.keys(), tb: it.tb}
+}
+
+func (it indexIterator) keys() keyCursor {
+	return &indexCursor{
+		iter: it.index.Iterator(&index.IteratorOptions{
+			Reverse: it.e == nil && it.orderByDirection == scanner.DESC,
+		}),
+		selectRanges: it.ranges,
+	}
+}
+
+// ranges returns the ranges of values selected by the iterator, in the order of the index.
+func (it indexIterator) ranges() ([]indexRange, error) {
+	if it.e == nil {
+		match := func(val document.Value) (bool, bool, error) {
+			return true, false, nil
+		}
+		if it.distinct {
+			match = distinctValues()
+		}
+
+		return []indexRange{{match: match}}, nil
+	}
+
+	v, err := it.e.Eval(EvalStack{
+		Tx:     it.tx,
+		Params: it.args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	switch it.op {
+	case scanner.IN:
+		return inRanges(v)
+	case scanner.BETWEEN:
+		r, err := it.betweenRange(v)
+		return []indexRange{r}, err
+	}
+
+	if v.Type.IsNumber() {
+		v, err = v.ConvertTo(document.Float64Value)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if it.op == scanner.EQ {
+		return []indexRange{equalRange(v)}, nil
+	}
+
+	// values lesser than v are read from the first value of their type.
+	pivot := &index.Pivot{Value: v}
+	if it.op == scanner.LT || it.op == scanner.LTE {
+		pivot = index.EmptyPivot(v.Type)
+	}
+
+	return []indexRange{{
+		pivot: pivot,
+		match: func(val document.Value) (bool, bool, error) {
+			switch it.op {
+			case scanner.GT:
+				ok, err := v.IsEqual(val)
+				return !ok, false, err
+			case scanner.LT:
+				stop, err := v.IsLesserThanOrEqual(val)
+				return !stop, stop, err
+			case scanner.LTE:
+				stop, err := v.IsLesserThan(val)
+				return !stop, stop, err
+			case scanner.EQREGEX:
+				// v is the literal prefix of the regular expression,
+				// text and blob values starting with it follow the pivot.
+				ok := (val.Type == document.TextValue || val.Type == document.BlobValue) &&
+					bytes.HasPrefix(val.V.([]byte), v.V.([]byte))
+				return ok, !ok, nil
+			}
+
+			return true, false, nil
+		},
+	}}, nil
+}
+
+// distinctValues returns a function matching the first occurrence of every value of an index.
+func distinctValues() func(val document.Value) (bool, bool, error) {
+	var prev *document.Value
+
+	return func(val document.Value) (bool, bool, error) {
+		if prev != nil && prev.Type == val.Type {
+			ok, err := prev.IsEqual(val)
+			if err != nil || ok {
+				return false, false, err
+			}
+		}
+
+		// the value is only valid until the iterator moves.
+		if b, ok := val.V.([]byte); ok {
+			val.V = append([]byte(nil), b...)
+		}
+		prev = &val
+		return true, false, nil
+	}
+}
+
+// equalRange returns the range of the values equal to v.
+func equalRange(v document.Value) indexRange {
+	return indexRange{
+		pivot: &index.Pivot{Value: v},
+		match: func(val document.Value) (bool, bool, error) {
+			ok, err := v.IsEqual(val)
+			return ok, !ok, err
+		},
+	}
+}
+
+// inRanges returns the ranges of the values of the list, in the order of the index.
+// Duplicate values are only looked up once.
+func inRanges(list document.Value) ([]indexRange, error) {
+	if list.Type != document.ArrayValue {
+		return nil, nil
+	}
+
+	type entry struct {
+		v   document.Value
+		enc []byte
+	}
+
+	var entries []entry
+	err := list.V.(document.Array).Iterate(func(i int, v document.Value) error {
+		v, err := evalIndexedValue(LiteralValue(v), EvalStack{})
+		if err != nil {
+			return err
+		}
+
+		enc, err := index.EncodeFieldToIndexValue(v)
+		if err != nil {
+			return err
+		}
+
+		enc = append([]byte{byte(index.NewTypeFromValueType(v.Type))}, enc...)
+		entries = append(entries, entry{v, enc})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return bytes.Compare(entries[i].enc, entries[j].enc) < 0
+	})
+
+	ranges := make([]indexRange, 0, len(entries))
+	for i, e := range entries {
+		if i > 0 && bytes.Equal(e.enc, entries[i-1].enc) {
+			continue
+		}
+
+		ranges = append(ranges, equalRange(e.v))
+	}
+
+	return ranges, nil
+}
+
+// betweenRange returns the range of the values from the lower bound to the upper bound,
+// which are included unless the range is compared with them otherwise.
+// bounds is an array containing the two bounds.
+func (it indexIterator) betweenRange(bounds document.Value) (indexRange, error) {
+	a, b, err := betweenBounds(bounds)
+	if err != nil {
+		return indexRange{}, err
+	}
+
+	lowerOp, upperOp := rangeOperators(it.lowerOp, it.upperOp)
+	return indexRange{
+		pivot: &index.Pivot{Value: a},
+		match: func(val document.Value) (bool, bool, error) {
+			ok, err := compareIndexedValue(upperOp, val, b)
+			if err != nil || !ok {
+				return false, true, err
+			}
+
+			if lowerOp == scanner.GT {
+				ok, err = a.IsEqual(val)
+				return !ok, false, err
+			}
+
+			return true, false, nil
+		},
+	}, nil
+}
+
+// rangeOperators returns the comparisons with the bounds of a range,
+// which default to the ones of the BETWEEN operator.
+func rangeOperators(lowerOp, upperOp scanner.Token) (scanner.Token, scanner.Token) {
+	if lowerOp == 0 {
+		return scanner.GTE, scanner.LTE
+	}
+
+	return lowerOp, upperOp
+}
+
+// betweenBounds returns the two values of the array, with numbers converted to double.
+func betweenBounds(bounds document.Value) (document.Value, document.Value, error) {
+	arr := bounds.V.(document.Array)
+
+	a, err := arr.GetByIndex(0)
+	if err != nil {
+		return a, a, err
+	}
+	b, err := arr.GetByIndex(1)
+	if err != nil {
+		return a, b, err
+	}
+
+	a, err = evalIndexedValue(LiteralValue(a), EvalStack{})
+	if err != nil {
+		return a, b, err
+	}
+	b, err = evalIndexedValue(LiteralValue(b), EvalStack{})
+	return a, b, err
+}
+
+// indexRange is a range of values read by an indexCursor.
+type indexRange struct {
+	// pivot from which the index is read, see index.Iterator.
+	pivot *index.Pivot
+	// match returns whether the value belongs to the range. If it doesn't,
+	// stop is true if the values following it don't belong to the range either.
+	match func(val document.Value) (ok, stop bool, err error)
+}
+
+// indexCursor reads the keys associated with the values of an index which belong to
+// a list of ranges, one range after the other. The ranges are selected by the first call to Next.
+type indexCursor struct {
+	iter         index.Iterator
+	selectRanges func() ([]indexRange, error)
+	// ranges left to read, starting with the current one.
+	ranges []indexRange
+	// seeked is true once the iterator is positioned in the current range.
+	seeked bool
+	err    error
+}
+
+func (c *indexCursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if c.selectRanges != nil {
+		c.ranges, c.err = c.selectRanges()
+		c.selectRanges = nil
+		if c.err != nil {
+			return false
+		}
+	} else if c.seeked {
+		c.iter.Next()
+	}
+
+	for len(c.ranges) > 0 {
+		r := c.ranges[0]
+		if !c.seeked {
+			c.iter.Seek(r.pivot)
+			c.seeked = true
+		}
+
+		for ; c.iter.Valid(); c.iter.Next() {
+			ok, stop, err := r.match(c.iter.Value())
+			if err != nil {
+				c.err = err
+				return false
+			}
+			if ok {
+				return true
+			}
+			if stop {
+				break
+			}
+		}
+
+		c.err = c.iter.Err()
+		if c.err != nil {
+			return false
+		}
+
+		c.ranges = c.ranges[1:]
+		c.seeked = false
+	}
+
+	return false
+}
+
+func (c *indexCursor) Key() []byte {
+	return c.iter.Key()
+}
+
+func (c *indexCursor) Err() error {
+	return c.err
+}
 
-	return
+func (c *indexCursor) Close() error {
+	return c.iter.Close()
 }
 
-type sortedIterator struct {
-	h heap.Interface
-	k int
+// documentCursor reads the documents of a table whose keys are read by a key cursor.
+type documentCursor struct {
+	keys keyCursor
+	tb   *database.Table
+	d    document.Document
+	err  error
 }
 
-func (s *sortedIterator) Iterate(fn func(d document.Document) error) error {
-	i := 0
-	for s.h.Len() > 0 && (s.k == 0 || i < s.k) {
-		err := fn(encoding.EncodedDocument(heap.Pop(s.h).(heapNode).data))
+func (c *documentCursor) Next() bool {
+	if c.err != nil || !c.keys.Next() {
+		return false
+	}
+
+	c.d, c.err = c.tb.GetDocument(c.keys.Key())
+	return c.err == nil
+}
+
+func (c *documentCursor) Document() document.Document {
+	return c.d
+}
+
+func (c *documentCursor) Err() error {
+	if c.err != nil {
+		return c.err
+	}
+
+	return c.keys.Err()
+}
+
+func (c *documentCursor) Close() error {
+	return c.keys.Close()
+}
+
+// compositeIndexIterator reads the documents whose first indexed fields are equal to the values
+// of the prefix, and whose next field, if e is not nil, matches the comparison.
+// The selected documents are contiguous in the index: the iterator reads the index
+// from the first document starting with the prefix and stops as soon as the documents
+// don't match anymore.
+type compositeIndexIterator struct {
+	tx      *database.Transaction
+	tb      *database.Table
+	args    []driver.NamedValue
+	index   *database.Index
+	prefix  []Expr
+	op      scanner.Token
+	e       Expr
+	reverse bool
+}
+
+func (it compositeIndexIterator) Iterate(fn func(d document.Document) error) error {
+	return iterateCursor(it.Cursor(), fn)
+}
+
+func (it compositeIndexIterator) Cursor() document.Cursor {
+	return &documentCursor{keys: it.keys(), tb: it.tb}
+}
+
+func (it compositeIndexIterator) keys() keyCursor {
+	return &indexCursor{
+		iter:         it.index.Iterator(&index.IteratorOptions{Reverse: it.reverse}),
+		selectRanges: it.ranges,
+	}
+}
+
+// ranges returns the range of the values starting with the prefix and matching the comparison.
+func (it compositeIndexIterator) ranges() ([]indexRange, error) {
+	stack := EvalStack{
+		Tx:     it.tx,
+		Params: it.args,
+	}
+
+	prefix := make(document.ValueBuffer, 0, len(it.prefix)+1)
+	for _, e := range it.prefix {
+		v, err := evalIndexedValue(e, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		prefix = prefix.Append(v)
+	}
+	pivot := index.Pivot{Value: document.NewArrayValue(prefix)}
+
+	var v document.Value
+	if it.e != nil {
+		var err error
+		v, err = evalIndexedValue(it.e, stack)
+		if err != nil {
+			return nil, err
+		}
+
+		// in the order of the index, values greater than v follow v, unless
+		// the field is sorted in descending order.
+		after := it.op == scanner.GT || it.op == scanner.GTE
+		if it.index.Fields[len(prefix)].Desc {
+			after = !after
+		}
+
+		// documents following v can be looked up directly.
+		if after && !it.reverse {
+			pivot.Value = document.NewArrayValue(prefix.Append(v))
+		}
+	}
+
+	var started bool
+	match := func(val document.Value) (bool, bool, error) {
+		values := val.V.(document.ValueBuffer)
+
+		for i, pv := range prefix {
+			ok, err := pv.IsEqual(values[i])
+			if err != nil || !ok {
+				return false, true, err
+			}
+		}
+
+		if it.e != nil {
+			ok, err := compareIndexedValue(it.op, values[len(prefix)], v)
+			if err != nil {
+				return false, true, err
+			}
+
+			// values matching the comparison are contiguous, skip the ones
+			// preceding them and stop after the last one.
+			if !ok {
+				return false, started, nil
+			}
+		}
+
+		started = true
+		return true, false, nil
+	}
+
+	return []indexRange{{pivot: &pivot, match: match}}, nil
+}
+
+// evalIndexedValue evaluates e and converts numbers to double, which is how they are indexed.
+func evalIndexedValue(e Expr, stack EvalStack) (document.Value, error) {
+	v, err := e.Eval(stack)
+	if err != nil {
+		return v, err
+	}
+
+	if v.Type.IsNumber() {
+		return v.ConvertTo(document.Float64Value)
+	}
+
+	return v, nil
+}
+
+// compareIndexedValue returns true if indexed op v.
+// Values of different types are never matched.
+func compareIndexedValue(op scanner.Token, indexed, v document.Value) (bool, error) {
+	if index.NewTypeFromValueType(indexed.Type) != index.NewTypeFromValueType(v.Type) {
+		return false, nil
+	}
+
+	switch op {
+	case scanner.GT:
+		return indexed.IsGreaterThan(v)
+	case scanner.GTE:
+		return indexed.IsGreaterThanOrEqual(v)
+	case scanner.LT:
+		return indexed.IsLesserThan(v)
+	case scanner.LTE:
+		return indexed.IsLesserThanOrEqual(v)
+	}
+
+	return indexed.IsEqual(v)
+}
+
+type pkIterator struct {
+	tx               *database.Transaction
+	tb               *database.Table
+	cfg              *database.TableConfig
+	args             []driver.NamedValue
+	op               scanner.Token
+	e                Expr
+	orderByDirection scanner.Token
+	evalValue        document.Value
+	// comparisons with the bounds of the range, see queryPlanField.
+	lowerOp, upperOp scanner.Token
+}
+
+func (it pkIterator) Iterate(fn func(d document.Document) error) error {
+	return iterateCursor(it.Cursor(), fn)
+}
+
+func (it pkIterator) Cursor() document.Cursor {
+	return &pkCursor{it: it}
+}
+
+func (it pkIterator) keys() keyCursor {
+	return &pkCursor{it: it}
+}
+
+// open positions the cursor on the documents selected by the iterator.
+func (it pkIterator) open(c *pkCursor) error {
+	if it.e == nil {
+		c.scan(nil, nil, it.orderByDirection == scanner.DESC)
+		return nil
+	}
+
+	switch it.op {
+	case scanner.IN:
+		var err error
+		c.lookups, err = it.inKeys()
+		return err
+	case scanner.BETWEEN:
+		lower, upper, err := it.betweenKeys()
 		if err != nil {
 			return err
 		}
-		i++
+
+		c.scan(lower, upper, false)
+		return nil
+	}
+
+	data, err := encoding.EncodeValue(it.evalValue)
+	if err != nil {
+		return err
+	}
+
+	switch it.op {
+	case scanner.EQ:
+		c.lookups = [][]byte{data}
+	case scanner.GT:
+		c.scan(keySuccessor(data), nil, false)
+	case scanner.GTE:
+		c.scan(data, nil, false)
+	case scanner.LT:
+		c.scan(nil, data, false)
+	case scanner.LTE:
+		c.scan(nil, keySuccessor(data), false)
 	}
 
 	return nil
 }
 
-type heapNode struct {
-	value []byte
-	data  []byte
+// inKeys returns the keys of the documents whose primary key is one of the values of the list, in order.
+// Duplicate keys are only returned once.
+func (it pkIterator) inKeys() ([][]byte, error) {
+	var keys [][]byte
+	err := it.evalValue.V.(document.Array).Iterate(func(i int, v document.Value) error {
+		data, err := encoding.EncodeValue(v)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, data)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+
+	unique := keys[:0]
+	for i, k := range keys {
+		if i > 0 && bytes.Equal(k, keys[i-1]) {
+			continue
+		}
+
+		unique = append(unique, k)
+	}
+
+	return unique, nil
+}
+
+// betweenKeys returns the range of the keys of the documents whose primary key is between the bounds,
+// which are included unless the range is compared with them otherwise.
+// The lower key is included and the upper one excluded.
+func (it pkIterator) betweenKeys() ([]byte, []byte, error) {
+	bounds := it.evalValue.V.(document.Array)
+
+	var enc [2][]byte
+	for i := range enc {
+		v, err := bounds.GetByIndex(i)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		enc[i], err = encoding.EncodeValue(v)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	lowerOp, upperOp := rangeOperators(it.lowerOp, it.upperOp)
+	if lowerOp == scanner.GT {
+		enc[0] = keySuccessor(enc[0])
+	}
+	if upperOp == scanner.LTE {
+		enc[1] = keySuccessor(enc[1])
+	}
+
+	return enc[0], enc[1], nil
+}
+
+// pkCursor reads the documents selected by a pkIterator, either by looking up their keys
+// or by reading a range of the table store. It is positioned by the first call to Next.
+type pkCursor struct {
+	it      pkIterator
+	started bool
+	// keys of the documents left to look up, if the documents are not read from a range.
+	lookups [][]byte
+	// iterator reading the range of the store, and its lower bound in decreasing order.
+	iter    engine.Iterator
+	lower   []byte
+	reverse bool
+	done    bool
+	key     []byte
+	val     []byte
+	err     error
+}
+
+// scan reads the key value pairs of the store whose keys are greater than or equal to the lower bound
+// and less than the upper bound, in increasing order, or in decreasing order if reverse is true.
+// A nil bound means the range is unbounded on that side.
+func (c *pkCursor) scan(lower, upper []byte, reverse bool) {
+	c.iter = c.it.tb.Store.Iterator(&engine.IteratorOptions{UpperBound: upper})
+	c.lower = lower
+	c.reverse = reverse
+
+	if reverse {
+		c.iter.SeekReverse(nil)
+	} else {
+		c.iter.Seek(lower)
+	}
+}
+
+func (c *pkCursor) Next() bool {
+	if c.done || c.err != nil {
+		return false
+	}
+
+	if !c.started {
+		c.started = true
+
+		c.err = c.it.open(c)
+		if c.err != nil {
+			return false
+		}
+	} else if c.iter != nil {
+		if c.reverse {
+			c.iter.Prev()
+		} else {
+			c.iter.Next()
+		}
+	}
+
+	if c.iter != nil {
+		if !c.iter.Valid() || c.reverse && c.lower != nil && bytes.Compare(c.iter.Key(), c.lower) < 0 {
+			c.done = true
+			return false
+		}
+
+		c.key = c.iter.Key()
+		c.val, c.err = c.iter.Value()
+		return c.err == nil
+	}
+
+	for len(c.lookups) > 0 {
+		k := c.lookups[0]
+		c.lookups = c.lookups[1:]
+
+		val, err := c.it.tb.Store.Get(k)
+		if err == engine.ErrKeyNotFound {
+			continue
+		}
+		if err != nil {
+			c.err = err
+			return false
+		}
+
+		c.key, c.val = k, val
+		return true
+	}
+
+	return false
+}
+
+func (c *pkCursor) Document() document.Document {
+	return encoding.EncodedDocument(c.val)
+}
+
+func (c *pkCursor) Key() []byte {
+	return c.key
+}
+
+func (c *pkCursor) Err() error {
+	if c.err != nil || c.iter == nil {
+		return c.err
+	}
+
+	return c.iter.Err()
+}
+
+func (c *pkCursor) Close() error {
+	if c.iter == nil {
+		return nil
+	}
+
+	return c.iter.Close()
+}
+
+// keySuccessor returns the smallest key greater than k.
+func keySuccessor(k []byte) []byte {
+	return append(k[:len(k):len(k)], 0)
+}
+
+// sortIterator returns a stream which sorts the documents of the iterator according to the ordering terms.
+// Every document is associated with a key encoding the values of the ordering terms,
+// so that sorting the keys in ascending order sorts the documents as requested by the terms.
+// Documents with equal keys are returned in the order in which they were read.
+// The ordering terms are evaluated against the documents of the iterator. If project is not nil,
+// the documents are then projected, and the projected documents are returned by the stream.
+// If the query has a limit, only the limit + offset first documents are kept, using a heap.
+// Otherwise, the documents are sorted in memory, or using temporary files if they
+// exceed the memory limit of the database. See the sorter type.
+// The documents are read and sorted when the stream is iterated.
+func (qo *queryOptimizer) sortIterator(it document.Iterator, project func(d document.Document) (document.Document, error)) (st document.Stream, err error) {
+	k := 0
+	if qo.limit != -1 && !qo.distinct {
+		k += qo.limit
+		if qo.offset != -1 {
+			k += qo.offset
+		}
+
+		// no documents are returned.
+		if k == 0 {
+			return document.NewStream(document.NewIterator()), nil
+		}
+	}
+
+	return document.NewStream(&sortedIterator{
+		it:      it,
+		project: project,
+		terms:   qo.orderBy,
+		stack: EvalStack{
+			Tx:     qo.tx,
+			Params: qo.args,
+			Cfg:    qo.cfg,
+		},
+		cfg: newSortConfig(qo.tx),
+		k:   k,
+	}), nil
+}
+
+// newSortConfig returns the configuration used to sort or deduplicate the documents
+// read by the transaction.
+func newSortConfig(tx *database.Transaction) sortConfig {
+	cfg := sortConfig{ctx: tx.Context()}
+	if db := tx.DB(); db != nil {
+		cfg.SortOptions = db.Sort
+	}
+
+	return cfg
+}
+
+type sortedIterator struct {
+	it      document.Iterator
+	project func(d document.Document) (document.Document, error)
+	terms   []OrderingTerm
+	stack   EvalStack
+	cfg     sortConfig
+	k       int
+}
+
+func (s *sortedIterator) Iterate(fn func(d document.Document) error) error {
+	return iterateCursor(s.Cursor(), fn)
+}
+
+func (s *sortedIterator) Cursor() document.Cursor {
+	return &sortedCursor{s: s}
+}
+
+// sort adds the documents of the underlying iterator to the sorter.
+func (s *sortedIterator) sort(srt *sorter) error {
+	stack := s.stack
+	return s.it.Iterate(func(d document.Document) error {
+		stack.Document = d
+
+		var value []byte
+		for _, t := range s.terms {
+			v, err := t.Expr.Eval(stack)
+			if err != nil && err != document.ErrFieldNotFound {
+				return err
+			}
+			if err == document.ErrFieldNotFound {
+				v = document.NewNullValue()
+			}
+
+			value, err = appendOrderingKey(value, v, t)
+			if err != nil {
+				return err
+			}
+		}
+
+		if s.project != nil {
+			var err error
+			d, err = s.project(d)
+			if err != nil {
+				return err
+			}
+		}
+
+		data, err := encoding.EncodeDocument(d)
+		if err != nil {
+			return err
+		}
+
+		e := sortEntry{
+			value: value,
+			data:  append([]byte(nil), data...),
+		}
+		if k, ok := d.(document.Keyer); ok {
+			e.key = append([]byte(nil), k.Key()...)
+		}
+
+		return srt.add(e)
+	})
+}
+
+// sortedCursor reads the documents sorted by a sortedIterator.
+// The documents are read and sorted by the first call to Next.
+type sortedCursor struct {
+	s       *sortedIterator
+	srt     *sorter
+	entries *sortCursor
+	d       document.Document
+	err     error
 }
 
-type minHeap []heapNode
+func (c *sortedCursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if c.srt == nil {
+		c.srt = newSorter(c.s.cfg, c.s.k)
+		c.err = c.s.sort(c.srt)
+		if c.err != nil {
+			return false
+		}
+
+		c.entries = c.srt.cursor()
+	}
+
+	if !c.entries.next() {
+		c.err = c.entries.err
+		return false
+	}
 
-func (h minHeap) Len() int           { return len(h) }
-func (h minHeap) Less(i, j int) bool { return bytes.Compare(h[i].value, h[j].value) < 0 }
-func (h minHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+	e := c.entries.cur
+	if e.key != nil {
+		c.d = &encodedDocumentWithKey{EncodedDocument: e.data, key: e.key}
+	} else {
+		c.d = encoding.EncodedDocument(e.data)
+	}
 
-func (h *minHeap) Push(x interface{}) {
-	*h = append(*h, x.(heapNode))
+	return true
 }
 
-func (h *minHeap) Pop() interface{} {
-	old := *h
-	n := len(old)
-	x := old[n-1]
-	*h = old[0 : n-1]
-	return x
+func (c *sortedCursor) Document() document.Document {
+	return c.d
 }
 
-type maxHeap struct {
-	minHeap
+func (c *sortedCursor) Err() error {
+	return c.err
 }
 
-func (h maxHeap) Less(i, j int) bool {
-	return bytes.Compare(h.minHeap[i].value, h.minHeap[j].value) > 0
+func (c *sortedCursor) Close() error {
+	if c.srt == nil {
+		return nil
+	}
+
+	if c.entries != nil {
+		c.entries.close()
+	}
+
+	return c.srt.close()
 }
@@ -0,0 +1,249 @@
+package query_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQueryPlanIndexSelection(t *testing.T) {
+	tests := []struct {
+		where    string
+		scan     string
+		expected string
+	}{
+		{"c = 10", `{"type":"index","index":"idx_c","path":"c","operator":"="}`, `[10]`},
+		{"10 < c AND c < 13", `{"type":"index","index":"idx_c","path":"c","operator":"> AND <"}`, `[11, 12]`},
+		{"c <= 12 AND b = 0 AND c >= 10", `{"type":"index","index":"idx_c","path":"c","operator":">= AND <="}`, `[10, 12]`},
+		{"c > 10 AND c < 'a'", `{"type":"index","index":"idx_c","path":"c","operator":"> AND <"}`, `[]`},
+		{"c = 1 AND c > 0", `{"type":"index","index":"idx_c","path":"c","operator":"="}`, `[1]`},
+		{"a > 95 AND 97 >= a", `{"type":"primary key","path":"a","operator":"> AND <="}`, `[96, 97]`},
+		{"a >= 3 AND a < 5", `{"type":"primary key","path":"a","operator":">= AND <"}`, `[3, 4]`},
+		{"a < 3", `{"type":"primary key","path":"a","operator":"<"}`, `[0, 1, 2]`},
+		{"97 < a", `{"type":"primary key","path":"a","operator":">"}`, `[98, 99]`},
+		{"a <= 2", `{"type":"primary key","path":"a","operator":"<="}`, `[0, 1, 2]`},
+		{"a >= 98", `{"type":"primary key","path":"a","operator":">="}`, `[98, 99]`},
+		{"b = 1", `{"type":"table"}`, ``},
+		{"b = 1 AND c = 11", `{"type":"index","index":"idx_c","path":"c","operator":"="}`, `[11]`},
+		{"b = 1 AND d = 11", `{"type":"index","index":"idx_d","path":"d","operator":"="}`, `[11, 61]`},
+		{"b > 0 AND d > 47", `{"type":"intersection","scans":[
+			{"type":"index","index":"idx_b","path":"b","operator":">"},
+			{"type":"index","index":"idx_d","path":"d","operator":">"}
+		]}`, `[49, 99]`},
+		{"c = 1 OR d = 5", `{"type":"union","scans":[
+			{"type":"index","index":"idx_c","path":"c","operator":"="},
+			{"type":"index","index":"idx_d","path":"d","operator":"="}
+		]}`, `[1, 5, 55]`},
+		{"a = 3 OR c = 2 OR c = 1", `{"type":"union","scans":[
+			{"type":"primary key","path":"a","operator":"="},
+			{"type":"index","index":"idx_c","path":"c","operator":"="},
+			{"type":"index","index":"idx_c","path":"c","operator":"="}
+		]}`, `[1, 2, 3]`},
+		{"c = 1 OR c = 2 AND d = 2", `{"type":"union","scans":[
+			{"type":"index","index":"idx_c","path":"c","operator":"="},
+			{"type":"index","index":"idx_c","path":"c","operator":"="}
+		]}`, `[1, 2]`},
+		{"c IN (12, 3, 3.0, 200)", `{"type":"index","index":"idx_c","path":"c","operator":"IN"}`, `[3, 12]`},
+		{"d IN (1, 2)", `{"type":"index","index":"idx_d","path":"d","operator":"IN"}`, `[1, 51, 2, 52]`},
+		{"a IN (5, 1)", `{"type":"primary key","path":"a","operator":"IN"}`, `[1, 5]`},
+		{"b IN (0, 1)", `{"type":"table"}`, ``},
+		{"c BETWEEN 10 AND 12.5", `{"type":"index","index":"idx_c","path":"c","operator":"BETWEEN"}`, `[10, 11, 12]`},
+		{"a BETWEEN 96 AND 200", `{"type":"primary key","path":"a","operator":"BETWEEN"}`, `[96, 97, 98, 99]`},
+		{"a BETWEEN 3 AND 5", `{"type":"primary key","path":"a","operator":"BETWEEN"}`, `[3, 4, 5]`},
+		{"c BETWEEN 10 AND 'a'", `{"type":"index","index":"idx_c","path":"c","operator":"BETWEEN"}`, `[]`},
+		{"c NOT IN (1, 2)", `{"type":"table"}`, ``},
+		{"c = 1 OR e = 5", `{"type":"table"}`, `[1]`},
+		{"b = 0 OR b = 1", `{"type":"table"}`, ``},
+	}
+
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE test (a INTEGER PRIMARY KEY);
+		CREATE INDEX idx_b ON test (b);
+		CREATE UNIQUE INDEX idx_c ON test (c);
+		CREATE INDEX idx_d ON test (d);
+	`)
+	require.NoError(t, err)
+
+	for i := 0; i < 100; i++ {
+		err = db.Exec("INSERT INTO test (a, b, c, d) VALUES (?, ?, ?, ?)", i, i%2, i, i%50)
+		require.NoError(t, err)
+	}
+
+	// compute the cardinality of the non unique indexes
+	err = db.Update(func(tx *genji.Tx) error {
+		return tx.ReIndexAll()
+	})
+	require.NoError(t, err)
+
+	for _, test := range tests {
+		t.Run(test.where, func(t *testing.T) {
+			d, err := db.QueryDocument("EXPLAIN SELECT a FROM test WHERE " + test.where)
+			require.NoError(t, err)
+			v, err := d.GetByField("scan")
+			require.NoError(t, err)
+			data, err := v.MarshalJSON()
+			require.NoError(t, err)
+			require.JSONEq(t, test.scan, string(data))
+
+			if test.expected == "" {
+				return
+			}
+
+			require.JSONEq(t, test.expected, selectField(t, db, "SELECT a FROM test WHERE "+test.where, "a"))
+		})
+	}
+}
+
+func TestQueryPlanCompositeIndex(t *testing.T) {
+	tests := []struct {
+		query    string
+		scan     string
+		sort     string
+		expected string
+	}{
+		{"SELECT id FROM test WHERE tenant = 2 AND created_at = 3",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant, created_at","operator":"="}`, ``, `[23]`},
+		{"SELECT id FROM test WHERE created_at > 6 AND tenant = 2",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant, created_at","operator":">"}`, ``, `[29, 28, 27]`},
+		{"SELECT id FROM test WHERE tenant = 2 AND created_at <= 1",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant, created_at","operator":"<="}`, ``, `[21, 20]`},
+		{"SELECT id FROM test WHERE tenant = 1",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant","operator":"="}`, ``, `[19, 18, 17, 16, 15, 14, 13, 12, 11, 10]`},
+		{"SELECT id FROM test WHERE tenant = 1 AND created_at > 5 ORDER BY created_at LIMIT 2",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant, created_at","operator":">"}`,
+			`{"type":"index","terms":[{"expr":"created_at","direction":"ASC","nulls":"FIRST"}]}`, `[16, 17]`},
+		{"SELECT id FROM test WHERE tenant = 1 AND created_at < 5 ORDER BY created_at DESC",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant, created_at","operator":"<"}`,
+			`{"type":"index","terms":[{"expr":"created_at","direction":"DESC","nulls":"LAST"}]}`, `[14, 13, 12, 11, 10]`},
+		{"SELECT id FROM test WHERE tenant = 3 ORDER BY created_at",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant","operator":"="}`,
+			`{"type":"index","terms":[{"expr":"created_at","direction":"ASC","nulls":"FIRST"}]}`, `[30, 31, 32, 33, 34, 35, 36, 37, 38, 39]`},
+		{"SELECT id FROM test ORDER BY tenant DESC LIMIT 3",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant"}`,
+			`{"type":"index","terms":[{"expr":"tenant","direction":"DESC","nulls":"LAST"}]}`, `[30, 31, 32]`},
+		{"SELECT id FROM test WHERE tenant = 1 ORDER BY id LIMIT 1",
+			`{"type":"index","index":"idx_tenant_created","path":"tenant","operator":"="}`,
+			`{"type":"heap","terms":[{"expr":"id","direction":"ASC","nulls":"FIRST"}],"limit":1}`, `[10]`},
+		{"SELECT id FROM test WHERE created_at = 3",
+			`{"type":"table"}`, ``, `[3, 13, 23, 33]`},
+		{"SELECT id FROM test WHERE tenant = 2 AND created_at = 3 AND id = 23",
+			`{"type":"primary key","path":"id","operator":"="}`, ``, `[23]`},
+	}
+
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE test (id INTEGER PRIMARY KEY);
+		CREATE UNIQUE INDEX idx_tenant_created ON test (tenant, created_at DESC);
+	`)
+	require.NoError(t, err)
+
+	for i := 0; i < 40; i++ {
+		err = db.Exec("INSERT INTO test (id, tenant, created_at) VALUES (?, ?, ?)", i, i/10, i%10)
+		require.NoError(t, err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			d, err := db.QueryDocument("EXPLAIN " + test.query)
+			require.NoError(t, err)
+			v, err := d.GetByField("scan")
+			require.NoError(t, err)
+			data, err := v.MarshalJSON()
+			require.NoError(t, err)
+			require.JSONEq(t, test.scan, string(data))
+
+			v, err = d.GetByField("sort")
+			if test.sort == "" {
+				require.Equal(t, document.ErrFieldNotFound, err)
+			} else {
+				require.NoError(t, err)
+				data, err = v.MarshalJSON()
+				require.NoError(t, err)
+				require.JSONEq(t, test.sort, string(data))
+			}
+
+			require.JSONEq(t, test.expected, selectField(t, db, test.query, "id"))
+		})
+	}
+}
+
+func TestQueryPlanRegex(t *testing.T) {
+	tests := []struct {
+		where    string
+		scan     string
+		expected string
+	}{
+		{"name =~ /^payments\\./", `{"type":"index","index":"idx_name","path":"name","operator":"=~"}`, `[1, 2]`},
+		{"name =~ /^pay.*s$/", `{"type":"index","index":"idx_name","path":"name","operator":"=~"}`, `[3]`},
+		{"name =~ /^a/", `{"type":"index","index":"idx_name","path":"name","operator":"=~"}`, `[0, 4]`},
+		{"name =~ /payments/", `{"type":"table"}`, `[1, 2]`},
+		{"name =~ /(?i)^PAY/", `{"type":"table"}`, `[1, 2, 3]`},
+		{"name =~ /^a|^b/", `{"type":"table"}`, `[0, 4]`},
+		{"name !~ /^a/", `{"type":"table"}`, `[1, 2, 3, 5]`},
+	}
+
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE test (id INTEGER PRIMARY KEY);
+		CREATE INDEX idx_name ON test (name);
+	`)
+	require.NoError(t, err)
+
+	names := []interface{}{"accounts", "payments.in", "payments.out", "paypals", []byte("auth"), 10}
+	for i, name := range names {
+		err = db.Exec("INSERT INTO test (id, name) VALUES (?, ?)", i, name)
+		require.NoError(t, err)
+	}
+
+	for _, test := range tests {
+		t.Run(test.where, func(t *testing.T) {
+			d, err := db.QueryDocument("EXPLAIN SELECT id FROM test WHERE " + test.where)
+			require.NoError(t, err)
+			v, err := d.GetByField("scan")
+			require.NoError(t, err)
+			data, err := v.MarshalJSON()
+			require.NoError(t, err)
+			require.JSONEq(t, test.scan, string(data))
+
+			require.JSONEq(t, test.expected, selectField(t, db, "SELECT id FROM test WHERE "+test.where, "id"))
+		})
+	}
+}
+
+// selectField runs the query and returns the values of the given field
+// of every document, encoded as a JSON array.
+func selectField(t *testing.T, db *genji.DB, q string, field string) string {
+	st, err := db.Query(q)
+	require.NoError(t, err)
+	defer st.Close()
+
+	var values document.ValueBuffer
+	err = st.Iterate(func(d document.Document) error {
+		v, err := d.GetByField(field)
+		if err != nil {
+			return err
+		}
+		values = values.Append(v)
+		return nil
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = document.ArrayToJSON(&buf, values)
+	require.NoError(t, err)
+
+	return buf.String()
+}
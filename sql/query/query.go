@@ -1,6 +1,7 @@
 package query
 
 import (
+	"context"
 	"database/sql/driver"
 	"errors"
 
@@ -19,7 +20,66 @@ type Query struct {
 }
 
 // Run executes all the statements in their own transaction and returns the last result.
-func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, error) {
+// If a transaction is started by a BEGIN statement, the following statements are executed
+// within that transaction until it is committed or rolled back by a COMMIT or ROLLBACK statement.
+// If it is still in progress once all the statements were executed, it is rolled back and an error
+// is returned. To keep a transaction across multiple queries, use a Session.
+// The transactions started by Run are bound to ctx: once it is canceled or its deadline is exceeded,
+// the statements and the returned stream fail with the context error.
+func (q Query) Run(ctx context.Context, db *database.Database, args []driver.NamedValue) (*Result, error) {
+	s := NewSession(db)
+
+	res, err := s.Run(ctx, q, args)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.tx != nil {
+		res.Close()
+		s.Close()
+		return nil, errors.New("the transaction started with BEGIN must be committed or rolled back")
+	}
+
+	return res, nil
+}
+
+// A Session runs queries against a database. It keeps the transaction started by a BEGIN statement
+// across queries, until it is committed or rolled back by a COMMIT or ROLLBACK statement.
+// A Session must not be used by multiple goroutines concurrently.
+type Session struct {
+	db *database.Database
+	tx *database.Transaction
+}
+
+// NewSession creates a session running queries against db.
+func NewSession(db *database.Database) *Session {
+	return &Session{db: db}
+}
+
+// Tx returns the transaction started by a BEGIN statement, or nil if there is none in progress.
+func (s *Session) Tx() *database.Transaction {
+	return s.tx
+}
+
+// Close rolls back the transaction started by a BEGIN statement, if any.
+func (s *Session) Close() error {
+	if s.tx == nil {
+		return nil
+	}
+
+	err := s.tx.Rollback()
+	s.tx = nil
+	return err
+}
+
+// Run executes all the statements in their own transaction and returns the last result.
+// If a transaction was started by a BEGIN statement, in this query or in a previous one,
+// the statements are executed within that transaction until it is committed or rolled back
+// by a COMMIT or ROLLBACK statement. If one of these statements fails, the transaction is rolled back.
+// The transactions started by Run, including the one started by a BEGIN statement, are bound to ctx:
+// once it is canceled or its deadline is exceeded, the statements and the returned stream fail
+// with the context error.
+func (s *Session) Run(ctx context.Context, q Query, args []driver.NamedValue) (*Result, error) {
 	var res Result
 	var tx *database.Transaction
 	var err error
@@ -39,10 +99,67 @@ func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, er
 					return nil, err
 				}
 			}
+			tx = nil
+		}
+
+		res = Result{}
+
+		switch t := stmt.(type) {
+		case BeginStmt:
+			if s.tx != nil {
+				return nil, ErrTransactionInProgress
+			}
+
+			s.tx, err = s.db.BeginTx(ctx, t.Writable)
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case CommitStmt:
+			if s.tx == nil {
+				return nil, ErrNoTransaction
+			}
+
+			// read-only transactions are rolled back, there is nothing to commit.
+			if s.tx.Writable() {
+				err = s.tx.Commit()
+			} else {
+				err = s.tx.Rollback()
+			}
+			s.tx = nil
+			if err != nil {
+				return nil, err
+			}
+			continue
+		case RollbackStmt:
+			if s.tx == nil {
+				return nil, ErrNoTransaction
+			}
+
+			err = s.Close()
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
+
+		// run the statement within the transaction of the session
+		if s.tx != nil {
+			if !s.tx.Writable() && !stmt.IsReadOnly() {
+				s.Close()
+				return nil, errors.New("cannot write within a read-only transaction")
+			}
+
+			res, err = stmt.Run(s.tx, args)
+			if err != nil {
+				s.Close()
+				return nil, err
+			}
+			continue
 		}
 
 		// start a new transaction for every statement
-		tx, err = db.Begin(!stmt.IsReadOnly())
+		tx, err = s.db.BeginTx(ctx, !stmt.IsReadOnly())
 		if err != nil {
 			return nil, err
 		}
@@ -56,6 +173,7 @@ func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, er
 
 	// the returned result will now own the transaction.
 	// its Close method is expected to be called.
+	// the transaction of the session, if any, is not owned by the result.
 	res.tx = tx
 
 	return &res, nil
@@ -63,7 +181,15 @@ func (q Query) Run(db *database.Database, args []driver.NamedValue) (*Result, er
 
 // Exec the query within the given transaction. If the one of the statements requires a read-write
 // transaction and tx is not, tx will get promoted.
+// The statements are bound to the context of the transaction.
 func (q Query) Exec(tx *database.Transaction, args []driver.NamedValue, forceReadOnly bool) (*Result, error) {
+	return q.ExecContext(tx.Context(), tx, args, forceReadOnly)
+}
+
+// ExecContext is like Exec, but the statements are bound to ctx as well as to the context
+// of the transaction: once one of them is canceled or its deadline is exceeded,
+// the statements and the returned stream fail with the context error.
+func (q Query) ExecContext(ctx context.Context, tx *database.Transaction, args []driver.NamedValue, forceReadOnly bool) (*Result, error) {
 	var res Result
 	var err error
 
@@ -77,7 +203,7 @@ func (q Query) Exec(tx *database.Transaction, args []driver.NamedValue, forceRea
 			}
 		}
 
-		res, err = stmt.Run(tx, args)
+		res, err = stmt.Run(tx.WithContext(ctx), args)
 		if err != nil {
 			return nil, err
 		}
@@ -125,6 +251,68 @@ func (r Result) RowsAffected() (int64, error) {
 	return r.rowsAffected.RowsAffected()
 }
 
+// Cursor returns a cursor reading the documents of the result one at a time.
+// The documents are read from the tables and the indexes as the cursor moves, unless the stream
+// of the result uses an operation which can only pass its documents to Iterate, like a join
+// or a GROUP BY clause: in that case, the documents are all read and copied in memory
+// by the first call to Next.
+// The cursor must be closed before the result.
+func (r *Result) Cursor() document.Cursor {
+	if c, ok := r.Stream.Cursor(); ok {
+		return c
+	}
+
+	return &bufferedCursor{it: r.Stream}
+}
+
+// bufferedCursor reads the documents of an iterator in memory, then returns them one at a time.
+type bufferedCursor struct {
+	it     document.Iterator
+	docs   []document.Document
+	loaded bool
+	err    error
+}
+
+func (c *bufferedCursor) Next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if !c.loaded {
+		c.loaded = true
+		c.err = c.it.Iterate(func(d document.Document) error {
+			d, err := copyDocument(d)
+			if err != nil {
+				return err
+			}
+
+			c.docs = append(c.docs, d)
+			return nil
+		})
+
+		return c.err == nil && len(c.docs) > 0
+	}
+
+	if len(c.docs) > 0 {
+		c.docs = c.docs[1:]
+	}
+
+	return len(c.docs) > 0
+}
+
+func (c *bufferedCursor) Document() document.Document {
+	return c.docs[0]
+}
+
+func (c *bufferedCursor) Err() error {
+	return c.err
+}
+
+func (c *bufferedCursor) Close() error {
+	c.docs = nil
+	return nil
+}
+
 // Close the result stream.
 // After closing the result, Stream is not supposed to be used.
 // If the result stream was already closed, it returns
@@ -0,0 +1,99 @@
+package query_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResultCursor(t *testing.T) {
+	tests := []struct {
+		query    string
+		expected string
+	}{
+		{"SELECT a FROM test WHERE d >= 17", `[{"a":17},{"a":18},{"a":19}]`},
+		{"SELECT a FROM test WHERE a > 16", `[{"a":17},{"a":18},{"a":19}]`},
+		{"SELECT a FROM test WHERE a IN (3, 1, 3, 42)", `[{"a":1},{"a":3}]`},
+		{"SELECT a FROM test WHERE a BETWEEN 4 AND 6", `[{"a":4},{"a":5},{"a":6}]`},
+		{"SELECT a FROM test ORDER BY a DESC LIMIT 3", `[{"a":19},{"a":18},{"a":17}]`},
+		{"SELECT a FROM test WHERE b = 3", `[{"a":3},{"a":7},{"a":11},{"a":15},{"a":19}]`},
+		{"SELECT a FROM test WHERE b IN (2, 1) LIMIT 6", `[{"a":1},{"a":5},{"a":9},{"a":13},{"a":17},{"a":2}]`},
+		{"SELECT a FROM test WHERE c = 1 AND d < 5", `[{"a":1},{"a":3}]`},
+		{"SELECT a FROM test WHERE a = 2 OR b = 3", `[{"a":2},{"a":3},{"a":7},{"a":11},{"a":15},{"a":19}]`},
+		{"SELECT a FROM test WHERE a < 10 ORDER BY d DESC LIMIT 2 OFFSET 1", `[{"a":8},{"a":7}]`},
+		{"SELECT DISTINCT c FROM test", `[{"c":0},{"c":1}]`},
+		{"SELECT b, COUNT(*) AS n FROM test WHERE a < 6 GROUP BY b", `[{"b":0,"n":2},{"b":1,"n":2},{"b":2,"n":1},{"b":3,"n":1}]`},
+		{"SELECT a FROM test WHERE a < 2 UNION ALL SELECT a FROM test WHERE a > 18", `[{"a":0},{"a":1},{"a":19}]`},
+		{"SELECT 1 + 1 AS x", `[{"x":2}]`},
+		{"SELECT a FROM test WHERE a > 100", `[]`},
+	}
+
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec(`
+		CREATE TABLE test (a INTEGER PRIMARY KEY);
+		CREATE INDEX idx_b ON test (b);
+		CREATE INDEX idx_c_d ON test (c, d);
+	`)
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		err = db.Exec("INSERT INTO test (a, b, c, d) VALUES (?, ?, ?, ?)", i, i%4, i%2, i)
+		require.NoError(t, err)
+	}
+
+	readCursor := func(t *testing.T, res *query.Result) string {
+		c := res.Cursor()
+		defer func() {
+			require.NoError(t, c.Close())
+		}()
+
+		var buf bytes.Buffer
+		buf.WriteByte('[')
+		for i := 0; c.Next(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			require.NoError(t, document.ToJSON(&buf, c.Document()))
+		}
+		require.NoError(t, c.Err())
+		buf.WriteByte(']')
+
+		return buf.String()
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			res, err := db.Query(test.query)
+			require.NoError(t, err)
+			defer res.Close()
+
+			require.JSONEq(t, test.expected, readCursor(t, res))
+		})
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := db.QueryContext(ctx, "SELECT a FROM test")
+		require.NoError(t, err)
+		defer res.Close()
+
+		c := res.Cursor()
+		defer c.Close()
+
+		require.True(t, c.Next())
+		cancel()
+		require.False(t, c.Next())
+		require.Equal(t, context.Canceled, c.Err())
+	})
+}
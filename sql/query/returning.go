@@ -0,0 +1,95 @@
+package query
+
+import (
+	"database/sql/driver"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+)
+
+// returning collects the documents affected by an INSERT, UPDATE or DELETE statement
+// with a RETURNING clause.
+// The result fields are evaluated as soon as a document is added, while the transaction
+// is still in use by the statement, and the resulting documents are kept in memory.
+type returning struct {
+	tx           *database.Transaction
+	params       []driver.NamedValue
+	cfg          *database.TableConfig
+	resultFields []ResultField
+	docs         []document.Document
+}
+
+// newReturning returns a returning that evaluates the result fields against the documents of the table.
+// If there are no result fields, it returns nil, and calling add on it does nothing.
+func newReturning(tx *database.Transaction, args []driver.NamedValue, t *database.Table, resultFields []ResultField) (*returning, error) {
+	if len(resultFields) == 0 {
+		return nil, nil
+	}
+
+	cfg, err := t.Config()
+	if err != nil {
+		return nil, err
+	}
+
+	return &returning{
+		tx:           tx,
+		params:       args,
+		cfg:          cfg,
+		resultFields: resultFields,
+	}, nil
+}
+
+// add evaluates the result fields against d and stores the resulting document.
+func (r *returning) add(d document.Document) error {
+	if r == nil {
+		return nil
+	}
+
+	mask := documentMask{
+		tx:           r.tx,
+		params:       r.params,
+		cfg:          r.cfg,
+		r:            d,
+		resultFields: r.resultFields,
+	}
+
+	var fb document.FieldBuffer
+	err := mask.Iterate(func(f string, v document.Value) error {
+		v, err := copyValue(v)
+		if err != nil {
+			return err
+		}
+
+		fb.Add(f, v)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	r.docs = append(r.docs, &fb)
+	return nil
+}
+
+// addKey fetches the document associated with the key from the table and adds it.
+func (r *returning) addKey(t *database.Table, key []byte) error {
+	if r == nil {
+		return nil
+	}
+
+	d, err := t.GetDocument(key)
+	if err != nil {
+		return err
+	}
+
+	return r.add(d)
+}
+
+// stream returns a stream of the collected documents, or an empty stream if r is nil.
+func (r *returning) stream() document.Stream {
+	if r == nil {
+		return document.Stream{}
+	}
+
+	return document.NewStream(document.NewIterator(r.docs...))
+}
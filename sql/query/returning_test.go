@@ -0,0 +1,94 @@
+package query_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReturning(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		expected string
+		table    string
+	}{
+		{"INSERT / Wildcard", "INSERT INTO test (id, a) VALUES (3, 'c'), (4, 'd') RETURNING *",
+			`[{"id": 3, "a": "c"}, {"id": 4, "a": "d"}]`,
+			`[{"id": 1, "a": "a"}, {"id": 2, "a": "b"}, {"id": 3, "a": "c"}, {"id": 4, "a": "d"}]`},
+		{"INSERT / Converted values", "INSERT INTO test (id, a) VALUES (3.0, 'c') RETURNING id, typeof(id) AS t",
+			`[{"id": 3, "t": "int64"}]`,
+			`[{"id": 1, "a": "a"}, {"id": 2, "a": "b"}, {"id": 3, "a": "c"}]`},
+		{"INSERT / On conflict do nothing", "INSERT INTO test (id, a) VALUES (1, 'x'), (3, 'c') ON CONFLICT DO NOTHING RETURNING pk(), a",
+			`[{"pk()": 3, "a": "c"}]`,
+			`[{"id": 1, "a": "a"}, {"id": 2, "a": "b"}, {"id": 3, "a": "c"}]`},
+		{"INSERT / On conflict do update", "INSERT INTO test (id, a) VALUES (1, 'x') ON CONFLICT DO UPDATE SET a = excluded.a RETURNING *",
+			`[{"id": 1, "a": "x"}]`,
+			`[{"id": 1, "a": "x"}, {"id": 2, "a": "b"}]`},
+		{"UPDATE", "UPDATE test SET a = 'z' WHERE id > 1 RETURNING id, a AS value",
+			`[{"id": 2, "value": "z"}]`,
+			`[{"id": 1, "a": "a"}, {"id": 2, "a": "z"}]`},
+		{"UPDATE / No match", "UPDATE test SET a = 'z' WHERE id > 10 RETURNING *",
+			`[]`,
+			`[{"id": 1, "a": "a"}, {"id": 2, "a": "b"}]`},
+		{"DELETE", "DELETE FROM test WHERE id = 1 RETURNING *, a = 'a' AS ok",
+			`[{"id": 1, "a": "a", "ok": true}]`,
+			`[{"id": 2, "a": "b"}]`},
+		{"DELETE / All", "DELETE FROM test RETURNING a",
+			`[{"a": "a"}, {"a": "b"}]`,
+			`[]`},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE test (id INTEGER PRIMARY KEY);
+				INSERT INTO test (id, a) VALUES (1, 'a'), (2, 'b');
+			`)
+			require.NoError(t, err)
+
+			res, err := db.Query(test.query)
+			require.NoError(t, err)
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, res)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+			require.NoError(t, res.Close())
+
+			st, err := db.Query("SELECT * FROM test")
+			require.NoError(t, err)
+			defer st.Close()
+
+			buf.Reset()
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, test.table, buf.String())
+		})
+	}
+
+	t.Run("Without RETURNING", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec("CREATE TABLE test")
+		require.NoError(t, err)
+
+		res, err := db.Query("INSERT INTO test (a) VALUES (1)")
+		require.NoError(t, err)
+		defer res.Close()
+
+		var buf bytes.Buffer
+		err = document.IteratorToJSONArray(&buf, res)
+		require.NoError(t, err)
+		require.JSONEq(t, `[]`, buf.String())
+	})
+}
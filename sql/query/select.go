@@ -7,18 +7,21 @@ import (
 
 	"github.com/asdine/genji/database"
 	"github.com/asdine/genji/document"
-	"github.com/asdine/genji/sql/scanner"
 )
 
 // SelectStmt is a DSL that allows creating a full Select query.
 type SelectStmt struct {
-	TableName        string
-	WhereExpr        Expr
-	OrderBy          FieldSelector
-	OrderByDirection scanner.Token
-	OffsetExpr       Expr
-	LimitExpr        Expr
-	Selectors        []ResultField
+	TableName  string
+	TableAlias string
+	// Distinct removes the duplicate documents from the result.
+	Distinct   bool
+	Joins      []JoinClause
+	WhereExpr  Expr
+	GroupBy    []FieldSelector
+	OrderBy    []OrderingTerm
+	OffsetExpr Expr
+	LimitExpr  Expr
+	Selectors  []ResultField
 }
 
 // IsReadOnly always returns true. It implements the Statement interface.
@@ -36,6 +39,11 @@ func (stmt SelectStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 func (stmt SelectStmt) exec(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
 	var res Result
 
+	err := prepareSubqueries(tx, args, stmt.expressions()...)
+	if err != nil {
+		return res, err
+	}
+
 	// if there is no table name specified, evaluate the expression immediatly and return
 	// a stream with the result.
 	if stmt.TableName == "" {
@@ -44,6 +52,8 @@ func (stmt SelectStmt) exec(tx *database.Transaction, args []driver.NamedValue)
 		}
 
 		d := documentMask{
+			tx:           tx,
+			params:       args,
 			resultFields: stmt.Selectors,
 		}
 		var fb document.FieldBuffer
@@ -58,88 +68,285 @@ func (stmt SelectStmt) exec(tx *database.Transaction, args []driver.NamedValue)
 		return Result{Stream: document.NewStream(document.NewIterator(fb))}, nil
 	}
 
-	if stmt.OrderByDirection != scanner.DESC {
-		stmt.OrderByDirection = scanner.ASC
+	qo, err := stmt.prepare(tx, args)
+	if err != nil {
+		return res, err
 	}
 
-	offset := -1
-	limit := -1
+	orderBy := resolveOrderingTerms(stmt.OrderBy, stmt.Selectors)
 
 	stack := EvalStack{
 		Tx:     tx,
 		Params: args,
 	}
+	offset, limit := qo.offset, qo.limit
+	joined := len(stmt.Joins) > 0
+	aggregators := collectAggregators(stmt.Selectors)
+	grouped := len(stmt.GroupBy) > 0 || len(aggregators) > 0
+	windows := collectWindowFuncs(stmt.Selectors, orderBy)
 
-	if stmt.OffsetExpr != nil {
-		v, err := stmt.OffsetExpr.Eval(stack)
+	st, err := qo.optimizeQuery()
+	if err != nil {
+		return res, err
+	}
+
+	cfg := qo.cfg
+
+	if joined {
+		alias := stmt.TableAlias
+		if alias == "" {
+			alias = stmt.TableName
+		}
+
+		st, err = newJoinStream(tx, args, st, alias, stmt.Joins)
 		if err != nil {
 			return res, err
 		}
 
-		if !v.Type.IsNumber() {
-			return res, fmt.Errorf("offset expression must evaluate to a number, got %q", v.Type)
+		st = st.Filter(whereClause(stmt.WhereExpr, stack))
+
+		// joined documents don't have a primary key.
+		cfg = nil
+
+		if !grouped && len(windows) == 0 && len(orderBy) != 0 {
+			qo.orderBy = orderBy
+
+			st, err = qo.sortIterator(st, nil)
+			if err != nil {
+				return res, err
+			}
 		}
+	}
 
-		voff, err := v.ConvertToInt64()
-		if err != nil {
-			return res, err
+	mask := func(d document.Document) (document.Document, error) {
+		return documentMask{
+			tx:           tx,
+			params:       args,
+			cfg:          cfg,
+			r:            d,
+			resultFields: stmt.Selectors,
+		}, nil
+	}
+
+	if grouped {
+		// the ordering terms are evaluated against the groups, before they are projected,
+		// which requires the groups to hold the aggregators used by the terms as well.
+		for _, t := range orderBy {
+			walkExpr(t.Expr, func(e Expr) {
+				if b, ok := e.(AggregatorBuilder); ok {
+					aggregators = append(aggregators, b)
+				}
+			})
 		}
-		offset = int(voff)
+
+		st = document.NewStream(groupIterator{
+			st:          st,
+			groupBy:     stmt.GroupBy,
+			aggregators: aggregators,
+			stack: EvalStack{
+				Tx:     tx,
+				Params: args,
+				Cfg:    cfg,
+			},
+		})
+
+		if len(orderBy) != 0 {
+			qo.orderBy = orderBy
+
+			st, err = qo.sortIterator(st, mask)
+			if err != nil {
+				return res, err
+			}
+		} else {
+			st = st.Map(mask)
+		}
+	} else if len(windows) > 0 {
+		// window functions are evaluated once the documents are filtered,
+		// and the documents are sorted afterwards, so they can be ordered by
+		// the result of a window function.
+		st = newWindowStream(st, windows, EvalStack{
+			Tx:     tx,
+			Params: args,
+			Cfg:    cfg,
+		}, newSortConfig(tx))
+
+		if len(orderBy) != 0 {
+			qo.orderBy = orderBy
+
+			st, err = qo.sortIterator(st, mask)
+			if err != nil {
+				return res, err
+			}
+		} else {
+			st = st.Map(mask)
+		}
+	} else {
+		st = st.Map(mask)
 	}
 
-	if stmt.LimitExpr != nil {
-		v, err := stmt.LimitExpr.Eval(stack)
-		if err != nil {
-			return res, err
+	if qo.distinct {
+		st = document.NewStream(distinctIterator{it: st, cfg: newSortConfig(tx)})
+	}
+
+	if offset > 0 {
+		st = st.Offset(offset)
+	}
+
+	if limit >= 0 {
+		st = st.Limit(limit)
+	}
+
+	return Result{Stream: st}, nil
+}
+
+// prepare evaluates the limit and offset expressions and returns the query optimizer
+// used to read the documents of the table selected by the statement.
+func (stmt SelectStmt) prepare(tx *database.Transaction, args []driver.NamedValue) (*queryOptimizer, error) {
+	var misused bool
+	walkExpr(stmt.WhereExpr, func(e Expr) {
+		if _, ok := e.(AggregatorBuilder); ok {
+			misused = true
+		}
+	})
+	if misused {
+		return nil, errors.New("aggregate functions are not allowed in WHERE clause")
+	}
+
+	walkExpr(stmt.WhereExpr, func(e Expr) {
+		if _, ok := e.(*WindowFunc); ok {
+			misused = true
 		}
+	})
+	if misused {
+		return nil, errors.New("window functions are not allowed in WHERE clause")
+	}
 
-		if !v.Type.IsNumber() {
-			return res, fmt.Errorf("limit expression must evaluate to a number, got %q", v.Type)
+	// aggregate functions can only be used to sort groups.
+	aggregators := collectAggregators(stmt.Selectors)
+	grouped := len(stmt.GroupBy) > 0 || len(aggregators) > 0
+	if !grouped {
+		for _, t := range stmt.OrderBy {
+			walkExpr(t.Expr, func(e Expr) {
+				if _, ok := e.(AggregatorBuilder); ok {
+					misused = true
+				}
+			})
 		}
+	}
+	if misused {
+		return nil, errors.New("aggregate functions are not allowed in ORDER BY clause without GROUP BY")
+	}
+
+	windowed := len(collectWindowFuncs(stmt.Selectors, stmt.OrderBy)) > 0
+	if grouped && windowed {
+		return nil, errors.New("window functions cannot be used with GROUP BY or aggregate functions")
+	}
 
-		vlim, err := v.ConvertToInt64()
+	if grouped {
+		err := checkGroupedFields(stmt.Selectors, stmt.GroupBy)
 		if err != nil {
-			return res, err
+			return nil, err
 		}
-		limit = int(vlim)
+	}
+
+	stack := EvalStack{
+		Tx:     tx,
+		Params: args,
+	}
+
+	offset, err := evalIntClause(stmt.OffsetExpr, stack, "offset")
+	if err != nil {
+		return nil, err
+	}
+
+	limit, err := evalIntClause(stmt.LimitExpr, stack, "limit")
+	if err != nil {
+		return nil, err
 	}
 
 	qo, err := newQueryOptimizer(tx, stmt.TableName)
 	if err != nil {
-		return res, err
+		return nil, err
 	}
-	qo.whereExpr = stmt.WhereExpr
 	qo.args = args
-	qo.orderBy = stmt.OrderBy
-	qo.orderByDirection = stmt.OrderByDirection
 	qo.limit = limit
 	qo.offset = offset
 
-	st, err := qo.optimizeQuery()
+	// if the query joins multiple tables, the where clause and the order by clause
+	// are evaluated once the documents are joined.
+	joined := len(stmt.Joins) > 0
+	if !joined {
+		qo.whereExpr = stmt.WhereExpr
+	}
+
+	// if the query uses aggregate functions, window functions or a GROUP BY clause,
+	// documents are sorted after being grouped or after the window functions
+	// are evaluated, so they can be ordered by the result of these functions.
+	if !grouped && !joined && !windowed {
+		qo.orderBy = resolveOrderingTerms(stmt.OrderBy, stmt.Selectors)
+	}
+
+	// documents are deduplicated once projected, unless the statement selects
+	// a single field whose distinct values can be read from an index.
+	qo.distinct = stmt.Distinct
+	if stmt.Distinct && !grouped && !joined && !windowed && len(stmt.Selectors) == 1 {
+		if rf, ok := stmt.Selectors[0].(ResultFieldExpr); ok {
+			qo.distinctField, _ = rf.Expr.(FieldSelector)
+		}
+
+		if _, ok := qo.distinctIndex(); ok {
+			qo.distinct = false
+		}
+	}
+
+	return &qo, nil
+}
+
+// evalIntClause evaluates the expression of a LIMIT or OFFSET clause.
+// It returns -1 if there is no expression.
+func evalIntClause(e Expr, stack EvalStack, clause string) (int, error) {
+	if e == nil {
+		return -1, nil
+	}
+
+	v, err := e.Eval(stack)
 	if err != nil {
-		return res, err
+		return 0, err
 	}
 
-	if offset > 0 {
-		st = st.Offset(offset)
+	if !v.Type.IsNumber() {
+		return 0, fmt.Errorf("%s expression must evaluate to a number, got %q", clause, v.Type)
 	}
 
-	if limit >= 0 {
-		st = st.Limit(limit)
+	n, err := v.ConvertToInt64()
+	if err != nil {
+		return 0, err
 	}
 
-	st = st.Map(func(d document.Document) (document.Document, error) {
-		return documentMask{
-			cfg:          qo.cfg,
-			r:            d,
-			resultFields: stmt.Selectors,
-		}, nil
-	})
+	return int(n), nil
+}
 
-	return Result{Stream: st}, nil
+// expressions returns the expressions used by the statement.
+func (stmt SelectStmt) expressions() []Expr {
+	exprs := []Expr{stmt.WhereExpr, stmt.OffsetExpr, stmt.LimitExpr}
+	for _, j := range stmt.Joins {
+		exprs = append(exprs, j.On)
+	}
+	for _, rf := range stmt.Selectors {
+		if e, ok := rf.(ResultFieldExpr); ok {
+			exprs = append(exprs, e.Expr)
+		}
+	}
+	for _, t := range stmt.OrderBy {
+		exprs = append(exprs, t.Expr)
+	}
+
+	return exprs
 }
 
 type documentMask struct {
+	tx           *database.Transaction
+	params       []driver.NamedValue
 	cfg          *database.TableConfig
 	r            document.Document
 	resultFields []ResultField
@@ -147,11 +354,23 @@ type documentMask struct {
 
 var _ document.Document = documentMask{}
 
-func (r documentMask) GetByField(name string) (document.Value, error) {
-	for _, rf := range r.resultFields {
-		if rf.Name() == name || rf.Name() == "*" {
-			return r.r.GetByField(name)
+// errStop stops an iteration once the expected value is found.
+var errStop = errors.New("stop")
+
+func (r documentMask) GetByField(name string) (v document.Value, err error) {
+	err = r.Iterate(func(f string, value document.Value) error {
+		if f == name {
+			v = value
+			return errStop
 		}
+
+		return nil
+	})
+	if err == errStop {
+		return v, nil
+	}
+	if err != nil {
+		return
 	}
 
 	return document.Value{}, document.ErrFieldNotFound
@@ -159,7 +378,9 @@ func (r documentMask) GetByField(name string) (document.Value, error) {
 
 func (r documentMask) Iterate(fn func(f string, v document.Value) error) error {
 	stack := EvalStack{
+		Tx:       r.tx,
 		Document: r.r,
+		Params:   r.params,
 		Cfg:      r.cfg,
 	}
 
@@ -209,6 +430,17 @@ func (w Wildcard) Name() string {
 	return "*"
 }
 
+// Eval returns the current document.
+// It implements the Expr interface, which allows the wildcard
+// to be passed to functions, like in COUNT(*).
+func (w Wildcard) Eval(stack EvalStack) (document.Value, error) {
+	if stack.Document == nil {
+		return nilLitteral, errors.New("no table specified")
+	}
+
+	return document.NewDocumentValue(stack.Document), nil
+}
+
 // Iterate call the document iterate method.
 func (w Wildcard) Iterate(stack EvalStack, fn func(fd string, v document.Value) error) error {
 	if stack.Document == nil {
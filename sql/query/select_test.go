@@ -7,6 +7,7 @@ import (
 
 	"github.com/asdine/genji"
 	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/sql/query"
 	"github.com/stretchr/testify/require"
 )
 
@@ -25,6 +26,15 @@ func TestSelectStmt(t *testing.T) {
 		{"No table, BitwiseAnd", "SELECT 10 & 6", false, `[{"10 & 6":2}]`, nil},
 		{"No table, BitwiseOr", "SELECT 10 | 6", false, `[{"10 | 6":14}]`, nil},
 		{"No table, BitwiseXor", "SELECT 10 ^ 6", false, `[{"10 ^ 6":12}]`, nil},
+		{"No table, Like", `SELECT 'héllo' LIKE 'h_l%' AS a, 'a%b' LIKE 'a\\%b' AS b, 'axb' LIKE 'a\\%b' AS c, 'abc' LIKE '%b%c' AS d, 'abc' LIKE '%b' AS e`, false, `[{"a":true,"b":true,"c":false,"d":true,"e":false}]`, nil},
+		{"No table, In", "SELECT 1 IN (1.0, 2) AS a, NULL IN (1, NULL) AS b, 1 IN 1 AS c", false, `[{"a":true,"b":true,"c":false}]`, nil},
+		{"No table, Is", "SELECT NULL IS NULL AS a, 1 IS NOT NULL AS b, x IS NULL AS c", false, `[{"a":true,"b":true,"c":true}]`, nil},
+		{"No table, string functions", "SELECT lower('HéLLO') AS a, upper('abc') AS b, trim('  a ') AS c, trim('xxaxx', 'x') AS d, substr('héllo', 2, 3) AS e, substr('abc', 0, 2) AS f, length('héllo') AS g, concat('a', 1, NULL, true) AS h", false, `[{"a":"héllo","b":"ABC","c":"a","d":"a","e":"éll","f":"a","g":5,"h":"a1true"}]`, nil},
+		{"No table, math functions", "SELECT abs(-2) AS a, abs(-2.5) AS b, round(2.5) AS c, round(1.2345, 2) AS d, round(1250, -2) AS e, floor(-1.5) AS f, ceil(1.2) AS g", false, `[{"a":2,"b":2.5,"c":3.0,"d":1.23,"e":1300,"f":-2.0,"g":2.0}]`, nil},
+		{"No table, type functions", "SELECT typeof('a') AS a, typeof(x) AS b, coalesce(NULL, x, 2, 3) AS c, ifnull(1, 2) AS d, lower(NULL) AS e", false, `[{"a":"text","b":"null","c":2,"d":1,"e":null}]`, nil},
+		{"No table, array and document functions", "SELECT len([1, 2, 3]) AS a, len({a: 1, b: 2}) AS b, array_contains([1, 'a'], 'a') AS c, array_contains([1], 2) AS d, keys({a: 1, b: 2}) AS e", false, `[{"a":3,"b":2,"c":true,"d":false,"e":["a","b"]}]`, nil},
+		{"No table, function with wrong type", "SELECT lower(1)", true, ``, nil},
+		{"No table, function with wrong arity", "SELECT lower('a', 'b')", true, ``, nil},
 		{"No table, function", "SELECT pk()", true, ``, nil},
 		{"No table, function", "SELECT a", false, `[{"a": null}]`, nil},
 		{"No table, function", "SELECT *", true, ``, nil},
@@ -41,6 +51,20 @@ func TestSelectStmt(t *testing.T) {
 		{"With sub op", "SELECT size - 10 AS s FROM test ORDER BY k", false, `[{"s":0},{"s":0},{"s":null}]`, nil},
 		{"With mul op", "SELECT size * 10 AS s FROM test ORDER BY k", false, `[{"s":100},{"s":100},{"s":null}]`, nil},
 		{"With div op", "SELECT size / 10 AS s FROM test ORDER BY k", false, `[{"s":1},{"s":1},{"s":null}]`, nil},
+		{"With regex op", "SELECT * FROM test WHERE color =~ /^r/", false, `[{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
+		{"With not regex op", "SELECT * FROM test WHERE color !~ /^r/", false, `[{"k":2,"color":"blue","size":10,"weight":100},{"k":3,"height":100,"weight":200}]`, nil},
+		{"With regex op on number", "SELECT * FROM test WHERE size =~ /1/", false, `[]`, nil},
+		{"With invalid regex", "SELECT * FROM test WHERE color =~ /(/", true, ``, nil},
+		{"With in op", "SELECT * FROM test WHERE color IN ('red', 'green', 'blue') ORDER BY k", false, `[{"k":1,"color":"red","size":10,"shape":"square"},{"k":2,"color":"blue","size":10,"weight":100}]`, nil},
+		{"With not in op", "SELECT * FROM test WHERE color NOT IN ('red', 'green')", false, `[{"k":2,"color":"blue","size":10,"weight":100},{"k":3,"height":100,"weight":200}]`, nil},
+		{"With in op and param", "SELECT * FROM test WHERE k IN ?", false, `[{"k":1,"color":"red","size":10,"shape":"square"},{"k":3,"height":100,"weight":200}]`, []interface{}{[]int{3, 1}}},
+		{"With is null op", "SELECT * FROM test WHERE color IS NULL", false, `[{"k":3,"height":100,"weight":200}]`, nil},
+		{"With is not null op", "SELECT * FROM test WHERE color IS NOT NULL AND weight IS NOT NULL", false, `[{"k":2,"color":"blue","size":10,"weight":100}]`, nil},
+		{"With like op", "SELECT * FROM test WHERE shape LIKE 's_u%e'", false, `[{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
+		{"With not like op", "SELECT * FROM test WHERE color NOT LIKE '%e%'", false, `[{"k":3,"height":100,"weight":200}]`, nil},
+		{"With between op", "SELECT * FROM test WHERE weight BETWEEN 50 AND 100", false, `[{"k":2,"color":"blue","size":10,"weight":100}]`, nil},
+		{"With not between op", "SELECT * FROM test WHERE k NOT BETWEEN 2 AND 3", false, `[{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
+		{"With not op", "SELECT * FROM test WHERE NOT size = 10", false, `[{"k":3,"height":100,"weight":200}]`, nil},
 		{"With field comparison", "SELECT * FROM test WHERE color < shape", false, `[{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
 		{"With order by", "SELECT * FROM test ORDER BY color", false, `[{"k":3,"height":100,"weight":200},{"k":2,"color":"blue","size":10,"weight":100},{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
 		{"With order by asc", "SELECT * FROM test ORDER BY color ASC", false, `[{"k":3,"height":100,"weight":200},{"k":2,"color":"blue","size":10,"weight":100},{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
@@ -57,6 +81,14 @@ func TestSelectStmt(t *testing.T) {
 		{"With order by pk asc", "SELECT * FROM test ORDER BY k ASC", false, `[{"k":1,"color":"red","size":10,"shape":"square"},{"k":2,"color":"blue","size":10,"weight":100},{"k":3,"height":100,"weight":200}]`, nil},
 		{"With order by pk desc", "SELECT * FROM test ORDER BY k DESC", false, `[{"k":3,"height":100,"weight":200},{"k":2,"color":"blue","size":10,"weight":100},{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
 		{"With order by and where", "SELECT * FROM test WHERE color != 'blue' ORDER BY color DESC LIMIT 1", false, `[{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
+		{"With order by multiple terms", "SELECT k FROM test ORDER BY size DESC, weight DESC", false, `[{"k":2},{"k":1},{"k":3}]`, nil},
+		{"With order by multiple terms and limit", "SELECT k FROM test ORDER BY size, weight DESC LIMIT 2", false, `[{"k":3},{"k":2}]`, nil},
+		{"With order by nulls last", "SELECT k FROM test ORDER BY color NULLS LAST", false, `[{"k":2},{"k":1},{"k":3}]`, nil},
+		{"With order by desc nulls first", "SELECT k FROM test ORDER BY weight DESC NULLS FIRST", false, `[{"k":1},{"k":3},{"k":2}]`, nil},
+		{"With order by pk nulls last", "SELECT k FROM test ORDER BY k NULLS LAST", false, `[{"k":1},{"k":2},{"k":3}]`, nil},
+		{"With order by expression", "SELECT k FROM test ORDER BY 10 - k", false, `[{"k":3},{"k":2},{"k":1}]`, nil},
+		{"With order by alias", "SELECT k, weight AS w FROM test ORDER BY w DESC", false, `[{"k":3,"w":200},{"k":2,"w":100},{"k":1,"w":null}]`, nil},
+		{"With order by aggregate", "SELECT * FROM test ORDER BY COUNT(*)", true, ``, nil},
 		{"With limit", "SELECT * FROM test WHERE size = 10 LIMIT 1", false, `[{"k":1,"color":"red","size":10,"shape":"square"}]`, nil},
 		{"With offset", "SELECT *, pk() FROM test WHERE size = 10 OFFSET 1", false, `[{"pk()":2,"color":"blue","size":10,"weight":100,"k":2}]`, nil},
 		{"With limit then offset", "SELECT * FROM test WHERE size = 10 LIMIT 1 OFFSET 1", false, `[{"k":2,"color":"blue","size":10,"weight":100,"k":2}]`, nil},
@@ -68,6 +100,26 @@ func TestSelectStmt(t *testing.T) {
 		{"With pk in cond, =", "SELECT * FROM test WHERE k = 2.0 AND weight = 100", false, `[{"k":2,"color":"blue","size":10,"weight":100,"k":2}]`, nil},
 		{"With two non existing idents, =", "SELECT * FROM test WHERE z = y", false, `[]`, nil},
 		{"With two non existing idents, >", "SELECT * FROM test WHERE z > y", false, `[]`, nil},
+		{"With count", "SELECT COUNT(*) FROM test", false, `[{"COUNT(*)":3}]`, nil},
+		{"With count on field", "SELECT COUNT(color) AS n FROM test", false, `[{"n":2}]`, nil},
+		{"With aggregates", "SELECT SUM(size), AVG(weight), MIN(color), MAX(k) FROM test", false, `[{"SUM(size)":20,"AVG(weight)":150,"MIN(color)":"blue","MAX(k)":3}]`, nil},
+		{"With aggregates and where", "SELECT COUNT(*) AS n, SUM(weight) AS w FROM test WHERE size = 10", false, `[{"n":2,"w":100}]`, nil},
+		{"With aggregates, no match", "SELECT COUNT(*) AS n, SUM(weight) AS w FROM test WHERE size > 10", false, `[{"n":0,"w":null}]`, nil},
+		{"With group by", "SELECT size, COUNT(*) AS n FROM test GROUP BY size", false, `[{"size":10,"n":2},{"size":null,"n":1}]`, nil},
+		{"With group by, no match", "SELECT size, COUNT(*) AS n FROM test WHERE size > 10 GROUP BY size", false, `[]`, nil},
+		{"With group by multiple fields", "SELECT size, color, COUNT(*) AS n FROM test GROUP BY size, color", false, `[{"size":10,"color":"red","n":1},{"size":10,"color":"blue","n":1},{"size":null,"color":null,"n":1}]`, nil},
+		{"With group by and order by", "SELECT size, SUM(weight) AS w FROM test GROUP BY size ORDER BY w DESC", false, `[{"size":null,"w":200},{"size":10,"w":100}]`, nil},
+		{"With group by and order by aggregate", "SELECT size FROM test GROUP BY size ORDER BY COUNT(*) DESC", false, `[{"size":10},{"size":null}]`, nil},
+		{"With group by and order by same expression", "SELECT size, MAX(k) FROM test GROUP BY size ORDER BY MAX(k)", false, `[{"size":10,"MAX(k)":2},{"size":null,"MAX(k)":3}]`, nil},
+		{"With group by and limit", "SELECT size, COUNT(*) AS n FROM test GROUP BY size LIMIT 1 OFFSET 1", false, `[{"size":null,"n":1}]`, nil},
+		{"With aggregate in where", "SELECT * FROM test WHERE COUNT(*) > 1", true, ``, nil},
+		{"With aggregate in where and group by", "SELECT size FROM test WHERE SUM(k) > 1 GROUP BY size", true, ``, nil},
+		{"With non-grouped field", "SELECT color, COUNT(*) FROM test GROUP BY size", true, ``, nil},
+		{"With non-grouped field and aggregate", "SELECT color, COUNT(*) FROM test", true, ``, nil},
+		{"With non-grouped field in expression", "SELECT size, k + 1 FROM test GROUP BY size", true, ``, nil},
+		{"With wildcard and group by", "SELECT * FROM test GROUP BY size", true, ``, nil},
+		{"With grouped field in expression", "SELECT size + 1 AS s, COUNT(*) AS n FROM test GROUP BY size", false, `[{"s":11,"n":2},{"s":null,"n":1}]`, nil},
+		{"With non-grouped field in aggregate", "SELECT size, MAX(color) AS c FROM test GROUP BY size", false, `[{"size":10,"c":"red"},{"size":null,"c":null}]`, nil},
 		{"With two non existing idents, !=", "SELECT * FROM test WHERE z != y", false, `[{"k":1,"color":"red","size":10,"shape":"square"},{"k":2,"color":"blue","size":10,"weight":100},{"k":3,"height":100,"weight":200}]`, nil},
 	}
 
@@ -172,6 +224,78 @@ func TestSelectStmt(t *testing.T) {
 		call("SELECT a.b FROM test", `{"a.b": 1}`, `{"a.b": null}`, `{"a.b": null}`)
 		call("SELECT a.1 FROM test", `{"a.1": null}`, `{"a.1": null}`, `{"a.1": 2}`)
 		call("SELECT a.2.1 FROM test", `{"a.2.1": null}`, `{"a.2.1": null}`, `{"a.2.1": 9}`)
+		call("SELECT a.0, COUNT(*) AS n FROM test GROUP BY a.0", `{"a.0": null, "n": 2}`, `{"a.0": 1, "n": 1}`)
+		call("SELECT a, COUNT(*) AS n FROM test GROUP BY a", `{"a": {"b": 1}, "n": 1}`, `{"a": 1, "n": 1}`, `{"a": [1, 2, [8, 9]], "n": 1}`)
+	})
+
+	t.Run("aggregates", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec(`
+			CREATE TABLE empty;
+			CREATE TABLE test;
+			INSERT INTO test (k, a, n, g) VALUES (1, 2, 2, {b: 1}), (2, 'b', 1.5, {b: 1.0}), (3, true, 3, {b: 2}), (4, [1], 1.0, {c: 1}), (5, {c: 1}, 1, null), (6, null, 2.0, {b: 1});
+			INSERT INTO test (k) VALUES (7);
+		`)
+		require.NoError(t, err)
+
+		tests := []struct {
+			name     string
+			query    string
+			fails    bool
+			expected string
+		}{
+			{"Empty table", "SELECT COUNT(*) AS c, COUNT(a) AS ca, SUM(a) AS s, AVG(a) AS av, MIN(a) AS mi, MAX(a) AS ma FROM empty", false, `[{"c":0,"ca":0,"s":null,"av":null,"mi":null,"ma":null}]`},
+			{"Empty table, group by", "SELECT a, COUNT(*) AS c FROM empty GROUP BY a", false, `[]`},
+			{"Min and max, mixed types", "SELECT MIN(a) AS mi, MAX(a) AS ma FROM test", false, `[{"mi":true,"ma":{"c":1}}]`},
+			{"Min and max, mixed numbers", "SELECT MIN(n) AS mi, MAX(n) AS ma FROM test", false, `[{"mi":1.0,"ma":3}]`},
+			{"Min and max, group by", "SELECT g.b, MIN(a) AS mi, MAX(a) AS ma FROM test GROUP BY g.b", false, `[{"g.b":1,"mi":2,"ma":"b"},{"g.b":2,"mi":true,"ma":true},{"g.b":null,"mi":[1],"ma":{"c":1}}]`},
+			{"Group by nested path", "SELECT g.b, COUNT(*) AS c FROM test GROUP BY g.b", false, `[{"g.b":1,"c":3},{"g.b":2,"c":1},{"g.b":null,"c":3}]`},
+			{"Group by nested path, selecting a sub path", "SELECT g.b, COUNT(*) AS c FROM test GROUP BY g", false, `[{"g.b":1,"c":3},{"g.b":2,"c":1},{"g.b":null,"c":1},{"g.b":null,"c":2}]`},
+			{"Group by mixed numbers", "SELECT n, COUNT(*) AS c, SUM(k) AS s FROM test GROUP BY n", false, `[{"n":2,"c":2,"s":7},{"n":1.5,"c":1,"s":2},{"n":3,"c":1,"s":3},{"n":1.0,"c":2,"s":9},{"n":null,"c":1,"s":7}]`},
+			{"Group by a field, selecting another one", "SELECT n, a FROM test GROUP BY n", true, ``},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				st, err := db.Query(test.query)
+				if test.fails {
+					require.Error(t, err)
+					return
+				}
+				require.NoError(t, err)
+				defer st.Close()
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, buf.String())
+			})
+		}
+	})
+
+	t.Run("order by mixed types", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec(`
+			CREATE TABLE test;
+			INSERT INTO test (k, a) VALUES (1, {b: 1}), (2, 'b'), (3, [1, 2]), (4, 2.5), (5, true), (6, null), (7, 1), (8, [1]), (9, 'a');
+			INSERT INTO test (k) VALUES (10);
+		`)
+		require.NoError(t, err)
+
+		st, err := db.Query("SELECT k FROM test ORDER BY a, k DESC")
+		require.NoError(t, err)
+		defer st.Close()
+
+		var buf bytes.Buffer
+		err = document.IteratorToJSONArray(&buf, st)
+		require.NoError(t, err)
+		require.JSONEq(t, `[{"k":10},{"k":6},{"k":5},{"k":7},{"k":4},{"k":9},{"k":2},{"k":8},{"k":3},{"k":1}]`, buf.String())
 	})
 
 	t.Run("table not found", func(t *testing.T) {
@@ -183,3 +307,96 @@ func TestSelectStmt(t *testing.T) {
 		require.Error(t, err)
 	})
 }
+
+func TestSelectStmtJoin(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		fails    bool
+		expected string
+	}{
+		{"Inner join on primary key", "SELECT u.name, o.amount FROM orders AS o JOIN users AS u ON o.user_id = u.id", false,
+			`[{"u.name":"a","o.amount":10},{"u.name":"b","o.amount":20},{"u.name":"a","o.amount":30}]`},
+		{"Inner join on field", "SELECT u.name, o.amount FROM users u INNER JOIN orders o ON o.user_id = u.id", false,
+			`[{"u.name":"a","o.amount":10},{"u.name":"a","o.amount":30},{"u.name":"b","o.amount":20}]`},
+		{"Inner join, reversed condition", "SELECT u.name, o.amount FROM users u JOIN orders o ON u.id = o.user_id", false,
+			`[{"u.name":"a","o.amount":10},{"u.name":"a","o.amount":30},{"u.name":"b","o.amount":20}]`},
+		{"Left join", "SELECT u.name, o.amount FROM users u LEFT JOIN orders o ON o.user_id = u.id", false,
+			`[{"u.name":"a","o.amount":10},{"u.name":"a","o.amount":30},{"u.name":"b","o.amount":20},{"u.name":"c","o.amount":null}]`},
+		{"Left outer join", "SELECT u.name, o FROM users u LEFT OUTER JOIN orders o ON o.user_id = u.id AND o.amount > 20", false,
+			`[{"u.name":"a","o":{"id":3,"user_id":1,"amount":30}},{"u.name":"b","o":null},{"u.name":"c","o":null}]`},
+		{"Without alias", "SELECT users.name, orders.id FROM users JOIN orders ON orders.user_id = users.id WHERE users.id = 2", false,
+			`[{"users.name":"b","orders.id":2}]`},
+		{"Wildcard", "SELECT * FROM users u JOIN orders o ON o.user_id = u.id AND o.amount > 20", false,
+			`[{"u":{"id":1,"name":"a"},"o":{"id":3,"user_id":1,"amount":30}}]`},
+		{"Where and order by", "SELECT u.name, o.amount FROM users u JOIN orders o ON o.user_id = u.id WHERE o.amount > 10 ORDER BY o.amount DESC", false,
+			`[{"u.name":"a","o.amount":30},{"u.name":"b","o.amount":20}]`},
+		{"Limit and offset", "SELECT o.id FROM users u JOIN orders o ON o.user_id = u.id LIMIT 1 OFFSET 1", false,
+			`[{"o.id":3}]`},
+		{"Group by", "SELECT u.name, SUM(o.amount) AS total FROM users u LEFT JOIN orders o ON o.user_id = u.id GROUP BY u.name", false,
+			`[{"u.name":"a","total":40},{"u.name":"b","total":20},{"u.name":"c","total":null}]`},
+		{"Multiple joins", "SELECT u.name, o.id, i.label FROM users u JOIN orders o ON o.user_id = u.id JOIN items i ON i.order_id = o.id", false,
+			`[{"u.name":"a","o.id":1,"i.label":"x"},{"u.name":"a","o.id":1,"i.label":"y"},{"u.name":"b","o.id":2,"i.label":"z"}]`},
+		{"Duplicate alias", "SELECT * FROM users u JOIN orders u ON u.id = u.user_id", true, ``},
+		{"Duplicate table", "SELECT * FROM users JOIN users ON users.id = users.id", true, ``},
+		{"Unknown table", "SELECT * FROM users u JOIN foo f ON f.id = u.id", true, ``},
+	}
+
+	for _, test := range tests {
+		testFn := func(withIndexes bool) func(t *testing.T) {
+			return func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec(`
+					CREATE TABLE users (id INTEGER PRIMARY KEY);
+					CREATE TABLE orders;
+					CREATE TABLE items;
+				`)
+				require.NoError(t, err)
+				if withIndexes {
+					err = db.Exec(`
+						CREATE INDEX idx_orders_user_id ON orders (user_id);
+						CREATE UNIQUE INDEX idx_orders_id ON orders (id);
+						CREATE INDEX idx_items_order_id ON items (order_id);
+					`)
+					require.NoError(t, err)
+				}
+
+				err = db.Exec(`
+					INSERT INTO users (id, name) VALUES (1, 'a'), (2, 'b'), (3, 'c');
+					INSERT INTO orders (id, user_id, amount) VALUES (1, 1, 10), (2, 2, 20), (3, 1, 30), (4, 4, 40);
+					INSERT INTO items (order_id, label) VALUES (1, 'x'), (2, 'z'), (1, 'y');
+				`)
+				require.NoError(t, err)
+
+				check := func(st *query.Result, err error) {
+					if test.fails {
+						require.Error(t, err)
+						return
+					}
+					require.NoError(t, err)
+
+					var buf bytes.Buffer
+					err = document.IteratorToJSONArray(&buf, st)
+					require.NoError(t, err)
+					require.JSONEq(t, test.expected, buf.String())
+				}
+
+				st, err := db.Query(test.query)
+				defer st.Close()
+				check(st, err)
+
+				// read-write transactions
+				err = db.Update(func(tx *genji.Tx) error {
+					check(tx.Query(test.query))
+					return nil
+				})
+				require.NoError(t, err)
+			}
+		}
+		t.Run("No Index/"+test.name, testFn(false))
+		t.Run("With Index/"+test.name, testFn(true))
+	}
+}
@@ -0,0 +1,500 @@
+package query
+
+import (
+	"bufio"
+	"bytes"
+	"container/heap"
+	"context"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+
+	"github.com/asdine/genji/database"
+)
+
+// sortEntryOverhead is an estimation of the memory used by a sort entry, in addition to its data.
+const sortEntryOverhead = 64
+
+// maxMergedRuns is the maximum number of runs merged at once. If there are more runs,
+// they are merged in several passes so that the number of open files remains bounded.
+const maxMergedRuns = 64
+
+// sortConfig controls how a sorter stores its entries, and stops it
+// once the context of the transaction is canceled.
+type sortConfig struct {
+	database.SortOptions
+
+	ctx context.Context
+}
+
+// sortEntry is a document being sorted.
+type sortEntry struct {
+	// value encodes the values of the ordering terms, see appendOrderingKey.
+	value []byte
+	// position of the document in the sorted stream, used to keep the sort stable.
+	seq uint64
+	// key of the document, if any.
+	key []byte
+	// encoded document.
+	data []byte
+}
+
+func (e *sortEntry) less(other *sortEntry) bool {
+	if c := bytes.Compare(e.value, other.value); c != 0 {
+		return c < 0
+	}
+
+	return e.seq < other.seq
+}
+
+func (e *sortEntry) size() int {
+	return len(e.value) + len(e.key) + len(e.data) + sortEntryOverhead
+}
+
+// sorter sorts entries using an external merge sort.
+// Entries are kept in memory until their size exceeds the memory limit. They are then sorted
+// and written to a temporary file, called a run. Once all the entries are added,
+// the runs are merged to return the entries in order.
+// If k is greater than zero, only the k smallest entries are returned. In that case,
+// the entries are kept in a max-heap which never holds more than k entries, and
+// runs never contain more than k entries.
+type sorter struct {
+	cfg sortConfig
+	k   int
+
+	entries []sortEntry
+	size    int
+	seq     uint64
+	// paths of the temporary files containing the runs.
+	runs []string
+}
+
+func newSorter(cfg sortConfig, k int) *sorter {
+	if cfg.MemoryLimit <= 0 {
+		cfg.MemoryLimit = database.DefaultSortMemoryLimit
+	}
+	if cfg.ctx == nil {
+		cfg.ctx = context.Background()
+	}
+
+	return &sorter{cfg: cfg, k: k}
+}
+
+// add an entry to the sorter. The sorter takes ownership of the entry's buffers.
+func (s *sorter) add(e sortEntry) error {
+	e.seq = s.seq
+	s.seq++
+
+	if s.k > 0 {
+		h := (*topHeap)(&s.entries)
+		if len(s.entries) < s.k {
+			heap.Push(h, e)
+		} else {
+			// the entry is not among the k smallest entries.
+			if !e.less(&s.entries[0]) {
+				return nil
+			}
+
+			s.size -= s.entries[0].size()
+			s.entries[0] = e
+			heap.Fix(h, 0)
+		}
+	} else {
+		s.entries = append(s.entries, e)
+	}
+
+	s.size += e.size()
+	if s.size > s.cfg.MemoryLimit {
+		return s.spill()
+	}
+
+	return nil
+}
+
+// sortEntries sorts the entries kept in memory.
+func (s *sorter) sortEntries() {
+	sort.Slice(s.entries, func(i, j int) bool {
+		return s.entries[i].less(&s.entries[j])
+	})
+}
+
+// spill sorts the entries kept in memory and writes them to a new run.
+func (s *sorter) spill() error {
+	s.sortEntries()
+
+	err := s.writeRun(func(fn func(e *sortEntry) error) error {
+		for i := range s.entries {
+			err := fn(&s.entries[i])
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.entries = s.entries[:0]
+	s.size = 0
+	return nil
+}
+
+// writeRun creates a temporary file and writes the entries returned by iterate to it.
+func (s *sorter) writeRun(iterate func(fn func(e *sortEntry) error) error) error {
+	f, err := ioutil.TempFile(s.cfg.TempDir, "genji-sort-")
+	if err != nil {
+		return err
+	}
+	s.runs = append(s.runs, f.Name())
+
+	w := bufio.NewWriter(f)
+	var buf [binary.MaxVarintLen64]byte
+	writeBytes := func(b []byte) error {
+		n := binary.PutUvarint(buf[:], uint64(len(b)))
+		_, err := w.Write(buf[:n])
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(b)
+		return err
+	}
+
+	err = iterate(func(e *sortEntry) error {
+		n := binary.PutUvarint(buf[:], e.seq)
+		_, err := w.Write(buf[:n])
+		if err != nil {
+			return err
+		}
+
+		for _, b := range [][]byte{e.value, e.key, e.data} {
+			err = writeBytes(b)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err == nil {
+		err = w.Flush()
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// iterate calls fn with every entry, in order.
+// It stops and returns the context error if the context is canceled.
+func (s *sorter) iterate(fn func(e *sortEntry) error) error {
+	c := s.cursor()
+	defer c.close()
+
+	for c.next() {
+		err := fn(c.cur)
+		if err != nil {
+			return err
+		}
+	}
+
+	return c.err
+}
+
+// cursor returns a cursor reading the entries in order.
+func (s *sorter) cursor() *sortCursor {
+	return &sortCursor{s: s}
+}
+
+// finish prepares the entries to be read in order. If they all fit in memory, they are sorted.
+// Otherwise, the runs are merged until they can be merged at once, by the returned merger.
+func (s *sorter) finish() (*runMerger, error) {
+	// all the entries fit in memory
+	if len(s.runs) == 0 {
+		s.sortEntries()
+		return nil, nil
+	}
+
+	if len(s.entries) > 0 {
+		err := s.spill()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	// reduce the number of runs until they can be merged at once.
+	for len(s.runs) > maxMergedRuns {
+		// the merged runs are kept in s.runs until they are removed,
+		// so that close removes them if the merge fails.
+		runs := s.runs[:maxMergedRuns]
+
+		err := s.writeRun(func(fn func(e *sortEntry) error) error {
+			return s.merge(runs, fn)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		err = removeRuns(runs)
+		if err != nil {
+			return nil, err
+		}
+
+		s.runs = s.runs[maxMergedRuns:]
+	}
+
+	return newRunMerger(s.cfg.ctx, s.runs, s.k)
+}
+
+// merge reads the given runs and calls fn with their entries, in order.
+// If k is greater than zero, it stops after k entries.
+func (s *sorter) merge(runs []string, fn func(e *sortEntry) error) error {
+	m, err := newRunMerger(s.cfg.ctx, runs, s.k)
+	if err != nil {
+		return err
+	}
+	defer m.close()
+
+	for {
+		e, err := m.next()
+		if err != nil || e == nil {
+			return err
+		}
+
+		err = fn(e)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// sortCursor reads the entries of a sorter one at a time, in order.
+// The entries are prepared by the first call to next, see sorter.finish.
+type sortCursor struct {
+	s       *sorter
+	started bool
+	// position of the next entry, if all the entries fit in memory.
+	i      int
+	merger *runMerger
+	// current entry, only valid until the next call to next.
+	cur *sortEntry
+	err error
+}
+
+// next moves the cursor to the next entry. It returns false once there are no more entries
+// or if an error occurred. It stops with the context error if the context is canceled.
+func (c *sortCursor) next() bool {
+	if c.err != nil {
+		return false
+	}
+
+	if !c.started {
+		c.started = true
+
+		c.merger, c.err = c.s.finish()
+		if c.err != nil {
+			return false
+		}
+	}
+
+	if c.merger != nil {
+		c.cur, c.err = c.merger.next()
+		return c.cur != nil
+	}
+
+	if c.i == len(c.s.entries) {
+		return false
+	}
+
+	if c.err = c.s.cfg.ctx.Err(); c.err != nil {
+		return false
+	}
+
+	c.cur = &c.s.entries[c.i]
+	c.i++
+	return true
+}
+
+// close closes the runs being merged, if any.
+func (c *sortCursor) close() {
+	if c.merger != nil {
+		c.merger.close()
+	}
+}
+
+// runMerger reads the entries of several runs, in order.
+// If k is greater than zero, it stops after k entries.
+type runMerger struct {
+	ctx context.Context
+	h   runHeap
+	k   int
+	n   int
+	// run whose entry was returned by the last call to next.
+	last *runReader
+}
+
+func newRunMerger(ctx context.Context, runs []string, k int) (*runMerger, error) {
+	m := runMerger{ctx: ctx, k: k}
+
+	for _, path := range runs {
+		f, err := os.Open(path)
+		if err != nil {
+			m.close()
+			return nil, err
+		}
+
+		r := &runReader{f: f, r: bufio.NewReader(f)}
+		ok, err := r.next()
+		if err != nil || !ok {
+			f.Close()
+			if err != nil {
+				m.close()
+				return nil, err
+			}
+			continue
+		}
+
+		m.h = append(m.h, r)
+	}
+	heap.Init(&m.h)
+
+	return &m, nil
+}
+
+// next returns the next entry, or nil if there are no more entries.
+// The entry is only valid until the next call.
+// It stops and returns the context error if the context is canceled.
+func (m *runMerger) next() (*sortEntry, error) {
+	if m.last != nil {
+		ok, err := m.last.next()
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			heap.Fix(&m.h, 0)
+		} else {
+			heap.Pop(&m.h)
+			m.last.f.Close()
+		}
+		m.last = nil
+	}
+
+	if len(m.h) == 0 || (m.k > 0 && m.n >= m.k) {
+		return nil, nil
+	}
+
+	if err := m.ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	m.last = m.h[0]
+	m.n++
+	return &m.last.cur, nil
+}
+
+// close closes the files of the runs.
+func (m *runMerger) close() {
+	for _, r := range m.h {
+		r.f.Close()
+	}
+	m.h = nil
+}
+
+// close removes the temporary files.
+func (s *sorter) close() error {
+	err := removeRuns(s.runs)
+	s.runs = nil
+	return err
+}
+
+// removeRuns removes the files of the given runs. Runs which were already removed are ignored.
+func removeRuns(runs []string) error {
+	var err error
+	for _, path := range runs {
+		if rerr := os.Remove(path); rerr != nil && !os.IsNotExist(rerr) && err == nil {
+			err = rerr
+		}
+	}
+
+	return err
+}
+
+// runReader reads the entries of a run.
+type runReader struct {
+	f   *os.File
+	r   *bufio.Reader
+	cur sortEntry
+}
+
+// next reads the next entry. It returns false if there are no more entries.
+// The previous entry is not reused, it remains valid after the call.
+func (r *runReader) next() (bool, error) {
+	seq, err := binary.ReadUvarint(r.r)
+	if err == io.EOF {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	var bufs [3][]byte
+	for i := range bufs {
+		l, err := binary.ReadUvarint(r.r)
+		if err != nil {
+			return false, err
+		}
+
+		bufs[i] = make([]byte, l)
+		_, err = io.ReadFull(r.r, bufs[i])
+		if err != nil {
+			return false, err
+		}
+	}
+
+	r.cur = sortEntry{seq: seq, value: bufs[0], key: bufs[1], data: bufs[2]}
+	return true, nil
+}
+
+// runHeap is a min-heap of runs, ordered by their current entry.
+type runHeap []*runReader
+
+func (h runHeap) Len() int           { return len(h) }
+func (h runHeap) Less(i, j int) bool { return h[i].cur.less(&h[j].cur) }
+func (h runHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *runHeap) Push(x interface{}) {
+	*h = append(*h, x.(*runReader))
+}
+
+func (h *runHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
+
+// topHeap is a max-heap of entries, used to keep the k smallest entries.
+type topHeap []sortEntry
+
+func (h topHeap) Len() int           { return len(h) }
+func (h topHeap) Less(i, j int) bool { return h[j].less(&h[i]) }
+func (h topHeap) Swap(i, j int)      { h[i], h[j] = h[j], h[i] }
+
+func (h *topHeap) Push(x interface{}) {
+	*h = append(*h, x.(sortEntry))
+}
+
+func (h *topHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	x := old[n-1]
+	*h = old[0 : n-1]
+	return x
+}
@@ -0,0 +1,122 @@
+package query_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectStmtExternalSort(t *testing.T) {
+	const n = 500
+
+	dir, err := ioutil.TempDir("", "genji")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	// small enough to write more runs than can be merged at once
+	db.DB.Sort = database.SortOptions{MemoryLimit: 256, TempDir: dir}
+
+	err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY)")
+	require.NoError(t, err)
+
+	var values []string
+	for i := 0; i < n; i++ {
+		values = append(values, fmt.Sprintf("(%d, %d, %d)", i, (i*7919)%n, i%3))
+	}
+	err = db.Exec("INSERT INTO test (k, a, b) VALUES " + strings.Join(values, ", "))
+	require.NoError(t, err)
+
+	type doc struct {
+		K int
+		A int
+		B int
+	}
+
+	query := func(t *testing.T, q string) []doc {
+		res, err := db.Query(q)
+		require.NoError(t, err)
+		defer res.Close()
+
+		var docs []doc
+		err = res.Iterate(func(d document.Document) error {
+			var dd doc
+			err := document.StructScan(d, &dd)
+			docs = append(docs, dd)
+			return err
+		})
+		require.NoError(t, err)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, files)
+
+		return docs
+	}
+
+	t.Run("ASC", func(t *testing.T) {
+		docs := query(t, "SELECT pk() AS k, a FROM test ORDER BY a")
+		require.Len(t, docs, n)
+		for i, d := range docs {
+			require.Equal(t, i, d.A)
+			require.Equal(t, d.A, (d.K*7919)%n)
+		}
+	})
+
+	t.Run("DESC with limit", func(t *testing.T) {
+		docs := query(t, "SELECT k, a FROM test ORDER BY a DESC LIMIT 10 OFFSET 5")
+		require.Len(t, docs, 10)
+		for i, d := range docs {
+			require.Equal(t, n-6-i, d.A)
+		}
+	})
+
+	t.Run("Stable", func(t *testing.T) {
+		docs := query(t, "SELECT k, b FROM test ORDER BY b")
+		require.Len(t, docs, n)
+		for i := 1; i < len(docs); i++ {
+			if docs[i].B == docs[i-1].B {
+				require.True(t, docs[i].K > docs[i-1].K)
+			} else {
+				require.Equal(t, docs[i-1].B+1, docs[i].B)
+			}
+		}
+	})
+
+	t.Run("Grouped", func(t *testing.T) {
+		docs := query(t, "SELECT b, COUNT(*) AS a FROM test GROUP BY b ORDER BY b DESC")
+		require.Equal(t, []doc{{B: 2, A: 166}, {B: 1, A: 167}, {B: 0, A: 167}}, docs)
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		res, err := db.QueryContext(ctx, "SELECT k, a FROM test ORDER BY a")
+		require.NoError(t, err)
+		defer res.Close()
+
+		var count int
+		err = res.Iterate(func(d document.Document) error {
+			count++
+			cancel()
+			return nil
+		})
+		require.Equal(t, context.Canceled, err)
+		require.Equal(t, 1, count)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
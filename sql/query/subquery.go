@@ -0,0 +1,197 @@
+package query
+
+import (
+	"database/sql/driver"
+	"errors"
+	"sync"
+
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+)
+
+// A Subquery is a SELECT statement used as an expression.
+// It evaluates to the only value of the only document returned by the statement,
+// or to null if the statement doesn't return any document.
+//
+// Subqueries cannot refer to the documents of the statement they are part of,
+// they are run once, before the statement reads any document, and their result is reused
+// every time they are evaluated during the execution of the statement.
+type Subquery struct {
+	Statement SelectStmt
+
+	mu     sync.Mutex
+	result *subqueryResult
+}
+
+// subqueryResult holds the values returned by a subquery within a transaction.
+type subqueryResult struct {
+	tx     *database.Transaction
+	values []document.Value
+	// set if one of the documents doesn't have exactly one field
+	invalid bool
+}
+
+// Eval returns the value returned by the subquery.
+// It returns an error if the subquery returns more than one document,
+// or if the document has more than one field.
+func (s *Subquery) Eval(stack EvalStack) (document.Value, error) {
+	r, err := s.load(stack)
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	if len(r.values) == 0 {
+		return nilLitteral, nil
+	}
+	if r.invalid {
+		return nilLitteral, errors.New("subquery must return documents with a single field")
+	}
+	if len(r.values) > 1 {
+		return nilLitteral, errors.New("subquery returned more than one document")
+	}
+
+	return r.values[0], nil
+}
+
+// prepare runs the statement of the subquery and stores its result,
+// so that it can be reused by every evaluation within the same transaction.
+func (s *Subquery) prepare(tx *database.Transaction, args []driver.NamedValue) error {
+	r, err := s.run(tx, args)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	s.result = r
+	s.mu.Unlock()
+	return nil
+}
+
+// load returns the result of the subquery prepared for the transaction of the stack.
+// If it wasn't prepared, the statement is run.
+func (s *Subquery) load(stack EvalStack) (*subqueryResult, error) {
+	if stack.Tx == nil {
+		return nil, errors.New("subqueries must be evaluated within a transaction")
+	}
+
+	s.mu.Lock()
+	r := s.result
+	s.mu.Unlock()
+
+	if r != nil && r.tx == stack.Tx {
+		return r, nil
+	}
+
+	return s.run(stack.Tx, stack.Params)
+}
+
+// run executes the statement and copies the value of every returned document.
+func (s *Subquery) run(tx *database.Transaction, args []driver.NamedValue) (*subqueryResult, error) {
+	res, err := s.Statement.Run(tx, args)
+	if err != nil {
+		return nil, err
+	}
+
+	r := subqueryResult{tx: tx}
+	err = res.Iterate(func(d document.Document) error {
+		var n int
+		err := d.Iterate(func(f string, v document.Value) error {
+			n++
+			if n > 1 {
+				return nil
+			}
+
+			v, err := copyValue(v)
+			if err != nil {
+				return err
+			}
+
+			r.values = append(r.values, v)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		if n != 1 {
+			r.invalid = true
+			if n == 0 {
+				r.values = append(r.values, nilLitteral)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// SubqueryArray is used as the right operand of the IN operator.
+// It evaluates to an array containing the value of every document returned by the subquery.
+type SubqueryArray struct {
+	Subquery *Subquery
+}
+
+// Eval returns the values returned by the subquery as an array.
+// It returns an error if one of the documents has more than one field.
+func (s SubqueryArray) Eval(stack EvalStack) (document.Value, error) {
+	r, err := s.Subquery.load(stack)
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	if r.invalid {
+		return nilLitteral, errors.New("subquery must return documents with a single field")
+	}
+
+	return document.NewArrayValue(document.NewValueBuffer(r.values...)), nil
+}
+
+// ExistsOp is the EXISTS operator.
+type ExistsOp struct {
+	Subquery *Subquery
+}
+
+// Exists creates an expression that returns true if the subquery returns at least one document.
+func Exists(s *Subquery) ExistsOp {
+	return ExistsOp{Subquery: s}
+}
+
+// Eval returns true if the subquery returns at least one document.
+func (op ExistsOp) Eval(stack EvalStack) (document.Value, error) {
+	r, err := op.Subquery.load(stack)
+	if err != nil {
+		return nilLitteral, err
+	}
+
+	if len(r.values) > 0 {
+		return trueLitteral, nil
+	}
+
+	return falseLitteral, nil
+}
+
+// prepareSubqueries runs the subqueries found in the given expressions
+// and stores their results, before the statement reads any document.
+func prepareSubqueries(tx *database.Transaction, args []driver.NamedValue, exprs ...Expr) error {
+	var subqueries []*Subquery
+	for _, e := range exprs {
+		walkExpr(e, func(e Expr) {
+			if s, ok := e.(*Subquery); ok {
+				subqueries = append(subqueries, s)
+			}
+		})
+	}
+
+	for _, s := range subqueries {
+		err := s.prepare(tx, args)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
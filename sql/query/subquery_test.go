@@ -0,0 +1,145 @@
+package query_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubquery(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		fails    bool
+		expected string
+	}{
+		{"Scalar", "SELECT (SELECT max(b) FROM bar) AS m", false, `[{"m": 30}]`},
+		{"Scalar / No document", "SELECT (SELECT b FROM bar WHERE b > 100) AS m", false, `[{"m": null}]`},
+		{"Scalar / Too many documents", "SELECT (SELECT b FROM bar) AS m", true, ``},
+		{"Scalar / Too many fields", "SELECT (SELECT a, b FROM bar WHERE b = 10) AS m", true, ``},
+		{"Scalar / Where", "SELECT a FROM foo WHERE a > (SELECT min(b) FROM bar)", false, `[{"a": 20}, {"a": 30}, {"a": 40}]`},
+		{"Scalar / Primary key", "SELECT k FROM foo WHERE k = (SELECT b / 10 FROM bar WHERE a = 'b')", false, `[{"k": 2}]`},
+		{"IN", "SELECT a FROM foo WHERE a IN (SELECT b FROM bar)", false, `[{"a": 10}, {"a": 20}, {"a": 30}]`},
+		{"IN / Filtered", "SELECT a FROM foo WHERE a IN (SELECT b FROM bar WHERE a != 'b')", false, `[{"a": 10}, {"a": 30}]`},
+		{"NOT IN", "SELECT a FROM foo WHERE a NOT IN (SELECT b FROM bar)", false, `[{"a": 40}]`},
+		{"IN / Too many fields", "SELECT a FROM foo WHERE a IN (SELECT * FROM bar)", true, ``},
+		{"EXISTS", "SELECT a FROM foo WHERE EXISTS (SELECT * FROM bar WHERE b = 20)", false, `[{"a": 10}, {"a": 20}, {"a": 30}, {"a": 40}]`},
+		{"EXISTS / No document", "SELECT a FROM foo WHERE EXISTS (SELECT * FROM bar WHERE b = 40)", false, `[]`},
+		{"NOT EXISTS", "SELECT a FROM foo WHERE NOT EXISTS (SELECT * FROM bar WHERE b = 40)", false, `[{"a": 10}, {"a": 20}, {"a": 30}, {"a": 40}]`},
+	}
+
+	for _, test := range tests {
+		testFn := func(withIndexes bool) func(t *testing.T) {
+			return func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec(`
+					CREATE TABLE foo (k INTEGER PRIMARY KEY);
+					CREATE TABLE bar;
+				`)
+				require.NoError(t, err)
+				if withIndexes {
+					err = db.Exec(`
+						CREATE INDEX idx_foo_a ON foo (a);
+						CREATE INDEX idx_bar_b ON bar (b);
+					`)
+					require.NoError(t, err)
+				}
+				err = db.Exec(`
+					INSERT INTO foo (k, a) VALUES (1, 10), (2, 20), (3, 30), (4, 40);
+					INSERT INTO bar (a, b) VALUES ('a', 10), ('b', 20), ('c', 30);
+				`)
+				require.NoError(t, err)
+
+				st, err := db.Query(test.query)
+				if err == nil {
+					defer st.Close()
+
+					var buf bytes.Buffer
+					err = document.IteratorToJSONArray(&buf, st)
+					if !test.fails {
+						require.NoError(t, err)
+						require.JSONEq(t, test.expected, buf.String())
+						return
+					}
+				}
+
+				require.True(t, test.fails, err)
+				require.Error(t, err)
+			}
+		}
+
+		t.Run("No Index/"+test.name, testFn(false))
+		t.Run("With Index/"+test.name, testFn(true))
+	}
+
+	t.Run("Statements", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			query    string
+			expected string
+		}{
+			{"UPDATE", "UPDATE foo SET a = (SELECT max(b) FROM bar) WHERE a IN (SELECT b FROM bar WHERE b < 30)",
+				`[{"k": 1, "a": 30}, {"k": 2, "a": 30}, {"k": 3, "a": 30}, {"k": 4, "a": 40}]`},
+			{"DELETE", "DELETE FROM foo WHERE a IN (SELECT b FROM bar)", `[{"k": 4, "a": 40}]`},
+			{"DELETE / EXISTS", "DELETE FROM foo WHERE EXISTS (SELECT * FROM bar)", `[]`},
+			{"INSERT / Values", "INSERT INTO foo (k, a) VALUES ((SELECT count(*) FROM foo) + 1, (SELECT max(b) FROM bar))",
+				`[{"k": 1, "a": 10}, {"k": 2, "a": 20}, {"k": 3, "a": 30}, {"k": 4, "a": 40}, {"k": 5, "a": 30}]`},
+			{"INSERT / Select", "INSERT INTO foo SELECT b / 10 + 4 AS k, a FROM bar WHERE b > 10",
+				`[{"k": 1, "a": 10}, {"k": 2, "a": 20}, {"k": 3, "a": 30}, {"k": 4, "a": 40}, {"k": 6, "a": "b"}, {"k": 7, "a": "c"}]`},
+			{"INSERT / Select with columns", "INSERT INTO foo (a, k) SELECT a, b FROM bar",
+				`[{"k": 1, "a": 10}, {"k": 2, "a": 20}, {"k": 3, "a": 30}, {"k": 4, "a": 40}, {"k": 10, "a": "a"}, {"k": 20, "a": "b"}, {"k": 30, "a": "c"}]`},
+			{"INSERT / Select same table", "INSERT INTO foo SELECT k + 4 AS k, a * 2 AS a FROM foo",
+				`[{"k": 1, "a": 10}, {"k": 2, "a": 20}, {"k": 3, "a": 30}, {"k": 4, "a": 40}, {"k": 5, "a": 20}, {"k": 6, "a": 40}, {"k": 7, "a": 60}, {"k": 8, "a": 80}]`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec(`
+					CREATE TABLE foo (k INTEGER PRIMARY KEY);
+					CREATE TABLE bar;
+					CREATE INDEX idx_foo_a ON foo (a);
+					INSERT INTO foo (k, a) VALUES (1, 10), (2, 20), (3, 30), (4, 40);
+					INSERT INTO bar (a, b) VALUES ('a', 10), ('b', 20), ('c', 30);
+				`)
+				require.NoError(t, err)
+
+				err = db.Exec(test.query)
+				require.NoError(t, err)
+
+				st, err := db.Query("SELECT * FROM foo")
+				require.NoError(t, err)
+				defer st.Close()
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, buf.String())
+			})
+		}
+	})
+
+	t.Run("INSERT / Select with wrong number of fields", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec(`
+			CREATE TABLE foo;
+			INSERT INTO foo (a, b) VALUES (1, 2);
+		`)
+		require.NoError(t, err)
+
+		err = db.Exec("INSERT INTO foo (a) SELECT a, b FROM foo")
+		require.EqualError(t, err, "2 values for 1 fields")
+	})
+}
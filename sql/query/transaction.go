@@ -0,0 +1,67 @@
+package query
+
+import (
+	"database/sql/driver"
+	"errors"
+
+	"github.com/asdine/genji/database"
+)
+
+var (
+	// ErrTransactionInProgress is returned when running a BEGIN statement
+	// while a transaction started with BEGIN is in progress.
+	ErrTransactionInProgress = errors.New("cannot begin a transaction within a transaction")
+
+	// ErrNoTransaction is returned when running a COMMIT or ROLLBACK statement
+	// while no transaction started with BEGIN is in progress.
+	ErrNoTransaction = errors.New("no transaction in progress")
+)
+
+// BeginStmt is a statement that creates a new transaction.
+// The transaction is kept by the session and used by the statements
+// of the subsequent queries until it is committed or rolled back.
+type BeginStmt struct {
+	Writable bool
+}
+
+// IsReadOnly always returns true. It implements the Statement interface.
+func (stmt BeginStmt) IsReadOnly() bool {
+	return true
+}
+
+// Run returns an error: transactions cannot be started within a transaction.
+// BEGIN statements are handled by Session.Run.
+// It implements the Statement interface.
+func (stmt BeginStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	return Result{}, ErrTransactionInProgress
+}
+
+// CommitStmt is a statement that commits the transaction started with BEGIN.
+type CommitStmt struct{}
+
+// IsReadOnly always returns true. It implements the Statement interface.
+func (stmt CommitStmt) IsReadOnly() bool {
+	return true
+}
+
+// Run returns an error: only transactions started with BEGIN can be committed.
+// COMMIT statements are handled by Session.Run.
+// It implements the Statement interface.
+func (stmt CommitStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	return Result{}, errors.New("cannot commit a transaction that was not started with BEGIN")
+}
+
+// RollbackStmt is a statement that rolls back the transaction started with BEGIN.
+type RollbackStmt struct{}
+
+// IsReadOnly always returns true. It implements the Statement interface.
+func (stmt RollbackStmt) IsReadOnly() bool {
+	return true
+}
+
+// Run returns an error: only transactions started with BEGIN can be rolled back.
+// ROLLBACK statements are handled by Session.Run.
+// It implements the Statement interface.
+func (stmt RollbackStmt) Run(tx *database.Transaction, args []driver.NamedValue) (Result, error) {
+	return Result{}, errors.New("cannot rollback a transaction that was not started with BEGIN")
+}
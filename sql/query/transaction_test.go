@@ -0,0 +1,143 @@
+package query_test
+
+import (
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/sql/query"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTransactionStmt(t *testing.T) {
+	count := func(t *testing.T, db *genji.DB) int {
+		d, err := db.QueryDocument("SELECT COUNT(*) FROM test")
+		require.NoError(t, err)
+		v, err := d.GetByField("COUNT(*)")
+		require.NoError(t, err)
+		return int(v.V.(int64))
+	}
+
+	tests := []struct {
+		name     string
+		queries  []string
+		fails    bool
+		expected int
+	}{
+		{"Commit", []string{"BEGIN", "INSERT INTO test (a) VALUES (1)", "INSERT INTO test (a) VALUES (2)", "COMMIT"}, false, 2},
+		{"Rollback", []string{"BEGIN", "INSERT INTO test (a) VALUES (1)", "ROLLBACK"}, false, 0},
+		{"Single query", []string{"BEGIN; INSERT INTO test (a) VALUES (1); COMMIT"}, false, 1},
+		{"Read only", []string{"BEGIN READ ONLY", "SELECT * FROM test", "COMMIT"}, false, 0},
+		{"Write in read only", []string{"BEGIN READ ONLY", "INSERT INTO test (a) VALUES (1)"}, true, 0},
+		{"Nested begin", []string{"BEGIN", "BEGIN"}, true, 0},
+		{"Commit without begin", []string{"COMMIT"}, true, 0},
+		{"Rollback without begin", []string{"ROLLBACK"}, true, 0},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec("CREATE TABLE test")
+			require.NoError(t, err)
+
+			s := db.NewSession()
+			defer s.Close()
+
+			for i, q := range test.queries {
+				err = s.Exec(q)
+				if test.fails && i == len(test.queries)-1 {
+					require.Error(t, err)
+					break
+				}
+				require.NoError(t, err)
+			}
+
+			if s.Tx() != nil {
+				require.NoError(t, s.Exec("ROLLBACK"))
+			}
+
+			require.Equal(t, test.expected, count(t, db))
+		})
+	}
+
+	t.Run("Failing statement rolls back the transaction", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		s := db.NewSession()
+		defer s.Close()
+
+		err = s.Exec("CREATE TABLE test; BEGIN; INSERT INTO test (a) VALUES (1)")
+		require.NoError(t, err)
+		require.NotNil(t, s.Tx())
+
+		err = s.Exec("INSERT INTO unknown (a) VALUES (1)")
+		require.Error(t, err)
+		require.Nil(t, s.Tx())
+		require.Equal(t, query.ErrNoTransaction, s.Exec("COMMIT"))
+		require.Equal(t, 0, count(t, db))
+	})
+
+	t.Run("Transactions are kept by their session", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec("CREATE TABLE test")
+		require.NoError(t, err)
+
+		s1 := db.NewSession()
+		defer s1.Close()
+		s2 := db.NewSession()
+		defer s2.Close()
+
+		err = s1.Exec("BEGIN; INSERT INTO test (a) VALUES (1)")
+		require.NoError(t, err)
+
+		// the other sessions and the queries run on db don't see the transaction.
+		require.Nil(t, s2.Tx())
+		require.Equal(t, query.ErrNoTransaction, s2.Exec("ROLLBACK"))
+		require.Equal(t, 0, count(t, db))
+
+		err = s1.Exec("COMMIT")
+		require.NoError(t, err)
+		require.Equal(t, 1, count(t, db))
+
+		// closing a session rolls back its transaction.
+		err = s2.Exec("BEGIN; INSERT INTO test (a) VALUES (2)")
+		require.NoError(t, err)
+		require.NoError(t, s2.Close())
+		require.Equal(t, 1, count(t, db))
+	})
+
+	t.Run("Transactions must end with the query run on DB", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec("CREATE TABLE test")
+		require.NoError(t, err)
+
+		err = db.Exec("BEGIN; INSERT INTO test (a) VALUES (1)")
+		require.Error(t, err)
+		require.Equal(t, query.ErrNoTransaction, db.Exec("COMMIT"))
+		require.Equal(t, 0, count(t, db))
+	})
+
+	t.Run("Statements within a Go transaction", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Update(func(tx *genji.Tx) error {
+			require.Error(t, tx.Exec("BEGIN"))
+			require.Error(t, tx.Exec("COMMIT"))
+			require.Error(t, tx.Exec("ROLLBACK"))
+			return nil
+		})
+		require.NoError(t, err)
+	})
+}
@@ -15,8 +15,20 @@ const updateBufferSize = 100
 // UpdateStmt is a DSL that allows creating a full Update query.
 type UpdateStmt struct {
 	TableName string
-	Pairs     map[string]Expr
-	WhereExpr Expr
+	// Pairs is used along with the SET clause. Each pair sets the value
+	// of the field found at its path, creating it if it doesn't exist.
+	Pairs []SetPair
+	// UnsetFields is used along with the UNSET clause. It holds the paths of the fields
+	// that must be deleted from the documents.
+	UnsetFields []document.ValuePath
+	WhereExpr   Expr
+	Returning   []ResultField
+}
+
+// A SetPair assigns the result of an expression to the field found at the given path.
+type SetPair struct {
+	Path document.ValuePath
+	Expr Expr
 }
 
 // IsReadOnly always returns false. It implements the Statement interface.
@@ -33,7 +45,7 @@ func (stmt UpdateStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		return res, errors.New("missing table name")
 	}
 
-	if len(stmt.Pairs) == 0 {
+	if len(stmt.Pairs) == 0 && len(stmt.UnsetFields) == 0 {
 		return res, errors.New("Set method not called")
 	}
 
@@ -47,6 +59,20 @@ func (stmt UpdateStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 		return res, err
 	}
 
+	exprs := []Expr{stmt.WhereExpr}
+	for _, pair := range stmt.Pairs {
+		exprs = append(exprs, pair.Expr)
+	}
+	err = prepareSubqueries(tx, args, exprs...)
+	if err != nil {
+		return res, err
+	}
+
+	ret, err := newReturning(tx, args, t, stmt.Returning)
+	if err != nil {
+		return res, err
+	}
+
 	// replace store implementation by a resumable store, temporarily.
 	resumableStore := storeFromKey{Store: t.Store}
 	t.Store = &resumableStore
@@ -72,25 +98,18 @@ func (stmt UpdateStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 				return err
 			}
 
-			for fname, e := range stmt.Pairs {
-				_, err := docs[i].GetByField(fname)
-				if err != nil {
-					continue
-				}
-
-				ev, err := e.Eval(EvalStack{
-					Tx:       tx,
-					Document: d,
-					Params:   args,
-				})
-				if err != nil && err != document.ErrFieldNotFound {
-					return err
-				}
-
-				err = docs[i].Replace(fname, ev)
-				if err != nil {
-					return err
-				}
+			err = setFields(&docs[i], stmt.Pairs, EvalStack{
+				Tx:       tx,
+				Document: d,
+				Params:   args,
+			})
+			if err != nil {
+				return err
+			}
+
+			err = unsetFields(&docs[i], stmt.UnsetFields)
+			if err != nil {
+				return err
 			}
 
 			// copy the key and reuse the buffer
@@ -99,22 +118,68 @@ func (stmt UpdateStmt) Run(tx *database.Transaction, args []driver.NamedValue) (
 
 			return nil
 		})
+		if err != nil {
+			return res, err
+		}
 
 		for j := 0; j < i; j++ {
 			err = t.Replace(keys[j], docs[j])
 			if err != nil {
 				return res, err
 			}
+
+			res.rowsAffected++
+
+			err = ret.addKey(t, keys[j])
+			if err != nil {
+				return res, err
+			}
 		}
 
-		if i < deleteBufferSize {
+		if i < updateBufferSize {
 			break
 		}
 
-		resumableStore.key = keys[i-1]
+		// resume the iteration right after the last updated document,
+		// which must not be updated twice.
+		resumableStore.key = append(resumableStore.key[:0], keys[i-1]...)
+		resumableStore.key = append(resumableStore.key, 0)
+	}
+
+	res.Stream = ret.stream()
+	return res, nil
+}
+
+// setFields evaluates the expressions of the pairs and sets the values at their paths in fb.
+// Missing fields are created, but paths going through values that are neither documents nor arrays,
+// or through array indexes that are out of range, are ignored.
+func setFields(fb *document.FieldBuffer, pairs []SetPair, stack EvalStack) error {
+	for _, pair := range pairs {
+		ev, err := pair.Expr.Eval(stack)
+		if err != nil && err != document.ErrFieldNotFound {
+			return err
+		}
+
+		err = fb.SetPath(pair.Path, ev)
+		if err != nil && err != document.ErrFieldNotFound && err != document.ErrValueNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unsetFields deletes the fields found at the given paths from fb.
+// Fields that don't exist in fb are ignored.
+func unsetFields(fb *document.FieldBuffer, paths []document.ValuePath) error {
+	for _, p := range paths {
+		err := fb.DeletePath(p)
+		if err != nil && err != document.ErrFieldNotFound && err != document.ErrValueNotFound {
+			return err
+		}
 	}
 
-	return res, err
+	return nil
 }
 
 // storeFromKey implements an engine.Store which iterates from a certain key.
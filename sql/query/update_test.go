@@ -18,13 +18,17 @@ func TestUpdateStmt(t *testing.T) {
 		expected string
 		params   []interface{}
 	}{
-		{"No cond", `UPDATE test SET a = 'boo'`, false, `[{"a":"boo","b":"bar1","c":"baz1"},{"a":"boo","b":"bar2"},{"d":"foo3","e":"bar3"}]`, nil},
-		{"No cond / with ident string", "UPDATE test SET `a` = 'boo'", false, `[{"a":"boo","b":"bar1","c":"baz1"},{"a":"boo","b":"bar2"},{"d":"foo3","e":"bar3"}]`, nil},
-		{"No cond / with multiple idents", `UPDATE test SET a = c`, false, `[{"a":"baz1","b":"bar1","c":"baz1"},{"a":null,"b":"bar2"},{"d":"foo3","e":"bar3"}]`, nil},
+		{"No cond", `UPDATE test SET a = 'boo'`, false, `[{"a":"boo","b":"bar1","c":"baz1"},{"a":"boo","b":"bar2"},{"a":"boo","d":"foo3","e":"bar3"}]`, nil},
+		{"No cond / with ident string", "UPDATE test SET `a` = 'boo'", false, `[{"a":"boo","b":"bar1","c":"baz1"},{"a":"boo","b":"bar2"},{"a":"boo","d":"foo3","e":"bar3"}]`, nil},
+		{"No cond / with multiple idents", `UPDATE test SET a = c`, false, `[{"a":"baz1","b":"bar1","c":"baz1"},{"a":null,"b":"bar2"},{"a":null,"d":"foo3","e":"bar3"}]`, nil},
 		{"No cond / with string", `UPDATE test SET 'a' = 'boo'`, true, "", nil},
 		{"With cond", "UPDATE test SET a = 1, b = 2 WHERE a = 'foo2'", false, `[{"a":"foo1","b":"bar1","c":"baz1"},{"a":1,"b":2},{"d":"foo3","e":"bar3"}]`, nil},
 		{"Field not found", "UPDATE test SET a = 1, b = 2 WHERE a = f", false, `[{"a":"foo1","b":"bar1","c":"baz1"},{"a":"foo2","b":"bar2"},{"d":"foo3","e":"bar3"}]`, nil},
 		{"Positional params", "UPDATE test SET a = ?, b = ? WHERE a = ?", false, `[{"a":"a","b":"b","c":"baz1"},{"a":"foo2","b":"bar2"},{"d":"foo3","e":"bar3"}]`, []interface{}{"a", "b", "foo1"}},
+		{"New field", "UPDATE test SET f = e WHERE d = 'foo3'", false, `[{"a":"foo1","b":"bar1","c":"baz1"},{"a":"foo2","b":"bar2"},{"d":"foo3","e":"bar3","f":"bar3"}]`, nil},
+		{"New nested field", "UPDATE test SET f.g = e WHERE d = 'foo3'", false, `[{"a":"foo1","b":"bar1","c":"baz1"},{"a":"foo2","b":"bar2"},{"d":"foo3","e":"bar3","f":{"g":"bar3"}}]`, nil},
+		{"Unset", "UPDATE test UNSET b, c", false, `[{"a":"foo1"},{"a":"foo2"},{"d":"foo3","e":"bar3"}]`, nil},
+		{"Set and unset", "UPDATE test SET z = b UNSET b WHERE a = 'foo1'", false, `[{"a":"foo1","c":"baz1","z":"bar1"},{"a":"foo2","b":"bar2"},{"d":"foo3","e":"bar3"}]`, nil},
 		{"Named params", "UPDATE test SET a = $a, b = $b WHERE a = $c", false, `[{"a":"a","b":"b","c":"baz1"},{"a":"foo2","b":"bar2"},{"d":"foo3","e":"bar3"}]`, []interface{}{sql.Named("b", "b"), sql.Named("a", "a"), sql.Named("c", "foo1")}},
 	}
 
@@ -61,4 +65,104 @@ func TestUpdateStmt(t *testing.T) {
 			require.JSONEq(t, test.expected, buf.String())
 		})
 	}
+
+	t.Run("Nested paths", func(t *testing.T) {
+		tests := []struct {
+			name     string
+			query    string
+			expected string
+		}{
+			{"Set nested field", "UPDATE test SET a.b = 10", `[{"a": {"b": 10, "c": [1, 2]}, "d": [{"e": 1}, {"e": 2}]}]`},
+			{"Set array index", "UPDATE test SET a.c.1 = 10", `[{"a": {"b": 1, "c": [1, 10]}, "d": [{"e": 1}, {"e": 2}]}]`},
+			{"Set document in array", "UPDATE test SET d.0.e = d.1.e, d.1.f = 3", `[{"a": {"b": 1, "c": [1, 2]}, "d": [{"e": 2}, {"e": 2, "f": 3}]}]`},
+			{"Set index out of range", "UPDATE test SET a.c.5 = 10", `[{"a": {"b": 1, "c": [1, 2]}, "d": [{"e": 1}, {"e": 2}]}]`},
+			{"Set field of a scalar", "UPDATE test SET a.b.c = 10", `[{"a": {"b": 1, "c": [1, 2]}, "d": [{"e": 1}, {"e": 2}]}]`},
+			{"Unset nested field", "UPDATE test UNSET a.b, d.1.e", `[{"a": {"c": [1, 2]}, "d": [{"e": 1}, {}]}]`},
+			{"Unset missing field", "UPDATE test UNSET a.z, z", `[{"a": {"b": 1, "c": [1, 2]}, "d": [{"e": 1}, {"e": 2}]}]`},
+		}
+
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				db, err := genji.Open(":memory:")
+				require.NoError(t, err)
+				defer db.Close()
+
+				err = db.Exec(`
+					CREATE TABLE test;
+					INSERT INTO test VALUES {a: {b: 1, c: [1, 2]}, d: [{e: 1}, {e: 2}]};
+				`)
+				require.NoError(t, err)
+
+				err = db.Exec(test.query)
+				require.NoError(t, err)
+
+				st, err := db.Query("SELECT * FROM test")
+				require.NoError(t, err)
+				defer st.Close()
+
+				var buf bytes.Buffer
+				err = document.IteratorToJSONArray(&buf, st)
+				require.NoError(t, err)
+				require.JSONEq(t, test.expected, buf.String())
+			})
+		}
+	})
+
+	t.Run("Unset array index", func(t *testing.T) {
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		err = db.Exec(`
+			CREATE TABLE test;
+			INSERT INTO test (a) VALUES ([1, 2]);
+		`)
+		require.NoError(t, err)
+
+		err = db.Exec("UPDATE test UNSET a.0")
+		require.Error(t, err)
+	})
+}
+
+func TestUpdateStmtRowsAffected(t *testing.T) {
+	db, err := genji.Open(":memory:")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.Exec("CREATE TABLE test")
+	require.NoError(t, err)
+
+	// insert more documents than the size of the update buffer
+	for i := 0; i < 250; i++ {
+		err = db.Exec("INSERT INTO test (a, b) VALUES (?, 0)", i)
+		require.NoError(t, err)
+	}
+
+	tests := []struct {
+		query    string
+		affected int64
+	}{
+		{"UPDATE test SET b = b + 1", 250},
+		{"UPDATE test SET b = b + 1 WHERE a >= 100", 150},
+		{"UPDATE test SET b = b + 1 WHERE a < 0", 0},
+	}
+
+	for _, test := range tests {
+		res, err := db.Query(test.query)
+		require.NoError(t, err)
+		n, err := res.RowsAffected()
+		require.NoError(t, err)
+		require.Equal(t, test.affected, n)
+		require.NoError(t, res.Close())
+	}
+
+	// every document must have been updated once per matching statement
+	st, err := db.Query("SELECT COUNT(*) AS n FROM test WHERE (a < 100 AND b = 1) OR (a >= 100 AND b = 2)")
+	require.NoError(t, err)
+	defer st.Close()
+
+	var buf bytes.Buffer
+	err = document.IteratorToJSON(&buf, st)
+	require.NoError(t, err)
+	require.JSONEq(t, `{"n": 250}`, buf.String())
 }
@@ -0,0 +1,664 @@
+package query
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+
+	"github.com/asdine/genji/document"
+	"github.com/asdine/genji/document/encoding"
+)
+
+// A WindowFunc is a function evaluated over a window of documents, using the OVER clause:
+// the documents are split into partitions, sorted within each partition, and the function
+// is evaluated for each document using the documents of its partition.
+// The function is either a ranking function, like ROW_NUMBER(), an offset function,
+// like LAG(), or an aggregate function, which computes a running aggregate.
+type WindowFunc struct {
+	Func   Expr
+	Window Window
+}
+
+// A Window describes how the documents are partitioned and sorted before a window function
+// is evaluated.
+type Window struct {
+	PartitionBy []Expr
+	OrderBy     []OrderingTerm
+}
+
+// NewWindowFunc returns a window function evaluating fn over the given window.
+// It returns an error if fn can't be used with an OVER clause.
+func NewWindowFunc(fn Expr, w Window) (*WindowFunc, error) {
+	switch fn.(type) {
+	case windowFunction, AggregatorBuilder:
+	default:
+		return nil, errors.New("OVER clause can only be used with window and aggregate functions")
+	}
+
+	return &WindowFunc{Func: fn, Window: w}, nil
+}
+
+// Eval returns the value computed for the current document.
+func (w *WindowFunc) Eval(stack EvalStack) (document.Value, error) {
+	d, ok := stack.Document.(*windowDocument)
+	if !ok {
+		return nilLitteral, errors.New("misuse of window function")
+	}
+
+	for i, fn := range d.funcs {
+		if fn == w {
+			return d.values[i], nil
+		}
+	}
+
+	return nilLitteral, errors.New("misuse of window function")
+}
+
+// windowFunction is implemented by the functions that can only be used with an OVER clause.
+type windowFunction interface {
+	Expr
+
+	// calculator returns a new calculator, setting the value at index i of the rows
+	// of a partition.
+	calculator(i int) windowCalculator
+}
+
+// A windowCalculator computes the values of a window function for the rows of a partition.
+// Rows are added in order and the calculator sets their value once it is known, which might
+// require reading the next rows.
+type windowCalculator interface {
+	// add is called for every row of the partition. peer reports whether the row has
+	// the same ordering key as the previous one. The row is available in the stack.
+	add(stack EvalStack, r *windowRow, peer bool) error
+	// end is called after the last row of the partition has been added.
+	// All the rows must have a value once it returns.
+	end(stack EvalStack) error
+}
+
+// RowNumberFunc is the ROW_NUMBER window function.
+// It returns the position of the document within its partition, starting at 1.
+type RowNumberFunc struct{}
+
+// Eval returns an error: ROW_NUMBER must be used with an OVER clause.
+func (RowNumberFunc) Eval(EvalStack) (document.Value, error) {
+	return nilLitteral, errors.New("row_number() must be used with an OVER clause")
+}
+
+func (RowNumberFunc) calculator(i int) windowCalculator {
+	return &rankCalculator{i: i, rowNumber: true}
+}
+
+// RankFunc is the RANK window function.
+// It returns the rank of the document within its partition: documents with the same ordering
+// key have the same rank, and leave a gap in the sequence of ranks.
+type RankFunc struct{}
+
+// Eval returns an error: RANK must be used with an OVER clause.
+func (RankFunc) Eval(EvalStack) (document.Value, error) {
+	return nilLitteral, errors.New("rank() must be used with an OVER clause")
+}
+
+func (RankFunc) calculator(i int) windowCalculator {
+	return &rankCalculator{i: i}
+}
+
+// DenseRankFunc is the DENSE_RANK window function.
+// It is like RANK, without gaps in the sequence of ranks.
+type DenseRankFunc struct{}
+
+// Eval returns an error: DENSE_RANK must be used with an OVER clause.
+func (DenseRankFunc) Eval(EvalStack) (document.Value, error) {
+	return nilLitteral, errors.New("dense_rank() must be used with an OVER clause")
+}
+
+func (DenseRankFunc) calculator(i int) windowCalculator {
+	return &rankCalculator{i: i, dense: true}
+}
+
+type rankCalculator struct {
+	i         int
+	rowNumber bool
+	dense     bool
+
+	rows int64
+	rank int64
+}
+
+func (c *rankCalculator) add(stack EvalStack, r *windowRow, peer bool) error {
+	c.rows++
+	switch {
+	case c.rowNumber:
+		c.rank = c.rows
+	case c.dense && !peer:
+		c.rank++
+	case !peer:
+		c.rank = c.rows
+	}
+
+	r.set(c.i, document.NewInt64Value(c.rank))
+	return nil
+}
+
+func (c *rankCalculator) end(stack EvalStack) error {
+	return nil
+}
+
+// LagFunc is the LAG window function.
+// It returns the value of Expr for the document located Offset documents before the current
+// one in its partition, or the value of Default if there is no such document.
+// Offset defaults to 1 and Default to NULL.
+type LagFunc struct {
+	Expr    Expr
+	Offset  Expr
+	Default Expr
+}
+
+// Eval returns an error: LAG must be used with an OVER clause.
+func (l *LagFunc) Eval(EvalStack) (document.Value, error) {
+	return nilLitteral, errors.New("lag() must be used with an OVER clause")
+}
+
+func (l *LagFunc) calculator(i int) windowCalculator {
+	return &offsetCalculator{i: i, expr: l.Expr, offset: l.Offset, def: l.Default}
+}
+
+// LeadFunc is the LEAD window function.
+// It returns the value of Expr for the document located Offset documents after the current
+// one in its partition, or the value of Default if there is no such document.
+// Offset defaults to 1 and Default to NULL.
+type LeadFunc struct {
+	Expr    Expr
+	Offset  Expr
+	Default Expr
+}
+
+// Eval returns an error: LEAD must be used with an OVER clause.
+func (l *LeadFunc) Eval(EvalStack) (document.Value, error) {
+	return nilLitteral, errors.New("lead() must be used with an OVER clause")
+}
+
+func (l *LeadFunc) calculator(i int) windowCalculator {
+	return &offsetCalculator{i: i, expr: l.Expr, offset: l.Offset, def: l.Default, lead: true}
+}
+
+func offsetFunction(name string, build func(expr, offset, def Expr) Expr) func(args ...Expr) (Expr, error) {
+	return func(args ...Expr) (Expr, error) {
+		if len(args) < 1 || len(args) > 3 {
+			return nil, fmt.Errorf("%s() takes between 1 and 3 arguments", name)
+		}
+
+		args = append(args, nil, nil)
+		return build(args[0], args[1], args[2]), nil
+	}
+}
+
+type offsetCalculator struct {
+	i      int
+	expr   Expr
+	offset Expr
+	def    Expr
+	lead   bool
+
+	// n is the offset, evaluated when the first row of the partition is added.
+	n     int
+	ready bool
+	// lag: values of the last n rows.
+	values []document.Value
+	// lead: rows waiting for the value of the row located n rows after them.
+	rows []*windowRow
+}
+
+func (c *offsetCalculator) init(stack EvalStack) error {
+	c.ready = true
+	c.n = 1
+	if c.offset == nil {
+		return nil
+	}
+
+	v, err := c.offset.Eval(stack)
+	if err != nil {
+		return err
+	}
+
+	if !v.Type.IsNumber() {
+		return fmt.Errorf("offset must evaluate to a number, got %q", v.Type)
+	}
+
+	n, err := v.ConvertToInt64()
+	if err != nil {
+		return err
+	}
+	if n < 0 {
+		return errors.New("offset must not be negative")
+	}
+
+	c.n = int(n)
+	return nil
+}
+
+func (c *offsetCalculator) add(stack EvalStack, r *windowRow, peer bool) error {
+	if !c.ready {
+		err := c.init(stack)
+		if err != nil {
+			return err
+		}
+	}
+
+	v, err := c.expr.Eval(stack)
+	if err == document.ErrFieldNotFound {
+		v, err = nilLitteral, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if c.lead {
+		c.rows = append(c.rows, r)
+		if len(c.rows) > c.n {
+			c.rows[0].set(c.i, v)
+			c.rows = c.rows[1:]
+		}
+
+		return nil
+	}
+
+	c.values = append(c.values, v)
+	if len(c.values) > c.n {
+		r.set(c.i, c.values[0])
+		c.values = c.values[1:]
+		return nil
+	}
+
+	return c.setDefault(stack, r)
+}
+
+// setDefault evaluates the default value within the context of r.
+func (c *offsetCalculator) setDefault(stack EvalStack, r *windowRow) error {
+	if c.def == nil {
+		r.set(c.i, nilLitteral)
+		return nil
+	}
+
+	stack.Document = r.document()
+	v, err := c.def.Eval(stack)
+	if err == document.ErrFieldNotFound {
+		v, err = nilLitteral, nil
+	}
+	if err != nil {
+		return err
+	}
+
+	r.set(c.i, v)
+	return nil
+}
+
+func (c *offsetCalculator) end(stack EvalStack) error {
+	for _, r := range c.rows {
+		err := c.setDefault(stack, r)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// aggregateCalculator computes a running aggregate: the value of a row is the result of the
+// aggregation of the rows preceding it in the partition and of its peers.
+type aggregateCalculator struct {
+	i   int
+	agg Aggregator
+	// rows of the current peer group.
+	peers []*windowRow
+}
+
+func (c *aggregateCalculator) add(stack EvalStack, r *windowRow, peer bool) error {
+	if !peer {
+		err := c.end(stack)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.peers = append(c.peers, r)
+	return c.agg.Aggregate(stack)
+}
+
+func (c *aggregateCalculator) end(stack EvalStack) error {
+	if len(c.peers) == 0 {
+		return nil
+	}
+
+	v, err := c.agg.Eval(stack)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range c.peers {
+		r.set(c.i, v)
+	}
+	c.peers = c.peers[:0]
+
+	return nil
+}
+
+// windowDocument is a document returned by a windowIterator. It holds the values
+// computed by the window functions for this document.
+type windowDocument struct {
+	document.Document
+
+	key    []byte
+	funcs  []*WindowFunc
+	values []document.Value
+}
+
+// Key returns the key of the document the window functions were evaluated for, if any.
+func (d *windowDocument) Key() []byte {
+	return d.key
+}
+
+// windowRow is a row of the partition being processed by a windowIterator.
+type windowRow struct {
+	d document.Document
+	// key of the document, if any.
+	key    []byte
+	funcs  []*WindowFunc
+	values []document.Value
+	// number of values which are not computed yet.
+	missing int
+}
+
+func (r *windowRow) set(i int, v document.Value) {
+	r.values[i] = v
+	r.missing--
+}
+
+func (r *windowRow) document() *windowDocument {
+	return &windowDocument{Document: r.d, key: r.key, funcs: r.funcs, values: r.values}
+}
+
+// collectWindowFuncs returns the window functions used by the result fields and the ordering terms.
+func collectWindowFuncs(fields []ResultField, terms []OrderingTerm) []*WindowFunc {
+	var funcs []*WindowFunc
+	collect := func(e Expr) {
+		walkExpr(e, func(e Expr) {
+			w, ok := e.(*WindowFunc)
+			if !ok {
+				return
+			}
+
+			for _, f := range funcs {
+				if f == w {
+					return
+				}
+			}
+			funcs = append(funcs, w)
+		})
+	}
+
+	for _, rf := range fields {
+		if rfe, ok := rf.(ResultFieldExpr); ok {
+			collect(rfe.Expr)
+		}
+	}
+	for _, t := range terms {
+		collect(t.Expr)
+	}
+
+	return funcs
+}
+
+// newWindowStream evaluates the given window functions for the documents of st.
+// The functions sharing the same window are evaluated by the same windowIterator.
+func newWindowStream(st document.Stream, funcs []*WindowFunc, stack EvalStack, cfg sortConfig) document.Stream {
+	var evaluated []*WindowFunc
+	for len(funcs) > 0 {
+		var same, others []*WindowFunc
+		for _, w := range funcs {
+			if reflect.DeepEqual(w.Window, funcs[0].Window) {
+				same = append(same, w)
+			} else {
+				others = append(others, w)
+			}
+		}
+
+		st = document.NewStream(&windowIterator{
+			it:        st,
+			window:    funcs[0].Window,
+			evaluated: evaluated,
+			funcs:     same,
+			stack:     stack,
+			cfg:       cfg,
+		})
+
+		evaluated = append(evaluated[:len(evaluated):len(evaluated)], same...)
+		funcs = others
+	}
+
+	return st
+}
+
+// windowIterator evaluates window functions sharing the same window.
+// Documents are sorted by partition and by ordering key using a sorter, which writes
+// them to temporary files if necessary. They are then read partition by partition,
+// and returned as soon as all the functions have computed their value, which
+// for some functions requires reading the next documents of the partition, or
+// the whole partition.
+// The values computed by the previous windowIterators are stored alongside the documents
+// while they are sorted.
+type windowIterator struct {
+	it     document.Iterator
+	window Window
+	// functions evaluated by the previous iterators.
+	evaluated []*WindowFunc
+	funcs     []*WindowFunc
+	stack     EvalStack
+	cfg       sortConfig
+}
+
+func (it *windowIterator) Iterate(fn func(d document.Document) error) (err error) {
+	srt := newSorter(it.cfg, 0)
+	defer func() {
+		if cerr := srt.close(); err == nil {
+			err = cerr
+		}
+	}()
+
+	stack := it.stack
+	err = it.it.Iterate(func(d document.Document) error {
+		stack.Document = d
+
+		var partition []byte
+		for _, e := range it.window.PartitionBy {
+			v, err := e.Eval(stack)
+			if err == document.ErrFieldNotFound {
+				v, err = document.NewNullValue(), nil
+			}
+			if err != nil {
+				return err
+			}
+
+			partition, err = appendOrderingKey(partition, v, OrderingTerm{})
+			if err != nil {
+				return err
+			}
+		}
+
+		value := partition
+		for _, t := range it.window.OrderBy {
+			v, err := t.Expr.Eval(stack)
+			if err == document.ErrFieldNotFound {
+				v, err = document.NewNullValue(), nil
+			}
+			if err != nil {
+				return err
+			}
+
+			value, err = appendOrderingKey(value, v, t)
+			if err != nil {
+				return err
+			}
+		}
+
+		e := sortEntry{value: value}
+		if k, ok := d.(document.Keyer); ok {
+			e.key = append([]byte(nil), k.Key()...)
+		}
+
+		e.data, err = encodeWindowEntry(d, partition)
+		if err != nil {
+			return err
+		}
+
+		return srt.add(e)
+	})
+	if err != nil {
+		return err
+	}
+
+	funcs := append(it.evaluated[:len(it.evaluated):len(it.evaluated)], it.funcs...)
+
+	var calculators []windowCalculator
+	var rows []*windowRow
+	var partition, prev []byte
+
+	// flush returns the rows whose values are all computed, in order.
+	flush := func() error {
+		for len(rows) > 0 && rows[0].missing == 0 {
+			err := fn(rows[0].document())
+			if err != nil {
+				return err
+			}
+			rows = rows[1:]
+		}
+
+		return nil
+	}
+
+	end := func() error {
+		for _, c := range calculators {
+			err := c.end(stack)
+			if err != nil {
+				return err
+			}
+		}
+
+		return flush()
+	}
+
+	err = srt.iterate(func(e *sortEntry) error {
+		r, p, err := decodeWindowEntry(e, funcs, len(it.funcs))
+		if err != nil {
+			return err
+		}
+
+		peer := calculators != nil && bytes.Equal(p, partition) && bytes.Equal(e.value, prev)
+		if calculators == nil || !bytes.Equal(p, partition) {
+			if calculators != nil {
+				err = end()
+				if err != nil {
+					return err
+				}
+			}
+
+			calculators = it.calculators()
+			partition = p
+		}
+		prev = e.value
+
+		rows = append(rows, r)
+		stack.Document = r.document()
+		for _, c := range calculators {
+			err = c.add(stack, r, peer)
+			if err != nil {
+				return err
+			}
+		}
+
+		return flush()
+	})
+	if err != nil || calculators == nil {
+		return err
+	}
+
+	return end()
+}
+
+// calculators returns new calculators for the functions of the iterator.
+func (it *windowIterator) calculators() []windowCalculator {
+	calculators := make([]windowCalculator, len(it.funcs))
+	for i, w := range it.funcs {
+		idx := len(it.evaluated) + i
+
+		switch t := w.Func.(type) {
+		case windowFunction:
+			calculators[i] = t.calculator(idx)
+		case AggregatorBuilder:
+			calculators[i] = &aggregateCalculator{i: idx, agg: t.Aggregator()}
+		}
+	}
+
+	return calculators
+}
+
+// encodeWindowEntry encodes a document being sorted by a windowIterator, along with its partition
+// key and the values computed by the previous windowIterators.
+func encodeWindowEntry(d document.Document, partition []byte) ([]byte, error) {
+	var fb document.FieldBuffer
+	fb.Add("p", document.NewBlobValue(partition))
+
+	if wd, ok := d.(*windowDocument); ok {
+		d = wd.Document
+		fb.Add("v", document.NewArrayValue(document.NewValueBuffer(wd.values...)))
+	}
+
+	fb.Add("d", document.NewDocumentValue(d))
+
+	data, err := encoding.EncodeDocument(&fb)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(nil), data...), nil
+}
+
+// decodeWindowEntry decodes an entry encoded by encodeWindowEntry and returns the row and
+// its partition key. The row expects the values of the n last functions.
+func decodeWindowEntry(e *sortEntry, funcs []*WindowFunc, n int) (*windowRow, []byte, error) {
+	d := encoding.EncodedDocument(e.data)
+
+	p, err := d.GetByField("p")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := d.GetByField("d")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	r := windowRow{
+		d:       doc.V.(document.Document),
+		key:     e.key,
+		funcs:   funcs,
+		values:  make([]document.Value, len(funcs)),
+		missing: n,
+	}
+
+	if len(funcs) > n {
+		v, err := d.GetByField("v")
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for i := 0; i < len(funcs)-n; i++ {
+			r.values[i], err = v.V.(document.Array).GetByIndex(i)
+			if err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return &r, p.V.([]byte), nil
+}
@@ -0,0 +1,154 @@
+package query_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/asdine/genji"
+	"github.com/asdine/genji/database"
+	"github.com/asdine/genji/document"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSelectStmtWindow(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		fails    bool
+		expected string
+	}{
+		{"Row number", "SELECT id, ROW_NUMBER() OVER (PARTITION BY dept ORDER BY salary DESC) AS n FROM test ORDER BY id", false,
+			`[{"id":1,"n":3},{"id":2,"n":1},{"id":3,"n":2},{"id":4,"n":2},{"id":5,"n":1},{"id":6,"n":1}]`},
+		{"Rank", "SELECT id, RANK() OVER (ORDER BY salary DESC) AS r, DENSE_RANK() OVER (ORDER BY salary DESC) AS d FROM test ORDER BY r, id", false,
+			`[{"id":2,"r":1,"d":1},{"id":3,"r":1,"d":1},{"id":5,"r":3,"d":2},{"id":1,"r":4,"d":3},{"id":6,"r":5,"d":4},{"id":4,"r":6,"d":5}]`},
+		{"Lag and lead", "SELECT id, LAG(salary) OVER (ORDER BY id) AS prev, LEAD(salary, 2, -1) OVER (ORDER BY id) AS next FROM test", false,
+			`[{"id":1,"prev":null,"next":20},{"id":2,"prev":10,"next":5},{"id":3,"prev":20,"next":15},{"id":4,"prev":20,"next":7},{"id":5,"prev":5,"next":-1},{"id":6,"prev":15,"next":-1}]`},
+		{"Lag with partition", "SELECT id, LAG(id, 1, id) OVER (PARTITION BY dept ORDER BY id) AS prev FROM test ORDER BY id", false,
+			`[{"id":1,"prev":1},{"id":2,"prev":1},{"id":3,"prev":2},{"id":4,"prev":4},{"id":5,"prev":4},{"id":6,"prev":6}]`},
+		{"Running aggregates", "SELECT id, SUM(salary) OVER (PARTITION BY dept ORDER BY salary) AS s, COUNT(*) OVER (PARTITION BY dept) AS c FROM test ORDER BY id", false,
+			`[{"id":1,"s":10,"c":3},{"id":2,"s":50,"c":3},{"id":3,"s":50,"c":3},{"id":4,"s":5,"c":2},{"id":5,"s":20,"c":2},{"id":6,"s":7,"c":1}]`},
+		{"Running average", "SELECT id, AVG(salary) OVER (ORDER BY id) AS a FROM test WHERE dept = 'b'", false,
+			`[{"id":4,"a":5},{"id":5,"a":10}]`},
+		{"Order by window function", "SELECT id FROM test ORDER BY ROW_NUMBER() OVER (ORDER BY salary DESC) LIMIT 2 OFFSET 1", false,
+			`[{"id":3},{"id":5}]`},
+		{"Wildcard", "SELECT *, ROW_NUMBER() OVER () AS n FROM test WHERE dept = 'c'", false,
+			`[{"id":6,"dept":"c","salary":7,"n":1}]`},
+		{"Primary key", "SELECT pk(), ROW_NUMBER() OVER (ORDER BY pk() DESC) AS n FROM test LIMIT 1", false,
+			`[{"pk()":6,"n":1}]`},
+		{"Distinct", "SELECT DISTINCT dept, COUNT(*) OVER (PARTITION BY dept) AS c FROM test", false,
+			`[{"dept":"a","c":3},{"dept":"b","c":2},{"dept":"c","c":1}]`},
+		{"Without OVER", "SELECT ROW_NUMBER() FROM test", true, ``},
+		{"In WHERE", "SELECT id FROM test WHERE ROW_NUMBER() OVER () > 1", true, ``},
+		{"With GROUP BY", "SELECT dept, RANK() OVER () FROM test GROUP BY dept", true, ``},
+		{"With aggregate", "SELECT COUNT(*), RANK() OVER () FROM test", true, ``},
+		{"Negative offset", "SELECT LAG(id, -1) OVER () FROM test", true, ``},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db, err := genji.Open(":memory:")
+			require.NoError(t, err)
+			defer db.Close()
+
+			err = db.Exec(`
+				CREATE TABLE test (id INTEGER PRIMARY KEY);
+				INSERT INTO test (id, dept, salary) VALUES
+					(1, 'a', 10), (2, 'a', 20), (3, 'a', 20),
+					(4, 'b', 5), (5, 'b', 15), (6, 'c', 7);
+			`)
+			require.NoError(t, err)
+
+			st, err := db.Query(test.query)
+			if test.fails {
+				if err == nil {
+					err = document.IteratorToJSONArray(ioutil.Discard, st)
+					st.Close()
+				}
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			defer st.Close()
+
+			var buf bytes.Buffer
+			err = document.IteratorToJSONArray(&buf, st)
+			require.NoError(t, err)
+			require.JSONEq(t, test.expected, buf.String())
+		})
+	}
+
+	t.Run("Spill", func(t *testing.T) {
+		dir, err := ioutil.TempDir("", "genji")
+		require.NoError(t, err)
+		defer os.RemoveAll(dir)
+
+		db, err := genji.Open(":memory:")
+		require.NoError(t, err)
+		defer db.Close()
+
+		db.DB.Sort = database.SortOptions{MemoryLimit: 256, TempDir: dir}
+
+		var values []string
+		for i := 0; i < 200; i++ {
+			values = append(values, fmt.Sprintf("(%d, %d)", i, i%3))
+		}
+		err = db.Exec("CREATE TABLE test (k INTEGER PRIMARY KEY); INSERT INTO test (k, a) VALUES " + strings.Join(values, ", "))
+		require.NoError(t, err)
+
+		st, err := db.Query(`
+			SELECT k, ROW_NUMBER() OVER (PARTITION BY a ORDER BY k DESC) AS n, LAG(k) OVER (ORDER BY k) AS prev
+			FROM test ORDER BY k`)
+		require.NoError(t, err)
+		defer st.Close()
+
+		var i int64
+		err = st.Iterate(func(d document.Document) error {
+			v, err := d.GetByField("k")
+			if err != nil {
+				return err
+			}
+			k, err := v.ConvertToInt64()
+			if err != nil {
+				return err
+			}
+			require.Equal(t, i, k)
+
+			v, err = d.GetByField("n")
+			if err != nil {
+				return err
+			}
+			n, err := v.ConvertToInt64()
+			if err != nil {
+				return err
+			}
+			require.Equal(t, (199-k)/3+1, n)
+
+			v, err = d.GetByField("prev")
+			if err != nil {
+				return err
+			}
+			if k == 0 {
+				require.Equal(t, document.NullValue, v.Type)
+			} else {
+				prev, err := v.ConvertToInt64()
+				if err != nil {
+					return err
+				}
+				require.Equal(t, k-1, prev)
+			}
+
+			i++
+			return nil
+		})
+		require.NoError(t, err)
+		require.EqualValues(t, 200, i)
+
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(t, err)
+		require.Empty(t, files)
+	})
+}
@@ -39,6 +39,13 @@ func (s *Scanner) ReadRune() (ch rune, size int, err error) {
 	return
 }
 
+// UnreadRune pushes the previously read rune back onto the buffer.
+// Along with ReadRune, it implements the io.RuneScanner interface.
+func (s *Scanner) UnreadRune() error {
+	s.unread()
+	return nil
+}
+
 func (s *Scanner) unread() {
 	if ch, _ := s.r.curr(); ch != eof {
 		s.buf.Truncate(s.buf.Len() - utf8.RuneLen(ch))
@@ -298,8 +305,17 @@ func (s *Scanner) scanString() TokenInfo {
 	return TokenInfo{STRING, pos, lit, s.unbuffer()}
 }
 
-// ScanRegex consumes a token to find escapes
+// ScanRegex consumes a token to find escapes.
+// Leading whitespace is skipped.
 func (s *Scanner) ScanRegex() TokenInfo {
+	for {
+		ch, _ := s.read()
+		if !isWhitespace(ch) {
+			s.unread()
+			break
+		}
+	}
+
 	_, pos := s.r.curr()
 
 	// Start & end sentinels.
@@ -307,7 +323,7 @@ func (s *Scanner) ScanRegex() TokenInfo {
 	// Valid escape chars.
 	escapes := map[rune]rune{'/': '/'}
 
-	b, err := ScanDelimited(s.r, start, end, escapes, true)
+	b, err := ScanDelimited(s, start, end, escapes, true)
 
 	if err == errBadEscape {
 		_, pos = s.r.curr()
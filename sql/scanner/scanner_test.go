@@ -42,6 +42,10 @@ func TestScanner_Scan(t *testing.T) {
 		{s: `and`, tok: scanner.AND, raw: `and`},
 		{s: `OR`, tok: scanner.OR, raw: `OR`},
 		{s: `or`, tok: scanner.OR, raw: `or`},
+		{s: `IN`, tok: scanner.IN, raw: `IN`},
+		{s: `is`, tok: scanner.IS, raw: `is`},
+		{s: `LIKE`, tok: scanner.LIKE, raw: `LIKE`},
+		{s: `between`, tok: scanner.BETWEEN, raw: `between`},
 
 		{s: `=`, tok: scanner.EQ, raw: `=`},
 		{s: `==`, tok: scanner.EQ, raw: `==`},
@@ -126,20 +130,50 @@ func TestScanner_Scan(t *testing.T) {
 		// Keywords
 		{s: `AS`, tok: scanner.AS, raw: `AS`},
 		{s: `ASC`, tok: scanner.ASC, raw: `ASC`},
+		{s: `BEGIN`, tok: scanner.BEGIN, raw: `BEGIN`},
 		{s: `BY`, tok: scanner.BY, raw: `BY`},
+		{s: `ADD`, tok: scanner.ADDKW, raw: `ADD`},
+		{s: `ALL`, tok: scanner.ALL, raw: `ALL`},
+		{s: `ALTER`, tok: scanner.ALTER, raw: `ALTER`},
 		{s: `CAST`, tok: scanner.CAST, raw: `CAST`},
+		{s: `COMMIT`, tok: scanner.COMMIT, raw: `COMMIT`},
+		{s: `CONFLICT`, tok: scanner.CONFLICT, raw: `CONFLICT`},
 		{s: `CREATE`, tok: scanner.CREATE, raw: `CREATE`},
 		{s: `DELETE`, tok: scanner.DELETE, raw: `DELETE`},
 		{s: `DESC`, tok: scanner.DESC, raw: `DESC`},
+		{s: `DISTINCT`, tok: scanner.DISTINCT, raw: `DISTINCT`},
+		{s: `DO`, tok: scanner.DO, raw: `DO`},
 		{s: `DROP`, tok: scanner.DROP, raw: `DROP`},
+		{s: `EXCEPT`, tok: scanner.EXCEPT, raw: `EXCEPT`},
+		{s: `EXPLAIN`, tok: scanner.EXPLAIN, raw: `EXPLAIN`},
+		{s: `FIELD`, tok: scanner.FIELD, raw: `FIELD`},
+		{s: `FIRST`, tok: scanner.FIRST, raw: `FIRST`},
 		{s: `FROM`, tok: scanner.FROM, raw: `FROM`},
+		{s: `GROUP`, tok: scanner.GROUP, raw: `GROUP`},
+		{s: `INNER`, tok: scanner.INNER, raw: `INNER`},
 		{s: `INSERT`, tok: scanner.INSERT, raw: `INSERT`},
+		{s: `INTERSECT`, tok: scanner.INTERSECT, raw: `INTERSECT`},
 		{s: `INTO`, tok: scanner.INTO, raw: `INTO`},
+		{s: `JOIN`, tok: scanner.JOIN, raw: `JOIN`},
+		{s: `LAST`, tok: scanner.LAST, raw: `LAST`},
+		{s: `LEFT`, tok: scanner.LEFT, raw: `LEFT`},
 		{s: `LIMIT`, tok: scanner.LIMIT, raw: `LIMIT`},
+		{s: `NOTHING`, tok: scanner.NOTHING, raw: `NOTHING`},
+		{s: `NULLS`, tok: scanner.NULLS, raw: `NULLS`},
 		{s: `OFFSET`, tok: scanner.OFFSET, raw: `OFFSET`},
+		{s: `ONLY`, tok: scanner.ONLY, raw: `ONLY`},
 		{s: `ORDER`, tok: scanner.ORDER, raw: `ORDER`},
+		{s: `OUTER`, tok: scanner.OUTER, raw: `OUTER`},
+		{s: `OVER`, tok: scanner.OVER, raw: `OVER`},
+		{s: `PARTITION`, tok: scanner.PARTITION, raw: `PARTITION`},
+		{s: `READ`, tok: scanner.READ, raw: `READ`},
+		{s: `RENAME`, tok: scanner.RENAME, raw: `RENAME`},
+		{s: `RETURNING`, tok: scanner.RETURNING, raw: `RETURNING`},
+		{s: `ROLLBACK`, tok: scanner.ROLLBACK, raw: `ROLLBACK`},
 		{s: `SELECT`, tok: scanner.SELECT, raw: `SELECT`},
 		{s: `TO`, tok: scanner.TO, raw: `TO`},
+		{s: `UNION`, tok: scanner.UNION, raw: `UNION`},
+		{s: `UNSET`, tok: scanner.UNSET, raw: `UNSET`},
 		{s: `VALUES`, tok: scanner.VALUES, raw: `VALUES`},
 		{s: `WHERE`, tok: scanner.WHERE, raw: `WHERE`},
 		{s: `seLECT`, tok: scanner.SELECT, raw: `seLECT`}, // case insensitive
@@ -273,6 +307,8 @@ func TestScanRegex(t *testing.T) {
 		{in: `/foo\\/bar/`, tok: scanner.REGEX, lit: `foo\/bar`},
 		{in: `/foo\\bar/`, tok: scanner.REGEX, lit: `foo\\bar`},
 		{in: `/http\:\/\/www\.example\.com/`, tok: scanner.REGEX, lit: `http\://www\.example\.com`},
+		{in: `  /^foo/`, tok: scanner.REGEX, lit: `^foo`},
+		{in: `/foo`, tok: scanner.BADREGEX},
 	}
 
 	for i, tt := range tests {
@@ -47,6 +47,11 @@ const (
 	AND // AND
 	OR  // OR
 
+	IN      // IN
+	IS      // IS
+	LIKE    // LIKE
+	BETWEEN // BETWEEN
+
 	EQ       // =
 	NEQ      // !=
 	EQREGEX  // =~
@@ -71,32 +76,62 @@ const (
 
 	keywordBeg
 	// ALL and the following are Genji SQL Keywords
+	ADDKW // ADD keyword, not to be confused with the ADD operator
+	ALL
+	ALTER
 	AS
 	ASC
+	BEGIN
 	BY
 	CAST
+	COMMIT
+	CONFLICT
 	CREATE
 	DELETE
 	DESC
+	DISTINCT
+	DO
 	DROP
+	EXCEPT
 	EXISTS
+	EXPLAIN
+	FIELD
+	FIRST
 	FROM
+	GROUP
 	IF
 	INDEX
+	INNER
 	INSERT
+	INTERSECT
 	INTO
+	JOIN
 	KEY
+	LAST
+	LEFT
 	LIMIT
 	NOT
+	NOTHING
+	NULLS
 	OFFSET
 	ON
+	ONLY
 	ORDER
+	OUTER
+	OVER
+	PARTITION
 	PRIMARY
+	READ
+	RENAME
+	RETURNING
+	ROLLBACK
 	SELECT
 	SET
 	TABLE
 	TO
+	UNION
 	UNIQUE
+	UNSET
 	UPDATE
 	VALUES
 	WHERE
@@ -146,6 +181,11 @@ var tokens = [...]string{
 	AND: "AND",
 	OR:  "OR",
 
+	IN:      "IN",
+	IS:      "IS",
+	LIKE:    "LIKE",
+	BETWEEN: "BETWEEN",
+
 	EQ:       "=",
 	NEQ:      "!=",
 	EQREGEX:  "=~",
@@ -167,35 +207,65 @@ var tokens = [...]string{
 	SEMICOLON:   ";",
 	DOT:         ".",
 
-	AS:      "AS",
-	ASC:     "ASC",
-	BY:      "BY",
-	CREATE:  "CREATE",
-	CAST:    "CAST",
-	DELETE:  "DELETE",
-	DESC:    "DESC",
-	DROP:    "DROP",
-	EXISTS:  "EXISTS",
-	KEY:     "KEY",
-	FROM:    "FROM",
-	IF:      "IF",
-	INDEX:   "INDEX",
-	INSERT:  "INSERT",
-	INTO:    "INTO",
-	LIMIT:   "LIMIT",
-	NOT:     "NOT",
-	OFFSET:  "OFFSET",
-	ON:      "ON",
-	ORDER:   "ORDER",
-	PRIMARY: "PRIMARY",
-	SELECT:  "SELECT",
-	SET:     "SET",
-	TABLE:   "TABLE",
-	TO:      "TO",
-	UNIQUE:  "UNIQUE",
-	UPDATE:  "UPDATE",
-	VALUES:  "VALUES",
-	WHERE:   "WHERE",
+	ADDKW:     "ADD",
+	ALL:       "ALL",
+	ALTER:     "ALTER",
+	AS:        "AS",
+	ASC:       "ASC",
+	BEGIN:     "BEGIN",
+	BY:        "BY",
+	CREATE:    "CREATE",
+	CAST:      "CAST",
+	COMMIT:    "COMMIT",
+	CONFLICT:  "CONFLICT",
+	DELETE:    "DELETE",
+	DESC:      "DESC",
+	DISTINCT:  "DISTINCT",
+	DO:        "DO",
+	DROP:      "DROP",
+	EXCEPT:    "EXCEPT",
+	EXISTS:    "EXISTS",
+	EXPLAIN:   "EXPLAIN",
+	KEY:       "KEY",
+	FIELD:     "FIELD",
+	FIRST:     "FIRST",
+	FROM:      "FROM",
+	GROUP:     "GROUP",
+	IF:        "IF",
+	INDEX:     "INDEX",
+	INNER:     "INNER",
+	INSERT:    "INSERT",
+	INTERSECT: "INTERSECT",
+	INTO:      "INTO",
+	JOIN:      "JOIN",
+	LAST:      "LAST",
+	LEFT:      "LEFT",
+	LIMIT:     "LIMIT",
+	NOT:       "NOT",
+	NOTHING:   "NOTHING",
+	NULLS:     "NULLS",
+	OFFSET:    "OFFSET",
+	ON:        "ON",
+	ONLY:      "ONLY",
+	ORDER:     "ORDER",
+	OUTER:     "OUTER",
+	OVER:      "OVER",
+	PARTITION: "PARTITION",
+	PRIMARY:   "PRIMARY",
+	READ:      "READ",
+	RENAME:    "RENAME",
+	RETURNING: "RETURNING",
+	ROLLBACK:  "ROLLBACK",
+	SELECT:    "SELECT",
+	SET:       "SET",
+	TABLE:     "TABLE",
+	TO:        "TO",
+	UNION:     "UNION",
+	UNIQUE:    "UNIQUE",
+	UNSET:     "UNSET",
+	UPDATE:    "UPDATE",
+	VALUES:    "VALUES",
+	WHERE:     "WHERE",
 
 	TYPEBYTES:    "BYTES",
 	TYPESTRING:   "STRING",
@@ -219,7 +289,7 @@ func init() {
 	for tok := keywordBeg + 1; tok < keywordEnd; tok++ {
 		keywords[strings.ToLower(tokens[tok])] = tok
 	}
-	for _, tok := range []Token{AND, OR, TRUE, FALSE, NULL} {
+	for _, tok := range []Token{AND, OR, IN, IS, LIKE, BETWEEN, TRUE, FALSE, NULL} {
 		keywords[strings.ToLower(tokens[tok])] = tok
 	}
 }
@@ -239,7 +309,7 @@ func (tok Token) Precedence() int {
 		return 1
 	case AND:
 		return 2
-	case EQ, NEQ, EQREGEX, NEQREGEX, LT, LTE, GT, GTE:
+	case EQ, NEQ, EQREGEX, NEQREGEX, LT, LTE, GT, GTE, IN, IS, LIKE, BETWEEN:
 		return 3
 	case ADD, SUB, BITWISEOR, BITWISEXOR:
 		return 4